@@ -1,36 +1,54 @@
 package main
 
 import (
+	"flag"
 	"gocomp/internal/parser"
 	"gocomp/internal/pipeline"
+	"gocomp/internal/typesystem"
 	"io"
 	"os"
 
 	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir"
 )
 
+// usage: compiler [-outparams] [dir | file.go...], source is read from stdin without arguments
 func main() {
-	var data []byte
-	if len(os.Args) > 1 {
-		var err error
-		data, err = os.ReadFile(os.Args[1])
-		if err != nil {
-			panic(err)
+	flag.BoolVar(&typesystem.OutParamResults, "outparams", false, "return multiple results through out parameters")
+	flag.Parse()
+	args := flag.Args()
+
+	var module *ir.Module
+	var err error
+	if len(args) > 0 {
+		if info, statErr := os.Stat(args[0]); statErr == nil && info.IsDir() {
+			// directory with main package of module
+			module, err = pipeline.ProcessDir(args[0])
+		} else {
+			// source files of main package
+			var files []parser.ISourceFileContext
+			for _, name := range args {
+				file, parseErr := pipeline.ParseFile(name)
+				if parseErr != nil {
+					err = parseErr
+					break
+				}
+				files = append(files, file)
+			}
+			if err == nil {
+				module, err = pipeline.ProcessFiles(files)
+			}
 		}
 	} else {
-		var err error
-		data, err = io.ReadAll(os.Stdin)
-		if err != nil {
-			panic(err)
+		data, readErr := io.ReadAll(os.Stdin)
+		if readErr != nil {
+			panic(readErr)
+		}
+		var file parser.ISourceFileContext
+		if file, err = pipeline.ParseStream(antlr.NewInputStream(string(data)), "<input>"); err == nil {
+			module, err = pipeline.ProcessTree(file)
 		}
 	}
-
-	lexer := parser.NewGoLexer(antlr.NewInputStream(string(data)))
-	tokenStream := antlr.NewCommonTokenStream(lexer, antlr.LexerDefaultTokenChannel)
-	parser := parser.NewGoParser(tokenStream)
-
-	sourceFileContext := parser.SourceFile()
-	module, err := pipeline.ProcessTree(sourceFileContext)
 	if err != nil {
 		os.Stderr.WriteString(err.Error() + "\n")
 		os.Exit(-1)
@@ -0,0 +1,263 @@
+/*
+ * chan.c
+ *
+ * Channel and select support routines.
+ */
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "runtime.h"
+
+struct go_chan_s
+{
+    int64_t elemsize;
+    go_int cap;
+    go_int len;
+    go_int sendx;
+    go_int recvx;
+    bool closed;
+    char *buf;                  // Ring buffer of 'cap' elements.
+    struct go_waitq_s recvq;
+    struct go_waitq_s sendq;
+};
+
+struct go_select_s
+{
+    bool done;
+    go_waiter_t winner;
+};
+
+static void chan_copy(go_chan_t c, void *dst, const void *src)
+{
+    if (dst != NULL)
+    {
+        if (src != NULL)
+            memcpy(dst, src, (size_t)c->elemsize);
+        else
+            memset(dst, 0, (size_t)c->elemsize);
+    }
+}
+
+static void *chan_slot(go_chan_t c, go_int idx)
+{
+    return c->buf + idx * c->elemsize;
+}
+
+extern go_chan_t runtime_makechan(int64_t elemsize, go_int cap)
+{
+    if (cap < 0)
+        runtime_panicerror("makechan: size out of range");
+    go_chan_t c = (go_chan_t)GC_malloc(sizeof(struct go_chan_s));
+    memset(c, 0, sizeof(struct go_chan_s));
+    c->elemsize = elemsize;
+    c->cap = cap;
+    size_t size = (size_t)elemsize * (size_t)cap;
+    if (size > 0)
+    {
+        c->buf = (char *)GC_malloc(size);
+        memset(c->buf, 0, size);
+    }
+    return c;
+}
+
+/*
+ * Hand element over to waiting receiver.
+ */
+static void chan_sendto(go_chan_t c, go_waiter_t w, const void *elem)
+{
+    chan_copy(c, w->elem, elem);
+    w->success = true;
+    runtime_ready(w->g);
+}
+
+/*
+ * Receive from waiting sender.  For buffered channel, element is taken from
+ * buffer, which is full, and sender's element takes its place.
+ */
+static void chan_recvfrom(go_chan_t c, go_waiter_t w, void *elem)
+{
+    if (c->cap == 0)
+        chan_copy(c, elem, w->elem);
+    else
+    {
+        void *slot = chan_slot(c, c->recvx);
+        chan_copy(c, elem, slot);
+        chan_copy(c, slot, w->elem);
+        c->recvx = (c->recvx + 1) % c->cap;
+        c->sendx = c->recvx;
+    }
+    w->success = true;
+    runtime_ready(w->g);
+}
+
+static bool chan_trysend(go_chan_t c, const void *elem)
+{
+    if (c->closed)
+        runtime_panicerror("send on closed channel");
+    go_waiter_t w = runtime_dequeue(&c->recvq);
+    if (w != NULL)
+    {
+        chan_sendto(c, w, elem);
+        return true;
+    }
+    if (c->len < c->cap)
+    {
+        chan_copy(c, chan_slot(c, c->sendx), elem);
+        c->sendx = (c->sendx + 1) % c->cap;
+        c->len++;
+        return true;
+    }
+    return false;
+}
+
+static bool chan_tryrecv(go_chan_t c, void *elem, bool *ok)
+{
+    go_waiter_t w = runtime_dequeue(&c->sendq);
+    if (w != NULL)
+    {
+        chan_recvfrom(c, w, elem);
+        *ok = true;
+        return true;
+    }
+    if (c->len > 0)
+    {
+        void *slot = chan_slot(c, c->recvx);
+        chan_copy(c, elem, slot);
+        chan_copy(c, slot, NULL);
+        c->recvx = (c->recvx + 1) % c->cap;
+        c->len--;
+        *ok = true;
+        return true;
+    }
+    if (c->closed)
+    {
+        chan_copy(c, elem, NULL);
+        *ok = false;
+        return true;
+    }
+    return false;
+}
+
+extern void runtime_chansend(go_chan_t c, const void *elem)
+{
+    if (c == NULL)
+        runtime_park("chan send (nil chan)");
+    if (chan_trysend(c, elem))
+        return;
+    struct go_waiter_s w = {.g = runtime_curg(), .elem = (void *)elem};
+    runtime_enqueue(&c->sendq, &w);
+    runtime_park("chan send");
+    if (!w.success)
+        runtime_panicerror("send on closed channel");
+}
+
+extern bool runtime_chanrecv(go_chan_t c, void *elem)
+{
+    if (c == NULL)
+        runtime_park("chan receive (nil chan)");
+    bool ok;
+    if (chan_tryrecv(c, elem, &ok))
+        return ok;
+    struct go_waiter_s w = {.g = runtime_curg(), .elem = elem};
+    runtime_enqueue(&c->recvq, &w);
+    runtime_park("chan receive");
+    return w.success;
+}
+
+extern void runtime_closechan(go_chan_t c)
+{
+    if (c == NULL)
+        runtime_panicerror("close of nil channel");
+    if (c->closed)
+        runtime_panicerror("close of closed channel");
+    c->closed = true;
+    go_waiter_t w;
+    while ((w = runtime_dequeue(&c->recvq)) != NULL)
+    {
+        chan_copy(c, w->elem, NULL);
+        w->success = false;
+        runtime_ready(w->g);
+    }
+    while ((w = runtime_dequeue(&c->sendq)) != NULL)
+    {
+        w->success = false;
+        runtime_ready(w->g);
+    }
+}
+
+extern go_int runtime_chanlen(go_chan_t c)
+{
+    return (c == NULL? 0: c->len);
+}
+
+extern go_int runtime_chancap(go_chan_t c)
+{
+    return (c == NULL? 0: c->cap);
+}
+
+extern bool runtime_selectwin(go_waiter_t w)
+{
+    if (w->sel->done)
+        return false;
+    w->sel->done = true;
+    w->sel->winner = w;
+    return true;
+}
+
+extern go_int runtime_select(struct go_scase_s *cases, go_int ncases,
+    bool block, bool *recvok)
+{
+    // poll cases in random order
+    go_int order[ncases + 1];
+    for (go_int i = 0; i < ncases; i++)
+    {
+        go_int j = (go_int)(runtime_fastrand() % (uint32_t)(i + 1));
+        order[i] = order[j];
+        order[j] = i;
+    }
+    for (go_int i = 0; i < ncases; i++)
+    {
+        struct go_scase_s *cas = cases + order[i];
+        if (cas->c == NULL)
+            continue;
+        if (cas->dir == SELECT_SEND && chan_trysend(cas->c, cas->elem))
+            return order[i];
+        if (cas->dir == SELECT_RECV && chan_tryrecv(cas->c, cas->elem, recvok))
+            return order[i];
+    }
+    if (!block)
+        return -1;
+
+    // wait on all channels, until one of them chooses its case
+    struct go_select_s sel = {0};
+    struct go_waiter_s ws[ncases + 1];
+    memset(ws, 0, sizeof(ws));
+    for (go_int i = 0; i < ncases; i++)
+    {
+        struct go_scase_s *cas = cases + i;
+        if (cas->c == NULL)
+            continue;
+        ws[i].g = runtime_curg();
+        ws[i].elem = cas->elem;
+        ws[i].sel = &sel;
+        ws[i].caseidx = i;
+        runtime_enqueue(cas->dir == SELECT_SEND? &cas->c->sendq:
+            &cas->c->recvq, &ws[i]);
+    }
+    runtime_park("select");
+    for (go_int i = 0; i < ncases; i++)
+    {
+        struct go_scase_s *cas = cases + i;
+        if (cas->c == NULL)
+            continue;
+        runtime_unlink(cas->dir == SELECT_SEND? &cas->c->sendq:
+            &cas->c->recvq, &ws[i]);
+    }
+    go_waiter_t w = sel.winner;
+    if (cases[w->caseidx].dir == SELECT_SEND && !w->success)
+        runtime_panicerror("send on closed channel");
+    *recvok = w->success;
+    return w->caseidx;
+}
@@ -0,0 +1,1957 @@
+/*
+ * fmt.c
+ *
+ * Formatted printing with semantics of Go package fmt.
+ *
+ * Operands are empty interface values, which are walked with type
+ * descriptors of their dynamic types, so values of any type can be printed.
+ * Values implementing error or fmt.Stringer are printed with their Error or
+ * String methods.  Formatting follows fmt/print.go and fmt/format.go of Go.
+ */
+
+#include <math.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+#include "runtime.h"
+
+/*
+ * Standard streams of package os.
+ */
+static struct go_file_s fmt_stdin  = {0};
+static struct go_file_s fmt_stdout = {1};
+static struct go_file_s fmt_stderr = {2};
+
+go_file_t os__Stdin  = &fmt_stdin;
+go_file_t os__Stdout = &fmt_stdout;
+go_file_t os__Stderr = &fmt_stderr;
+
+/*
+ * Errors created by Errorf: *errors.errorString, or *fmt.wrapError if
+ * operand was formatted with %w.
+ */
+struct fmt_error_s
+{
+    struct go_string_s msg;
+    struct go_iface_s err;      // Wrapped error.
+};
+
+static struct go_string_s fmt_error_Error(struct fmt_error_s **e)
+{
+    return (*e)->msg;
+}
+
+static const struct go_type_s fmt_stringtype =
+{
+    .name     = "string",
+    .keydesc  = NULL,
+    .kind     = KIND_STRING,
+    .size     = sizeof(struct go_string_s),
+};
+
+static const struct go_type_s fmt_errortype =
+{
+    .name     = "error",
+    .keydesc  = NULL,
+    .kind     = KIND_IFACE,
+    .size     = sizeof(struct go_iface_s),
+};
+
+static const struct go_field_s fmt_errorstring_fields[] =
+{
+    {"s", &fmt_stringtype, offsetof(struct fmt_error_s, msg)},
+};
+
+static const struct go_field_s fmt_wraperror_fields[] =
+{
+    {"msg", &fmt_stringtype, offsetof(struct fmt_error_s, msg)},
+    {"err", &fmt_errortype, offsetof(struct fmt_error_s, err)},
+};
+
+static const struct go_type_s fmt_errorstringtype =
+{
+    .name     = "errors.errorString",
+    .keydesc  = NULL,
+    .kind     = KIND_STRUCT,
+    .size     = sizeof(struct go_string_s),
+    .nfields  = 1,
+    .fields   = fmt_errorstring_fields,
+};
+
+static const struct go_type_s fmt_wraperrortype =
+{
+    .name     = "fmt.wrapError",
+    .keydesc  = NULL,
+    .kind     = KIND_STRUCT,
+    .size     = sizeof(struct fmt_error_s),
+    .nfields  = 2,
+    .fields   = fmt_wraperror_fields,
+};
+
+// pointers are compared by address
+static const int32_t fmt_ptrkeydesc[] = {1, MAP_KEY_MEM, 0, sizeof(void *)};
+
+static const struct go_type_s fmt_errorstringptrtype =
+{
+    .name     = "*errors.errorString",
+    .keydesc  = fmt_ptrkeydesc,
+    .kind     = KIND_POINTER,
+    .size     = sizeof(void *),
+    .elem     = &fmt_errorstringtype,
+    .nmethods = 1,
+    .methods  = {{"Error", "func() string", (void *)fmt_error_Error}},
+};
+
+static const struct go_type_s fmt_wraperrorptrtype =
+{
+    .name     = "*fmt.wrapError",
+    .keydesc  = fmt_ptrkeydesc,
+    .kind     = KIND_POINTER,
+    .size     = sizeof(void *),
+    .elem     = &fmt_wraperrortype,
+    .nmethods = 1,
+    .methods  = {{"Error", "func() string", (void *)fmt_error_Error}},
+};
+
+static const struct go_itab_s fmt_errorstringitab =
+{
+    .type = &fmt_errorstringptrtype,
+    .fns  = {(void *)fmt_error_Error},
+};
+
+static const struct go_itab_s fmt_wraperroritab =
+{
+    .type = &fmt_wraperrorptrtype,
+    .fns  = {(void *)fmt_error_Error},
+};
+
+/*
+ * Printer state.  Flags describe verb being formatted.
+ */
+struct fmt_printer_s
+{
+    char *buf;
+    size_t len;
+    size_t cap;
+
+    bool plus, minus, sharp, space, zero;
+    bool plusV;                 // %+v
+    bool sharpV;                // %#v
+    bool widPresent, precPresent;
+    go_int wid, prec;
+
+    bool reordered;             // Explicit argument indexes were used.
+    bool goodArgNum;            // Last argument index was valid.
+    bool erroring;              // Printing bad verb, methods are not called.
+    bool wrapErrs;              // Verb %w is allowed (Errorf).
+    go_int wrapped;             // Operand of %w, -1 if none.
+};
+typedef struct fmt_printer_s *fmt_printer_t;
+
+/*
+ * Slice header and func value layouts.
+ */
+struct fmt_slice_s
+{
+    const char *ptr;
+    go_int len;
+    go_int cap;
+};
+
+struct fmt_func_s
+{
+    void *code;
+    void *env;
+};
+
+#define FMT_NIL_ANGLE       "<nil>"
+#define FMT_NIL_PAREN       "(nil)"
+#define FMT_MAX_NUM         1000000
+
+static const char fmt_ldigits[] = "0123456789abcdefx";
+static const char fmt_udigits[] = "0123456789ABCDEFX";
+
+static void fmt_printvalue(fmt_printer_t p, go_type_t type, const void *data,
+    int32_t verb, int depth, bool ro);
+static void fmt_printarg(fmt_printer_t p, go_type_t type, const void *data,
+    int32_t verb);
+
+/*
+ * Output buffer.
+ */
+static void fmt_reserve(fmt_printer_t p, size_t n)
+{
+    if (p->len + n <= p->cap)
+        return;
+    size_t cap = 2 * p->cap + n;
+    char *buf = (char *)realloc(p->buf, cap);
+    if (buf == NULL)
+    {
+        fputs("fatal error: out of memory\n", stderr);
+        exit(2);
+    }
+    p->buf = buf;
+    p->cap = cap;
+}
+
+static void fmt_write(fmt_printer_t p, const char *s, size_t n)
+{
+    fmt_reserve(p, n);
+    memcpy(p->buf + p->len, s, n);
+    p->len += n;
+}
+
+static void fmt_writestr(fmt_printer_t p, const char *s)
+{
+    fmt_write(p, s, strlen(s));
+}
+
+static void fmt_writebyte(fmt_printer_t p, char c)
+{
+    fmt_write(p, &c, 1);
+}
+
+static void fmt_writerune(fmt_printer_t p, int32_t r)
+{
+    char buf[RUNE_MAXWIDTH];
+    fmt_write(p, buf, (size_t)runtime_encoderune(buf, r));
+}
+
+static go_int fmt_runecount(const char *s, go_int len)
+{
+    go_int n = 0;
+    int32_t r;
+    for (go_int pos = 0; pos < len; n++)
+        pos = runtime_decoderune(s, len, pos, &r);
+    return n;
+}
+
+static void fmt_clearflags(fmt_printer_t p)
+{
+    p->plus = p->minus = p->sharp = p->space = p->zero = false;
+    p->plusV = p->sharpV = false;
+    p->widPresent = p->precPresent = false;
+    p->wid = p->prec = 0;
+}
+
+/*
+ * Padding.
+ */
+static void fmt_writepadding(fmt_printer_t p, go_int n)
+{
+    if (n <= 0)
+        return;
+    fmt_reserve(p, (size_t)n);
+    // zero padding is allowed only to the left
+    memset(p->buf + p->len, p->zero && !p->minus ? '0' : ' ', (size_t)n);
+    p->len += (size_t)n;
+}
+
+static void fmt_pad(fmt_printer_t p, const char *s, go_int len)
+{
+    if (!p->widPresent || p->wid == 0)
+    {
+        fmt_write(p, s, (size_t)len);
+        return;
+    }
+    go_int width = p->wid - fmt_runecount(s, len);
+    if (!p->minus)
+    {
+        fmt_writepadding(p, width);
+        fmt_write(p, s, (size_t)len);
+    }
+    else
+    {
+        fmt_write(p, s, (size_t)len);
+        fmt_writepadding(p, width);
+    }
+}
+
+static void fmt_padstr(fmt_printer_t p, const char *s)
+{
+    fmt_pad(p, s, (go_int)strlen(s));
+}
+
+/*
+ * Quoting of strings and runes like strconv does.
+ */
+static bool fmt_isprint(int32_t r)
+{
+    // Approximation of unicode.IsPrint for non-ASCII runes: control and
+    // format characters, surrogates, private use and non-characters are
+    // not printable.
+    if (r < 0x20)
+        return false;
+    if (r < 0x7F)
+        return true;
+    if (r < 0xA1 || r == 0xAD || r > 0x10FFFF)
+        return false;
+    if ((r >= 0x200B && r <= 0x200F) || (r >= 0x2028 && r <= 0x202E) ||
+            (r >= 0x2060 && r <= 0x206F) || r == 0xFEFF ||
+            (r >= 0xFFF9 && r <= 0xFFFB))
+        return false;
+    if ((r >= 0xD800 && r <= 0xF8FF) || r >= 0xF0000)
+        return false;
+    if ((r & 0xFFFE) == 0xFFFE || (r >= 0xFDD0 && r <= 0xFDEF))
+        return false;
+    return true;
+}
+
+static bool fmt_validrune(int64_t r)
+{
+    return r >= 0 && r <= 0x10FFFF && !(r >= 0xD800 && r <= 0xDFFF);
+}
+
+static void fmt_appendescaped(fmt_printer_t p, int32_t r, char quote,
+    bool ascii)
+{
+    if (r == quote || r == '\\')
+    {
+        fmt_writebyte(p, '\\');
+        fmt_writerune(p, r);
+        return;
+    }
+    if (ascii ? r < 0x80 && fmt_isprint(r) : fmt_isprint(r))
+    {
+        fmt_writerune(p, r);
+        return;
+    }
+    char buf[16];
+    switch (r)
+    {
+        case '\a': fmt_writestr(p, "\\a"); return;
+        case '\b': fmt_writestr(p, "\\b"); return;
+        case '\f': fmt_writestr(p, "\\f"); return;
+        case '\n': fmt_writestr(p, "\\n"); return;
+        case '\r': fmt_writestr(p, "\\r"); return;
+        case '\t': fmt_writestr(p, "\\t"); return;
+        case '\v': fmt_writestr(p, "\\v"); return;
+    }
+    if (r < ' ' || r == 0x7F)
+        snprintf(buf, sizeof(buf), "\\x%02x", (unsigned)r);
+    else if (!fmt_validrune(r) || r < 0x10000)
+        snprintf(buf, sizeof(buf), "\\u%04x",
+            (unsigned)(fmt_validrune(r) ? r : RUNE_ERROR));
+    else
+        snprintf(buf, sizeof(buf), "\\U%08x", (unsigned)r);
+    fmt_writestr(p, buf);
+}
+
+static void fmt_appendquoted(fmt_printer_t p, const char *s, go_int len,
+    bool ascii)
+{
+    fmt_writebyte(p, '"');
+    for (go_int pos = 0; pos < len; )
+    {
+        int32_t r;
+        go_int next = runtime_decoderune(s, len, pos, &r);
+        if (r == RUNE_ERROR && next - pos == 1)
+        {
+            char buf[8];
+            snprintf(buf, sizeof(buf), "\\x%02x", (unsigned)(uint8_t)s[pos]);
+            fmt_writestr(p, buf);
+        }
+        else
+            fmt_appendescaped(p, r, '"', ascii);
+        pos = next;
+    }
+    fmt_writebyte(p, '"');
+}
+
+static bool fmt_canbackquote(const char *s, go_int len)
+{
+    for (go_int pos = 0; pos < len; )
+    {
+        int32_t r;
+        go_int next = runtime_decoderune(s, len, pos, &r);
+        if (next - pos > 1)
+        {
+            if (r == 0xFEFF || r == RUNE_ERROR)
+                return false;
+        }
+        else if (r == RUNE_ERROR || ((r < ' ' && r != '\t') || r == '`' ||
+                r == 0x7F))
+            return false;
+        pos = next;
+    }
+    return true;
+}
+
+/*
+ * Format into temporary printer, which inherits flags, and pad the result.
+ */
+static void fmt_padtemp(fmt_printer_t p, struct fmt_printer_s *tmp)
+{
+    fmt_pad(p, tmp->buf, (go_int)tmp->len);
+    free(tmp->buf);
+}
+
+/*
+ * Formatting of basic values (fmt/format.go).
+ */
+static void fmt_fmtboolean(fmt_printer_t p, bool v)
+{
+    fmt_padstr(p, v ? "true" : "false");
+}
+
+static void fmt_fmtinteger(fmt_printer_t p, uint64_t u, int base,
+    bool isSigned, int32_t verb, const char *digits)
+{
+    bool negative = isSigned && (int64_t)u < 0;
+    if (negative)
+        u = -u;
+
+    size_t size = 68;
+    if (p->widPresent || p->precPresent)
+    {
+        // extra bytes for possible sign and 0x
+        size_t width = 3 + (size_t)p->wid + (size_t)p->prec;
+        if (width > size)
+            size = width;
+    }
+    char stackbuf[68];
+    char *buf = size > sizeof(stackbuf) ? (char *)malloc(size) : stackbuf;
+
+    // two ways to ask for extra leading zero digits: %.3d or %03d
+    go_int prec = 0;
+    if (p->precPresent)
+    {
+        prec = p->prec;
+        // precision of 0 and value of 0 means "print nothing" but padding
+        if (prec == 0 && u == 0)
+        {
+            bool oldzero = p->zero;
+            p->zero = false;
+            fmt_writepadding(p, p->wid);
+            p->zero = oldzero;
+            if (buf != stackbuf)
+                free(buf);
+            return;
+        }
+    }
+    else if (p->zero && !p->minus && p->widPresent)
+    {
+        prec = p->wid;
+        if (negative || p->plus || p->space)
+            prec--;     // leave room for sign
+    }
+
+    size_t i = size;
+    switch (base)
+    {
+        case 10:
+            for (; u >= 10; u /= 10)
+                buf[--i] = (char)('0' + u % 10);
+            break;
+        case 16:
+            for (; u >= 16; u >>= 4)
+                buf[--i] = digits[u & 0xF];
+            break;
+        case 8:
+            for (; u >= 8; u >>= 3)
+                buf[--i] = (char)('0' + (u & 7));
+            break;
+        case 2:
+            for (; u >= 2; u >>= 1)
+                buf[--i] = (char)('0' + (u & 1));
+            break;
+    }
+    buf[--i] = digits[u];
+    while (i > 0 && prec > (go_int)(size - i))
+        buf[--i] = '0';
+
+    if (p->sharp)
+    {
+        switch (base)
+        {
+            case 2:
+                buf[--i] = 'b';
+                buf[--i] = '0';
+                break;
+            case 8:
+                if (buf[i] != '0')
+                    buf[--i] = '0';
+                break;
+            case 16:
+                buf[--i] = digits[16];
+                buf[--i] = '0';
+                break;
+        }
+    }
+    if (verb == 'O')
+    {
+        buf[--i] = 'o';
+        buf[--i] = '0';
+    }
+
+    if (negative)
+        buf[--i] = '-';
+    else if (p->plus)
+        buf[--i] = '+';
+    else if (p->space)
+        buf[--i] = ' ';
+
+    // left padding with zeros has already been handled like precision
+    bool oldzero = p->zero;
+    p->zero = false;
+    fmt_pad(p, buf + i, (go_int)(size - i));
+    p->zero = oldzero;
+    if (buf != stackbuf)
+        free(buf);
+}
+
+static void fmt_fmt0x64(fmt_printer_t p, uint64_t v, bool leading0x)
+{
+    bool sharp = p->sharp;
+    p->sharp = leading0x;
+    fmt_fmtinteger(p, v, 16, false, 'v', fmt_ldigits);
+    p->sharp = sharp;
+}
+
+static void fmt_fmtunicode(fmt_printer_t p, uint64_t u)
+{
+    char buf[64];
+    go_int prec = 4;
+    if (p->precPresent && p->prec > 4)
+        prec = p->prec;
+    struct fmt_printer_s tmp = {0};
+    fmt_writestr(&tmp, "U+");
+    snprintf(buf, sizeof(buf), "%0*llX", (int)(prec < 40 ? prec : 40),
+        (unsigned long long)u);
+    fmt_writestr(&tmp, buf);
+    if (p->sharp && u <= 0x10FFFF && fmt_isprint((int32_t)u))
+    {
+        fmt_writestr(&tmp, " '");
+        fmt_writerune(&tmp, (int32_t)u);
+        fmt_writebyte(&tmp, '\'');
+    }
+    bool oldzero = p->zero;
+    p->zero = false;
+    fmt_padtemp(p, &tmp);
+    p->zero = oldzero;
+}
+
+static void fmt_fmtc(fmt_printer_t p, uint64_t c)
+{
+    int32_t r = c > 0x10FFFF ? RUNE_ERROR : (int32_t)c;
+    char buf[RUNE_MAXWIDTH];
+    fmt_pad(p, buf, runtime_encoderune(buf, r));
+}
+
+static void fmt_fmtqc(fmt_printer_t p, uint64_t c)
+{
+    int32_t r = c > 0x10FFFF ? RUNE_ERROR : (int32_t)c;
+    if (!fmt_validrune(r))
+        r = RUNE_ERROR;
+    struct fmt_printer_s tmp = {0};
+    fmt_writebyte(&tmp, '\'');
+    fmt_appendescaped(&tmp, r, '\'', p->plus);
+    fmt_writebyte(&tmp, '\'');
+    fmt_padtemp(p, &tmp);
+}
+
+/*
+ * Truncate string to precision, which counts runes.
+ */
+static go_int fmt_truncate(fmt_printer_t p, const char *s, go_int len)
+{
+    if (!p->precPresent)
+        return len;
+    go_int n = p->prec;
+    int32_t r;
+    for (go_int pos = 0; pos < len; pos = runtime_decoderune(s, len, pos, &r))
+    {
+        if (--n < 0)
+            return pos;
+    }
+    return len;
+}
+
+static void fmt_fmts(fmt_printer_t p, const char *s, go_int len)
+{
+    fmt_pad(p, s, fmt_truncate(p, s, len));
+}
+
+static void fmt_fmtsbx(fmt_printer_t p, const char *s, go_int length,
+    const char *digits)
+{
+    if (p->precPresent && p->prec < length)
+        length = p->prec;
+    // width of the encoding, with 0x prefixes and spaces
+    go_int width = 2 * length;
+    if (width > 0)
+    {
+        if (p->space)
+        {
+            if (p->sharp)
+                width *= 2;
+            width += length - 1;
+        }
+        else if (p->sharp)
+            width += 2;
+    }
+    else
+    {
+        if (p->widPresent)
+            fmt_writepadding(p, p->wid);
+        return;
+    }
+    if (p->widPresent && p->wid > width && !p->minus)
+        fmt_writepadding(p, p->wid - width);
+    if (p->sharp)
+    {
+        fmt_writebyte(p, '0');
+        fmt_writebyte(p, digits[16]);
+    }
+    for (go_int i = 0; i < length; i++)
+    {
+        if (p->space && i > 0)
+        {
+            fmt_writebyte(p, ' ');
+            if (p->sharp)
+            {
+                fmt_writebyte(p, '0');
+                fmt_writebyte(p, digits[16]);
+            }
+        }
+        uint8_t c = (uint8_t)s[i];
+        fmt_writebyte(p, digits[c >> 4]);
+        fmt_writebyte(p, digits[c & 0xF]);
+    }
+    if (p->widPresent && p->wid > width && p->minus)
+        fmt_writepadding(p, p->wid - width);
+}
+
+static void fmt_fmtq(fmt_printer_t p, const char *s, go_int len)
+{
+    len = fmt_truncate(p, s, len);
+    struct fmt_printer_s tmp = {0};
+    if (p->sharp && fmt_canbackquote(s, len))
+    {
+        fmt_writebyte(&tmp, '`');
+        fmt_write(&tmp, s, (size_t)len);
+        fmt_writebyte(&tmp, '`');
+    }
+    else
+        fmt_appendquoted(&tmp, s, len, p->plus);
+    fmt_padtemp(p, &tmp);
+}
+
+/*
+ * Format float like strconv.AppendFloat with bit size 32 or 64.  Precision
+ * -1 means the fewest digits, which read back as the same value.
+ */
+static void fmt_formatfloat(char *buf, size_t size, double v, int bits,
+    char verb, go_int prec)
+{
+    if (isnan(v))
+    {
+        snprintf(buf, size, "NaN");
+        return;
+    }
+    if (isinf(v))
+    {
+        snprintf(buf, size, v > 0 ? "+Inf" : "-Inf");
+        return;
+    }
+    switch (verb)
+    {
+        case 'b':
+        {
+            // mantissa and binary exponent
+            int64_t exp;
+            uint64_t mant;
+            if (bits == 32)
+            {
+                float f = (float)v;
+                uint32_t u;
+                memcpy(&u, &f, sizeof(u));
+                exp = (int64_t)((u >> 23) & 0xFF);
+                mant = u & ((1u << 23) - 1);
+                if (exp == 0)
+                    exp++;
+                else
+                    mant |= 1u << 23;
+                exp += -127 - 23;
+                snprintf(buf, size, "%s%llup%+lld", u >> 31 ? "-" : "",
+                    (unsigned long long)mant, (long long)exp);
+            }
+            else
+            {
+                uint64_t u;
+                memcpy(&u, &v, sizeof(u));
+                exp = (int64_t)((u >> 52) & 0x7FF);
+                mant = u & (((uint64_t)1 << 52) - 1);
+                if (exp == 0)
+                    exp++;
+                else
+                    mant |= (uint64_t)1 << 52;
+                exp += -1023 - 52;
+                snprintf(buf, size, "%s%llup%+lld", u >> 63 ? "-" : "",
+                    (unsigned long long)mant, (long long)exp);
+            }
+            return;
+        }
+        case 'x':
+        case 'X':
+        {
+            // exponent has at least two digits
+            char tmp[64];
+            if (prec < 0)
+                snprintf(tmp, sizeof(tmp), verb == 'x' ? "%a" : "%A",
+                    bits == 32 ? (double)(float)v : v);
+            else
+                snprintf(tmp, sizeof(tmp), verb == 'x' ? "%.*a" : "%.*A",
+                    (int)prec, bits == 32 ? (double)(float)v : v);
+            char *p = strpbrk(tmp, "pP");
+            if (p != NULL && p[2] != '\0' && p[3] == '\0')
+            {
+                size_t n = (size_t)(p - tmp) + 2;
+                snprintf(buf, size, "%.*s0%s", (int)n, tmp, p + 2);
+            }
+            else
+                snprintf(buf, size, "%s", tmp);
+            return;
+        }
+    }
+    if (prec < 0)
+    {
+        // shortest digits in %e notation
+        int digits;
+        for (digits = 1; digits < 17; digits++)
+        {
+            snprintf(buf, size, "%.*e", digits - 1, v);
+            if (bits == 32 ? strtof(buf, NULL) == (float)v :
+                    strtod(buf, NULL) == v)
+                break;
+        }
+        snprintf(buf, size, "%.*e", digits - 1, v);
+        int exp = atoi(strchr(buf, 'e') + 1);
+        switch (verb)
+        {
+            case 'e':
+            case 'E':
+                snprintf(buf, size, verb == 'e' ? "%.*e" : "%.*E", digits - 1,
+                    v);
+                break;
+            case 'f':
+                snprintf(buf, size, "%.*f",
+                    digits - 1 - exp > 0 ? digits - 1 - exp : 0, v);
+                break;
+            default:
+                // %e is used if the exponent is less than -4 or greater
+                // than or equal to 6
+                if (exp < -4 || exp >= 6)
+                    snprintf(buf, size, verb == 'G' ? "%.*E" : "%.*e",
+                        digits - 1, v);
+                else
+                    snprintf(buf, size, "%.*f",
+                        digits - 1 - exp > 0 ? digits - 1 - exp : 0, v);
+                break;
+        }
+        return;
+    }
+    char format[] = "%.*?";
+    format[3] = (char)verb;
+    snprintf(buf, size, format, (int)prec, v);
+}
+
+static void fmt_fmtfloatprec(fmt_printer_t p, double v, int bits,
+    int32_t verb, go_int prec)
+{
+    // explicit precision in format specifier overrules default precision
+    if (p->precPresent)
+        prec = p->prec;
+    size_t size = 64 + (prec > 0 ? (size_t)prec : 0) + 320;
+    char *num = (char *)malloc(size + 1);
+    // leading byte is reserved for sign
+    fmt_formatfloat(num + 1, size, v, bits, (char)verb, prec);
+    char *s = num + 1;
+    if (s[0] == '-' || s[0] == '+')
+        ;
+    else
+    {
+        s = num;
+        s[0] = '+';
+    }
+    // space instead of + sign, unless the sign is explicitly asked for
+    if (p->space && s[0] == '+' && !p->plus)
+        s[0] = ' ';
+    // infinities and NaN are not padded with zeros
+    if (s[1] == 'I' || s[1] == 'N')
+    {
+        bool oldzero = p->zero;
+        p->zero = false;
+        if (s[1] == 'N' && !p->space && !p->plus)
+            s++;
+        fmt_padstr(p, s);
+        p->zero = oldzero;
+        free(num);
+        return;
+    }
+    // sharp flag forces decimal point and keeps trailing zeros
+    if (p->sharp && verb != 'b')
+    {
+        go_int digits = 0;
+        if (verb == 'v' || verb == 'g' || verb == 'G' || verb == 'x')
+            digits = prec == -1 ? 6 : prec;
+        char tail[16] = "";
+        bool hasdot = false, nonzero = false;
+        size_t len = strlen(s);
+        for (size_t i = 1; i < len; i++)
+        {
+            char c = s[i];
+            if (c == '.')
+                hasdot = true;
+            else if (c == 'p' || c == 'P' ||
+                    ((c == 'e' || c == 'E') && verb != 'x' && verb != 'X'))
+            {
+                snprintf(tail, sizeof(tail), "%s", s + i);
+                s[i] = '\0';
+                break;
+            }
+            else
+            {
+                if (c != '0')
+                    nonzero = true;
+                if (nonzero)
+                    digits--;
+            }
+        }
+        len = strlen(s);
+        char *res = (char *)malloc(len + (size_t)(digits > 0 ? digits : 0) +
+            sizeof(tail) + 2);
+        memcpy(res, s, len);
+        if (!hasdot)
+        {
+            if (len == 2 && s[1] == '0')
+                digits--;
+            res[len++] = '.';
+        }
+        for (; digits > 0; digits--)
+            res[len++] = '0';
+        strcpy(res + len, tail);
+        free(num);
+        num = s = res;
+    }
+    // sign if asked for or negative
+    if (p->plus || s[0] != '+')
+    {
+        go_int len = (go_int)strlen(s);
+        if (p->zero && !p->minus && p->widPresent && p->wid > len)
+        {
+            // sign goes before leading zeros
+            fmt_writebyte(p, s[0]);
+            fmt_writepadding(p, p->wid - len);
+            fmt_writestr(p, s + 1);
+        }
+        else
+            fmt_padstr(p, s);
+    }
+    else
+        fmt_padstr(p, s + 1);
+    free(num);
+}
+
+/*
+ * Formatting of operands by verb (fmt/print.go).
+ */
+static void fmt_badverb(fmt_printer_t p, go_type_t type, const void *data,
+    int32_t verb)
+{
+    p->erroring = true;
+    fmt_writestr(p, "%!");
+    fmt_writerune(p, verb);
+    fmt_writebyte(p, '(');
+    if (type != NULL)
+    {
+        fmt_writestr(p, type->name);
+        fmt_writebyte(p, '=');
+        fmt_printvalue(p, type, data, 'v', 0, false);
+    }
+    else
+        fmt_writestr(p, FMT_NIL_ANGLE);
+    fmt_writebyte(p, ')');
+    p->erroring = false;
+}
+
+static void fmt_fmtbool(fmt_printer_t p, go_type_t type, const void *data,
+    int32_t verb)
+{
+    switch (verb)
+    {
+        case 't':
+        case 'v':
+            fmt_fmtboolean(p, *(const uint8_t *)data != 0);
+            break;
+        default:
+            fmt_badverb(p, type, data, verb);
+    }
+}
+
+static void fmt_printinteger(fmt_printer_t p, go_type_t type,
+    const void *data, uint64_t v, bool isSigned, int32_t verb)
+{
+    switch (verb)
+    {
+        case 'v':
+            if (p->sharpV && !isSigned)
+                fmt_fmt0x64(p, v, true);
+            else
+                fmt_fmtinteger(p, v, 10, isSigned, verb, fmt_ldigits);
+            break;
+        case 'd':
+            fmt_fmtinteger(p, v, 10, isSigned, verb, fmt_ldigits);
+            break;
+        case 'b':
+            fmt_fmtinteger(p, v, 2, isSigned, verb, fmt_ldigits);
+            break;
+        case 'o':
+        case 'O':
+            fmt_fmtinteger(p, v, 8, isSigned, verb, fmt_ldigits);
+            break;
+        case 'x':
+            fmt_fmtinteger(p, v, 16, isSigned, verb, fmt_ldigits);
+            break;
+        case 'X':
+            fmt_fmtinteger(p, v, 16, isSigned, verb, fmt_udigits);
+            break;
+        case 'c':
+            fmt_fmtc(p, v);
+            break;
+        case 'q':
+            fmt_fmtqc(p, v);
+            break;
+        case 'U':
+            fmt_fmtunicode(p, v);
+            break;
+        default:
+            fmt_badverb(p, type, data, verb);
+    }
+}
+
+static void fmt_printfloat(fmt_printer_t p, go_type_t type, const void *data,
+    double v, int bits, int32_t verb)
+{
+    switch (verb)
+    {
+        case 'v':
+            fmt_fmtfloatprec(p, v, bits, 'g', -1);
+            break;
+        case 'b':
+        case 'g':
+        case 'G':
+        case 'x':
+        case 'X':
+            fmt_fmtfloatprec(p, v, bits, verb, -1);
+            break;
+        case 'f':
+        case 'e':
+        case 'E':
+            fmt_fmtfloatprec(p, v, bits, verb, 6);
+            break;
+        case 'F':
+            fmt_fmtfloatprec(p, v, bits, 'f', 6);
+            break;
+        default:
+            fmt_badverb(p, type, data, verb);
+    }
+}
+
+static void fmt_printstring(fmt_printer_t p, go_type_t type,
+    const void *data, struct go_string_s s, int32_t verb)
+{
+    switch (verb)
+    {
+        case 'v':
+            if (p->sharpV)
+                fmt_fmtq(p, s.ptr, s.len);
+            else
+                fmt_fmts(p, s.ptr, s.len);
+            break;
+        case 's':
+            fmt_fmts(p, s.ptr, s.len);
+            break;
+        case 'x':
+            fmt_fmtsbx(p, s.ptr, s.len, fmt_ldigits);
+            break;
+        case 'X':
+            fmt_fmtsbx(p, s.ptr, s.len, fmt_udigits);
+            break;
+        case 'q':
+            fmt_fmtq(p, s.ptr, s.len);
+            break;
+        default:
+            fmt_badverb(p, type, data, verb);
+    }
+}
+
+static void fmt_printbytes(fmt_printer_t p, const char *b, go_int len,
+    int32_t verb)
+{
+    switch (verb)
+    {
+        case 's':
+            fmt_pad(p, b, fmt_truncate(p, b, len));
+            break;
+        case 'x':
+            fmt_fmtsbx(p, b, len, fmt_ldigits);
+            break;
+        case 'X':
+            fmt_fmtsbx(p, b, len, fmt_udigits);
+            break;
+        case 'q':
+            fmt_fmtq(p, b, len);
+            break;
+    }
+}
+
+/*
+ * Address held by value of pointer-like type, or false for other types.
+ */
+static bool fmt_pointer(go_type_t type, const void *data, uint64_t *u)
+{
+    switch (type->kind)
+    {
+        case KIND_POINTER:
+        case KIND_MAP:
+        case KIND_CHAN:
+            *u = (uint64_t)(uintptr_t)*(void *const *)data;
+            return true;
+        case KIND_SLICE:
+            *u = (uint64_t)(uintptr_t)((const struct fmt_slice_s *)data)->ptr;
+            return true;
+        case KIND_FUNC:
+            *u = (uint64_t)(uintptr_t)((const struct fmt_func_s *)data)->code;
+            return true;
+    }
+    return false;
+}
+
+static void fmt_printpointer(fmt_printer_t p, go_type_t type,
+    const void *data, int32_t verb)
+{
+    uint64_t u;
+    if (!fmt_pointer(type, data, &u))
+    {
+        fmt_badverb(p, type, data, verb);
+        return;
+    }
+    switch (verb)
+    {
+        case 'v':
+            if (p->sharpV)
+            {
+                fmt_writebyte(p, '(');
+                fmt_writestr(p, type->name);
+                fmt_writestr(p, ")(");
+                if (u == 0)
+                    fmt_writestr(p, "nil");
+                else
+                    fmt_fmt0x64(p, u, true);
+                fmt_writebyte(p, ')');
+            }
+            else if (u == 0)
+                fmt_padstr(p, FMT_NIL_ANGLE);
+            else
+                fmt_fmt0x64(p, u, !p->sharp);
+            break;
+        case 'p':
+            fmt_fmt0x64(p, u, !p->sharp);
+            break;
+        case 'b':
+        case 'o':
+        case 'd':
+        case 'x':
+        case 'X':
+            fmt_printinteger(p, type, data, u, false, verb);
+            break;
+        default:
+            fmt_badverb(p, type, data, verb);
+    }
+}
+
+/*
+ * Find method of type, which returns string, like Error() or String().
+ */
+static struct go_string_s (*fmt_strmethod(go_type_t type,
+    const char *name))(const void *)
+{
+    for (int32_t i = 0; i < type->nmethods; i++)
+    {
+        if (strcmp(type->methods[i].name, name) == 0 &&
+                strcmp(type->methods[i].sig, "func() string") == 0)
+            return (struct go_string_s (*)(const void *))type->methods[i].fn;
+    }
+    return NULL;
+}
+
+/*
+ * Print value with its GoString, Error or String method, if verb allows it.
+ */
+static bool fmt_handlemethods(fmt_printer_t p, go_type_t type,
+    const void *data, int32_t verb)
+{
+    if (p->erroring)
+        return false;
+    if (verb == 'w')
+    {
+        // operand of %w must be error
+        if (fmt_strmethod(type, "Error") == NULL || !p->wrapErrs)
+        {
+            fmt_badverb(p, type, data, verb);
+            return true;
+        }
+        verb = 'v';
+    }
+    struct go_string_s (*str)(const void *);
+    if (p->sharpV)
+    {
+        if ((str = fmt_strmethod(type, "GoString")) != NULL)
+        {
+            struct go_string_s s = str(data);
+            fmt_fmts(p, s.ptr, s.len);
+            return true;
+        }
+        return false;
+    }
+    switch (verb)
+    {
+        case 'v':
+        case 's':
+        case 'x':
+        case 'X':
+        case 'q':
+            if ((str = fmt_strmethod(type, "Error")) != NULL ||
+                    (str = fmt_strmethod(type, "String")) != NULL)
+            {
+                fmt_printstring(p, type, data, str(data), verb);
+                return true;
+            }
+    }
+    return false;
+}
+
+/*
+ * Map keys are sorted like by internal/fmtsort.
+ */
+static int fmt_compare(go_type_t type, const void *a, const void *b)
+{
+    switch (type->kind)
+    {
+        case KIND_BOOL:
+        {
+            int x = *(const uint8_t *)a != 0, y = *(const uint8_t *)b != 0;
+            return x - y;
+        }
+        case KIND_INT:
+        {
+            int64_t x, y;
+            switch (type->size)
+            {
+                case 1: x = *(const int8_t *)a; y = *(const int8_t *)b; break;
+                case 2: x = *(const int16_t *)a; y = *(const int16_t *)b; break;
+                case 4: x = *(const int32_t *)a; y = *(const int32_t *)b; break;
+                default: x = *(const int64_t *)a; y = *(const int64_t *)b;
+            }
+            return (x > y) - (x < y);
+        }
+        case KIND_UINT:
+        {
+            uint64_t x, y;
+            switch (type->size)
+            {
+                case 1: x = *(const uint8_t *)a; y = *(const uint8_t *)b; break;
+                case 2: x = *(const uint16_t *)a; y = *(const uint16_t *)b; break;
+                case 4: x = *(const uint32_t *)a; y = *(const uint32_t *)b; break;
+                default: x = *(const uint64_t *)a; y = *(const uint64_t *)b;
+            }
+            return (x > y) - (x < y);
+        }
+        case KIND_FLOAT:
+        {
+            double x, y;
+            if (type->size == 4)
+            {
+                x = *(const float *)a;
+                y = *(const float *)b;
+            }
+            else
+            {
+                x = *(const double *)a;
+                y = *(const double *)b;
+            }
+            // NaN is less than any other value
+            if (x != x)
+                return y != y ? 0 : -1;
+            if (y != y)
+                return 1;
+            return (x > y) - (x < y);
+        }
+        case KIND_STRING:
+        {
+            const struct go_string_s *x = (const struct go_string_s *)a;
+            const struct go_string_s *y = (const struct go_string_s *)b;
+            return runtime_cmpstring(x->ptr, x->len, y->ptr, y->len);
+        }
+        case KIND_POINTER:
+        case KIND_CHAN:
+        {
+            uintptr_t x = (uintptr_t)*(void *const *)a;
+            uintptr_t y = (uintptr_t)*(void *const *)b;
+            return (x > y) - (x < y);
+        }
+        case KIND_STRUCT:
+            for (int32_t i = 0; i < type->nfields; i++)
+            {
+                const struct go_field_s *f = &type->fields[i];
+                int c = fmt_compare(f->type, (const char *)a + f->offset,
+                    (const char *)b + f->offset);
+                if (c != 0)
+                    return c;
+            }
+            return 0;
+        case KIND_ARRAY:
+            for (int64_t i = 0; i < type->len; i++)
+            {
+                int64_t off = i * type->elem->size;
+                int c = fmt_compare(type->elem, (const char *)a + off,
+                    (const char *)b + off);
+                if (c != 0)
+                    return c;
+            }
+            return 0;
+        case KIND_IFACE:
+        {
+            const struct go_iface_s *x = (const struct go_iface_s *)a;
+            const struct go_iface_s *y = (const struct go_iface_s *)b;
+            if (x->tab == NULL || y->tab == NULL)
+                return (x->tab != NULL) - (y->tab != NULL);
+            go_type_t tx = x->tab->type, ty = y->tab->type;
+            if (tx != ty)
+                return ((uintptr_t)tx > (uintptr_t)ty) -
+                    ((uintptr_t)tx < (uintptr_t)ty);
+            return fmt_compare(tx, x->data, y->data);
+        }
+    }
+    return 0;
+}
+
+static go_type_t fmt_sortkeytype;
+
+static int fmt_comparekeys(const void *a, const void *b)
+{
+    return fmt_compare(fmt_sortkeytype, *(void *const *)a, *(void *const *)b);
+}
+
+/*
+ * Print map entries sorted by key.
+ */
+static void fmt_printmap(fmt_printer_t p, go_type_t type, go_map_t m,
+    int32_t verb, int depth, bool ro)
+{
+    go_int n = runtime_maplen(m);
+    if (n == 0)
+        return;
+    void **keys = (void **)malloc(2 * (size_t)n * sizeof(void *));
+    void **vals = keys + n;
+    runtime_mapentries(m, keys, vals);
+    // sort key pointers, values are found by position of their keys
+    void **entries = (void **)malloc((size_t)n * sizeof(void *));
+    memcpy(entries, keys, (size_t)n * sizeof(void *));
+    fmt_sortkeytype = type->key;
+    qsort(entries, (size_t)n, sizeof(void *), fmt_comparekeys);
+    for (go_int i = 0; i < n; i++)
+    {
+        if (i > 0)
+        {
+            if (p->sharpV)
+                fmt_writestr(p, ", ");
+            else
+                fmt_writebyte(p, ' ');
+        }
+        go_int j = 0;
+        while (keys[j] != entries[i])
+            j++;
+        fmt_printvalue(p, type->key, keys[j], verb, depth + 1, ro);
+        fmt_writebyte(p, ':');
+        fmt_printvalue(p, type->elem, vals[j], verb, depth + 1, ro);
+    }
+    free(entries);
+    free(keys);
+}
+
+static bool fmt_exported(const char *name)
+{
+    return name[0] >= 'A' && name[0] <= 'Z';
+}
+
+/*
+ * Print value of type at address 'data'.  Values read through unexported
+ * fields ('ro') are printed without their methods.
+ */
+static void fmt_printvalue(fmt_printer_t p, go_type_t type, const void *data,
+    int32_t verb, int depth, bool ro)
+{
+    // values at depth 0 are handled by fmt_printarg
+    if (depth > 0 && !ro && fmt_handlemethods(p, type, data, verb))
+        return;
+
+    switch (type->kind)
+    {
+        case KIND_BOOL:
+            fmt_fmtbool(p, type, data, verb);
+            break;
+        case KIND_INT:
+        {
+            int64_t v;
+            switch (type->size)
+            {
+                case 1: v = *(const int8_t *)data; break;
+                case 2: v = *(const int16_t *)data; break;
+                case 4: v = *(const int32_t *)data; break;
+                default: v = *(const int64_t *)data;
+            }
+            fmt_printinteger(p, type, data, (uint64_t)v, true, verb);
+            break;
+        }
+        case KIND_UINT:
+        {
+            uint64_t v;
+            switch (type->size)
+            {
+                case 1: v = *(const uint8_t *)data; break;
+                case 2: v = *(const uint16_t *)data; break;
+                case 4: v = *(const uint32_t *)data; break;
+                default: v = *(const uint64_t *)data;
+            }
+            fmt_printinteger(p, type, data, v, false, verb);
+            break;
+        }
+        case KIND_FLOAT:
+            if (type->size == 4)
+                fmt_printfloat(p, type, data, *(const float *)data, 32, verb);
+            else
+                fmt_printfloat(p, type, data, *(const double *)data, 64, verb);
+            break;
+        case KIND_STRING:
+            fmt_printstring(p, type, data, *(const struct go_string_s *)data,
+                verb);
+            break;
+        case KIND_MAP:
+        {
+            go_map_t m = *(const go_map_t *)data;
+            if (p->sharpV)
+            {
+                fmt_writestr(p, type->name);
+                if (m == NULL)
+                {
+                    fmt_writestr(p, FMT_NIL_PAREN);
+                    return;
+                }
+                fmt_writebyte(p, '{');
+            }
+            else
+                fmt_writestr(p, "map[");
+            fmt_printmap(p, type, m, verb, depth, ro);
+            fmt_writebyte(p, p->sharpV ? '}' : ']');
+            break;
+        }
+        case KIND_STRUCT:
+            if (p->sharpV)
+                fmt_writestr(p, type->name);
+            fmt_writebyte(p, '{');
+            for (int32_t i = 0; i < type->nfields; i++)
+            {
+                const struct go_field_s *f = &type->fields[i];
+                if (i > 0)
+                {
+                    if (p->sharpV)
+                        fmt_writestr(p, ", ");
+                    else
+                        fmt_writebyte(p, ' ');
+                }
+                if (p->plusV || p->sharpV)
+                {
+                    fmt_writestr(p, f->name);
+                    fmt_writebyte(p, ':');
+                }
+                go_type_t ftype = f->type;
+                const void *fdata = (const char *)data + f->offset;
+                bool fro = ro || !fmt_exported(f->name);
+                // non-nil interface field is printed as its dynamic value
+                if (ftype->kind == KIND_IFACE &&
+                        ((const struct go_iface_s *)fdata)->tab != NULL)
+                {
+                    const struct go_iface_s *iface =
+                        (const struct go_iface_s *)fdata;
+                    ftype = iface->tab->type;
+                    fdata = iface->data;
+                }
+                fmt_printvalue(p, ftype, fdata, verb, depth + 1, fro);
+            }
+            fmt_writebyte(p, '}');
+            break;
+        case KIND_IFACE:
+        {
+            const struct go_iface_s *iface = (const struct go_iface_s *)data;
+            if (iface->tab == NULL)
+            {
+                if (p->sharpV)
+                {
+                    fmt_writestr(p, type->name);
+                    fmt_writestr(p, FMT_NIL_PAREN);
+                }
+                else
+                    fmt_writestr(p, FMT_NIL_ANGLE);
+            }
+            else
+                fmt_printvalue(p, iface->tab->type, iface->data, verb,
+                    depth + 1, ro);
+            break;
+        }
+        case KIND_ARRAY:
+        case KIND_SLICE:
+        {
+            const char *elems;
+            go_int len;
+            if (type->kind == KIND_SLICE)
+            {
+                elems = ((const struct fmt_slice_s *)data)->ptr;
+                len = ((const struct fmt_slice_s *)data)->len;
+            }
+            else
+            {
+                elems = (const char *)data;
+                len = (go_int)type->len;
+            }
+            switch (verb)
+            {
+                case 's':
+                case 'q':
+                case 'x':
+                case 'X':
+                    // byte slices and arrays are printed like strings
+                    if (type->elem->kind == KIND_UINT && type->elem->size == 1)
+                    {
+                        fmt_printbytes(p, elems, len, verb);
+                        return;
+                    }
+            }
+            if (p->sharpV)
+            {
+                fmt_writestr(p, type->name);
+                if (type->kind == KIND_SLICE && elems == NULL)
+                {
+                    fmt_writestr(p, FMT_NIL_PAREN);
+                    return;
+                }
+                fmt_writebyte(p, '{');
+                for (go_int i = 0; i < len; i++)
+                {
+                    if (i > 0)
+                        fmt_writestr(p, ", ");
+                    fmt_printvalue(p, type->elem, elems + i * type->elem->size,
+                        verb, depth + 1, ro);
+                }
+                fmt_writebyte(p, '}');
+            }
+            else
+            {
+                fmt_writebyte(p, '[');
+                for (go_int i = 0; i < len; i++)
+                {
+                    if (i > 0)
+                        fmt_writebyte(p, ' ');
+                    fmt_printvalue(p, type->elem, elems + i * type->elem->size,
+                        verb, depth + 1, ro);
+                }
+                fmt_writebyte(p, ']');
+            }
+            break;
+        }
+        case KIND_POINTER:
+        {
+            // pointer to composite value is printed as &value at top level
+            const void *ptr = *(void *const *)data;
+            if (depth == 0 && ptr != NULL && type->elem != NULL)
+            {
+                switch (type->elem->kind)
+                {
+                    case KIND_ARRAY:
+                    case KIND_SLICE:
+                    case KIND_STRUCT:
+                    case KIND_MAP:
+                        fmt_writebyte(p, '&');
+                        fmt_printvalue(p, type->elem, ptr, verb, depth + 1,
+                            ro);
+                        return;
+                }
+            }
+            fmt_printpointer(p, type, data, verb);
+            break;
+        }
+        case KIND_CHAN:
+        case KIND_FUNC:
+            fmt_printpointer(p, type, data, verb);
+            break;
+        default:
+            fmt_writebyte(p, '?');
+            fmt_writestr(p, type->name);
+            fmt_writebyte(p, '?');
+    }
+}
+
+/*
+ * Print operand, which is empty interface value with dynamic type 'type'
+ * (NULL for nil) and data word 'data'.
+ */
+static void fmt_printarg(fmt_printer_t p, go_type_t type, const void *data,
+    int32_t verb)
+{
+    if (type == NULL)
+    {
+        if (verb == 'T' || verb == 'v')
+            fmt_padstr(p, FMT_NIL_ANGLE);
+        else
+            fmt_badverb(p, NULL, NULL, verb);
+        return;
+    }
+    switch (verb)
+    {
+        case 'T':
+            fmt_fmts(p, type->name, (go_int)strlen(type->name));
+            return;
+        case 'p':
+            fmt_printpointer(p, type, data, 'p');
+            return;
+    }
+    if (!fmt_handlemethods(p, type, data, verb))
+        fmt_printvalue(p, type, data, verb, 0, false);
+}
+
+static go_type_t fmt_argtype(const struct go_iface_s *arg)
+{
+    return arg->tab != NULL ? arg->tab->type : NULL;
+}
+
+/*
+ * Parsing of format.
+ */
+static bool fmt_toolarge(go_int x)
+{
+    return x > FMT_MAX_NUM || x < -FMT_MAX_NUM;
+}
+
+static go_int fmt_parsenum(const char *s, go_int start, go_int end,
+    go_int *num, bool *isnum)
+{
+    *num = 0;
+    *isnum = false;
+    if (start >= end)
+        return end;
+    go_int i;
+    for (i = start; i < end && s[i] >= '0' && s[i] <= '9'; i++)
+    {
+        if (fmt_toolarge(*num))
+        {
+            *num = 0;
+            *isnum = false;
+            return end;
+        }
+        *num = *num * 10 + (s[i] - '0');
+        *isnum = true;
+    }
+    return i;
+}
+
+/*
+ * Integer operand used as width or precision by '*'.
+ */
+static go_int fmt_intfromarg(const struct go_iface_s *args, go_int nargs,
+    go_int argnum, go_int *num, bool *isint)
+{
+    *num = 0;
+    *isint = false;
+    if (argnum >= nargs)
+        return argnum;
+    go_type_t type = fmt_argtype(&args[argnum]);
+    const void *data = args[argnum].data;
+    if (type != NULL && type->kind == KIND_INT)
+    {
+        int64_t n;
+        switch (type->size)
+        {
+            case 1: n = *(const int8_t *)data; break;
+            case 2: n = *(const int16_t *)data; break;
+            case 4: n = *(const int32_t *)data; break;
+            default: n = *(const int64_t *)data;
+        }
+        if ((int64_t)(go_int)n == n)
+        {
+            *num = (go_int)n;
+            *isint = true;
+        }
+    }
+    else if (type != NULL && type->kind == KIND_UINT)
+    {
+        uint64_t n;
+        switch (type->size)
+        {
+            case 1: n = *(const uint8_t *)data; break;
+            case 2: n = *(const uint16_t *)data; break;
+            case 4: n = *(const uint32_t *)data; break;
+            default: n = *(const uint64_t *)data;
+        }
+        if (n <= (uint64_t)INT32_MAX)
+        {
+            *num = (go_int)n;
+            *isint = true;
+        }
+    }
+    if (fmt_toolarge(*num))
+    {
+        *num = 0;
+        *isint = false;
+    }
+    return argnum + 1;
+}
+
+/*
+ * Parse explicit argument index [n] at position 'i'.  Returns position
+ * after it and sets 'found'.
+ */
+static go_int fmt_argnumber(fmt_printer_t p, go_int *argnum,
+    const char *format, go_int end, go_int i, go_int nargs, bool *found)
+{
+    *found = false;
+    if (i >= end || format[i] != '[')
+        return i;
+    p->reordered = true;
+    // there must be at least 3 bytes: [n]
+    go_int index = 0, wid = 1;
+    bool ok = false;
+    if (end - i >= 3)
+    {
+        for (go_int j = i + 1; j < end; j++)
+        {
+            if (format[j] == ']')
+            {
+                go_int width;
+                bool isnum;
+                go_int newi = fmt_parsenum(format, i + 1, j, &width, &isnum);
+                wid = j - i + 1;
+                if (isnum && newi == j)
+                {
+                    // arguments are numbered from 1
+                    index = width - 1;
+                    ok = true;
+                }
+                break;
+            }
+        }
+    }
+    if (ok && index >= 0 && index < nargs)
+    {
+        *argnum = index;
+        *found = true;
+        return i + wid;
+    }
+    p->goodArgNum = false;
+    *found = ok;
+    return i + wid;
+}
+
+static void fmt_doprintf(fmt_printer_t p, const char *format, go_int end,
+    const struct go_iface_s *args, go_int nargs)
+{
+    go_int argnum = 0;
+    bool afterindex = false;
+    p->reordered = false;
+    for (go_int i = 0; i < end; )
+    {
+        p->goodArgNum = true;
+        go_int lasti = i;
+        while (i < end && format[i] != '%')
+            i++;
+        if (i > lasti)
+            fmt_write(p, format + lasti, (size_t)(i - lasti));
+        if (i >= end)
+            break;
+
+        // process one verb
+        i++;
+        fmt_clearflags(p);
+        for (; i < end; i++)
+        {
+            char c = format[i];
+            if (c == '#')
+                p->sharp = true;
+            else if (c == '0')
+                p->zero = true;
+            else if (c == '+')
+                p->plus = true;
+            else if (c == '-')
+                p->minus = true;
+            else if (c == ' ')
+                p->space = true;
+            else
+                break;
+        }
+
+        i = fmt_argnumber(p, &argnum, format, end, i, nargs, &afterindex);
+
+        // width
+        if (i < end && format[i] == '*')
+        {
+            i++;
+            argnum = fmt_intfromarg(args, nargs, argnum, &p->wid,
+                &p->widPresent);
+            if (!p->widPresent)
+                fmt_writestr(p, "%!(BADWIDTH)");
+            // negative width means left justification
+            if (p->wid < 0)
+            {
+                p->wid = -p->wid;
+                p->minus = true;
+                p->zero = false;
+            }
+            afterindex = false;
+        }
+        else
+        {
+            i = fmt_parsenum(format, i, end, &p->wid, &p->widPresent);
+            if (afterindex && p->widPresent)    // "%[3]2d"
+                p->goodArgNum = false;
+        }
+
+        // precision
+        if (i + 1 < end && format[i] == '.')
+        {
+            i++;
+            if (afterindex)                     // "%[3].2d"
+                p->goodArgNum = false;
+            i = fmt_argnumber(p, &argnum, format, end, i, nargs, &afterindex);
+            if (i < end && format[i] == '*')
+            {
+                i++;
+                argnum = fmt_intfromarg(args, nargs, argnum, &p->prec,
+                    &p->precPresent);
+                // negative precision makes no sense
+                if (p->prec < 0)
+                {
+                    p->prec = 0;
+                    p->precPresent = false;
+                }
+                if (!p->precPresent)
+                    fmt_writestr(p, "%!(BADPREC)");
+                afterindex = false;
+            }
+            else
+            {
+                i = fmt_parsenum(format, i, end, &p->prec, &p->precPresent);
+                if (!p->precPresent)
+                {
+                    p->prec = 0;
+                    p->precPresent = true;
+                }
+            }
+        }
+
+        if (!afterindex)
+            i = fmt_argnumber(p, &argnum, format, end, i, nargs, &afterindex);
+
+        if (i >= end)
+        {
+            fmt_writestr(p, "%!(NOVERB)");
+            break;
+        }
+
+        int32_t verb;
+        i = runtime_decoderune(format, end, i, &verb);
+
+        if (verb == '%')
+        {
+            // percent does not absorb operands and ignores width and
+            // precision
+            fmt_writebyte(p, '%');
+        }
+        else if (!p->goodArgNum)
+        {
+            fmt_writestr(p, "%!");
+            fmt_writerune(p, verb);
+            fmt_writestr(p, "(BADINDEX)");
+        }
+        else if (argnum >= nargs)
+        {
+            fmt_writestr(p, "%!");
+            fmt_writerune(p, verb);
+            fmt_writestr(p, "(MISSING)");
+        }
+        else
+        {
+            if (verb == 'w' && p->wrapErrs && p->wrapped < 0)
+                p->wrapped = argnum;
+            if (verb == 'v' || verb == 'w')
+            {
+                // Go syntax and struct field names
+                p->sharpV = p->sharp;
+                p->sharp = false;
+                p->plusV = p->plus;
+                p->plus = false;
+            }
+            fmt_printarg(p, fmt_argtype(&args[argnum]), args[argnum].data,
+                verb);
+            argnum++;
+        }
+    }
+
+    // extra operands are reported, unless operands were reordered
+    if (!p->reordered && argnum < nargs)
+    {
+        fmt_clearflags(p);
+        fmt_writestr(p, "%!(EXTRA ");
+        for (go_int i = argnum; i < nargs; i++)
+        {
+            if (i > argnum)
+                fmt_writestr(p, ", ");
+            go_type_t type = fmt_argtype(&args[i]);
+            if (type == NULL)
+                fmt_writestr(p, FMT_NIL_ANGLE);
+            else
+            {
+                fmt_writestr(p, type->name);
+                fmt_writebyte(p, '=');
+                fmt_printarg(p, type, args[i].data, 'v');
+            }
+        }
+        fmt_writebyte(p, ')');
+    }
+}
+
+static void fmt_doprint(fmt_printer_t p, const struct go_iface_s *args,
+    go_int nargs)
+{
+    bool prevstring = false;
+    for (go_int i = 0; i < nargs; i++)
+    {
+        go_type_t type = fmt_argtype(&args[i]);
+        bool isstring = type != NULL && type->kind == KIND_STRING;
+        // space between two non-string operands
+        if (i > 0 && !isstring && !prevstring)
+            fmt_writebyte(p, ' ');
+        fmt_printarg(p, type, args[i].data, 'v');
+        prevstring = isstring;
+    }
+}
+
+static void fmt_doprintln(fmt_printer_t p, const struct go_iface_s *args,
+    go_int nargs)
+{
+    for (go_int i = 0; i < nargs; i++)
+    {
+        if (i > 0)
+            fmt_writebyte(p, ' ');
+        fmt_printarg(p, fmt_argtype(&args[i]), args[i].data, 'v');
+    }
+    fmt_writebyte(p, '\n');
+}
+
+static void fmt_format(fmt_printer_t p, int32_t mode, const char *format,
+    go_int formatlen, const struct go_iface_s *args, go_int nargs)
+{
+    switch (mode)
+    {
+        case FMT_PRINT:
+            fmt_doprint(p, args, nargs);
+            break;
+        case FMT_PRINTLN:
+            fmt_doprintln(p, args, nargs);
+            break;
+        default:
+            fmt_doprintf(p, format, formatlen, args, nargs);
+    }
+}
+
+static struct go_string_s fmt_string(fmt_printer_t p)
+{
+    struct go_string_s s = {"", 0};
+    if (p->len > 0)
+    {
+        char *ptr = (char *)GC_malloc(p->len + 1);
+        memcpy(ptr, p->buf, p->len);
+        ptr[p->len] = '\0';
+        s.ptr = ptr;
+        s.len = (go_int)p->len;
+    }
+    free(p->buf);
+    return s;
+}
+
+extern go_int runtime_fprint(go_file_t f, int32_t mode, const char *format,
+    go_int formatlen, const struct go_iface_s *args, go_int nargs)
+{
+    struct fmt_printer_s p = {.wrapped = -1};
+    fmt_format(&p, mode, format, formatlen, args, nargs);
+    size_t n = p.len;
+    switch (f->fd)
+    {
+        case 1:
+            n = fwrite(p.buf, 1, p.len, stdout);
+            break;
+        case 2:
+            // keep order of output to terminal
+            fflush(stdout);
+            n = fwrite(p.buf, 1, p.len, stderr);
+            break;
+        default:
+            if (p.len > 0)
+            {
+                ssize_t written = write(f->fd, p.buf, p.len);
+                n = written < 0 ? 0 : (size_t)written;
+            }
+    }
+    free(p.buf);
+    return (go_int)n;
+}
+
+extern go_int runtime_print(int32_t mode, const char *format, go_int formatlen,
+    const struct go_iface_s *args, go_int nargs)
+{
+    return runtime_fprint(os__Stdout, mode, format, formatlen, args, nargs);
+}
+
+extern void runtime_sprint(struct go_string_s *res, int32_t mode,
+    const char *format, go_int formatlen, const struct go_iface_s *args,
+    go_int nargs)
+{
+    struct fmt_printer_s p = {.wrapped = -1};
+    fmt_format(&p, mode, format, formatlen, args, nargs);
+    *res = fmt_string(&p);
+}
+
+extern void runtime_errorf(struct go_iface_s *res, const char *format,
+    go_int formatlen, const struct go_iface_s *args, go_int nargs)
+{
+    struct fmt_printer_s p = {.wrapErrs = true, .wrapped = -1};
+    fmt_doprintf(&p, format, formatlen, args, nargs);
+    go_int wrapped = p.wrapped;
+
+    struct fmt_error_s *e =
+        (struct fmt_error_s *)GC_malloc(sizeof(struct fmt_error_s));
+    e->msg = fmt_string(&p);
+    e->err.tab = NULL;
+    e->err.data = NULL;
+    struct fmt_error_s **data =
+        (struct fmt_error_s **)GC_malloc(sizeof(struct fmt_error_s *));
+    *data = e;
+    res->data = data;
+    res->tab = &fmt_errorstringitab;
+    if (wrapped >= 0)
+    {
+        e->err = args[wrapped];
+        res->tab = &fmt_wraperroritab;
+    }
+}
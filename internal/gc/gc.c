@@ -19,7 +19,7 @@
 
 /*
  * This is a very simple conservative GC implementation for single-threaded
- * x86_64/AMD64.
+ * x86_64/AMD64.  Coroutine stacks are scanned with the help of GC_stacks().
  */
 
 #define NODEBUG
@@ -93,6 +93,8 @@ static void *gc_stackbottom;                    // Stack bottom.
 struct gc_region_s __gc_regions[GC_NUM_REGIONS] = {{0}};
 static void *gc_markstack;                      // Mark-stack.
 static gc_root_t gc_roots = NULL;               // All GC roots.
+static gc_stacks_func_t gc_stacks_func = NULL;  // Coroutine stacks callback.
+static gc_root_t gc_stack_roots = NULL;         // Coroutine stack roots.
 static gc_error_func_t gc_error_func = NULL;    // Memory error callback.
 
 // Timing and stats related:
@@ -386,6 +388,38 @@ extern bool GC_dynamic_root(void **ptrptr, size_t *sizeptr, size_t elemsize)
     return true;
 }
 
+/*
+ * GC stacks.
+ */
+extern void *GC_set_stackbottom(void *bottom)
+{
+    void *prev = gc_stackbottom;
+    gc_stackbottom = bottom;
+    return prev;
+}
+
+extern void GC_stacks(gc_stacks_func_t func)
+{
+    gc_stacks_func = func;
+}
+
+extern bool GC_stack_root(void *ptr, size_t size)
+{
+    if (size == 0)
+        return true;
+    gc_root_t root = (gc_root_t)malloc(sizeof(struct gc_root_s));
+    if (root == NULL)
+        return false;
+    root->ptr = ptr;
+    root->size = size;
+    root->ptrptr = &root->ptr;
+    root->sizeptr = &root->size;
+    root->elemsize = 1;
+    root->next = gc_stack_roots;
+    gc_stack_roots = root;
+    return true;
+}
+
 /*
  * Add a root to the global list.
  */
@@ -585,8 +619,23 @@ extern void GC_collect(void)
     root->next = gc_roots;
     gc_root_t roots = root;
 
+    // Stacks of suspended coroutines are temporary roots.
+    if (gc_stacks_func != NULL)
+    {
+        gc_stack_roots = gc_roots;
+        gc_stacks_func();
+        root->next = gc_stack_roots;
+    }
+
     gc_mark(roots);
     gc_sweep();
+
+    while (gc_stacks_func != NULL && gc_stack_roots != gc_roots)
+    {
+        gc_root_t next = gc_stack_roots->next;
+        free(gc_stack_roots);
+        gc_stack_roots = next;
+    }
 }
 
 /*
@@ -372,6 +372,23 @@ extern bool GC_root(void *ptr, size_t size);
 extern bool GC_dynamic_root(void **ptrptr, size_t *sizeptr, size_t elemsize);
 #define gc_dynamic_root     GC_dynamic_root
 
+/*
+ * GC stacks.
+ *
+ * By default, the GC only scans the stack of the main thread.  Coroutines
+ * running on their own stacks must set the bottom of the current stack on
+ * each switch (the bottom of the previous stack is returned), and provide a
+ * function that registers stacks of suspended coroutines with GC_stack_root()
+ * during collection.  The registered range is [ptr .. ptr+size].
+ */
+typedef void (*gc_stacks_func_t)(void);
+extern void *GC_set_stackbottom(void *bottom);
+extern void GC_stacks(gc_stacks_func_t func);
+extern bool GC_stack_root(void *ptr, size_t size);
+#define gc_set_stackbottom  GC_set_stackbottom
+#define gc_stacks           GC_stacks
+#define gc_stack_root       GC_stack_root
+
 /*
  * GC memory allocation.
  *
@@ -0,0 +1,152 @@
+/*
+ * iface.c
+ *
+ * Interface support routines.
+ *
+ * Itabs for conversions of concrete types are generated by the compiler.
+ * Itabs for conversions between interfaces are built here from method
+ * tables of type descriptors and cached forever.
+ */
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "runtime.h"
+
+struct itab_cache_s
+{
+    go_type_t type;
+    go_ifacedesc_t iface;
+    go_itab_t itab;
+    struct itab_cache_s *next;
+};
+
+static struct itab_cache_s *itab_cache = NULL;
+
+#define iface_panic(format, ...) \
+    runtime_panicerror("interface conversion: " format, __VA_ARGS__)
+
+static const struct go_method_s *iface_find_method(go_type_t type,
+    const struct go_imethod_s *imethod)
+{
+    int32_t lo = 0, hi = type->nmethods;
+    while (lo < hi)
+    {
+        int32_t mid = lo + (hi - lo) / 2;
+        int cmp = strcmp(type->methods[mid].name, imethod->name);
+        if (cmp == 0)
+        {
+            if (strcmp(type->methods[mid].sig, imethod->sig) != 0)
+                return NULL;
+            return &type->methods[mid];
+        }
+        else if (cmp < 0)
+            lo = mid + 1;
+        else
+            hi = mid;
+    }
+    return NULL;
+}
+
+/*
+ * Find or build itab of 'type' for 'iface'.  Returns NULL and sets missing
+ * method name, if type does not implement interface.
+ */
+static go_itab_t iface_getitab(go_type_t type, go_ifacedesc_t iface,
+    const char **missing)
+{
+    for (struct itab_cache_s *entry = itab_cache; entry != NULL;
+            entry = entry->next)
+    {
+        if (entry->type == type && entry->iface == iface)
+            return entry->itab;
+    }
+
+    // Itabs are never freed, so they are not allocated by GC.
+    struct go_itab_s *itab = (struct go_itab_s *)malloc(
+        sizeof(struct go_itab_s) + iface->nmethods * sizeof(void *));
+    if (itab == NULL)
+    {
+        fputs("fatal error: out of memory\n", stderr);
+        exit(2);
+    }
+    itab->type = type;
+    for (int32_t i = 0; i < iface->nmethods; i++)
+    {
+        const struct go_method_s *method = iface_find_method(type,
+            &iface->methods[i]);
+        if (method == NULL)
+        {
+            free(itab);
+            *missing = iface->methods[i].name;
+            return NULL;
+        }
+        itab->fns[i] = method->fn;
+    }
+
+    struct itab_cache_s *entry = (struct itab_cache_s *)malloc(
+        sizeof(struct itab_cache_s));
+    if (entry == NULL)
+    {
+        fputs("fatal error: out of memory\n", stderr);
+        exit(2);
+    }
+    entry->type  = type;
+    entry->iface = iface;
+    entry->itab  = itab;
+    entry->next  = itab_cache;
+    itab_cache   = entry;
+    return itab;
+}
+
+extern go_itab_t runtime_convI2I(go_itab_t tab, go_ifacedesc_t iface)
+{
+    if (tab == NULL)
+        return NULL;
+    const char *missing = NULL;
+    go_itab_t itab = iface_getitab(tab->type, iface, &missing);
+    if (itab == NULL)
+        iface_panic("%s is not %s: missing method %s", tab->type->name,
+            iface->name, missing);
+    return itab;
+}
+
+extern go_itab_t runtime_assertI2I(go_itab_t tab, go_ifacedesc_t iface,
+    bool canfail)
+{
+    if (tab == NULL)
+    {
+        if (canfail)
+            return NULL;
+        iface_panic("interface is nil, not %s", iface->name);
+    }
+    const char *missing = NULL;
+    go_itab_t itab = iface_getitab(tab->type, iface, &missing);
+    if (itab == NULL && !canfail)
+        iface_panic("%s is not %s: missing method %s", tab->type->name,
+            iface->name, missing);
+    return itab;
+}
+
+extern void runtime_panicassert(go_type_t have, go_type_t want,
+    const char *iface)
+{
+    if (have == NULL)
+        iface_panic("%s is nil, not %s", iface, want->name);
+    iface_panic("%s is %s, not %s", iface, have->name, want->name);
+}
+
+extern bool runtime_ifaceeq(go_itab_t tab1, const void *data1,
+    go_itab_t tab2, const void *data2)
+{
+    if (tab1 == NULL || tab2 == NULL)
+        return tab1 == tab2;
+    go_type_t type = tab1->type;
+    if (type != tab2->type)
+        return false;
+    if (type->keydesc == NULL)
+        runtime_panicerror("runtime error: comparing uncomparable type %s",
+            type->name);
+    return runtime_keyequal(type->keydesc, data1, data2);
+}
@@ -0,0 +1,311 @@
+/*
+ * map.c
+ *
+ * Hash table implementation of Go maps.
+ *
+ * Entries are allocated separately and chained in buckets, so pointers to
+ * values returned by runtime_mapassign() stay valid when table grows.
+ */
+
+#include <stdbool.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "runtime.h"
+
+#define MAP_MIN_BUCKETS     8
+#define MAP_ALIGN(size)     (((size) + 7) & ~(size_t)7)
+
+struct map_entry_s
+{
+    struct map_entry_s *next;
+    uint64_t hash;
+    char data[];                // Key followed by value.
+};
+typedef struct map_entry_s *map_entry_t;
+
+struct go_map_s
+{
+    const int32_t *keydesc;
+    size_t keysize;
+    size_t valsize;
+    go_int count;
+    size_t nbuckets;
+    map_entry_t *buckets;
+};
+
+/*
+ * Hashing with FNV-1a.
+ */
+#define FNV_OFFSET          ((uint64_t)14695981039346656037ULL)
+#define FNV_PRIME           ((uint64_t)1099511628211ULL)
+
+static uint64_t map_hash_bytes(uint64_t hash, const void *ptr, size_t size)
+{
+    const uint8_t *bytes = (const uint8_t *)ptr;
+    for (size_t i = 0; i < size; i++)
+    {
+        hash ^= bytes[i];
+        hash *= FNV_PRIME;
+    }
+    return hash;
+}
+
+static void map_panic_unhashable(go_type_t type)
+{
+    runtime_panicerror("runtime error: hash of unhashable type %s",
+        type->name);
+}
+
+static uint64_t map_hash_fields(uint64_t hash, const int32_t *keydesc,
+    const void *key)
+{
+    const char *base = (const char *)key;
+    int32_t n = keydesc[0];
+    for (int32_t i = 0; i < n; i++)
+    {
+        const int32_t *field = keydesc + 1 + 3*i;
+        const char *ptr = base + field[1];
+        switch (field[0])
+        {
+            case MAP_KEY_MEM:
+                hash = map_hash_bytes(hash, ptr, (size_t)field[2]);
+                break;
+            case MAP_KEY_FLOAT32:
+            {
+                float f;
+                memcpy(&f, ptr, sizeof(f));
+                if (f == 0)
+                    f = 0;          // +0 and -0 are equal
+                hash = map_hash_bytes(hash, &f, sizeof(f));
+                break;
+            }
+            case MAP_KEY_FLOAT64:
+            {
+                double d;
+                memcpy(&d, ptr, sizeof(d));
+                if (d == 0)
+                    d = 0;          // +0 and -0 are equal
+                hash = map_hash_bytes(hash, &d, sizeof(d));
+                break;
+            }
+            case MAP_KEY_STRING:
+            {
+                struct go_string_s str;
+                memcpy(&str, ptr, sizeof(str));
+                hash = map_hash_bytes(hash, str.ptr, (size_t)str.len);
+                hash = map_hash_bytes(hash, &str.len, sizeof(str.len));
+                break;
+            }
+            case MAP_KEY_IFACE:
+            {
+                struct go_iface_s iface;
+                memcpy(&iface, ptr, sizeof(iface));
+                if (iface.tab == NULL)
+                {
+                    hash = map_hash_bytes(hash, "", 1);
+                    break;
+                }
+                go_type_t type = iface.tab->type;
+                if (type->keydesc == NULL)
+                    map_panic_unhashable(type);
+                hash = map_hash_bytes(hash, &type, sizeof(type));
+                hash = map_hash_fields(hash, type->keydesc, iface.data);
+                break;
+            }
+        }
+    }
+    return hash;
+}
+
+static uint64_t map_hash_key(const int32_t *keydesc, const void *key)
+{
+    return map_hash_fields(FNV_OFFSET, keydesc, key);
+}
+
+extern bool runtime_keyequal(const int32_t *keydesc, const void *key1,
+    const void *key2)
+{
+    const char *base1 = (const char *)key1, *base2 = (const char *)key2;
+    int32_t n = keydesc[0];
+    for (int32_t i = 0; i < n; i++)
+    {
+        const int32_t *field = keydesc + 1 + 3*i;
+        const char *ptr1 = base1 + field[1], *ptr2 = base2 + field[1];
+        switch (field[0])
+        {
+            case MAP_KEY_MEM:
+                if (memcmp(ptr1, ptr2, (size_t)field[2]) != 0)
+                    return false;
+                break;
+            case MAP_KEY_FLOAT32:
+            {
+                float f1, f2;
+                memcpy(&f1, ptr1, sizeof(f1));
+                memcpy(&f2, ptr2, sizeof(f2));
+                if (f1 != f2)
+                    return false;
+                break;
+            }
+            case MAP_KEY_FLOAT64:
+            {
+                double d1, d2;
+                memcpy(&d1, ptr1, sizeof(d1));
+                memcpy(&d2, ptr2, sizeof(d2));
+                if (d1 != d2)
+                    return false;
+                break;
+            }
+            case MAP_KEY_STRING:
+            {
+                struct go_string_s s1, s2;
+                memcpy(&s1, ptr1, sizeof(s1));
+                memcpy(&s2, ptr2, sizeof(s2));
+                if (runtime_cmpstring(s1.ptr, s1.len, s2.ptr, s2.len) != 0)
+                    return false;
+                break;
+            }
+            case MAP_KEY_IFACE:
+            {
+                struct go_iface_s i1, i2;
+                memcpy(&i1, ptr1, sizeof(i1));
+                memcpy(&i2, ptr2, sizeof(i2));
+                if (!runtime_ifaceeq(i1.tab, i1.data, i2.tab, i2.data))
+                    return false;
+                break;
+            }
+        }
+    }
+    return true;
+}
+
+static map_entry_t *map_alloc_buckets(size_t nbuckets)
+{
+    size_t size = nbuckets * sizeof(map_entry_t);
+    map_entry_t *buckets = (map_entry_t *)GC_malloc(size);
+    memset(buckets, 0, size);
+    return buckets;
+}
+
+static void map_grow(go_map_t m)
+{
+    size_t nbuckets = 2 * m->nbuckets;
+    map_entry_t *buckets = map_alloc_buckets(nbuckets);
+    for (size_t i = 0; i < m->nbuckets; i++)
+    {
+        map_entry_t entry = m->buckets[i];
+        while (entry != NULL)
+        {
+            map_entry_t next = entry->next;
+            size_t idx = entry->hash & (nbuckets - 1);
+            entry->next = buckets[idx];
+            buckets[idx] = entry;
+            entry = next;
+        }
+    }
+    m->nbuckets = nbuckets;
+    m->buckets  = buckets;
+}
+
+static map_entry_t map_lookup(go_map_t m, const void *key, uint64_t hash)
+{
+    map_entry_t entry = m->buckets[hash & (m->nbuckets - 1)];
+    for (; entry != NULL; entry = entry->next)
+    {
+        if (entry->hash == hash &&
+                runtime_keyequal(m->keydesc, entry->data, key))
+            return entry;
+    }
+    return NULL;
+}
+
+extern go_map_t runtime_makemap(const int32_t *keydesc, int64_t keysize,
+    int64_t valsize, go_int hint)
+{
+    go_map_t m = (go_map_t)GC_malloc(sizeof(struct go_map_s));
+    m->keydesc  = keydesc;
+    m->keysize  = (size_t)keysize;
+    m->valsize  = (size_t)valsize;
+    m->count    = 0;
+    m->nbuckets = MAP_MIN_BUCKETS;
+    while (hint > 0 && m->nbuckets < (size_t)hint)
+        m->nbuckets *= 2;
+    m->buckets  = map_alloc_buckets(m->nbuckets);
+    return m;
+}
+
+extern void *runtime_mapaccess(go_map_t m, const void *key)
+{
+    if (m == NULL || m->count == 0)
+        return NULL;
+    map_entry_t entry = map_lookup(m, key, map_hash_key(m->keydesc, key));
+    if (entry == NULL)
+        return NULL;
+    return entry->data + MAP_ALIGN(m->keysize);
+}
+
+extern void *runtime_mapassign(go_map_t m, const void *key)
+{
+    if (m == NULL)
+        runtime_panicerror("assignment to entry in nil map");
+    uint64_t hash = map_hash_key(m->keydesc, key);
+    map_entry_t entry = map_lookup(m, key, hash);
+    if (entry == NULL)
+    {
+        if ((size_t)m->count >= m->nbuckets)
+            map_grow(m);
+        size_t size = sizeof(struct map_entry_s) + MAP_ALIGN(m->keysize) +
+            m->valsize;
+        entry = (map_entry_t)GC_malloc(size);
+        memset(entry, 0, size);
+        entry->hash = hash;
+        memcpy(entry->data, key, m->keysize);
+        size_t idx = hash & (m->nbuckets - 1);
+        entry->next = m->buckets[idx];
+        m->buckets[idx] = entry;
+        m->count++;
+    }
+    return entry->data + MAP_ALIGN(m->keysize);
+}
+
+extern void runtime_mapdelete(go_map_t m, const void *key)
+{
+    if (m == NULL || m->count == 0)
+        return;
+    uint64_t hash = map_hash_key(m->keydesc, key);
+    map_entry_t *prev = &m->buckets[hash & (m->nbuckets - 1)];
+    for (map_entry_t entry = *prev; entry != NULL; entry = entry->next)
+    {
+        if (entry->hash == hash &&
+                runtime_keyequal(m->keydesc, entry->data, key))
+        {
+            *prev = entry->next;
+            m->count--;
+            return;
+        }
+        prev = &entry->next;
+    }
+}
+
+extern go_int runtime_maplen(go_map_t m)
+{
+    return (m == NULL? 0: m->count);
+}
+
+extern void runtime_mapentries(go_map_t m, void **keys, void **vals)
+{
+    if (m == NULL)
+        return;
+    go_int n = 0;
+    for (size_t i = 0; i < m->nbuckets; i++)
+    {
+        for (map_entry_t entry = m->buckets[i]; entry != NULL;
+                entry = entry->next)
+        {
+            keys[n] = entry->data;
+            vals[n] = entry->data + MAP_ALIGN(m->keysize);
+            n++;
+        }
+    }
+}
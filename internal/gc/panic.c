@@ -0,0 +1,287 @@
+/*
+ * panic.c
+ *
+ * Panic and recover support routines.
+ *
+ * Panic jumps to innermost frame of current goroutine, which runs deferred
+ * calls of its function and then either returns normally, if panic was
+ * recovered, or continues panicking in the next frame.
+ *
+ * Faults of nil pointer dereference and call of nil func value are caught
+ * by SIGSEGV handler, which panics with runtime error.
+ */
+
+#include <signal.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "runtime.h"
+
+_Static_assert(sizeof(struct go_frame_s) <= GO_FRAME_SIZE,
+    "frame does not fit in GO_FRAME_SIZE");
+
+/*
+ * Runtime errors are values of type implementing error interface, which
+ * holds message as string.
+ */
+static struct go_string_s panic_error_Error(const struct go_string_s *msg)
+{
+    return *msg;
+}
+
+static const struct go_type_s panic_errortype =
+{
+    .name     = "runtime.Error",
+    .keydesc  = NULL,
+    .kind     = KIND_STRING,
+    .size     = sizeof(struct go_string_s),
+    .nmethods = 1,
+    .methods  = {{"Error", "func() string", (void *)panic_error_Error}},
+};
+
+static const struct go_itab_s panic_erroritab = {.type = &panic_errortype};
+
+extern void runtime_pushframe(go_frame_t f)
+{
+    struct go_pstate_s *ps = runtime_pstate();
+    f->prev = ps->frames;
+    ps->frames = f;
+}
+
+extern void runtime_popframe(go_frame_t f)
+{
+    runtime_pstate()->frames = f->prev;
+}
+
+/*
+ * Find method of type, which returns string, like Error() or String().
+ */
+static struct go_string_s (*panic_strmethod(go_type_t type,
+    const char *name))(void *)
+{
+    for (int32_t i = 0; i < type->nmethods; i++)
+    {
+        if (strcmp(type->methods[i].name, name) == 0 &&
+                strcmp(type->methods[i].sig, "func() string") == 0)
+            return (struct go_string_s (*)(void *))type->methods[i].fn;
+    }
+    return NULL;
+}
+
+static void panic_printfloat(double f, bool single)
+{
+    // shortest representation, which reads back as the same value
+    char buf[64];
+    for (int prec = 1; prec <= 17; prec++)
+    {
+        snprintf(buf, sizeof(buf), "%.*g", prec, f);
+        if (single ? strtof(buf, NULL) == (float)f : strtod(buf, NULL) == f)
+            break;
+    }
+    fputs(buf, stderr);
+}
+
+static void panic_printstring(struct go_string_s s)
+{
+    fwrite(s.ptr, 1, (size_t)s.len, stderr);
+}
+
+static void panic_printvalue(const struct go_iface_s *arg)
+{
+    if (arg->tab == NULL)
+    {
+        fputs("nil", stderr);
+        return;
+    }
+    go_type_t type = arg->tab->type;
+    struct go_string_s (*str)(void *) = panic_strmethod(type, "Error");
+    if (str == NULL)
+        str = panic_strmethod(type, "String");
+    if (str != NULL)
+    {
+        panic_printstring(str(arg->data));
+        return;
+    }
+
+    const char *name = type->name;
+    const void *data = arg->data;
+    if (strcmp(name, "string") == 0)
+        panic_printstring(*(const struct go_string_s *)data);
+    else if (strcmp(name, "bool") == 0)
+        fputs(*(const bool *)data ? "true" : "false", stderr);
+    else if (strcmp(name, "int") == 0 || strcmp(name, "int32") == 0)
+        fprintf(stderr, "%d", (int)*(const int32_t *)data);
+    else if (strcmp(name, "int8") == 0)
+        fprintf(stderr, "%d", (int)*(const int8_t *)data);
+    else if (strcmp(name, "int16") == 0)
+        fprintf(stderr, "%d", (int)*(const int16_t *)data);
+    else if (strcmp(name, "int64") == 0)
+        fprintf(stderr, "%ld", (long)*(const int64_t *)data);
+    else if (strcmp(name, "uint8") == 0)
+        fprintf(stderr, "%u", (unsigned)*(const uint8_t *)data);
+    else if (strcmp(name, "uint16") == 0)
+        fprintf(stderr, "%u", (unsigned)*(const uint16_t *)data);
+    else if (strcmp(name, "uint32") == 0)
+        fprintf(stderr, "%u", (unsigned)*(const uint32_t *)data);
+    else if (strcmp(name, "uint64") == 0)
+        fprintf(stderr, "%lu", (unsigned long)*(const uint64_t *)data);
+    else if (strcmp(name, "float32") == 0)
+        panic_printfloat(*(const float *)data, true);
+    else if (strcmp(name, "float64") == 0)
+        panic_printfloat(*(const double *)data, false);
+    else
+        fprintf(stderr, "(%s) %p", name, data);
+}
+
+static void panic_printpanics(go_panic_t p)
+{
+    if (p->link != NULL)
+    {
+        panic_printpanics(p->link);
+        fputc('\t', stderr);
+    }
+    fputs("panic: ", stderr);
+    panic_printvalue(&p->arg);
+    if (p->recovered)
+        fputs(" [recovered]", stderr);
+    fputc('\n', stderr);
+}
+
+/*
+ * Jump to innermost frame, or exit if there are no frames.
+ */
+static void __attribute__((__noreturn__)) panic_jump(struct go_pstate_s *ps)
+{
+    if (ps->frames != NULL)
+        longjmp(ps->frames->buf, 1);
+    fflush(stdout);
+    panic_printpanics(ps->panic);
+    exit(2);
+}
+
+extern void runtime_unwind(go_frame_t f)
+{
+    struct go_pstate_s *ps = runtime_pstate();
+    ps->frames = f->prev;
+    if (ps->panic->recovered)
+    {
+        ps->panic = NULL;
+        return;
+    }
+    panic_jump(ps);
+}
+
+extern void runtime_gopanic(go_itab_t tab, void *data)
+{
+    if (tab == NULL)
+        runtime_panicerror("panic called with nil argument");
+    struct go_pstate_s *ps = runtime_pstate();
+    go_panic_t p = (go_panic_t)GC_malloc(sizeof(struct go_panic_s));
+    p->arg.tab = tab;
+    p->arg.data = data;
+    p->recovered = false;
+    // recovered panic is over, even if its frame is not unwound yet
+    p->link = ps->panic != NULL && !ps->panic->recovered ? ps->panic : NULL;
+    ps->panic = p;
+    panic_jump(ps);
+}
+
+extern void runtime_panicerror(const char *format, ...)
+{
+    va_list args;
+    va_start(args, format);
+    int len = vsnprintf(NULL, 0, format, args);
+    va_end(args);
+    char *msg = (char *)GC_malloc((size_t)len + 1);
+    va_start(args, format);
+    vsnprintf(msg, (size_t)len + 1, format, args);
+    va_end(args);
+
+    struct go_string_s *data =
+        (struct go_string_s *)GC_malloc(sizeof(struct go_string_s));
+    data->ptr = msg;
+    data->len = len;
+    runtime_gopanic(&panic_erroritab, data);
+}
+
+extern void runtime_panicshift(void)
+{
+    runtime_panicerror("runtime error: negative shift amount");
+}
+
+extern void runtime_panicdivide(void)
+{
+    runtime_panicerror("runtime error: integer divide by zero");
+}
+
+extern void runtime_panicbounds(int32_t kind, go_int x, go_int y)
+{
+    // messages of failed checks, y is not reported for negative x
+    static const char *const formats[][2] =
+    {
+        {"runtime error: index out of range [%d] with length %d",
+            "runtime error: index out of range [%d]"},
+        {"runtime error: slice bounds out of range [:%d] with length %d",
+            "runtime error: slice bounds out of range [:%d]"},
+        {"runtime error: slice bounds out of range [:%d] with capacity %d",
+            "runtime error: slice bounds out of range [:%d]"},
+        {"runtime error: slice bounds out of range [%d:%d]",
+            "runtime error: slice bounds out of range [%d:]"},
+        {"runtime error: slice bounds out of range [::%d] with length %d",
+            "runtime error: slice bounds out of range [::%d]"},
+        {"runtime error: slice bounds out of range [::%d] with capacity %d",
+            "runtime error: slice bounds out of range [::%d]"},
+        {"runtime error: slice bounds out of range [:%d:%d]",
+            "runtime error: slice bounds out of range [:%d:]"},
+        {"runtime error: slice bounds out of range [%d:%d:]",
+            "runtime error: slice bounds out of range [%d::]"},
+    };
+    runtime_panicerror(formats[kind][x < 0], (int)x, (int)y);
+}
+
+extern void runtime_recover(struct go_iface_s *res)
+{
+    go_panic_t p = runtime_pstate()->panic;
+    if (p == NULL || p->recovered)
+    {
+        res->tab = NULL;
+        res->data = NULL;
+        return;
+    }
+    p->recovered = true;
+    *res = p->arg;
+}
+
+/*
+ * Addresses in first page are reached through nil pointer, other faults
+ * are fatal.
+ */
+#define PANIC_NILPAGE       4096
+
+static void panic_sigsegv(int sig, siginfo_t *info, void *uctx)
+{
+    (void)sig;
+    (void)uctx;
+    if ((uintptr_t)info->si_addr >= PANIC_NILPAGE)
+    {
+        fflush(stdout);
+        fprintf(stderr, "unexpected fault address %p\nfatal error: fault\n",
+            info->si_addr);
+        exit(2);
+    }
+    // handler is left by longjmp() of panic, so signal must not be blocked
+    runtime_panicerror(
+        "runtime error: invalid memory address or nil pointer dereference");
+}
+
+static void __attribute__((__constructor__)) panic_init(void)
+{
+    struct sigaction act;
+    memset(&act, 0, sizeof(act));
+    act.sa_sigaction = panic_sigsegv;
+    act.sa_flags = SA_SIGINFO | SA_NODEFER;
+    sigemptyset(&act.sa_mask);
+    sigaction(SIGSEGV, &act, NULL);
+}
@@ -0,0 +1,525 @@
+/*
+ * runtime.h
+ *
+ * Support routines called from code generated by gocomp.
+ */
+
+#ifndef __RUNTIME_H
+#define __RUNTIME_H
+
+#include <setjmp.h>
+#include <stdbool.h>
+#include <stddef.h>
+#include <stdint.h>
+
+/*
+ * Go 'int' type as lowered by the compiler.
+ */
+typedef int32_t go_int;
+
+/*
+ * String header.  Byte after the end of non-empty string is readable, strings
+ * created by runtime are terminated by NUL.
+ */
+struct go_string_s
+{
+    const char *ptr;
+    go_int len;
+};
+
+/*
+ * GC allocation and stack registration (see gc.h, which can not be included
+ * more than once).
+ */
+extern void *GC_malloc(size_t size);
+extern void *GC_set_stackbottom(void *bottom);
+extern void GC_stacks(void (*func)(void));
+extern bool GC_stack_root(void *ptr, size_t size);
+
+/*
+ * Allocate zeroed backing array for 'cap' elements of size 'elemsize'.
+ */
+extern void *runtime_makeslice(int64_t elemsize, go_int cap);
+
+/*
+ * Compute new capacity of slice with capacity 'cap', that must hold at least
+ * 'newlen' elements.
+ */
+extern go_int runtime_growcap(go_int cap, go_int newlen);
+
+/*
+ * Copy min(dstlen, srclen) elements from 'src' to 'dst'.  Memory regions
+ * may overlap.  Returns number of elements copied.
+ */
+extern go_int runtime_slicecopy(void *dst, go_int dstlen, const void *src,
+    go_int srclen, int64_t elemsize);
+
+/*
+ * Map key descriptor is an array of int32 values: number of key fields,
+ * followed by (kind, offset, size) triple for each field.  Padding bytes
+ * are never described, so they do not affect hashing and comparison.
+ */
+#define MAP_KEY_MEM         0   // Compared byte-by-byte.
+#define MAP_KEY_FLOAT32     1
+#define MAP_KEY_FLOAT64     2
+#define MAP_KEY_STRING      3   // String header, see go_string_s.
+#define MAP_KEY_IFACE       4   // Interface value, see go_iface_s.
+
+typedef struct go_map_s *go_map_t;
+
+/*
+ * Allocate map with given key descriptor and sizes of keys and values.
+ */
+extern go_map_t runtime_makemap(const int32_t *keydesc, int64_t keysize,
+    int64_t valsize, go_int hint);
+
+/*
+ * Find value stored for 'key'.  Returns NULL if there is no such key.
+ */
+extern void *runtime_mapaccess(go_map_t m, const void *key);
+
+/*
+ * Find value stored for 'key', inserting zero value if there is no such key.
+ * Panics if map is nil.
+ */
+extern void *runtime_mapassign(go_map_t m, const void *key);
+
+/*
+ * Remove 'key' from map.  Does nothing if map is nil or has no such key.
+ */
+extern void runtime_mapdelete(go_map_t m, const void *key);
+
+/*
+ * Number of keys in map.
+ */
+extern go_int runtime_maplen(go_map_t m);
+
+/*
+ * Store pointers to keys of map in 'keys' and pointers to their values in
+ * 'vals', which have room for runtime_maplen() entries.  Entries are stored
+ * in unspecified order.
+ */
+extern void runtime_mapentries(go_map_t m, void **keys, void **vals);
+
+/*
+ * Compare keys described by 'keydesc' for equality.
+ */
+extern bool runtime_keyequal(const int32_t *keydesc, const void *key1,
+    const void *key2);
+
+/*
+ * Type descriptor, generated by compiler for each dynamic type of interface
+ * values and for types of their elements and fields.  Methods are sorted by
+ * name and take pointer to receiver value (the data word of interface value)
+ * as first argument.
+ */
+#define KIND_BOOL           1
+#define KIND_INT            2   // Signed integer of any size.
+#define KIND_UINT           3   // Unsigned integer of any size.
+#define KIND_FLOAT          4
+#define KIND_STRING         5
+#define KIND_POINTER        6   // Elem is NULL for unsafe pointers.
+#define KIND_SLICE          7
+#define KIND_ARRAY          8
+#define KIND_STRUCT         9
+#define KIND_MAP            10
+#define KIND_CHAN           11
+#define KIND_FUNC           12
+#define KIND_IFACE          13
+
+typedef const struct go_type_s *go_type_t;
+
+struct go_method_s
+{
+    const char *name;
+    const char *sig;            // Signature, like "func(int) string".
+    void *fn;
+};
+
+struct go_field_s
+{
+    const char *name;
+    go_type_t type;
+    int64_t offset;
+};
+
+struct go_type_s
+{
+    const char *name;
+    const int32_t *keydesc;     // NULL if type is not comparable.
+    int32_t kind;
+    int64_t size;
+    go_type_t elem;             // Element of pointer, slice, array, map, chan.
+    go_type_t key;              // Key of map.
+    int64_t len;                // Length of array.
+    int32_t nfields;
+    const struct go_field_s *fields;
+    int32_t nmethods;
+    struct go_method_s methods[];
+};
+
+/*
+ * Interface descriptor, used to build itabs at runtime.  Methods are sorted
+ * by name.
+ */
+struct go_imethod_s
+{
+    const char *name;
+    const char *sig;
+};
+
+struct go_ifacedesc_s
+{
+    const char *name;
+    int32_t nmethods;
+    struct go_imethod_s methods[];
+};
+typedef const struct go_ifacedesc_s *go_ifacedesc_t;
+
+/*
+ * Itab holds dynamic type of interface value and its methods in the order of
+ * interface methods.
+ */
+struct go_itab_s
+{
+    go_type_t type;
+    void *fns[];
+};
+typedef const struct go_itab_s *go_itab_t;
+
+/*
+ * Interface value.  Data points to a copy of dynamic value.
+ */
+struct go_iface_s
+{
+    go_itab_t tab;              // NULL for nil interface.
+    void *data;
+};
+
+/*
+ * Convert interface value with itab 'tab' to interface 'iface', which has
+ * subset of its methods.
+ */
+extern go_itab_t runtime_convI2I(go_itab_t tab, go_ifacedesc_t iface);
+
+/*
+ * Itab for type assertion to interface 'iface'.  Returns NULL on failure if
+ * 'canfail' is set, panics otherwise.
+ */
+extern go_itab_t runtime_assertI2I(go_itab_t tab, go_ifacedesc_t iface,
+    bool canfail);
+
+/*
+ * Panic on failed type assertion of interface value of static type 'iface'
+ * with dynamic type 'have' (NULL for nil value) to type 'want'.
+ */
+extern void runtime_panicassert(go_type_t have, go_type_t want,
+    const char *iface) __attribute__((__noreturn__));
+
+/*
+ * Compare interface values.  Panics if dynamic type is not comparable.
+ */
+extern bool runtime_ifaceeq(go_itab_t tab1, const void *data1,
+    go_itab_t tab2, const void *data2);
+
+/*
+ * Panics.  Function with deferred calls registers frame on entry and jumps
+ * back to it from panic to run deferred calls.  Frame lives on stack of the
+ * function, which allocates GO_FRAME_SIZE bytes aligned to 16 for it.
+ */
+#define GO_FRAME_SIZE       256
+
+typedef struct go_frame_s *go_frame_t;
+typedef struct go_panic_s *go_panic_t;
+
+struct go_frame_s
+{
+    jmp_buf buf;                // Passed to setjmp() by function.
+    go_frame_t prev;
+};
+
+struct go_panic_s
+{
+    struct go_iface_s arg;      // Value of empty interface type.
+    bool recovered;
+    go_panic_t link;            // Earlier panic, replaced by this one.
+};
+
+/*
+ * Panic state of goroutine.
+ */
+struct go_pstate_s
+{
+    go_frame_t frames;          // Innermost frame first.
+    go_panic_t panic;           // NULL if goroutine is not panicking.
+};
+
+extern struct go_pstate_s *runtime_pstate(void);
+
+/*
+ * Register frame of function on entry, and remove it on return.
+ */
+extern void runtime_pushframe(go_frame_t f);
+extern void runtime_popframe(go_frame_t f);
+
+/*
+ * Remove frame after its deferred calls are run by panic.  Returns if panic
+ * is recovered, continues panicking in the next frame otherwise.
+ */
+extern void runtime_unwind(go_frame_t f);
+
+/*
+ * Start panicking with value 'arg' of empty interface type.  Goroutine
+ * without frames prints panic value and exits the program.
+ */
+extern void runtime_gopanic(go_itab_t tab, void *data)
+    __attribute__((__noreturn__));
+
+/*
+ * Panic with runtime error, message is formatted like printf().
+ */
+extern void runtime_panicerror(const char *format, ...)
+    __attribute__((__noreturn__, __format__(__printf__, 1, 2)));
+
+/*
+ * Panic with runtime error of shift by negative amount.
+ */
+extern void runtime_panicshift(void) __attribute__((__noreturn__));
+
+/*
+ * Panic with runtime error of integer division by zero.
+ */
+extern void runtime_panicdivide(void) __attribute__((__noreturn__));
+
+/*
+ * Kinds of failed bounds checks of index and slice expressions.
+ */
+enum
+{
+    BOUNDS_INDEX,       // s[x], 0 <= x < y failed
+    BOUNDS_SLICE_ALEN,  // s[?:x], 0 <= x <= y failed, y is length
+    BOUNDS_SLICE_ACAP,  // s[?:x], 0 <= x <= y failed, y is capacity
+    BOUNDS_SLICE_B,     // s[x:y], 0 <= x <= y failed
+    BOUNDS_SLICE3_ALEN, // s[?:?:x], 0 <= x <= y failed, y is length
+    BOUNDS_SLICE3_ACAP, // s[?:?:x], 0 <= x <= y failed, y is capacity
+    BOUNDS_SLICE3_B,    // s[?:x:y], 0 <= x <= y failed
+    BOUNDS_SLICE3_C,    // s[x:y:?], 0 <= x <= y failed
+};
+
+/*
+ * Panic with runtime error of index or slice expression out of range.
+ */
+extern void runtime_panicbounds(int32_t kind, go_int x, go_int y)
+    __attribute__((__noreturn__));
+
+/*
+ * Stop panicking and store panic value in 'res'.  Stores nil interface value
+ * if goroutine is not panicking.
+ */
+extern void runtime_recover(struct go_iface_s *res);
+
+#define RUNE_ERROR          0xFFFD
+#define RUNE_MAXWIDTH       4
+
+/*
+ * Decode UTF-8 encoded rune at byte position 'pos' of string of length 'len'.
+ * Invalid encodings are decoded as U+FFFD of width 1.  Returns position of
+ * next rune.
+ */
+extern go_int runtime_decoderune(const char *s, go_int len, go_int pos,
+    int32_t *rune);
+
+/*
+ * Encode rune as UTF-8 into 'p', which has room for RUNE_MAXWIDTH bytes.
+ * Invalid code points are encoded as U+FFFD.  Returns number of bytes written.
+ */
+extern go_int runtime_encoderune(char *p, int64_t r);
+
+/*
+ * Store concatenation of strings 's1' and 's2' in 'res'.
+ */
+extern void runtime_concatstrings(struct go_string_s *res, const char *s1,
+    go_int len1, const char *s2, go_int len2);
+
+/*
+ * Compare strings lexically by bytes.  Returns negative number, zero or
+ * positive number, if 's1' is less than, equal to or greater than 's2'.
+ */
+extern go_int runtime_cmpstring(const char *s1, go_int len1, const char *s2,
+    go_int len2);
+
+/*
+ * Return NUL-terminated string for C library, which is copied if string is
+ * not terminated.
+ */
+extern const char *runtime_cstring(const char *s, go_int len);
+
+/*
+ * Conversions between strings and byte or rune slices, which copy data.
+ * Converted slices are allocated with capacity equal to length.
+ */
+extern void runtime_slicebytetostring(struct go_string_s *res,
+    const uint8_t *ptr, go_int len);
+extern void runtime_slicerunetostring(struct go_string_s *res,
+    const int32_t *ptr, go_int len);
+extern void runtime_intstring(struct go_string_s *res, int64_t r);
+extern uint8_t *runtime_stringtoslicebyte(const char *s, go_int len);
+extern int32_t *runtime_stringtoslicerune(const char *s, go_int len,
+    go_int *n);
+
+/*
+ * Start new goroutine, which calls 'fn(arg)'.  Goroutines are scheduled
+ * cooperatively on a single OS thread: they switch only when blocked on
+ * channel or sync operations, or when exited.
+ */
+extern void runtime_newproc(void (*fn)(void *), void *arg);
+
+/*
+ * Goroutine parking.  Waiter describes goroutine blocked on channel or sync
+ * operation, and lives on its stack.  Parked goroutine is resumed after
+ * another one makes it ready.  Parking with no ready goroutines is reported
+ * as deadlock.
+ */
+typedef struct go_g_s *go_g_t;
+typedef struct go_waiter_s *go_waiter_t;
+typedef struct go_select_s *go_select_t;
+
+struct go_waiter_s
+{
+    go_g_t g;
+    void *elem;                 // Element to send or receive into.
+    bool success;               // Communication happened (not closed).
+    bool queued;
+    go_select_t sel;            // Select statement, if any.
+    go_int caseidx;
+    go_waiter_t prev;
+    go_waiter_t next;
+};
+
+/*
+ * Wait queue of goroutines (first in, first out).
+ */
+struct go_waitq_s
+{
+    go_waiter_t first;
+    go_waiter_t last;
+};
+
+extern go_g_t runtime_curg(void);
+extern void runtime_park(const char *reason);
+extern void runtime_ready(go_g_t g);
+extern void runtime_enqueue(struct go_waitq_s *q, go_waiter_t w);
+extern go_waiter_t runtime_dequeue(struct go_waitq_s *q);
+extern void runtime_unlink(struct go_waitq_s *q, go_waiter_t w);
+extern uint32_t runtime_fastrand(void);
+
+/*
+ * Choose case of select statement, which is waiting with 'w'.  Returns false
+ * if select has already chosen another case.
+ */
+extern bool runtime_selectwin(go_waiter_t w);
+
+/*
+ * Channels.  Element is passed by pointer to memory of element size.
+ */
+typedef struct go_chan_s *go_chan_t;
+
+extern go_chan_t runtime_makechan(int64_t elemsize, go_int cap);
+extern void runtime_chansend(go_chan_t c, const void *elem);
+extern bool runtime_chanrecv(go_chan_t c, void *elem);
+extern void runtime_closechan(go_chan_t c);
+extern go_int runtime_chanlen(go_chan_t c);
+extern go_int runtime_chancap(go_chan_t c);
+
+/*
+ * Select case.  Cases with nil channels are never chosen.
+ */
+#define SELECT_SEND         0
+#define SELECT_RECV         1
+
+struct go_scase_s
+{
+    go_chan_t c;
+    void *elem;
+    int32_t dir;
+};
+
+/*
+ * Choose one of ready cases at random and perform its communication.  If no
+ * case is ready, returns -1 when 'block' is not set, or waits for any case
+ * otherwise.  For receive cases, 'recvok' tells if value was received.
+ */
+extern go_int runtime_select(struct go_scase_s *cases, go_int ncases,
+    bool block, bool *recvok);
+
+/*
+ * Package sync.  Zero values are ready to use.
+ */
+struct go_waitgroup_s
+{
+    int32_t count;
+    struct go_waitq_s waiters;
+};
+
+struct go_mutex_s
+{
+    int32_t locked;
+    struct go_waitq_s waiters;
+};
+
+extern void sync__WaitGroup__Add(struct go_waitgroup_s *wg, go_int delta);
+extern void sync__WaitGroup__Done(struct go_waitgroup_s *wg);
+extern void sync__WaitGroup__Wait(struct go_waitgroup_s *wg);
+extern void sync__Mutex__Lock(struct go_mutex_s *m);
+extern void sync__Mutex__Unlock(struct go_mutex_s *m);
+
+/*
+ * Package os.  Files are identified by file descriptors, only standard
+ * streams are supported.
+ */
+struct go_file_s
+{
+    go_int fd;
+};
+typedef struct go_file_s *go_file_t;
+
+extern go_file_t os__Stdin;
+extern go_file_t os__Stdout;
+extern go_file_t os__Stderr;
+
+/*
+ * Package fmt.  Operands are passed as array of empty interface values and
+ * formatted according to their dynamic types, like Go does.  Format is
+ * ignored unless mode is FMT_PRINTF.
+ */
+#define FMT_PRINT           0   // Spaces between operands if neither is string.
+#define FMT_PRINTLN         1   // Spaces between operands, newline at the end.
+#define FMT_PRINTF          2
+
+/*
+ * Write formatted operands to 'f'.  Returns number of bytes written.
+ */
+extern go_int runtime_fprint(go_file_t f, int32_t mode, const char *format,
+    go_int formatlen, const struct go_iface_s *args, go_int nargs);
+
+/*
+ * Write formatted operands to standard output.
+ */
+extern go_int runtime_print(int32_t mode, const char *format, go_int formatlen,
+    const struct go_iface_s *args, go_int nargs);
+
+/*
+ * Store formatted operands in 'res'.
+ */
+extern void runtime_sprint(struct go_string_s *res, int32_t mode,
+    const char *format, go_int formatlen, const struct go_iface_s *args,
+    go_int nargs);
+
+/*
+ * Store error value with message formatted like by runtime_sprint() with
+ * FMT_PRINTF mode in 'res'.  Verb %w formats operand like %v.
+ */
+extern void runtime_errorf(struct go_iface_s *res, const char *format,
+    go_int formatlen, const struct go_iface_s *args, go_int nargs);
+
+#endif      /* __RUNTIME_H */
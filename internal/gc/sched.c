@@ -0,0 +1,330 @@
+/*
+ * sched.c
+ *
+ * Goroutine scheduler and package sync.
+ *
+ * Goroutines run on a single OS thread and switch cooperatively, when blocked
+ * or exited.  Each goroutine but the main one runs on its own stack, which is
+ * scanned by the GC along with saved registers.
+ */
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/mman.h>
+#include <ucontext.h>
+
+#include "runtime.h"
+
+#define G_STACK_SIZE        (512 * 1024)
+
+enum
+{
+    G_RUNNING,
+    G_RUNNABLE,
+    G_WAITING,
+    G_DEAD,
+};
+
+struct go_g_s
+{
+    ucontext_t ctx;             // Saved registers of suspended goroutine.
+    void (*fn)(void *);
+    void *arg;
+    void *stack;                // NULL for main goroutine.
+    void *stackbottom;          // Highest address of stack.
+    void *sp;                   // Stack top of suspended goroutine.
+    int status;
+    int64_t id;
+    struct go_pstate_s pstate;
+    go_g_t next;                // Run queue or free list.
+    go_g_t allnext;
+};
+
+static struct go_g_s sched_g0 = {.status = G_RUNNING, .id = 1};
+static int64_t sched_goid = 1;
+static go_g_t sched_curg = &sched_g0;
+static go_g_t sched_allgs = &sched_g0;
+static go_g_t sched_freegs = NULL;
+static go_g_t sched_runqhead = NULL;
+static go_g_t sched_runqtail = NULL;
+static bool sched_inited = false;
+
+static __attribute__((noinline)) void *sched_stacktop(void)
+{
+    void *sp;
+    asm ("movq %%rsp, %0" : "=r"(sp));
+    return sp;
+}
+
+/*
+ * Register stacks and saved registers of suspended goroutines with the GC.
+ */
+static void sched_gcstacks(void)
+{
+    for (go_g_t g = sched_allgs; g != NULL; g = g->allnext)
+    {
+        if (g->status == G_DEAD)
+            continue;
+        GC_stack_root(g, sizeof(struct go_g_s));
+        if (g != sched_curg)
+            GC_stack_root(g->sp, (char *)g->stackbottom - (char *)g->sp);
+    }
+}
+
+static void sched_init(void)
+{
+    if (sched_inited)
+        return;
+    sched_inited = true;
+    GC_stacks(sched_gcstacks);
+}
+
+static void runq_put(go_g_t g)
+{
+    g->next = NULL;
+    if (sched_runqtail == NULL)
+        sched_runqhead = g;
+    else
+        sched_runqtail->next = g;
+    sched_runqtail = g;
+}
+
+static go_g_t runq_get(void)
+{
+    go_g_t g = sched_runqhead;
+    if (g != NULL)
+    {
+        sched_runqhead = g->next;
+        if (sched_runqhead == NULL)
+            sched_runqtail = NULL;
+    }
+    return g;
+}
+
+/*
+ * Switch from current goroutine to 'next'.  Returns when current goroutine
+ * is resumed.
+ */
+static void sched_switch(go_g_t next)
+{
+    go_g_t g = sched_curg;
+    g->sp = (void *)((uintptr_t)sched_stacktop() & ~(uintptr_t)7);
+    g->stackbottom = GC_set_stackbottom(next->stackbottom);
+    next->status = G_RUNNING;
+    sched_curg = next;
+    swapcontext(&g->ctx, &next->ctx);
+}
+
+/*
+ * Run next ready goroutine.  Current goroutine must be already waiting, ready
+ * or dead.
+ */
+static void sched_schedule(const char *reason)
+{
+    go_g_t next = runq_get();
+    if (next == NULL)
+    {
+        fflush(stdout);
+        fprintf(stderr, "fatal error: all goroutines are asleep - deadlock!\n"
+            "\ngoroutine %ld [%s]:\n", (long)sched_curg->id, reason);
+        exit(2);
+    }
+    if (next != sched_curg)
+        sched_switch(next);
+    else
+        next->status = G_RUNNING;
+}
+
+static void sched_start(void)
+{
+    go_g_t g = sched_curg;
+    void *arg = g->arg;
+    g->arg = NULL;
+    g->fn(arg);
+
+    // stack of dead goroutine is reused by new goroutines
+    g->status = G_DEAD;
+    g->next = sched_freegs;
+    sched_freegs = g;
+    sched_schedule("exit");
+}
+
+extern void runtime_newproc(void (*fn)(void *), void *arg)
+{
+    sched_init();
+    go_g_t g = sched_freegs;
+    if (g != NULL)
+        sched_freegs = g->next;
+    else
+    {
+        g = (go_g_t)calloc(1, sizeof(struct go_g_s));
+        if (g == NULL)
+        {
+            fputs("fatal error: out of memory\n", stderr);
+            exit(2);
+        }
+        g->stack = mmap(NULL, G_STACK_SIZE, PROT_READ | PROT_WRITE,
+            MAP_PRIVATE | MAP_ANONYMOUS | MAP_NORESERVE | MAP_STACK, -1, 0);
+        if (g->stack == MAP_FAILED)
+        {
+            fputs("fatal error: out of memory\n", stderr);
+            exit(2);
+        }
+        // guard page catches stack overflow
+        mprotect(g->stack, 4096, PROT_NONE);
+        g->allnext = sched_allgs;
+        sched_allgs = g;
+    }
+    g->fn = fn;
+    g->arg = arg;
+    g->id = ++sched_goid;
+    memset(&g->pstate, 0, sizeof(g->pstate));
+    g->stackbottom = (char *)g->stack + G_STACK_SIZE;
+    g->sp = g->stackbottom;
+    getcontext(&g->ctx);
+    g->ctx.uc_stack.ss_sp = g->stack;
+    g->ctx.uc_stack.ss_size = G_STACK_SIZE;
+    g->ctx.uc_link = NULL;
+    makecontext(&g->ctx, sched_start, 0);
+    g->status = G_RUNNABLE;
+    runq_put(g);
+}
+
+extern go_g_t runtime_curg(void)
+{
+    return sched_curg;
+}
+
+/*
+ * Panic state is scanned by the GC along with goroutine.
+ */
+extern struct go_pstate_s *runtime_pstate(void)
+{
+    sched_init();
+    return &sched_curg->pstate;
+}
+
+extern void runtime_park(const char *reason)
+{
+    sched_curg->status = G_WAITING;
+    sched_schedule(reason);
+}
+
+extern void runtime_ready(go_g_t g)
+{
+    g->status = G_RUNNABLE;
+    runq_put(g);
+}
+
+extern void runtime_enqueue(struct go_waitq_s *q, go_waiter_t w)
+{
+    w->next = NULL;
+    w->prev = q->last;
+    if (q->last == NULL)
+        q->first = w;
+    else
+        q->last->next = w;
+    q->last = w;
+    w->queued = true;
+}
+
+extern void runtime_unlink(struct go_waitq_s *q, go_waiter_t w)
+{
+    if (!w->queued)
+        return;
+    if (w->prev == NULL)
+        q->first = w->next;
+    else
+        w->prev->next = w->next;
+    if (w->next == NULL)
+        q->last = w->prev;
+    else
+        w->next->prev = w->prev;
+    w->queued = false;
+}
+
+/*
+ * Take first waiter from queue.  Waiters of select statement, which already
+ * has chosen another case, are skipped.
+ */
+extern go_waiter_t runtime_dequeue(struct go_waitq_s *q)
+{
+    while (q->first != NULL)
+    {
+        go_waiter_t w = q->first;
+        runtime_unlink(q, w);
+        if (w->sel == NULL || runtime_selectwin(w))
+            return w;
+    }
+    return NULL;
+}
+
+extern uint32_t runtime_fastrand(void)
+{
+    static uint32_t state = 2463534242u;
+    state ^= state << 13;
+    state ^= state >> 17;
+    state ^= state << 5;
+    return state;
+}
+
+/*
+ * sync.WaitGroup
+ */
+extern void sync__WaitGroup__Add(struct go_waitgroup_s *wg, go_int delta)
+{
+    wg->count += delta;
+    if (wg->count < 0)
+        runtime_panicerror("sync: negative WaitGroup counter");
+    if (wg->count == 0)
+    {
+        go_waiter_t w;
+        while ((w = runtime_dequeue(&wg->waiters)) != NULL)
+            runtime_ready(w->g);
+    }
+}
+
+extern void sync__WaitGroup__Done(struct go_waitgroup_s *wg)
+{
+    sync__WaitGroup__Add(wg, -1);
+}
+
+extern void sync__WaitGroup__Wait(struct go_waitgroup_s *wg)
+{
+    if (wg->count == 0)
+        return;
+    struct go_waiter_s w = {.g = sched_curg};
+    runtime_enqueue(&wg->waiters, &w);
+    runtime_park("semacquire");
+}
+
+/*
+ * sync.Mutex.  Unlock hands mutex over to the first waiter.
+ */
+extern void sync__Mutex__Lock(struct go_mutex_s *m)
+{
+    if (!m->locked)
+    {
+        m->locked = 1;
+        return;
+    }
+    struct go_waiter_s w = {.g = sched_curg};
+    runtime_enqueue(&m->waiters, &w);
+    runtime_park("sync.Mutex.Lock");
+}
+
+extern void sync__Mutex__Unlock(struct go_mutex_s *m)
+{
+    if (!m->locked)
+    {
+        fflush(stdout);
+        fputs("fatal error: sync: unlock of unlocked mutex\n", stderr);
+        exit(2);
+    }
+    go_waiter_t w = runtime_dequeue(&m->waiters);
+    if (w != NULL)
+        runtime_ready(w->g);
+    else
+        m->locked = 0;
+}
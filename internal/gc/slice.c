@@ -0,0 +1,40 @@
+/*
+ * slice.c
+ *
+ * Slice support routines.
+ */
+
+#include <string.h>
+
+#include "runtime.h"
+
+extern void *runtime_makeslice(int64_t elemsize, go_int cap)
+{
+    size_t size = (size_t)elemsize * (size_t)cap;
+    if (size == 0)
+        size = 1;
+    void *ptr = GC_malloc(size);
+    memset(ptr, 0, size);
+    return ptr;
+}
+
+extern go_int runtime_growcap(go_int cap, go_int newlen)
+{
+    // Double capacity for small slices and grow by 1.25 for big ones,
+    // like Go runtime does.
+    go_int newcap = cap;
+    if (newcap == 0)
+        newcap = newlen;
+    while (newcap < newlen)
+        newcap += (newcap < 256? newcap: newcap / 4);
+    return newcap;
+}
+
+extern go_int runtime_slicecopy(void *dst, go_int dstlen, const void *src,
+    go_int srclen, int64_t elemsize)
+{
+    go_int n = (dstlen < srclen? dstlen: srclen);
+    if (n > 0)
+        memmove(dst, src, (size_t)elemsize * (size_t)n);
+    return n;
+}
@@ -0,0 +1,204 @@
+/*
+ * string.c
+ *
+ * String support routines.
+ */
+
+#include <string.h>
+
+#include "runtime.h"
+
+extern go_int runtime_decoderune(const char *s, go_int len, go_int pos,
+    int32_t *rune)
+{
+    const uint8_t *p = (const uint8_t *)s + pos;
+    go_int n = len - pos;
+    uint8_t c = p[0];
+    if (c < 0x80)
+    {
+        *rune = c;
+        return pos + 1;
+    }
+
+    // Determine sequence length and smallest valid code point for it,
+    // to reject overlong encodings.
+    int32_t r, min;
+    go_int width;
+    if ((c & 0xE0) == 0xC0)
+    {
+        r = c & 0x1F; width = 2; min = 0x80;
+    }
+    else if ((c & 0xF0) == 0xE0)
+    {
+        r = c & 0x0F; width = 3; min = 0x800;
+    }
+    else if ((c & 0xF8) == 0xF0)
+    {
+        r = c & 0x07; width = 4; min = 0x10000;
+    }
+    else
+    {
+        *rune = RUNE_ERROR;
+        return pos + 1;
+    }
+    if (n < width)
+    {
+        *rune = RUNE_ERROR;
+        return pos + 1;
+    }
+    for (go_int i = 1; i < width; i++)
+    {
+        if ((p[i] & 0xC0) != 0x80)
+        {
+            *rune = RUNE_ERROR;
+            return pos + 1;
+        }
+        r = (r << 6) | (p[i] & 0x3F);
+    }
+    if (r < min || r > 0x10FFFF || (r >= 0xD800 && r <= 0xDFFF))
+    {
+        *rune = RUNE_ERROR;
+        return pos + 1;
+    }
+    *rune = r;
+    return pos + width;
+}
+
+extern go_int runtime_encoderune(char *p, int64_t r)
+{
+    if (r < 0 || r > 0x10FFFF || (r >= 0xD800 && r <= 0xDFFF))
+        r = RUNE_ERROR;
+    if (r < 0x80)
+    {
+        p[0] = (char)r;
+        return 1;
+    }
+    else if (r < 0x800)
+    {
+        p[0] = (char)(0xC0 | (r >> 6));
+        p[1] = (char)(0x80 | (r & 0x3F));
+        return 2;
+    }
+    else if (r < 0x10000)
+    {
+        p[0] = (char)(0xE0 | (r >> 12));
+        p[1] = (char)(0x80 | ((r >> 6) & 0x3F));
+        p[2] = (char)(0x80 | (r & 0x3F));
+        return 3;
+    }
+    p[0] = (char)(0xF0 | (r >> 18));
+    p[1] = (char)(0x80 | ((r >> 12) & 0x3F));
+    p[2] = (char)(0x80 | ((r >> 6) & 0x3F));
+    p[3] = (char)(0x80 | (r & 0x3F));
+    return 4;
+}
+
+/*
+ * Allocate NUL-terminated buffer for string of length 'len'.
+ */
+static char *string_alloc(go_int len)
+{
+    char *p = (char *)GC_malloc((size_t)len + 1);
+    p[len] = '\0';
+    return p;
+}
+
+extern void runtime_concatstrings(struct go_string_s *res, const char *s1,
+    go_int len1, const char *s2, go_int len2)
+{
+    if (len1 == 0)
+    {
+        res->ptr = s2;
+        res->len = len2;
+        return;
+    }
+    else if (len2 == 0)
+    {
+        res->ptr = s1;
+        res->len = len1;
+        return;
+    }
+    char *p = string_alloc(len1 + len2);
+    memcpy(p, s1, (size_t)len1);
+    memcpy(p + len1, s2, (size_t)len2);
+    res->ptr = p;
+    res->len = len1 + len2;
+}
+
+extern go_int runtime_cmpstring(const char *s1, go_int len1, const char *s2,
+    go_int len2)
+{
+    go_int n = len1 < len2 ? len1 : len2;
+    int cmp = n > 0 ? memcmp(s1, s2, (size_t)n) : 0;
+    if (cmp != 0)
+        return cmp;
+    return len1 < len2 ? -1 : len1 > len2 ? 1 : 0;
+}
+
+extern const char *runtime_cstring(const char *s, go_int len)
+{
+    if (len == 0)
+        return "";
+    else if (s[len] == '\0')
+        return s;
+    char *p = string_alloc(len);
+    memcpy(p, s, (size_t)len);
+    return p;
+}
+
+extern void runtime_slicebytetostring(struct go_string_s *res,
+    const uint8_t *ptr, go_int len)
+{
+    char *p = string_alloc(len);
+    if (len > 0)
+        memcpy(p, ptr, (size_t)len);
+    res->ptr = p;
+    res->len = len;
+}
+
+extern void runtime_slicerunetostring(struct go_string_s *res,
+    const int32_t *ptr, go_int len)
+{
+    char buf[RUNE_MAXWIDTH];
+    go_int n = 0;
+    for (go_int i = 0; i < len; i++)
+        n += runtime_encoderune(buf, ptr[i]);
+    char *p = string_alloc(n);
+    n = 0;
+    for (go_int i = 0; i < len; i++)
+        n += runtime_encoderune(p + n, ptr[i]);
+    res->ptr = p;
+    res->len = n;
+}
+
+extern void runtime_intstring(struct go_string_s *res, int64_t r)
+{
+    char *p = string_alloc(RUNE_MAXWIDTH);
+    go_int n = runtime_encoderune(p, r);
+    p[n] = '\0';
+    res->ptr = p;
+    res->len = n;
+}
+
+extern uint8_t *runtime_stringtoslicebyte(const char *s, go_int len)
+{
+    uint8_t *p = (uint8_t *)runtime_makeslice(1, len);
+    if (len > 0)
+        memcpy(p, s, (size_t)len);
+    return p;
+}
+
+extern int32_t *runtime_stringtoslicerune(const char *s, go_int len,
+    go_int *n)
+{
+    int32_t r;
+    go_int count = 0;
+    for (go_int pos = 0; pos < len; count++)
+        pos = runtime_decoderune(s, len, pos, &r);
+    int32_t *p = (int32_t *)runtime_makeslice(sizeof(int32_t), count);
+    go_int i = 0;
+    for (go_int pos = 0; pos < len; i++)
+        pos = runtime_decoderune(s, len, pos, &p[i]);
+    *n = count;
+    return p;
+}
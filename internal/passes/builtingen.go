@@ -0,0 +1,332 @@
+package passes
+
+import (
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+var builtinFuncs = map[string]bool{
+	"append":  true,
+	"cap":     true,
+	"close":   true,
+	"copy":    true,
+	"delete":  true,
+	"len":     true,
+	"make":    true,
+	"panic":   true,
+	"recover": true,
+}
+
+// isBuiltinCall checks if callee expression names builtin function, not shadowed by user declarations.
+func (genCtx *GenContext) isBuiltinCall(ctx parser.IPrimaryExprContext) (string, bool) {
+	if ctx.Operand() == nil || ctx.Operand().OperandName() == nil {
+		return "", false
+	}
+	name := ctx.Operand().OperandName().GetText()
+	if !builtinFuncs[name] {
+		return "", false
+	}
+	if _, ok := genCtx.Vars.Lookup(name); ok {
+		return "", false
+	}
+	if _, err := genCtx.LookupFunc(name); err == nil {
+		return "", false
+	}
+	return name, true
+}
+
+func (genCtx *GenContext) GenerateBuiltinCall(block *ir.Block, name string, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	switch name {
+	case "len", "cap":
+		return genCtx.GenerateLenCap(block, name, ctx)
+	case "make":
+		return genCtx.GenerateMake(block, ctx)
+	case "append":
+		return genCtx.GenerateAppend(block, ctx)
+	case "copy":
+		return genCtx.GenerateCopy(block, ctx)
+	case "delete":
+		return genCtx.GenerateDelete(block, ctx)
+	case "close":
+		return genCtx.GenerateClose(block, ctx)
+	case "panic":
+		return genCtx.GeneratePanic(block, ctx)
+	case "recover":
+		return genCtx.GenerateRecover(block, ctx)
+	}
+	return nil, nil, utils.MakeErrorTrace(ctx, nil, "unknown builtin function %s", name)
+}
+
+// builtinArgs evaluates all arguments of builtin function call.
+func (genCtx *GenContext) builtinArgs(block *ir.Block, name string, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.GenerateArguments(block, ctx)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse arguments for %s", name)
+	}
+	return args, blocks, nil
+}
+
+func (genCtx *GenContext) GenerateLenCap(block *ir.Block, name string, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.builtinArgs(block, name, ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	tp := typesystem.Underlying(args[0].Type())
+	if ptp, ok := tp.(*types.PointerType); ok {
+		tp = typesystem.Underlying(ptp.ElemType)
+	}
+	switch tp := tp.(type) {
+	case *types.ArrayType:
+		return []value.Value{constant.NewInt(typesystem.Int, int64(tp.Len))}, blocks, nil
+	case *typesystem.StringType:
+		if name != "len" {
+			break
+		}
+		_, length := genCtx.GenerateStringParts(block, args[0])
+		return []value.Value{length}, blocks, nil
+	case *typesystem.SliceType:
+		_, length, capacity := genCtx.GenerateSliceParts(block, args[0])
+		if name == "len" {
+			return []value.Value{length}, blocks, nil
+		}
+		return []value.Value{capacity}, blocks, nil
+	case *typesystem.MapType:
+		if name != "len" {
+			break
+		}
+		maplen, err := genCtx.LookupFunc("runtime_maplen")
+		if err != nil {
+			return nil, nil, err
+		}
+		return []value.Value{
+			typesystem.NewTypedValue(block.NewCall(maplen, args[0]), typesystem.Int),
+		}, blocks, nil
+	case *typesystem.ChanType:
+		fun, err := genCtx.LookupFunc("runtime_chan" + name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []value.Value{
+			typesystem.NewTypedValue(block.NewCall(fun, args[0]), typesystem.Int),
+		}, blocks, nil
+	}
+	return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid argument for %s: %s", name, args[0].Type())
+}
+
+func (genCtx *GenContext) GenerateMake(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	// type is either parsed as type literal or as expression for named types
+	var tp types.Type
+	var err error
+	var sizeExprs []parser.IExpressionContext
+	if ctx.Type_() != nil {
+		tp, err = genCtx.PackageData.ParseType(ctx.Type_())
+		if ctx.ExpressionList() != nil {
+			sizeExprs = ctx.ExpressionList().AllExpression()
+		}
+	} else {
+		exprs := ctx.ExpressionList().AllExpression()
+		tp, err = genCtx.PackageData.ParseTypeName(exprs[0].GetText())
+		sizeExprs = exprs[1:]
+	}
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid type for make")
+	}
+	var sizes []value.Value
+	var blocks []*ir.Block
+	for _, expr := range sizeExprs {
+		vals, newBlocks, err := genCtx.GenerateExpr(block, expr)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse make arguments")
+		} else if newBlocks != nil {
+			blocks = append(blocks, newBlocks...)
+			block = blocks[len(blocks)-1]
+		}
+		size, err := genCtx.GenerateIntCast(block, vals[0])
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(expr, err, "invalid size argument for make")
+		}
+		sizes = append(sizes, size)
+	}
+	switch utp := typesystem.Underlying(tp).(type) {
+	case *typesystem.SliceType:
+		length, capacity := sizes[0], sizes[0]
+		if len(sizes) == 2 {
+			capacity = sizes[1]
+		}
+		slice, err := genCtx.GenerateMakeSlice(block, utp, length, capacity)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to make slice")
+		}
+		return []value.Value{typesystem.NewTypedValue(slice, tp)}, blocks, nil
+	case *typesystem.MapType:
+		var hint value.Value = constant.NewInt(typesystem.Int, 0)
+		if len(sizes) == 1 {
+			hint = sizes[0]
+		}
+		m, err := genCtx.GenerateMakeMap(block, utp, hint)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to make map")
+		}
+		return []value.Value{typesystem.NewTypedValue(m, tp)}, blocks, nil
+	case *typesystem.ChanType:
+		var size value.Value = constant.NewInt(typesystem.Int, 0)
+		if len(sizes) == 1 {
+			size = sizes[0]
+		}
+		ch, err := genCtx.GenerateMakeChan(block, utp, size)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to make channel")
+		}
+		return []value.Value{typesystem.NewTypedValue(ch, tp)}, blocks, nil
+	}
+	return nil, nil, utils.MakeErrorTrace(ctx, nil, "cannot make %s", tp)
+}
+
+func (genCtx *GenContext) GenerateAppend(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.builtinArgs(block, "append", ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	base, args := args[0], args[1:]
+	stp := typesystem.Underlying(base.Type()).(*typesystem.SliceType)
+	if len(args) == 0 {
+		return []value.Value{base}, blocks, nil
+	}
+
+	ptr, length, capacity := genCtx.GenerateSliceParts(block, base)
+	var newLen, srcPtr value.Value
+	if ctx.ELLIPSIS() != nil {
+		// append(s, t...)
+		var srcLen value.Value
+		srcPtr, srcLen = genCtx.copySource(block, args[0])
+		newLen = block.NewAdd(length, srcLen)
+	} else {
+		newLen = block.NewAdd(length, constant.NewInt(typesystem.Int, int64(len(args))))
+	}
+
+	// reallocate backing array if capacity is exceeded
+	bgrow := genCtx.NewBlock("append.grow")
+	bdone := genCtx.NewBlock("append.done")
+	block.NewCondBr(block.NewICmp(enum.IPredSGT, newLen, capacity), bgrow, bdone)
+
+	growcap, err := genCtx.LookupFunc("runtime_growcap")
+	if err != nil {
+		return nil, nil, err
+	}
+	grown, err := genCtx.GenerateMakeSlice(bgrow, stp, length, bgrow.NewCall(growcap, capacity, newLen))
+	if err != nil {
+		return nil, nil, err
+	}
+	grownPtr, _, _ := genCtx.GenerateSliceParts(bgrow, grown)
+	if err := genCtx.generateCopyCall(bgrow, stp, grownPtr, ptr, length); err != nil {
+		return nil, nil, err
+	}
+	bgrow.NewBr(bdone)
+
+	slice := bdone.NewPhi(ir.NewIncoming(base, block), ir.NewIncoming(grown, bgrow))
+	ptr, _, capacity = genCtx.GenerateSliceParts(bdone, typesystem.NewTypedValue(slice, stp))
+	blocks = append(blocks, bgrow, bdone)
+	block = bdone
+
+	// store new elements after the old ones
+	if srcPtr != nil {
+		if err := genCtx.generateCopyCall(block, stp, block.NewGetElementPtr(stp.ElemType, ptr, length), srcPtr, block.NewSub(newLen, length)); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		for i, arg := range args {
+			arg, err := genCtx.GenerateAssignConv(block, arg, stp.ElemType)
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid argument in append")
+			}
+			block.NewStore(
+				arg,
+				block.NewGetElementPtr(stp.ElemType, ptr, block.NewAdd(length, constant.NewInt(typesystem.Int, int64(i)))),
+			)
+		}
+	}
+	return []value.Value{
+		typesystem.NewTypedValue(genCtx.GenerateSliceValue(block, stp, ptr, newLen, capacity), base.Type()),
+	}, blocks, nil
+}
+
+// copySource returns pointer and length of the source operand of copy or
+// append(s, t...), which is either a slice or a string copied to byte slice.
+func (genCtx *GenContext) copySource(block *ir.Block, src value.Value) (value.Value, value.Value) {
+	if typesystem.IsStringType(src.Type()) {
+		return genCtx.GenerateStringParts(block, src)
+	}
+	ptr, length, _ := genCtx.GenerateSliceParts(block, src)
+	return ptr, length
+}
+
+// generateCopyCall copies count elements of slice type stp from src to dst.
+func (genCtx *GenContext) generateCopyCall(block *ir.Block, stp *typesystem.SliceType, dst, src, count value.Value) error {
+	slicecopy, err := genCtx.LookupFunc("runtime_slicecopy")
+	if err != nil {
+		return err
+	}
+	block.NewCall(slicecopy,
+		block.NewBitCast(dst, types.I8Ptr), count,
+		block.NewBitCast(src, types.I8Ptr), count,
+		sizeOf(stp.ElemType),
+	)
+	return nil
+}
+
+func (genCtx *GenContext) GenerateCopy(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.builtinArgs(block, "copy", ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	stp := typesystem.Underlying(args[0].Type()).(*typesystem.SliceType)
+	srcPtr, srcLen := genCtx.copySource(block, args[1])
+	slicecopy, err := genCtx.LookupFunc("runtime_slicecopy")
+	if err != nil {
+		return nil, nil, err
+	}
+	dstPtr, dstLen, _ := genCtx.GenerateSliceParts(block, args[0])
+	return []value.Value{
+		typesystem.NewTypedValue(
+			block.NewCall(slicecopy,
+				block.NewBitCast(dstPtr, types.I8Ptr), dstLen,
+				block.NewBitCast(srcPtr, types.I8Ptr), srcLen,
+				sizeOf(stp.ElemType),
+			),
+			typesystem.Int,
+		),
+	}, blocks, nil
+}
+
+func (genCtx *GenContext) GenerateDelete(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.builtinArgs(block, "delete", ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	mtp := typesystem.Underlying(args[0].Type()).(*typesystem.MapType)
+	mapdelete, err := genCtx.LookupFunc("runtime_mapdelete")
+	if err != nil {
+		return nil, nil, err
+	}
+	kptr, err := genCtx.generateMapKey(block, mtp, args[1])
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid key in delete")
+	}
+	block.NewCall(mapdelete, args[0], kptr)
+	return nil, blocks, nil
+}
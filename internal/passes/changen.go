@@ -0,0 +1,159 @@
+package passes
+
+import (
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// select case directions, must match SELECT_* constants in runtime.h
+const (
+	selectSend = iota
+	selectRecv
+)
+
+// type of select case passed to runtime: channel, pointer to element and direction
+var selectCaseType = types.NewStruct(types.I8Ptr, types.I8Ptr, types.I32)
+
+// GenerateMakeChan allocates channel with buffer for size elements.
+func (genCtx *GenContext) GenerateMakeChan(block *ir.Block, ctp *typesystem.ChanType, size value.Value) (value.Value, error) {
+	makechan, err := genCtx.LookupFunc("runtime_makechan")
+	if err != nil {
+		return nil, err
+	}
+	return typesystem.NewTypedValue(
+		block.NewCall(makechan, sizeOf(ctp.ElemType), size),
+		ctp,
+	), nil
+}
+
+// chanOperand checks that value is channel, which may be used in given direction.
+func (genCtx *GenContext) chanOperand(val value.Value, dir typesystem.ChanDir) (*typesystem.ChanType, error) {
+	ctp, ok := typesystem.Underlying(val.Type()).(*typesystem.ChanType)
+	if !ok {
+		return nil, utils.MakeError("non-channel %s", genCtx.PackageData.typeName(val.Type()))
+	} else if dir == typesystem.ChanSend && ctp.Dir == typesystem.ChanRecv {
+		return nil, utils.MakeError("send to receive-only channel %s", genCtx.PackageData.typeName(ctp))
+	} else if dir == typesystem.ChanRecv && ctp.Dir == typesystem.ChanSend {
+		return nil, utils.MakeError("receive from send-only channel %s", genCtx.PackageData.typeName(ctp))
+	}
+	return ctp, nil
+}
+
+// generateChanElem stores element in temporary memory and returns pointer to it for runtime calls.
+func (genCtx *GenContext) generateChanElem(block *ir.Block, ctp *typesystem.ChanType, elem value.Value) (value.Value, error) {
+	elem, err := genCtx.GenerateAssignConv(block, elem, ctp.ElemType)
+	if err != nil {
+		return nil, err
+	}
+	mem := genCtx.NewTemp(ctp.ElemType)
+	block.NewStore(elem, mem)
+	return block.NewBitCast(mem, types.I8Ptr), nil
+}
+
+// GenerateChanSend generates ch <- elem, which blocks until element is taken
+// by receiver or stored in buffer.
+func (genCtx *GenContext) GenerateChanSend(block *ir.Block, ch, elem value.Value) error {
+	ctp, err := genCtx.chanOperand(ch, typesystem.ChanSend)
+	if err != nil {
+		return err
+	}
+	chansend, err := genCtx.LookupFunc("runtime_chansend")
+	if err != nil {
+		return err
+	}
+	eptr, err := genCtx.generateChanElem(block, ctp, elem)
+	if err != nil {
+		return err
+	}
+	block.NewCall(chansend, ch, eptr)
+	return nil
+}
+
+// GenerateChanRecv generates <-ch. Comma-ok form returns additional flag,
+// which is false when zero value is received from closed channel.
+func (genCtx *GenContext) GenerateChanRecv(block *ir.Block, ch value.Value, commaOk bool) ([]value.Value, error) {
+	ctp, err := genCtx.chanOperand(ch, typesystem.ChanRecv)
+	if err != nil {
+		return nil, err
+	}
+	chanrecv, err := genCtx.LookupFunc("runtime_chanrecv")
+	if err != nil {
+		return nil, err
+	}
+	mem := genCtx.NewTemp(ctp.ElemType)
+	ok := block.NewCall(chanrecv, ch, block.NewBitCast(mem, types.I8Ptr))
+	vals := []value.Value{
+		typesystem.NewTypedValue(block.NewLoad(ctp.ElemType, mem), ctp.ElemType),
+	}
+	if commaOk {
+		vals = append(vals, typesystem.NewTypedValue(ok, typesystem.Bool))
+	}
+	return vals, nil
+}
+
+// GenerateRecvExpr generates receive expression <-ch.
+func (genCtx *GenContext) GenerateRecvExpr(block *ir.Block, ctx parser.IExpressionContext, commaOk bool) ([]value.Value, []*ir.Block, error) {
+	vals, blocks, err := genCtx.GenerateExpr(block, ctx.Expression(0))
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse receive expression")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	vals, err = genCtx.GenerateChanRecv(block, vals[0], commaOk)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid operation %s", ctx.GetText())
+	}
+	return vals, blocks, nil
+}
+
+// isRecvExpr checks if expression is receive operation <-ch.
+func isRecvExpr(ctx parser.IExpressionContext) bool {
+	return ctx.GetUnary_op() != nil && ctx.RECEIVE() != nil
+}
+
+func (genCtx *GenContext) GenerateClose(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.builtinArgs(block, "close", ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	if _, err := genCtx.chanOperand(args[0], typesystem.ChanSend); err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid argument for close")
+	}
+	closechan, err := genCtx.LookupFunc("runtime_closechan")
+	if err != nil {
+		return nil, nil, err
+	}
+	block.NewCall(closechan, args[0])
+	return nil, blocks, nil
+}
+
+// generateSelectCase stores case of select statement in array passed to runtime.
+func (genCtx *GenContext) generateSelectCase(block *ir.Block, cases value.Value, count int64, idx int, ch, elem value.Value, dir int64) {
+	cas := block.NewGetElementPtr(types.NewArray(uint64(count), selectCaseType), cases,
+		constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(idx)))
+	fields := []value.Value{typesystem.NewTypedValue(ch, types.I8Ptr), elem, constant.NewInt(types.I32, dir)}
+	for i, field := range fields {
+		block.NewStore(field, block.NewGetElementPtr(selectCaseType, cas, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))))
+	}
+}
+
+// GenerateChanCompare compares channels by identity, either of them may be nil.
+func (genCtx *GenContext) GenerateChanCompare(block *ir.Block, op int, left, right value.Value) (value.Value, error) {
+	ptrs := []value.Value{left, right}
+	for i, val := range ptrs {
+		if _, ok := val.(*constant.Null); ok {
+			ptrs[i] = constant.NewNull(types.I8Ptr)
+		} else {
+			ptrs[i] = typesystem.NewTypedValue(val, types.I8Ptr)
+		}
+	}
+	return typesystem.NewTypedValue(block.NewICmp(equalityPred(op), ptrs[0], ptrs[1]), typesystem.Bool), nil
+}
@@ -0,0 +1,251 @@
+package passes
+
+import (
+	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"sort"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// name of parameter, which points to environment of captured variables
+const envParamName = ".env"
+
+// capturedVar is variable of enclosing function referenced by function literal.
+type capturedVar struct {
+	name string
+	ref  value.Value // address of variable in enclosing function
+}
+
+// closureEnvType returns type of environment, which holds addresses of captured variables.
+func closureEnvType(captures []capturedVar) *types.StructType {
+	var fields []types.Type
+	for _, c := range captures {
+		fields = append(fields, c.ref.Type())
+	}
+	return types.NewStruct(fields...)
+}
+
+// capturedNames returns names referenced by function literals inside tree.
+// Variables with these names may be captured, so they are allocated by GC.
+func capturedNames(tree antlr.Tree) map[string]bool {
+	names := make(map[string]bool)
+	var walk func(t antlr.Tree)
+	walk = func(t antlr.Tree) {
+		if lit, ok := t.(parser.IFunctionLitContext); ok {
+			identNames(lit, names)
+			return
+		}
+		for _, child := range t.GetChildren() {
+			walk(child)
+		}
+	}
+	walk(tree)
+	return names
+}
+
+// identNames collects all identifiers inside tree.
+func identNames(tree antlr.Tree, names map[string]bool) {
+	if term, ok := tree.(antlr.TerminalNode); ok {
+		if term.GetSymbol().GetTokenType() == parser.GoParserIDENTIFIER {
+			names[term.GetText()] = true
+		}
+		return
+	}
+	for _, child := range tree.GetChildren() {
+		identNames(child, names)
+	}
+}
+
+// genClosureDef generates definition of function called through func values,
+// which takes pointer to environment after out parameters.
+func genClosureDef(decl *FunctionDecl) *ir.Func {
+	fun, _ := genFunDef(decl)
+	outCount := len(typesystem.OutParams(decl.ReturnTypes))
+	params := append([]*ir.Param{}, fun.Params[:outCount]...)
+	params = append(params, ir.NewParam(envParamName, types.I8Ptr))
+	params = append(params, fun.Params[outCount:]...)
+	return ir.NewFunc(decl.Name, fun.Sig.RetType, params...)
+}
+
+// declareCaptures declares captured variables in function literal body,
+// loading their addresses from environment.
+func (genCtx *GenContext) declareCaptures(block *ir.Block, env value.Value, captures []capturedVar) {
+	if len(captures) == 0 {
+		return
+	}
+	envType := closureEnvType(captures)
+	envPtr := block.NewBitCast(env, types.NewPointer(envType))
+	for i, c := range captures {
+		addr := block.NewGetElementPtr(envType, envPtr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i)))
+		genCtx.Vars.Add(c.name, typesystem.NewTypedValue(block.NewLoad(c.ref.Type(), addr), c.ref.Type()))
+	}
+}
+
+// newFuncValue builds func value from code pointer and environment.
+func newFuncValue(block *ir.Block, ftp *typesystem.FuncType, code, env value.Value) value.Value {
+	var val value.Value = constant.NewUndef(&ftp.StructType)
+	val = block.NewInsertValue(val, code, 0)
+	val = block.NewInsertValue(val, env, 1)
+	return typesystem.NewTypedValue(val, ftp)
+}
+
+// GenerateFuncValue generates func value of declared function. Function is
+// called through wrapper, which ignores environment.
+func (genCtx *GenContext) GenerateFuncValue(fun *ir.Func, decl *FunctionDecl) value.Value {
+	ftp := typesystem.NewFuncType(decl.ArgTypes, decl.ReturnTypes)
+	ftp.Variadic = decl.Ellipsis
+	name := decl.Name + "__func"
+	wrapper, ok := genCtx.ifaceFuncs[name]
+	if !ok {
+		wrapperDecl := &FunctionDecl{
+			Name:        name,
+			ReturnNames: make([]string, len(decl.ReturnTypes)),
+			ReturnTypes: decl.ReturnTypes,
+		}
+		for i, tp := range decl.ArgTypes {
+			wrapperDecl.ArgNames = append(wrapperDecl.ArgNames, fmt.Sprintf("arg%d", i))
+			wrapperDecl.ArgTypes = append(wrapperDecl.ArgTypes, tp)
+		}
+		wrapper = genClosureDef(wrapperDecl)
+		wrapper.Linkage = enum.LinkageLinkOnceODR
+		wrapper.Parent = genCtx.module
+		genCtx.module.Funcs = append(genCtx.module.Funcs, wrapper)
+		genCtx.ifaceFuncs[name] = wrapper
+
+		block := wrapper.NewBlock("entry")
+		var args []value.Value
+		for _, param := range wrapper.Params {
+			if param.Name() != envParamName {
+				args = append(args, param)
+			}
+		}
+		returnCall(block, block.NewCall(fun, args...))
+	}
+	return typesystem.NewTypedValue(
+		constant.NewStruct(&ftp.StructType, constant.NewBitCast(wrapper, types.I8Ptr), constant.NewNull(types.I8Ptr)),
+		ftp,
+	)
+}
+
+// GenerateFuncValueCall generates call through func value.
+func (genCtx *GenContext) GenerateFuncValueCall(block *ir.Block, fv value.Value, args []value.Value) ([]value.Value, error) {
+	ftp := typesystem.Underlying(fv.Type()).(*typesystem.FuncType)
+	args, err := genCtx.generateAssignConvs(block, args, ftp.ArgTypes)
+	if err != nil {
+		return nil, err
+	}
+	code := block.NewBitCast(block.NewExtractValue(typesystem.Raw(fv), 0), ftp.CodeType())
+	env := block.NewExtractValue(typesystem.Raw(fv), 1)
+	return genCtx.generateCall(block, code, ftp.ReturnTypes, append([]value.Value{env}, args...)), nil
+}
+
+// lookupFuncOperand checks if callee names declared function, which is then called directly.
+func (genCtx *GenContext) lookupFuncOperand(ctx parser.IPrimaryExprContext) (*ir.Func, bool) {
+	if ctx.DOT() != nil {
+		// function of imported package
+		module, ok := genCtx.lookupModuleOperand(ctx.PrimaryExpr())
+		if !ok {
+			return nil, false
+		}
+		pkg, ok := genCtx.PackageData.lookupPackage(module.Name)
+		if !ok {
+			return nil, false
+		}
+		decl, ok := pkg.Functions[pkg.symbol(ctx.IDENTIFIER().GetText())]
+		if !ok {
+			return nil, false
+		}
+		return genCtx.funcRef(decl), true
+	}
+	if ctx.Operand() == nil || ctx.Operand().OperandName() == nil {
+		return nil, false
+	}
+	name := ctx.Operand().OperandName().GetText()
+	if _, ok := genCtx.Vars.Lookup(name); ok {
+		return nil, false
+	}
+	funRef, err := genCtx.LookupFunc(name)
+	return funRef, err == nil
+}
+
+// GenerateFuncLit generates function of literal and func value referring to it.
+// Variables of enclosing functions are captured by reference.
+func (v *CodeGenVisitor) GenerateFuncLit(block *ir.Block, ctx parser.IFunctionLitContext) (value.Value, error) {
+	decl, err := v.ParseSignature(ctx.Signature())
+	if err != nil {
+		return nil, err
+	}
+	enclosing := v.packageData.symbol("init")
+	if v.currentFuncIR != nil {
+		enclosing = v.currentFuncIR.Name()
+	}
+	v.genCtx.funcLits[enclosing]++
+	decl.Name = fmt.Sprintf("%s__func%d", enclosing, v.genCtx.funcLits[enclosing])
+	ftp := typesystem.NewFuncType(decl.ArgTypes, decl.ReturnTypes)
+
+	// local variables referenced by literal, not shadowed by its parameters
+	params := make(map[string]bool)
+	for _, name := range append(append([]string{}, decl.ArgNames...), decl.ReturnNames...) {
+		params[name] = true
+	}
+	names := make(map[string]bool)
+	identNames(ctx.Block(), names)
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	// local constants are not captured, but stay visible in literal body
+	var captures []capturedVar
+	consts := NewVarContext(v.genCtx.Vars.Root())
+	for _, name := range sorted {
+		if params[name] {
+			continue
+		}
+		if ref, ok := v.genCtx.Vars.LookupLocal(name); ok {
+			if c, ok := ref.(*typesystem.Const); ok {
+				consts.Add(name, c)
+			} else {
+				captures = append(captures, capturedVar{name: name, ref: ref})
+			}
+		}
+	}
+
+	fun := genClosureDef(decl)
+	fun.Parent = v.genCtx.module
+	v.genCtx.module.Funcs = append(v.genCtx.module.Funcs, fun)
+
+	// literal body is generated as separate function
+	funcDecl, funcIR, results := v.currentFuncDecl, v.currentFuncIR, v.results
+	branches, labels, defers := v.branchManager, v.labelManager, v.deferManager
+	vars, entry, captured := v.genCtx.Vars, v.genCtx.entryBlock, v.genCtx.captured
+	v.branchManager = branchManager{}
+	v.genCtx.Vars = consts
+	res := v.visitFuncBody(fun, decl, ctx.Block(), captures)
+	v.currentFuncDecl, v.currentFuncIR, v.results = funcDecl, funcIR, results
+	v.branchManager, v.labelManager, v.deferManager = branches, labels, defers
+	v.genCtx.Vars, v.genCtx.entryBlock, v.genCtx.captured = vars, entry, captured
+	if err, ok := res.(error); ok {
+		return nil, err
+	}
+
+	// environment holds addresses of captured variables
+	var env value.Value = constant.NewNull(types.I8Ptr)
+	if len(captures) > 0 {
+		envType := closureEnvType(captures)
+		mem := block.NewCall(v.genCtx.SpecialFuncs["GC_malloc"], sizeOf(envType))
+		envPtr := block.NewBitCast(mem, types.NewPointer(envType))
+		for i, c := range captures {
+			block.NewStore(c.ref, block.NewGetElementPtr(envType, envPtr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))))
+		}
+		env = mem
+	}
+	return newFuncValue(block, ftp, constant.NewBitCast(fun, types.I8Ptr), env), nil
+}
@@ -1,7 +1,7 @@
 package passes
 
 import (
-	"fmt"
+	goconstant "go/constant"
 	"gocomp/internal/parser"
 	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
@@ -20,6 +20,8 @@ type CodeGenVisitor struct {
 
 	currentFuncDecl *FunctionDecl
 	currentFuncIR   *ir.Func
+	// memory of result variables, which are copied out on return
+	results []value.Value
 
 	branchManager
 	labelManager // goto handling
@@ -32,39 +34,59 @@ func NewCodeGenVisitor(pdata *PackageData) (*CodeGenVisitor, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &CodeGenVisitor{
+	v := &CodeGenVisitor{
 		packageData: pdata,
 		genCtx:      genCtx,
 		typeManager: pdata.typeManager,
-	}, nil
+	}
+	genCtx.funcLitGen = v.GenerateFuncLit
+	pdata.evalConst = func(ctx parser.IExpressionContext) (*typesystem.Const, bool, error) {
+		return genCtx.constEvaluator().Eval(ctx)
+	}
+	return v, nil
 }
 
-func (v *CodeGenVisitor) VisitSourceFile(ctx parser.ISourceFileContext) (*ir.Module, error) {
+// VisitSourceFiles generates module of package from its source files.
+func (v *CodeGenVisitor) VisitSourceFiles(files []parser.ISourceFileContext) (*ir.Module, error) {
 	// build real main function
 	module := v.genCtx.Module()
-	ctorFun, err := v.buildCtorFunc(module, ctx)
+	ctorFun, err := v.buildCtorFunc(module, files)
 	if err != nil {
 		return nil, err
 	}
-	dtorFun, err := v.buildDtorFunc(ctx)
+	dtorFun, err := v.buildDtorFunc()
 	if err != nil {
 		return nil, err
 	}
 
 	// add code for each function declaration
-	for _, fun := range ctx.AllFunctionDecl() {
-		res := v.VisitFunctionDecl(fun.(*parser.FunctionDeclContext))
-		if err, ok := res.(error); ok {
-			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse func %s", fun.IDENTIFIER().GetText())
+	for _, ctx := range files {
+		v.packageData.UseFile(ctx)
+		for _, fun := range ctx.AllFunctionDecl() {
+			res := v.VisitFunctionDecl(fun.(*parser.FunctionDeclContext))
+			if err, ok := res.(error); ok {
+				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse func %s", fun.IDENTIFIER().GetText())
+			}
+		}
+		for _, meth := range ctx.AllMethodDecl() {
+			res := v.VisitMethodDecl(meth.(*parser.MethodDeclContext))
+			if err, ok := res.(error); ok {
+				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse method %s", meth.IDENTIFIER().GetText())
+			}
 		}
 	}
 
 	// update type defs
 	v.typeManager.UpdateModule(module)
 
+	module.Funcs = append(module.Funcs, ctorFun, dtorFun)
+	if v.packageData.PackageName != "main" {
+		// initialized by main package of program
+		return module, nil
+	}
 	var mainFun *ir.Func
 	for _, fun := range module.Funcs {
-		if fun.Name() == v.packageData.PackageName+"__main" {
+		if fun.Name() == v.packageData.symbol("main") {
 			mainFun = fun
 			break
 		}
@@ -72,9 +94,14 @@ func (v *CodeGenVisitor) VisitSourceFile(ctx parser.ISourceFileContext) (*ir.Mod
 	if mainFun == nil {
 		return nil, utils.MakeError("main function not found")
 	}
-	module.Funcs = append(module.Funcs, ctorFun, dtorFun)
 	realMainFun := module.NewFunc("main", types.I32)
 	realMainEntry := realMainFun.NewBlock("entry")
+	// imported packages are initialized first, in dependency order
+	for _, pkg := range v.packageData.Packages {
+		if !pkg.stdlib {
+			realMainEntry.NewCall(module.NewFunc(pkg.Path+"_init", types.Void))
+		}
+	}
 	realMainEntry.NewCall(ctorFun)
 	realMainEntry.NewCall(mainFun)
 	realMainEntry.NewCall(dtorFun)
@@ -83,9 +110,9 @@ func (v *CodeGenVisitor) VisitSourceFile(ctx parser.ISourceFileContext) (*ir.Mod
 	return module, nil
 }
 
-func (v *CodeGenVisitor) buildCtorFunc(module *ir.Module, ctx parser.ISourceFileContext) (*ir.Func, error) {
+func (v *CodeGenVisitor) buildCtorFunc(module *ir.Module, files []parser.ISourceFileContext) (*ir.Func, error) {
 	// gather global declarations
-	ctorFun := ir.NewFunc(fmt.Sprintf("%s_init", v.packageData.PackageName), types.Void)
+	ctorFun := ir.NewFunc(v.packageData.Path+"_init", types.Void)
 	globalInitBlocks := []*ir.Block{ir.NewBlock("entry")}
 
 	// initialize GC
@@ -94,14 +121,17 @@ func (v *CodeGenVisitor) buildCtorFunc(module *ir.Module, ctx parser.ISourceFile
 		return nil, err
 	}
 	globalInitBlocks[0].NewCall(gcInitFun)
+	v.genCtx.SetEntryBlock(globalInitBlocks[0])
 
 	// initialize defer stack
 	v.deferManager.initDeferStack(module, globalInitBlocks[0])
 
-	for _, decl := range ctx.AllDeclaration() {
-		blocks, err := v.VisitDeclaration(globalInitBlocks[len(globalInitBlocks)-1], true, decl)
+	// global variables are initialized in order of their dependencies
+	for _, init := range initOrder(files) {
+		v.packageData.UseFile(init.file)
+		blocks, err := v.VisitConstVarSpecHelper(globalInitBlocks[len(globalInitBlocks)-1], true, init.spec)
 		if err != nil {
-			return nil, err
+			return nil, utils.MakeErrorTrace(init.spec, err, "failed to parse var declaration")
 		} else if blocks != nil {
 			globalInitBlocks = append(globalInitBlocks, blocks...)
 		}
@@ -111,8 +141,8 @@ func (v *CodeGenVisitor) buildCtorFunc(module *ir.Module, ctx parser.ISourceFile
 	return ctorFun, nil
 }
 
-func (v *CodeGenVisitor) buildDtorFunc(_ parser.ISourceFileContext) (*ir.Func, error) {
-	dtorFun := ir.NewFunc(fmt.Sprintf("%s_cleanup", v.packageData.PackageName), types.Void)
+func (v *CodeGenVisitor) buildDtorFunc() (*ir.Func, error) {
+	dtorFun := ir.NewFunc(v.packageData.Path+"_cleanup", types.Void)
 	globalInitBlocks := []*ir.Block{ir.NewBlock("entry")}
 
 	v.deferManager.cleanupDeferStack(v.genCtx.module, globalInitBlocks[0])
@@ -125,16 +155,13 @@ func (v *CodeGenVisitor) buildDtorFunc(_ parser.ISourceFileContext) (*ir.Func, e
 func (v *CodeGenVisitor) VisitDeclaration(block *ir.Block, globalScope bool, ctx parser.IDeclarationContext) ([]*ir.Block, error) {
 	var newBlocks []*ir.Block
 	// populate global consts and variables
-	if ctx.ConstDecl() != nil {
-		// iota and inherited declarations not supported yet
-		for _, spec := range ctx.ConstDecl().AllConstSpec() {
-			blocks, err := v.VisitConstVarSpecHelper(block, globalScope, spec)
-			if err != nil {
-				return nil, utils.MakeErrorTrace(spec, err, "failed to parse const declaration")
-			} else if blocks != nil {
-				newBlocks = append(newBlocks, blocks...)
-				block = newBlocks[len(newBlocks)-1]
-			}
+	if ctx.ConstDecl() != nil && !globalScope {
+		// global constants are already declared by package listener
+		err := v.genCtx.constEvaluator().EvalConstDecl(ctx.ConstDecl(), func(name string, c *typesystem.Const) error {
+			return v.genCtx.Vars.Add(name, c)
+		})
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse const declaration")
 		}
 	} else if ctx.VarDecl() != nil {
 		for _, spec := range ctx.VarDecl().AllVarSpec() {
@@ -161,15 +188,17 @@ func (v *CodeGenVisitor) VisitConstVarSpecHelper(block *ir.Block, globalScope bo
 	blocks, ids, vals, err := v.VisitConstVarSpec(block, ctx)
 	if err != nil {
 		return nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
 	}
 	for i := range ids {
 		var memRef value.Value
 		if globalScope {
-			glob := v.genCtx.module.NewGlobal(ids[i], vals[i].Type())
+			glob := v.genCtx.module.NewGlobal(v.packageData.symbol(ids[i]), vals[i].Type())
 			glob.Init = constant.NewZeroInitializer(vals[i].Type())
 			memRef = glob
 		} else {
-			memRef = block.NewAlloca(vals[i].Type())
+			memRef = v.genCtx.NewVar(block, ids[i], vals[i].Type())
 		}
 		block.NewStore(vals[i], memRef)
 		if err := v.genCtx.Vars.Add(ids[i], memRef); err != nil {
@@ -180,15 +209,35 @@ func (v *CodeGenVisitor) VisitConstVarSpecHelper(block *ir.Block, globalScope bo
 }
 
 func (v *CodeGenVisitor) VisitConstVarSpec(block *ir.Block, ctx ConstVarContext) ([]*ir.Block, []string, []value.Value, error) {
-	// iota and inherited declarations not supported yet
 	ids := v.genCtx.GenerateIdentList(ctx.IdentifierList())
 	var vals []value.Value
 	var blocks []*ir.Block
 	if ctx.ExpressionList() != nil {
 		var err error
-		vals, blocks, err = v.genCtx.GenerateExprList(block, ctx.ExpressionList())
+		vals, blocks, err = v.genCtx.GenerateAssignedExprList(block, ctx.ExpressionList(), len(ids))
 		if err != nil {
 			return nil, nil, nil, err
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		if ctx.Type_() != nil {
+			// values are converted to declared type
+			llvmType, err := v.ParseType(ctx.Type_())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			for i, val := range vals {
+				vals[i], err = v.genCtx.GenerateAssignConv(block, val, llvmType)
+				if err != nil {
+					return nil, nil, nil, utils.MakeErrorTrace(ctx, err, "cannot use %s as %s value", ctx.ExpressionList().Expression(i).GetText(), ctx.Type_().GetText())
+				}
+			}
+		} else {
+			for i, val := range vals {
+				if vals[i], err = defaultConst(val); err != nil {
+					return nil, nil, nil, utils.MakeErrorTrace(ctx, err, "invalid declaration spec")
+				}
+			}
 		}
 	} else if ctx.Type_() != nil {
 		// zero value init based on type
@@ -215,8 +264,23 @@ func (v *CodeGenVisitor) VisitFunctionDecl(ctx parser.IFunctionDeclContext) inte
 		return utils.MakeErrorTrace(ctx, err, "failed to parse function declaration")
 	}
 
-	v.currentFuncDecl = v.packageData.Functions[fun.Name()]
+	return v.visitFuncBody(fun, v.packageData.Functions[fun.Name()], ctx.Block(), nil)
+}
+
+func (v *CodeGenVisitor) VisitMethodDecl(ctx parser.IMethodDeclContext) interface{} {
+	typeName, _, _ := receiverTypeName(ctx.Receiver().Parameters().ParameterDecl(0).Type_())
+	decl, ok := v.packageData.Methods[typeName][ctx.IDENTIFIER().GetText()]
+	if !ok {
+		return utils.MakeErrorTrace(ctx, nil, "failed to parse method declaration")
+	}
+	return v.visitFuncBody(v.genCtx.Methods[decl.Name], decl, ctx.Block(), nil)
+}
+
+// visitFuncBody generates body of function or method.
+func (v *CodeGenVisitor) visitFuncBody(fun *ir.Func, decl *FunctionDecl, body parser.IBlockContext, captures []capturedVar) interface{} {
+	v.currentFuncDecl = decl
 	v.currentFuncIR = fun
+	v.genCtx.captured = capturedNames(body)
 
 	v.branchManager.EnterFuncDef()
 
@@ -226,17 +290,33 @@ func (v *CodeGenVisitor) VisitFunctionDecl(ctx parser.IFunctionDeclContext) inte
 
 	// populate function arguments
 	block := fun.NewBlock("entry")
+	v.genCtx.SetEntryBlock(block)
+	outCount := len(typesystem.OutParams(decl.ReturnTypes))
 	for i, param := range fun.Params {
-		if i < len(v.currentFuncDecl.ReturnTypes) && len(v.currentFuncDecl.ReturnTypes) > 1 {
-			// out parameter
-			v.genCtx.Vars.Add(param.Name(), param)
+		if i < outCount {
+			// out parameter is written on return
+			continue
+		} else if param.Name() == envParamName {
+			// captured variables are accessed through environment
+			v.genCtx.declareCaptures(block, param, captures)
 		} else {
 			// regular parameter
-			memRef := block.NewAlloca(param.Type())
+			memRef := v.genCtx.NewVar(block, param.Name(), param.Type())
 			block.NewStore(param, memRef)
 			v.genCtx.Vars.Add(param.Name(), memRef)
 		}
 	}
+	// results are zero initialized variables, named ones are visible in body
+	v.results = nil
+	for i, tp := range decl.ReturnTypes {
+		name := decl.ReturnNames[i]
+		memRef := v.genCtx.NewVar(block, name, tp)
+		block.NewStore(constant.NewZeroInitializer(tp), memRef)
+		if name != "" && name != "_" {
+			v.genCtx.Vars.Add(name, memRef)
+		}
+		v.results = append(v.results, memRef)
+	}
 
 	// initialize & cleanup goto labels
 	v.labelManager.clearLabels()
@@ -245,22 +325,30 @@ func (v *CodeGenVisitor) VisitFunctionDecl(ctx parser.IFunctionDeclContext) inte
 	// setup defer stack
 	defer v.clearDeferStack()
 	v.setupDeferStack(block)
+	prologue := []*ir.Block{block}
+	if hasDefers(body) {
+		prologue = append(prologue, v.setupPanicFrame(block)...)
+		block = prologue[len(prologue)-1]
+	}
 
 	// codegen body
-	bodyBlocks, err := v.VisitBlock(block, ctx.Block())
+	bodyBlocks, err := v.VisitBlock(block, body)
 	if err != nil {
-		return utils.MakeErrorTrace(ctx, err, "failed to parse body")
+		return utils.MakeErrorTrace(body, err, "failed to parse body")
 	} else {
-		bodyBlocks = append([]*ir.Block{block}, bodyBlocks...)
-		if bodyBlocks[len(bodyBlocks)-1].Term == nil {
-			// add void return stmt
+		bodyBlocks = append(prologue, bodyBlocks...)
+		if bodyBlocks[len(bodyBlocks)-1].Term == nil && len(decl.ReturnTypes) > 0 {
+			// end of function with result is unreachable (like after exhaustive switch)
+			bodyBlocks[len(bodyBlocks)-1].NewUnreachable()
+		} else if bodyBlocks[len(bodyBlocks)-1].Term == nil {
+			// add return stmt of function without results
 			block = bodyBlocks[len(bodyBlocks)-1]
 			newBlocks := v.applyDefers(block)
 			if newBlocks != nil {
 				bodyBlocks = append(bodyBlocks, newBlocks...)
 				block = newBlocks[len(newBlocks)-1]
 			}
-			block.NewRet(nil)
+			v.genReturn(block)
 		}
 		for _, block := range bodyBlocks {
 			block.Parent = fun
@@ -301,6 +389,10 @@ func (v *CodeGenVisitor) VisitStatement(block *ir.Block, ctx parser.IStatementCo
 		return v.VisitBlock(block, s)
 	case parser.IForStmtContext:
 		return v.VisitForStmt(block, s)
+	case parser.ISwitchStmtContext:
+		return v.VisitSwitchStmt(block, s)
+	case parser.IFallthroughStmtContext:
+		return nil, utils.MakeErrorTrace(ctx, nil, "fallthrough statement out of place")
 	case parser.IDeclarationContext:
 		return v.VisitDeclaration(block, false, s)
 	case parser.IBreakStmtContext:
@@ -313,6 +405,10 @@ func (v *CodeGenVisitor) VisitStatement(block *ir.Block, ctx parser.IStatementCo
 		return v.VisitGotoStmt(block, s)
 	case parser.IDeferStmtContext:
 		return v.VisitDeferStmt(block, s)
+	case parser.IGoStmtContext:
+		return v.VisitGoStmt(block, s)
+	case parser.ISelectStmtContext:
+		return v.VisitSelectStmt(block, s)
 	default:
 		return nil, utils.MakeErrorTrace(ctx, nil, "unsupported instruction")
 	}
@@ -329,29 +425,31 @@ func (v *CodeGenVisitor) VisitSimpleStatement(block *ir.Block, ctx parser.ISimpl
 		return blocks, err
 	case parser.IIncDecStmtContext:
 		return v.VisitIncDecStmt(block, s)
+	case parser.ISendStmtContext:
+		return v.VisitSendStmt(block, s)
 	default:
 		return nil, utils.MakeErrorTrace(ctx, nil, "unimplemented simple statement")
 	}
 }
 
 func (v *CodeGenVisitor) VisitIncDecStmt(block *ir.Block, ctx parser.IIncDecStmtContext) ([]*ir.Block, error) {
-	// assume variable name in expression
-	varName := ctx.Expression().GetText()
-	varRef, ok := v.genCtx.Vars.Lookup(varName)
-	if !ok {
-		return nil, utils.MakeErrorTrace(ctx, nil, "variable %s not found in this scope", varName)
+	lvals, blocks, err := v.genCtx.GenerateLValue(block, ctx.Expression())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse %s", ctx.GetText())
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
 	}
-	itype, ok := varRef.Type().(*types.PointerType).ElemType.(*types.IntType)
-	if !ok {
-		return nil, utils.MakeErrorTrace(ctx, nil, "variable %s is not of integer type", varName)
+	// untyped constant gets type of operand
+	one := typesystem.NewConst(goconstant.MakeInt64(1), nil)
+	op := parser.GoParserPLUS
+	if ctx.MINUS_MINUS() != nil {
+		op = parser.GoParserMINUS
 	}
-	varVal := block.NewLoad(varRef.Type().(*types.PointerType).ElemType, varRef)
-	if ctx.PLUS_PLUS() != nil {
-		block.NewStore(block.NewAdd(varVal, constant.NewInt(itype, 1)), varRef)
-	} else {
-		block.NewStore(block.NewSub(varVal, constant.NewInt(itype, 1)), varRef)
+	newBlocks, err := v.generateAssignOp(block, op, lvals[0], one)
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse %s", ctx.GetText())
 	}
-	return nil, nil
+	return append(blocks, newBlocks...), nil
 }
 
 func (v *CodeGenVisitor) VisitAssignment(block *ir.Block, ctx parser.IAssignmentContext) ([]*ir.Block, error) {
@@ -361,7 +459,7 @@ func (v *CodeGenVisitor) VisitAssignment(block *ir.Block, ctx parser.IAssignment
 	} else if newBlocks != nil {
 		block = newBlocks[len(newBlocks)-1]
 	}
-	rvals, blocks, err := v.genCtx.GenerateExprList(block, ctx.ExpressionList(1))
+	rvals, blocks, err := v.genCtx.GenerateAssignedExprList(block, ctx.ExpressionList(1), len(lvals))
 	if err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse assignment")
 	} else if blocks != nil {
@@ -382,7 +480,11 @@ func (v *CodeGenVisitor) VisitAssignment(block *ir.Block, ctx parser.IAssignment
 	}
 	for i := range len(rvals) {
 		if lvals[i] != nil {
-			block.NewStore(rvals[i], lvals[i])
+			rval, err := v.genCtx.GenerateAssignConv(block, rvals[i], lvals[i].Type().(*types.PointerType).ElemType)
+			if err != nil {
+				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse assignment")
+			}
+			block.NewStore(rval, lvals[i])
 		}
 	}
 	return newBlocks, nil
@@ -392,67 +494,54 @@ func (v *CodeGenVisitor) visitAssignOp(block *ir.Block, ctx parser.IAssign_opCon
 	if len(lvals) != 1 || len(rvals) != 1 {
 		return nil, utils.MakeErrorTrace(ctx, nil, "multiple values in sigle-valued context")
 	}
-	lval := block.NewLoad(lvals[0].Type().(*types.PointerType).ElemType, lvals[0])
-	// check type
-	ctp, ok := typesystem.CommonSupertype(lval, rvals[0])
-	if !ok {
-		return nil, utils.MakeErrorTrace(ctx, nil, "failed to deduce common type for values %s and %s", lvals[0].String(), rvals[0].String())
-	}
-	if ctx.PLUS() != nil {
-		if typesystem.IsFloatType(ctp) {
-			block.NewStore(block.NewFAdd(lval, rvals[0]), lvals[0])
-			return nil, nil
-		} else if typesystem.IsIntType(ctp) {
-			block.NewStore(block.NewAdd(lval, rvals[0]), lvals[0])
-			return nil, nil
-		}
-	} else if ctx.MINUS() != nil {
-		if typesystem.IsFloatType(ctp) {
-			block.NewStore(block.NewFSub(lval, rvals[0]), lvals[0])
-			return nil, nil
-		} else if typesystem.IsIntType(ctp) {
-			block.NewStore(block.NewSub(lval, rvals[0]), lvals[0])
-			return nil, nil
-		}
-	} else if ctx.STAR() != nil {
-		if typesystem.IsFloatType(ctp) {
-			block.NewStore(block.NewFMul(lval, rvals[0]), lvals[0])
-			return nil, nil
-		} else if typesystem.IsIntType(ctp) {
-			block.NewStore(block.NewMul(lval, rvals[0]), lvals[0])
-			return nil, nil
-		}
-	} else if ctx.DIV() != nil {
-		if typesystem.IsFloatType(ctp) {
-			block.NewStore(block.NewFDiv(lval, rvals[0]), lvals[0])
-			return nil, nil
-		} else if typesystem.IsIntType(ctp) {
-			block.NewStore(block.NewSDiv(lval, rvals[0]), lvals[0])
-			return nil, nil
-		} else if typesystem.IsUintType(ctp) {
-			block.NewStore(block.NewUDiv(lval, rvals[0]), lvals[0])
-			return nil, nil
-		}
-	} else {
-		return nil, utils.MakeErrorTrace(ctx, nil, "unsupported operator '%s'", ctx.GetText())
+	// operator token precedes ASSIGN
+	op := ctx.GetChild(0).(antlr.TerminalNode).GetSymbol().GetTokenType()
+	blocks, err := v.generateAssignOp(block, op, lvals[0], rvals[0])
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "invalid operation %s", ctx.GetText())
 	}
-	return nil, utils.MakeErrorTrace(ctx, nil, "unsupported type for %s operation", ctx.GetText())
+	return blocks, nil
+}
+
+// generateAssignOp generates *lval = *lval op rval, address is evaluated by caller once.
+func (v *CodeGenVisitor) generateAssignOp(block *ir.Block, op int, lval, rval value.Value) ([]*ir.Block, error) {
+	if lval == nil {
+		return nil, utils.MakeError("cannot use _ as value")
+	}
+	elemType := lval.Type().(*types.PointerType).ElemType
+	cur := block.NewLoad(elemType, lval)
+	res, blocks, err := v.genCtx.generateBinaryExpr(block, op, elemType, cur, rval)
+	if err != nil {
+		return nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	conv, err := v.genCtx.GenerateAssignConv(block, res[0], elemType)
+	if err != nil {
+		return nil, err
+	}
+	block.NewStore(conv, lval)
+	return blocks, nil
 }
 
 func (v *CodeGenVisitor) VisitShortVarDecl(block *ir.Block, ctx parser.IShortVarDeclContext) ([]*ir.Block, error) {
-	vals, blocks, err := v.genCtx.GenerateExprList(block, ctx.ExpressionList())
+	ids := v.genCtx.GenerateIdentList(ctx.IdentifierList())
+	vals, blocks, err := v.genCtx.GenerateAssignedExprList(block, ctx.ExpressionList(), len(ids))
 	if err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse short var declaration")
 	} else if blocks != nil {
 		block = blocks[len(blocks)-1]
 	}
-	ids := v.genCtx.GenerateIdentList(ctx.IdentifierList())
 	for i, val := range vals {
 		varName := ids[i]
 		if varName == "_" {
 			continue
 		}
-		memRef := block.NewAlloca(val.Type())
+		val, err := defaultConst(val)
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to declare %s", varName)
+		}
+		memRef := v.genCtx.NewVar(block, varName, val.Type())
 		if err := v.genCtx.Vars.Add(varName, memRef); err != nil {
 			return nil, err
 		}
@@ -461,36 +550,41 @@ func (v *CodeGenVisitor) VisitShortVarDecl(block *ir.Block, ctx parser.IShortVar
 	return blocks, nil
 }
 
+// genReturn returns current values of result variables.
+func (v *CodeGenVisitor) genReturn(block *ir.Block) {
+	var vals []value.Value
+	for i, tp := range v.currentFuncDecl.ReturnTypes {
+		vals = append(vals, block.NewLoad(tp, v.results[i]))
+	}
+	generateReturn(block, v.currentFuncIR, vals)
+}
+
 func (v *CodeGenVisitor) VisitReturnStmt(block *ir.Block, ctx parser.IReturnStmtContext) ([]*ir.Block, error) {
-	blocks := v.applyDefers(block)
-	if blocks != nil {
-		block = blocks[len(blocks)-1]
+	// results are evaluated before deferred calls
+	var blocks []*ir.Block
+	var vals []value.Value
+	if ctx.ExpressionList() != nil {
+		var err error
+		vals, blocks, err = v.genCtx.GenerateExprList(block, ctx.ExpressionList())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse return statement")
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		// match return types of function with value types
+		vals, err = v.genCtx.generateAssignConvs(block, vals, v.currentFuncDecl.ReturnTypes)
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse return statement")
+		}
 	}
-	if ctx.ExpressionList() == nil {
-		block.NewRet(nil)
-		return blocks, nil
+	// deferred calls see results assigned by return statement
+	for i, val := range vals {
+		block.NewStore(val, v.results[i])
 	}
-	vals, newBlocks, err := v.genCtx.GenerateExprList(block, ctx.ExpressionList())
-	if err != nil {
-		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse return statement")
-	} else if newBlocks != nil {
+	if newBlocks := v.applyDefers(block); newBlocks != nil {
 		blocks = append(blocks, newBlocks...)
 		block = newBlocks[len(newBlocks)-1]
 	}
-	// match return types of function with value types
-	if len(vals) == 1 {
-		block.NewRet(vals[0])
-	} else if len(vals) > 1 {
-		// store result values in first func params (which are out params now)
-		for i, val := range vals {
-			pName := v.currentFuncIR.Params[i].Name()
-			outPar, ok := v.genCtx.Vars.Lookup(pName)
-			if !ok {
-				return nil, utils.MakeErrorTrace(ctx, nil, "invalid function parameter: %s", pName)
-			}
-			block.NewStore(val, outPar)
-		}
-		block.NewRet(nil)
-	}
+	v.genReturn(block)
 	return blocks, nil
 }
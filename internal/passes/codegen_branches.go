@@ -5,8 +5,13 @@ import (
 	"gocomp/internal/parser"
 	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
+	"sort"
 
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
 )
 
 type branchManager struct {
@@ -39,15 +44,25 @@ func (m *branchManager) topLoopBlocks() loopBlocks {
 }
 
 func (v *CodeGenVisitor) VisitIfStmt(block *ir.Block, ctx parser.IIfStmtContext) ([]*ir.Block, error) {
+	// init statement variables are scoped to if statement, including else branches
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+
+	var newBlocks []*ir.Block
 	if ctx.SimpleStmt() != nil {
-		return nil, utils.MakeErrorTrace(ctx, nil, "unsupported init statement in if")
+		blocks, err := v.VisitSimpleStatement(block, ctx.SimpleStmt())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse if init statement")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
 	}
-	exprs, newBlocks, err := v.genCtx.GenerateExpr(block, ctx.Expression())
+	exprs, blocks, err := v.genCtx.GenerateExpr(block, ctx.Expression())
 	if err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse if expression")
-	} else if !typesystem.IsBoolType(exprs[0].Type()) {
-		return nil, utils.MakeErrorTrace(ctx.Expression(), err, "expression must have boolean type")
-	} else if newBlocks != nil {
+	} else if blocks != nil {
+		newBlocks = append(newBlocks, blocks...)
 		block = newBlocks[len(newBlocks)-1]
 	}
 	stmtUID := v.branchManager.UID
@@ -104,7 +119,7 @@ func (v *CodeGenVisitor) VisitForStmt(block *ir.Block, ctx parser.IForStmtContex
 	if ctx.Expression() != nil {
 		return v.VisitWhileLoop(block, ctx)
 	} else if ctx.RangeClause() != nil {
-		return nil, utils.MakeErrorTrace(ctx, nil, "range for loop not implemented yet")
+		return v.VisitRangeLoop(block, ctx)
 	} else if ctx.ForClause() != nil {
 		return v.VisitForClaused(block, ctx)
 	} else {
@@ -163,6 +178,27 @@ func (v *CodeGenVisitor) VisitForClaused(block *ir.Block, ctx parser.IForStmtCon
 	v.pushLoopStack(bpost, bend)
 	defer v.popLoopStack()
 
+	// captured loop variables are allocated anew on each iteration,
+	// so cell of current iteration is selected by phi
+	var loopVars []string
+	var loopVarPhis []*ir.InstPhi
+	for name := range v.genCtx.Vars.vars {
+		if v.genCtx.captured[name] {
+			loopVars = append(loopVars, name)
+		}
+	}
+	sort.Strings(loopVars)
+	for _, name := range loopVars {
+		loopVarPhis = append(loopVarPhis, condBlock.NewPhi(ir.NewIncoming(v.genCtx.Vars.vars[name], block)))
+	}
+	if loopVars != nil {
+		v.genCtx.PushLexicalScope()
+		defer v.genCtx.PopLexicalScope()
+		for i, name := range loopVars {
+			v.genCtx.Vars.Add(name, typesystem.NewTypedValue(loopVarPhis[i], loopVarPhis[i].Incs[0].X.Type()))
+		}
+	}
+
 	// condition (assume expression always exist)
 	newBlocks = append(newBlocks, condBlock)
 	block.NewBr(condBlock)
@@ -192,14 +228,33 @@ func (v *CodeGenVisitor) VisitForClaused(block *ir.Block, ctx parser.IForStmtCon
 	newBlocks = append(newBlocks, bpost)
 	block = bpost
 
+	// post statement operates on copies of loop variables for next iteration
+	var nextRefs []value.Value
+	if loopVars != nil {
+		v.genCtx.PushLexicalScope()
+		for i, name := range loopVars {
+			ptp := loopVarPhis[i].Type().(*types.PointerType)
+			mem := v.genCtx.NewVar(block, name, ptp.ElemType)
+			block.NewStore(block.NewLoad(ptp.ElemType, loopVarPhis[i]), mem)
+			v.genCtx.Vars.Add(name, mem)
+			nextRefs = append(nextRefs, mem)
+		}
+	}
+
 	// post condition
 	blocks, err = v.VisitSimpleStatement(block, ctx.ForClause().GetPostStmt())
+	if loopVars != nil {
+		v.genCtx.PopLexicalScope()
+	}
 	if err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse for loop postcondition")
 	} else if blocks != nil {
 		newBlocks = append(newBlocks, blocks...)
 		block = newBlocks[len(newBlocks)-1]
 	}
+	for i, phi := range loopVarPhis {
+		phi.Incs = append(phi.Incs, ir.NewIncoming(nextRefs[i], block))
+	}
 	if block.Term == nil {
 		block.NewBr(condBlock)
 	}
@@ -208,6 +263,280 @@ func (v *CodeGenVisitor) VisitForClaused(block *ir.Block, ctx parser.IForStmtCon
 	return newBlocks, nil
 }
 
+// rangeIter describes iteration over range expression.
+type rangeIter struct {
+	keyType  types.Type
+	elemType types.Type
+	length   value.Value
+	// address of iterated array, pointer to array or slice header
+	base value.Value
+	// string iteration decodes runes instead of indexing
+	str value.Value
+	// map iteration walks over pointers to keys of map entries
+	m    value.Value
+	keys value.Value
+}
+
+func (v *CodeGenVisitor) VisitRangeLoop(block *ir.Block, ctx parser.IForStmtContext) ([]*ir.Block, error) {
+	stmtUID := v.branchManager.UID
+	v.branchManager.UID++
+
+	rctx := ctx.RangeClause()
+	var newBlocks []*ir.Block
+
+	// range expression is evaluated only once before loop
+	vals, blocks, err := v.genCtx.GenerateExpr(block, rctx.Expression())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse range expression")
+	} else if blocks != nil {
+		newBlocks = append(newBlocks, blocks...)
+		block = newBlocks[len(newBlocks)-1]
+	}
+	if typesystem.IsChanType(vals[0].Type()) {
+		return v.visitChanRangeLoop(block, ctx, vals[0], newBlocks)
+	}
+	iter, err := v.genRangeIter(block, vals[0])
+	if err != nil {
+		return nil, utils.MakeErrorTrace(rctx.Expression(), err, "failed to parse range expression")
+	}
+
+	// declare iteration variables
+	var keyRef, elemRef value.Value
+	var loopVars []string
+	var loopVarRefs []*value.Value
+	var loopVarTypes []types.Type
+	if rctx.IdentifierList() != nil {
+		ids := v.genCtx.GenerateIdentList(rctx.IdentifierList())
+		if len(ids) > 2 || (len(ids) == 2 && iter.elemType == nil) {
+			return nil, utils.MakeErrorTrace(rctx, nil, "range over %s permits only one iteration variable", rctx.Expression().GetText())
+		}
+		refTypes := []types.Type{iter.keyType, iter.elemType}
+		refs := []*value.Value{&keyRef, &elemRef}
+		for i, id := range ids {
+			if id == "_" {
+				continue
+			} else if v.genCtx.captured[id] {
+				// captured variable is allocated anew on each iteration
+				loopVars = append(loopVars, id)
+				loopVarRefs = append(loopVarRefs, refs[i])
+				loopVarTypes = append(loopVarTypes, refTypes[i])
+				continue
+			}
+			mem := block.NewAlloca(refTypes[i])
+			if err := v.genCtx.Vars.Add(id, mem); err != nil {
+				return nil, utils.MakeErrorTrace(rctx, err, "failed to declare range variable")
+			}
+			*refs[i] = mem
+		}
+	}
+
+	idxType := iter.keyType
+	if iter.keys != nil {
+		idxType = typesystem.Int
+	}
+	itp, _ := typesystem.UnderlyingIntType(idxType)
+	idxRef := v.genCtx.NewTemp(itp)
+	block.NewStore(constant.NewInt(itp, 0), idxRef)
+	var nextRef value.Value
+	if iter.str != nil {
+		nextRef = v.genCtx.NewTemp(iter.keyType)
+	}
+
+	condBlock := ir.NewBlock(fmt.Sprintf("range.cond.%d", stmtUID))
+	bbody := ir.NewBlock(fmt.Sprintf("range.body.%d", stmtUID))
+	bpost := ir.NewBlock(fmt.Sprintf("range.post.%d", stmtUID))
+	bend := ir.NewBlock(fmt.Sprintf("range.end.%d", stmtUID))
+	v.pushLoopStack(bpost, bend)
+	defer v.popLoopStack()
+
+	// condition
+	block.NewBr(condBlock)
+	newBlocks = append(newBlocks, condBlock)
+	idx := condBlock.NewLoad(itp, idxRef)
+	pred := enum.IPredSLT
+	if typesystem.IsUintType(idxType) {
+		pred = enum.IPredULT
+	}
+	condBlock.NewCondBr(condBlock.NewICmp(pred, typesystem.Raw(idx), typesystem.Raw(iter.length)), bbody, bend)
+	newBlocks = append(newBlocks, bbody)
+	block = bbody
+
+	var key value.Value = typesystem.NewTypedValue(idx, iter.keyType)
+	// entries deleted during iteration are skipped
+	var elemAddr value.Value
+	if iter.keys != nil {
+		mapaccess, err := v.genCtx.LookupFunc("runtime_mapaccess")
+		if err != nil {
+			return nil, err
+		}
+		keyAddr := block.NewLoad(types.I8Ptr, block.NewGetElementPtr(types.I8Ptr, iter.keys, idx))
+		elemAddr = block.NewCall(mapaccess, iter.m, keyAddr)
+		bfound := ir.NewBlock(fmt.Sprintf("range.found.%d", stmtUID))
+		block.NewCondBr(block.NewICmp(enum.IPredNE, elemAddr, constant.NewNull(types.I8Ptr)), bfound, bpost)
+		newBlocks = append(newBlocks, bfound)
+		block = bfound
+		key = typesystem.NewTypedValue(block.NewLoad(iter.keyType, block.NewBitCast(keyAddr, types.NewPointer(iter.keyType))), iter.keyType)
+	}
+
+	// iteration values
+	for i, id := range loopVars {
+		mem := v.genCtx.NewVar(block, id, loopVarTypes[i])
+		if err := v.genCtx.Vars.Add(id, mem); err != nil {
+			return nil, utils.MakeErrorTrace(rctx, err, "failed to declare range variable")
+		}
+		*loopVarRefs[i] = mem
+	}
+	var elem value.Value
+	if elemAddr != nil {
+		elem = typesystem.NewTypedValue(block.NewLoad(iter.elemType, block.NewBitCast(elemAddr, types.NewPointer(iter.elemType))), iter.elemType)
+	} else if iter.str != nil {
+		decoderune, err := v.genCtx.LookupFunc("runtime_decoderune")
+		if err != nil {
+			return nil, err
+		}
+		runeRef := v.genCtx.NewTemp(typesystem.Rune)
+		block.NewStore(block.NewCall(decoderune, iter.str, iter.length, idx, runeRef), nextRef)
+		elem = typesystem.NewTypedValue(block.NewLoad(typesystem.Rune, runeRef), typesystem.Rune)
+	} else if iter.elemType != nil && (elemRef != nil || rctx.ExpressionList() != nil) {
+		addr, blocks, err := v.genCtx.GenerateIndexAddr(block, iter.base, idx)
+		if err != nil {
+			return nil, utils.MakeErrorTrace(rctx, err, "failed to generate range element access")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		elem = typesystem.NewTypedValue(block.NewLoad(iter.elemType, addr), iter.elemType)
+	}
+	if rctx.ExpressionList() != nil {
+		// assignment to existing variables
+		lvals, blocks, err := v.genCtx.GenerateLValueList(block, rctx.ExpressionList())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(rctx, err, "failed to parse range assignment")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		if len(lvals) > 2 || (len(lvals) == 2 && iter.elemType == nil) {
+			return nil, utils.MakeErrorTrace(rctx, nil, "range over %s permits only one iteration variable", rctx.Expression().GetText())
+		}
+		keyRef = lvals[0]
+		if len(lvals) == 2 {
+			elemRef = lvals[1]
+		}
+	}
+	if keyRef != nil {
+		block.NewStore(key, keyRef)
+	}
+	if elemRef != nil {
+		block.NewStore(elem, elemRef)
+	}
+
+	// loop body
+	blocks, err = v.VisitBlock(block, ctx.Block())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse range loop body")
+	} else if blocks != nil {
+		newBlocks = append(newBlocks, blocks...)
+		block = newBlocks[len(newBlocks)-1]
+	}
+	if block.Term == nil {
+		block.NewBr(bpost)
+	}
+
+	// advance to next element
+	newBlocks = append(newBlocks, bpost)
+	var next value.Value
+	if iter.str != nil {
+		next = bpost.NewLoad(iter.keyType, nextRef)
+	} else {
+		next = bpost.NewAdd(bpost.NewLoad(itp, idxRef), constant.NewInt(itp, 1))
+	}
+	bpost.NewStore(next, idxRef)
+	bpost.NewBr(condBlock)
+
+	newBlocks = append(newBlocks, bend)
+	return newBlocks, nil
+}
+
+// genRangeIter prepares iteration over integer, string, array, pointer to array,
+// slice or map.
+func (v *CodeGenVisitor) genRangeIter(block *ir.Block, x value.Value) (*rangeIter, error) {
+	// untyped constant gets its default type
+	x, err := defaultConst(x)
+	if err != nil {
+		return nil, err
+	}
+	switch tp := typesystem.Underlying(x.Type()).(type) {
+	case *types.IntType, *typesystem.IntType, *typesystem.UintType:
+		return &rangeIter{keyType: x.Type(), length: x}, nil
+	case *types.ArrayType:
+		// iterate over copy of array
+		base := v.genCtx.NewTemp(x.Type())
+		block.NewStore(x, base)
+		return &rangeIter{
+			keyType:  typesystem.Int,
+			elemType: tp.ElemType,
+			length:   constant.NewInt(typesystem.Int, int64(tp.Len)),
+			base:     base,
+		}, nil
+	case *typesystem.SliceType:
+		base := v.genCtx.NewTemp(x.Type())
+		block.NewStore(x, base)
+		_, length, _ := v.genCtx.GenerateSliceParts(block, x)
+		return &rangeIter{
+			keyType:  typesystem.Int,
+			elemType: tp.ElemType,
+			length:   length,
+			base:     base,
+		}, nil
+	case *typesystem.StringType:
+		ptr, length := v.genCtx.GenerateStringParts(block, x)
+		return &rangeIter{
+			keyType:  typesystem.Int,
+			elemType: typesystem.Rune,
+			length:   length,
+			str:      ptr,
+		}, nil
+	case *typesystem.MapType:
+		// pointers to keys and values of entries present before loop
+		maplen, err := v.genCtx.LookupFunc("runtime_maplen")
+		if err != nil {
+			return nil, err
+		}
+		mapentries, err := v.genCtx.LookupFunc("runtime_mapentries")
+		if err != nil {
+			return nil, err
+		}
+		makeslice, err := v.genCtx.LookupFunc("runtime_makeslice")
+		if err != nil {
+			return nil, err
+		}
+		length := block.NewCall(maplen, x)
+		mem := block.NewCall(makeslice, sizeOf(types.I8Ptr), block.NewMul(length, constant.NewInt(typesystem.Int, 2)))
+		keys := block.NewBitCast(mem, types.NewPointer(types.I8Ptr))
+		block.NewCall(mapentries, x, keys, block.NewGetElementPtr(types.I8Ptr, keys, length))
+		return &rangeIter{
+			keyType:  tp.KeyType,
+			elemType: tp.ElemType,
+			length:   length,
+			m:        x,
+			keys:     keys,
+		}, nil
+	case *types.PointerType:
+		if atp, ok := typesystem.Underlying(tp.ElemType).(*types.ArrayType); ok {
+			base := v.genCtx.NewTemp(x.Type())
+			block.NewStore(x, base)
+			return &rangeIter{
+				keyType:  typesystem.Int,
+				elemType: atp.ElemType,
+				length:   constant.NewInt(typesystem.Int, int64(atp.Len)),
+				base:     base,
+			}, nil
+		}
+	}
+	return nil, utils.MakeError("cannot range over value of type %s", x.Type())
+}
+
 func (v *CodeGenVisitor) VisitWhileLoop(block *ir.Block, ctx parser.IForStmtContext) ([]*ir.Block, error) {
 	stmtUID := v.branchManager.UID
 	v.branchManager.UID++
@@ -246,11 +575,17 @@ func (v *CodeGenVisitor) VisitWhileLoop(block *ir.Block, ctx parser.IForStmtCont
 }
 
 func (v *CodeGenVisitor) VisitBreakStmt(block *ir.Block, ctx parser.IBreakStmtContext) error {
+	if len(v.loopStack) == 0 {
+		return utils.MakeErrorTrace(ctx, nil, "break is not in a loop or switch")
+	}
 	block.NewBr(v.topLoopBlocks().end)
 	return nil
 }
 
 func (v *CodeGenVisitor) VisitContinueStmt(block *ir.Block, ctx parser.IContinueStmtContext) error {
+	if len(v.loopStack) == 0 || v.topLoopBlocks().cond == nil {
+		return utils.MakeErrorTrace(ctx, nil, "continue is not in a loop")
+	}
 	block.NewBr(v.topLoopBlocks().cond)
 	return nil
 }
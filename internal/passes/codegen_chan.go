@@ -0,0 +1,318 @@
+package passes
+
+import (
+	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// VisitGoStmt starts new goroutine. Function value and arguments are evaluated
+// in current goroutine, call is made by thunk passed to scheduler.
+func (v *CodeGenVisitor) VisitGoStmt(block *ir.Block, ctx parser.IGoStmtContext) ([]*ir.Block, error) {
+	v.goCounter++
+	name := fmt.Sprintf("__go_wrpr_%s.%d", v.currentFuncIR.Name(), v.goCounter)
+	wrapper, argsStruct, blocks, err := v.genStmtCall(block, "go", name, ctx.Expression())
+	if err != nil {
+		return nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	newproc, err := v.genCtx.LookupFunc("runtime_newproc")
+	if err != nil {
+		return nil, err
+	}
+	block.NewCall(newproc, wrapper, argsStruct)
+	return blocks, nil
+}
+
+func (v *CodeGenVisitor) VisitSendStmt(block *ir.Block, ctx parser.ISendStmtContext) ([]*ir.Block, error) {
+	chans, blocks, err := v.genCtx.GenerateExpr(block, ctx.GetChannel())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse send statement")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	vals, newBlocks, err := v.genCtx.GenerateExpr(block, ctx.Expression(1))
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse send statement")
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+	}
+	if err := v.genCtx.GenerateChanSend(block, chans[0], vals[0]); err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "invalid operation %s", ctx.GetText())
+	}
+	return blocks, nil
+}
+
+// VisitSelectStmt passes all cases to runtime, which chooses one of ready
+// cases or blocks until some case is ready, and jumps to body of chosen case.
+func (v *CodeGenVisitor) VisitSelectStmt(block *ir.Block, ctx parser.ISelectStmtContext) ([]*ir.Block, error) {
+	stmtUID := v.branchManager.UID
+	v.branchManager.UID++
+
+	clauses := ctx.AllCommClause()
+	bodies := make([]*ir.Block, len(clauses))
+	var bdefault *ir.Block
+	var count int64
+	for i, clause := range clauses {
+		bodies[i] = ir.NewBlock(fmt.Sprintf("select.case.%d.%d", stmtUID, i))
+		if clause.CommCase().DEFAULT() == nil {
+			count++
+		} else if bdefault != nil {
+			return nil, utils.MakeErrorTrace(clause, nil, "multiple defaults in select")
+		} else {
+			bdefault = bodies[i]
+		}
+	}
+	bend := ir.NewBlock(fmt.Sprintf("select.end.%d", stmtUID))
+
+	// channels and values to send are evaluated in source order
+	var newBlocks []*ir.Block
+	cases := v.genCtx.NewTemp(types.NewArray(uint64(count), selectCaseType))
+	recvElems := make([]value.Value, len(clauses))
+	var caseIdxs []int
+	for i, clause := range clauses {
+		comm := clause.CommCase()
+		if comm.DEFAULT() != nil {
+			continue
+		}
+		var ch, elem value.Value
+		var dir int64
+		if send := comm.SendStmt(); send != nil {
+			vals, blocks, err := v.genCtx.GenerateExpr(block, send.GetChannel())
+			if err != nil {
+				return nil, utils.MakeErrorTrace(send, err, "failed to parse select case")
+			} else if blocks != nil {
+				newBlocks = append(newBlocks, blocks...)
+				block = newBlocks[len(newBlocks)-1]
+			}
+			ch = vals[0]
+			vals, blocks, err = v.genCtx.GenerateExpr(block, send.Expression(1))
+			if err != nil {
+				return nil, utils.MakeErrorTrace(send, err, "failed to parse select case")
+			} else if blocks != nil {
+				newBlocks = append(newBlocks, blocks...)
+				block = newBlocks[len(newBlocks)-1]
+			}
+			ctp, err := v.genCtx.chanOperand(ch, typesystem.ChanSend)
+			if err != nil {
+				return nil, utils.MakeErrorTrace(send, err, "invalid select case")
+			}
+			elem, err = v.genCtx.generateChanElem(block, ctp, vals[0])
+			if err != nil {
+				return nil, utils.MakeErrorTrace(send, err, "invalid select case")
+			}
+			dir = selectSend
+		} else {
+			recv := comm.RecvStmt().GetRecvExpr()
+			if !isRecvExpr(recv) {
+				return nil, utils.MakeErrorTrace(recv, nil, "select case must be receive, send or assign recv")
+			}
+			vals, blocks, err := v.genCtx.GenerateExpr(block, recv.Expression(0))
+			if err != nil {
+				return nil, utils.MakeErrorTrace(recv, err, "failed to parse select case")
+			} else if blocks != nil {
+				newBlocks = append(newBlocks, blocks...)
+				block = newBlocks[len(newBlocks)-1]
+			}
+			ch = vals[0]
+			ctp, err := v.genCtx.chanOperand(ch, typesystem.ChanRecv)
+			if err != nil {
+				return nil, utils.MakeErrorTrace(recv, err, "invalid select case")
+			}
+			recvElems[i] = v.genCtx.NewTemp(ctp.ElemType)
+			elem = block.NewBitCast(recvElems[i], types.I8Ptr)
+			dir = selectRecv
+		}
+		v.genCtx.generateSelectCase(block, cases, count, len(caseIdxs), ch, elem, dir)
+		caseIdxs = append(caseIdxs, i)
+	}
+
+	// select blocks unless it has default case
+	sel, err := v.genCtx.LookupFunc("runtime_select")
+	if err != nil {
+		return nil, err
+	}
+	recvOk := v.genCtx.NewTemp(types.I1)
+	chosen := block.NewCall(sel,
+		block.NewBitCast(cases, types.I8Ptr),
+		constant.NewInt(typesystem.Int, count),
+		constant.NewBool(bdefault == nil),
+		recvOk,
+	)
+	var irCases []*ir.Case
+	for idx, i := range caseIdxs {
+		irCases = append(irCases, ir.NewCase(constant.NewInt(typesystem.Int, int64(idx)), bodies[i]))
+	}
+	if bdefault == nil {
+		// chosen case is always valid for blocking select
+		bdefault = bend
+	}
+	block.NewSwitch(chosen, bdefault, irCases...)
+
+	// break leaves select, while continue refers to enclosing loop
+	var bcont *ir.Block
+	if len(v.loopStack) > 0 {
+		bcont = v.topLoopBlocks().cond
+	}
+	v.pushLoopStack(bcont, bend)
+	defer v.popLoopStack()
+
+	for i, clause := range clauses {
+		newBlocks = append(newBlocks, bodies[i])
+		blocks, err := v.visitCommClause(bodies[i], clause, recvElems[i], recvOk)
+		if err != nil {
+			return nil, utils.MakeErrorTrace(clause, err, "failed to parse select case")
+		}
+		block = bodies[i]
+		if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		if block.Term == nil {
+			block.NewBr(bend)
+		}
+	}
+
+	newBlocks = append(newBlocks, bend)
+	return newBlocks, nil
+}
+
+// visitCommClause generates body of select clause. Received value and flag
+// are assigned or declared in scope of clause.
+func (v *CodeGenVisitor) visitCommClause(block *ir.Block, clause parser.ICommClauseContext, recvElem, recvOk value.Value) ([]*ir.Block, error) {
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+
+	var newBlocks []*ir.Block
+	if recv := clause.CommCase().RecvStmt(); recv != nil {
+		elemType := recvElem.Type().(*types.PointerType).ElemType
+		vals := []value.Value{
+			typesystem.NewTypedValue(block.NewLoad(elemType, recvElem), elemType),
+			typesystem.NewTypedValue(block.NewLoad(types.I1, recvOk), typesystem.Bool),
+		}
+		if recv.IdentifierList() != nil {
+			ids := v.genCtx.GenerateIdentList(recv.IdentifierList())
+			for i, id := range ids {
+				if id == "_" {
+					continue
+				}
+				mem := v.genCtx.NewVar(block, id, vals[i].Type())
+				if err := v.genCtx.Vars.Add(id, mem); err != nil {
+					return nil, err
+				}
+				block.NewStore(vals[i], mem)
+			}
+		} else if recv.ExpressionList() != nil {
+			lvals, blocks, err := v.genCtx.GenerateLValueList(block, recv.ExpressionList())
+			if err != nil {
+				return nil, utils.MakeErrorTrace(recv, err, "failed to parse select assignment")
+			} else if blocks != nil {
+				newBlocks = append(newBlocks, blocks...)
+				block = newBlocks[len(newBlocks)-1]
+			}
+			for i, lval := range lvals {
+				if lval == nil {
+					continue
+				}
+				val, err := v.genCtx.GenerateAssignConv(block, vals[i], lval.Type().(*types.PointerType).ElemType)
+				if err != nil {
+					return nil, utils.MakeErrorTrace(recv, err, "failed to parse select assignment")
+				}
+				block.NewStore(val, lval)
+			}
+		}
+	}
+	blocks, fallsThrough, err := v.visitCaseClauseBody(block, clause.StatementList())
+	if err != nil {
+		return nil, err
+	} else if fallsThrough {
+		return nil, utils.MakeErrorTrace(clause, nil, "fallthrough statement out of place")
+	}
+	return append(newBlocks, blocks...), nil
+}
+
+// visitChanRangeLoop generates range loop over channel, which receives
+// values until channel is closed.
+func (v *CodeGenVisitor) visitChanRangeLoop(block *ir.Block, ctx parser.IForStmtContext, ch value.Value, newBlocks []*ir.Block) ([]*ir.Block, error) {
+	stmtUID := v.branchManager.UID - 1
+	rctx := ctx.RangeClause()
+	ctp, err := v.genCtx.chanOperand(ch, typesystem.ChanRecv)
+	if err != nil {
+		return nil, utils.MakeErrorTrace(rctx.Expression(), err, "failed to parse range expression")
+	}
+	chanrecv, err := v.genCtx.LookupFunc("runtime_chanrecv")
+	if err != nil {
+		return nil, err
+	}
+
+	condBlock := ir.NewBlock(fmt.Sprintf("range.cond.%d", stmtUID))
+	bbody := ir.NewBlock(fmt.Sprintf("range.body.%d", stmtUID))
+	bend := ir.NewBlock(fmt.Sprintf("range.end.%d", stmtUID))
+	v.pushLoopStack(condBlock, bend)
+	defer v.popLoopStack()
+
+	// receive until channel is closed
+	elemRef := v.genCtx.NewTemp(ctp.ElemType)
+	block.NewBr(condBlock)
+	newBlocks = append(newBlocks, condBlock)
+	ok := condBlock.NewCall(chanrecv, ch, condBlock.NewBitCast(elemRef, types.I8Ptr))
+	condBlock.NewCondBr(ok, bbody, bend)
+	newBlocks = append(newBlocks, bbody)
+	block = bbody
+
+	// iteration value
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+	elem := typesystem.NewTypedValue(block.NewLoad(ctp.ElemType, elemRef), ctp.ElemType)
+	if rctx.IdentifierList() != nil {
+		ids := v.genCtx.GenerateIdentList(rctx.IdentifierList())
+		if len(ids) > 1 {
+			return nil, utils.MakeErrorTrace(rctx, nil, "range over %s permits only one iteration variable", rctx.Expression().GetText())
+		}
+		if ids[0] != "_" {
+			// each iteration has its own variable
+			mem := v.genCtx.NewVar(block, ids[0], ctp.ElemType)
+			if err := v.genCtx.Vars.Add(ids[0], mem); err != nil {
+				return nil, utils.MakeErrorTrace(rctx, err, "failed to declare range variable")
+			}
+			block.NewStore(elem, mem)
+		}
+	} else if rctx.ExpressionList() != nil {
+		lvals, blocks, err := v.genCtx.GenerateLValueList(block, rctx.ExpressionList())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(rctx, err, "failed to parse range assignment")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		if len(lvals) > 1 {
+			return nil, utils.MakeErrorTrace(rctx, nil, "range over %s permits only one iteration variable", rctx.Expression().GetText())
+		}
+		if lvals[0] != nil {
+			block.NewStore(elem, lvals[0])
+		}
+	}
+
+	// loop body
+	blocks, err := v.VisitBlock(block, ctx.Block())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse range loop body")
+	} else if blocks != nil {
+		newBlocks = append(newBlocks, blocks...)
+		block = newBlocks[len(newBlocks)-1]
+	}
+	if block.Term == nil {
+		block.NewBr(condBlock)
+	}
+
+	newBlocks = append(newBlocks, bend)
+	return newBlocks, nil
+}
@@ -3,8 +3,10 @@ package passes
 import (
 	"fmt"
 	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
 
+	"github.com/antlr4-go/antlr/v4"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
@@ -16,6 +18,8 @@ type deferManager struct {
 	deferStack        []*ir.InstAlloca
 	deferCounter      int // how many defer statements encountered so far in current function
 	deferApplyCounter int
+	goCounter         int         // how many go statements encountered so far in current function
+	panicFrame        value.Value // frame registered in runtime by functions with defers, nil otherwise
 }
 
 // type used in LLVM IR code to keep track of defered calls
@@ -34,35 +38,40 @@ var deferCallStackType = func() *types.StructType {
 var dfStackNodePtr = types.NewPointer(deferCallStackType)
 var deferCallStackTypeSize = 32 // more than enough
 
+// memory for frame registered in runtime, see GO_FRAME_SIZE in runtime.h
+var frameType = types.NewArray(256, types.I8)
+
 func (dm *deferManager) setupDeferStack(block *ir.Block) {
 	callStack := block.NewAlloca(types.NewPointer(deferCallStackType))
 	block.NewStore(constant.NewNull(dfStackNodePtr), callStack)
 	dm.deferStack = append([]*ir.InstAlloca{callStack}, dm.deferStack...)
 	dm.deferCounter = 0
 	dm.deferApplyCounter = 0
+	dm.goCounter = 0
+	dm.panicFrame = nil
 }
 
 func (dm *deferManager) clearDeferStack() {
 	dm.deferStack = dm.deferStack[1:]
 }
 
-func (v *CodeGenVisitor) pushDeferCall(block *ir.Block, funRef *ir.Func, args []value.Value) error {
-	malloc, err := v.genCtx.LookupFunc("GC_malloc")
-	if err != nil {
-		return err
-	}
-
-	v.deferCounter++
-
+// genCallThunk generates wrapper, which calls declared function with arguments
+// stored in memory. Arguments are evaluated and stored by caller of thunk.
+func (v *CodeGenVisitor) genCallThunk(block *ir.Block, funRef *ir.Func, args []value.Value) (*ir.Func, value.Value, error) {
 	// function declaration for multiple return values support
 	funDecl, err := v.genCtx.LookupFuncDeclByIR(funRef)
 	if err != nil {
-		return utils.MakeError("function declaration for %s not found", funRef.String())
+		return nil, nil, utils.MakeError("function declaration for %s not found", funRef.String())
+	}
+	args, err = v.genCtx.generateFuncArgs(block, funRef, funDecl, args)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// create args struct definition
 	module := v.currentFuncIR.Parent
-	tpDefName := "__df_" + funRef.Name()
+	// names are local to package, as thunks of the same function may differ
+	tpDefName := v.genCtx.localSymbol("__df_%s", funRef.Name())
 	var tpDef types.Type
 	for _, tpd := range module.TypeDefs {
 		if tpd.Name() == tpDefName {
@@ -81,7 +90,7 @@ func (v *CodeGenVisitor) pushDeferCall(block *ir.Block, funRef *ir.Func, args []
 	}
 
 	// create wrapper function
-	wrapperFunName := "__df_wrpr_" + funRef.Name()
+	wrapperFunName := v.genCtx.localSymbol("__df_wrpr_%s", funRef.Name())
 	var wrapperFun *ir.Func
 	for _, fn := range module.Funcs {
 		if fn.Name() == wrapperFunName {
@@ -93,16 +102,17 @@ func (v *CodeGenVisitor) pushDeferCall(block *ir.Block, funRef *ir.Func, args []
 		wrapperFun = module.NewFunc(wrapperFunName, types.Void, ir.NewParam("args", types.I8Ptr))
 		// fill function body
 		entry := wrapperFun.NewBlock("entry")
-		if args == nil && len(funDecl.ReturnTypes) <= 1 {
+		// results are discarded, out parameters point to wrapper's memory
+		outParams := typesystem.OutParams(funDecl.ReturnTypes)
+		if len(args) == 0 && len(outParams) == 0 {
 			entry.NewCall(funRef)
 		} else {
 			argsStruct := entry.NewBitCast(wrapperFun.Params[0], types.NewPointer(tpDef))
 			// load real function arguments from passed struct (named args)
 			argValues := []value.Value{}
-			if len(funDecl.ReturnTypes) > 1 {
-				for _, argTp := range funDecl.ReturnTypes {
-					mem := entry.NewAlloca(argTp)
-					argValues = append(argValues, mem)
+			if len(outParams) > 0 {
+				for _, tp := range funDecl.ReturnTypes {
+					argValues = append(argValues, entry.NewAlloca(tp))
 				}
 			}
 			for i, arg := range args {
@@ -121,9 +131,132 @@ func (v *CodeGenVisitor) pushDeferCall(block *ir.Block, funRef *ir.Func, args []
 		entry.NewRet(nil)
 	}
 
+	var argsStructRaw value.Value = constant.NewNull(types.I8Ptr)
+	if len(args) > 0 {
+		argsStructRaw = block.NewCall(v.genCtx.SpecialFuncs["GC_malloc"], sizeOf(tpDef))
+		argsStruct := block.NewBitCast(argsStructRaw, types.NewPointer(tpDef))
+		// fill struct fields
+		for i, arg := range args {
+			offset := block.NewGetElementPtr(
+				tpDef,
+				argsStruct,
+				constant.NewInt(types.I32, 0),
+				constant.NewInt(types.I32, int64(i)),
+			)
+			block.NewStore(arg, offset)
+		}
+	}
+	return wrapperFun, argsStructRaw, nil
+}
+
+// genFuncValueThunk generates wrapper, which calls func value stored along
+// with arguments. Wrapper is generated for each statement with given name.
+func (v *CodeGenVisitor) genFuncValueThunk(block *ir.Block, name string, fv value.Value, args []value.Value) (*ir.Func, value.Value, error) {
+	ftp := typesystem.Underlying(fv.Type()).(*typesystem.FuncType)
+	args, err := v.genCtx.generateAssignConvs(block, args, ftp.ArgTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+	tpDef := types.NewStruct(append([]types.Type{ftp}, ftp.ArgTypes...)...)
+
+	// create wrapper function
+	module := v.currentFuncIR.Parent
+	wrapperFun := module.NewFunc(name, types.Void, ir.NewParam("args", types.I8Ptr))
+	entry := wrapperFun.NewBlock("entry")
+	argsStruct := entry.NewBitCast(wrapperFun.Params[0], types.NewPointer(tpDef))
+	var argValues []value.Value
+	for i, tp := range tpDef.Fields {
+		argValues = append(argValues, typesystem.NewTypedValue(entry.NewLoad(
+			tp,
+			entry.NewGetElementPtr(tpDef, argsStruct, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))),
+		), tp))
+	}
+	if _, err := v.genCtx.GenerateFuncValueCall(entry, argValues[0], argValues[1:]); err != nil {
+		return nil, nil, err
+	}
+	entry.NewRet(nil)
+
+	// func value and arguments are evaluated at statement
+	argsStructRaw := block.NewCall(v.genCtx.SpecialFuncs["GC_malloc"], sizeOf(tpDef))
+	argsPtr := block.NewBitCast(argsStructRaw, types.NewPointer(tpDef))
+	for i, arg := range append([]value.Value{fv}, args...) {
+		block.NewStore(arg, block.NewGetElementPtr(tpDef, argsPtr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))))
+	}
+	return wrapperFun, argsStructRaw, nil
+}
+
+// genStmtCall generates thunk for call of go or defer statement. Function value,
+// method receiver and arguments are evaluated at statement, call is made by thunk.
+// Calls of func values get wrapper with given name.
+func (v *CodeGenVisitor) genStmtCall(block *ir.Block, stmt, wrapperName string, ctx parser.IExpressionContext) (*ir.Func, value.Value, []*ir.Block, error) {
+	// statement can only be function or method call,
+	// so we expect ctx to be primary expression
+	primExpr := ctx.PrimaryExpr()
+	if primExpr == nil || primExpr.PrimaryExpr() == nil || primExpr.Arguments() == nil {
+		return nil, nil, nil, utils.MakeErrorTrace(ctx, nil, "expression in %s must be function call", stmt)
+	}
+	primExpr2 := primExpr.PrimaryExpr()
+	var blocks []*ir.Block
+	var callee value.Value
+	if name, ok := v.genCtx.isBuiltinCall(primExpr2); ok {
+		if name != "close" {
+			return nil, nil, nil, utils.MakeErrorTrace(ctx, nil, "%s of builtin %s not supported", stmt, name)
+		}
+		callee = v.genCtx.SpecialFuncs["runtime_closechan"]
+	} else if fun, ok := v.genCtx.lookupFuncOperand(primExpr2); ok {
+		// declared functions are called directly, other callees are func values
+		callee = fun
+	} else if v.genCtx.isMethodCall(primExpr2) {
+		fv, newBlocks, err := v.genCtx.GenerateMethodValue(block, primExpr2)
+		if err != nil {
+			return nil, nil, nil, err
+		} else if newBlocks != nil {
+			blocks = newBlocks
+			block = blocks[len(blocks)-1]
+		}
+		callee = fv
+	} else {
+		exprs, newBlocks, err := v.genCtx.GeneratePrimaryExpr(block, primExpr2)
+		if err != nil {
+			return nil, nil, nil, err
+		} else if newBlocks != nil {
+			blocks = newBlocks
+			block = blocks[len(blocks)-1]
+		}
+		callee = exprs[0]
+	}
+	args, newBlocks, err := v.genCtx.GenerateArguments(block, primExpr.Arguments())
+	if err != nil {
+		return nil, nil, nil, err
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+	}
+	if callee == v.genCtx.SpecialFuncs["runtime_closechan"] && len(args) == 1 {
+		if _, err := v.genCtx.chanOperand(args[0], typesystem.ChanSend); err != nil {
+			return nil, nil, nil, utils.MakeErrorTrace(ctx, err, "invalid argument for close")
+		}
+	}
+	var wrapper *ir.Func
+	var argsStruct value.Value
+	if fun, ok := callee.(*ir.Func); ok {
+		wrapper, argsStruct, err = v.genCallThunk(block, fun, args)
+	} else if typesystem.IsFuncType(callee.Type()) {
+		wrapper, argsStruct, err = v.genFuncValueThunk(block, wrapperName, callee, args)
+	} else {
+		err = utils.MakeError("%s requires function call, got %s", stmt, primExpr.GetText())
+	}
+	if err != nil {
+		return nil, nil, nil, utils.MakeErrorTrace(ctx, err, "invalid %s statement", stmt)
+	}
+	return wrapper, argsStruct, blocks, nil
+}
+
+// pushDeferNode pushes wrapper of deferred call with its arguments to defer stack.
+func (v *CodeGenVisitor) pushDeferNode(block *ir.Block, wrapperFun *ir.Func, argsStruct value.Value) {
 	// create defer stack node
 	nodeMem := block.NewBitCast(
-		block.NewCall(malloc, constant.NewInt(types.I64, int64(deferCallStackTypeSize))),
+		block.NewCall(v.genCtx.SpecialFuncs["GC_malloc"], constant.NewInt(types.I64, int64(deferCallStackTypeSize))),
 		dfStackNodePtr,
 	)
 
@@ -136,29 +269,13 @@ func (v *CodeGenVisitor) pushDeferCall(block *ir.Block, funRef *ir.Func, args []
 	)
 	block.NewStore(wrapperFun, node_FuncRef)
 
-	if args != nil || funDecl.ReturnTypes != nil {
-		node_argsRef := block.NewGetElementPtr(
-			deferCallStackType,
-			nodeMem,
-			constant.NewInt(types.I32, 0),
-			constant.NewInt(types.I32, 1),
-		)
-		// TODO: merge malloc calls
-		// TODO: calculate exact storage size needed
-		argsStructRaw := block.NewCall(malloc, constant.NewInt(types.I64, 32))
-		argsStruct := block.NewBitCast(argsStructRaw, types.NewPointer(tpDef))
-		// fill struct fields
-		for i, arg := range args {
-			offset := block.NewGetElementPtr(
-				tpDef,
-				argsStruct,
-				constant.NewInt(types.I32, 0),
-				constant.NewInt(types.I32, int64(i)),
-			)
-			block.NewStore(arg, offset)
-		}
-		block.NewStore(argsStructRaw, node_argsRef)
-	}
+	node_argsRef := block.NewGetElementPtr(
+		deferCallStackType,
+		nodeMem,
+		constant.NewInt(types.I32, 0),
+		constant.NewInt(types.I32, 1),
+	)
+	block.NewStore(argsStruct, node_argsRef)
 
 	node_NextRef := block.NewGetElementPtr(
 		deferCallStackType,
@@ -167,11 +284,11 @@ func (v *CodeGenVisitor) pushDeferCall(block *ir.Block, funRef *ir.Func, args []
 		constant.NewInt(types.I32, 2),
 	)
 	nextRef := block.NewLoad(dfStackNodePtr, v.deferStack[0])
+	nextRef.Volatile = true
 	block.NewStore(nextRef, node_NextRef)
 
 	// update local stack head
-	block.NewStore(nodeMem, v.deferStack[0])
-	return nil
+	block.NewStore(nodeMem, v.deferStack[0]).Volatile = true
 }
 
 // must be called from main__init func
@@ -184,12 +301,22 @@ func (dm *deferManager) cleanupDeferStack(module *ir.Module, block *ir.Block) {
 	// pass
 }
 
+// applyDefers runs deferred calls and removes frame of function on return.
 func (dm *CodeGenVisitor) applyDefers(block *ir.Block) []*ir.Block {
-	if dm.deferCounter == 0 {
+	if dm.panicFrame == nil {
 		// nothing to do - no waste of CPU cycles
 		return nil
 	}
+	blocks := dm.genDeferLoop(block)
+	loopEnd := blocks[len(blocks)-1]
+	loopEnd.NewCall(dm.genCtx.SpecialFuncs["runtime_popframe"], dm.panicFrame)
+	return blocks
+}
 
+// genDeferLoop generates calls of deferred functions in LIFO order. Node is
+// removed from defer stack before call, so panic in deferred call continues
+// with the next one. Defer stack head is volatile, as it is read after longjmp.
+func (dm *CodeGenVisitor) genDeferLoop(block *ir.Block) []*ir.Block {
 	// traverse defer stack list from head
 	loopStart := ir.NewBlock(fmt.Sprintf("__df_loop_start.%d", dm.deferApplyCounter))
 	loopBody := ir.NewBlock(fmt.Sprintf("__df_loop_body.%d", dm.deferApplyCounter))
@@ -200,11 +327,19 @@ func (dm *CodeGenVisitor) applyDefers(block *ir.Block) []*ir.Block {
 
 	// check if stack is empty
 	nodeRef := loopStart.NewLoad(dfStackNodePtr, dm.deferStack[0])
+	nodeRef.Volatile = true
 	cmpRes := loopStart.NewICmp(enum.IPredEQ, nodeRef, constant.NewNull(dfStackNodePtr))
 	loopStart.NewCondBr(cmpRes, loopEnd, loopBody)
 
-	// load stack head
-	nodeRef = loopBody.NewLoad(dfStackNodePtr, dm.deferStack[0])
+	// update head to next node
+	nextNodeRef := loopBody.NewGetElementPtr(
+		deferCallStackType,
+		nodeRef,
+		constant.NewInt(types.I32, 0),
+		constant.NewInt(types.I32, 2),
+	)
+	nextNode := loopBody.NewLoad(dfStackNodePtr, nextNodeRef)
+	loopBody.NewStore(nextNode, dm.deferStack[0]).Volatile = true
 
 	// call defered function
 	funcOffset := loopBody.NewGetElementPtr(
@@ -226,49 +361,62 @@ func (dm *CodeGenVisitor) applyDefers(block *ir.Block) []*ir.Block {
 		),
 	)
 	loopBody.NewCall(funcRef, argsStruct)
-
-	// update head to next node
-	nextNodeRef := loopBody.NewGetElementPtr(
-		deferCallStackType,
-		nodeRef,
-		constant.NewInt(types.I32, 0),
-		constant.NewInt(types.I32, 2),
-	)
-	nextNode := loopBody.NewLoad(dfStackNodePtr, nextNodeRef)
-	loopBody.NewStore(nextNode, dm.deferStack[0])
 	// goto loop start
 	loopBody.NewBr(loopStart)
 
 	return []*ir.Block{loopStart, loopBody, loopEnd}
 }
 
+// hasDefers checks if function body contains defer statements, not counting
+// bodies of function literals.
+func hasDefers(tree antlr.Tree) bool {
+	if _, ok := tree.(parser.IFunctionLitContext); ok {
+		return false
+	} else if _, ok := tree.(parser.IDeferStmtContext); ok {
+		return true
+	}
+	for _, child := range tree.GetChildren() {
+		if hasDefers(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// setupPanicFrame registers frame of function with defers in runtime. Panic
+// jumps back to frame, which runs deferred calls and returns zero results
+// if panic is recovered. Returns blocks ending with block of function body.
+func (v *CodeGenVisitor) setupPanicFrame(block *ir.Block) []*ir.Block {
+	frameMem := block.NewAlloca(frameType)
+	frameMem.Align = 16
+	v.panicFrame = block.NewBitCast(frameMem, types.I8Ptr)
+	block.NewCall(v.genCtx.SpecialFuncs["runtime_pushframe"], v.panicFrame)
+	jumped := block.NewCall(v.genCtx.SpecialFuncs["_setjmp"], v.panicFrame)
+	jumped.FuncAttrs = append(jumped.FuncAttrs, enum.FuncAttrReturnsTwice)
+
+	body := ir.NewBlock("__df_body")
+	landing := ir.NewBlock("__df_panic")
+	block.NewCondBr(block.NewICmp(enum.IPredNE, jumped, constant.NewInt(types.I32, 0)), landing, body)
+
+	blocks := append([]*ir.Block{landing}, v.genDeferLoop(landing)...)
+	recovered := blocks[len(blocks)-1]
+	recovered.NewCall(v.genCtx.SpecialFuncs["runtime_unwind"], v.panicFrame)
+	v.genReturn(recovered)
+	return append(blocks, body)
+}
+
 func (v *CodeGenVisitor) VisitDeferStmt(block *ir.Block, ctx parser.IDeferStmtContext) ([]*ir.Block, error) {
-	// defer statement can only be function or method call
-	// so we expect ctx to be primary expression
 	if ctx.Expression() == nil {
 		return nil, utils.MakeError("defer statement must be expression")
 	}
-	primExpr := ctx.Expression().PrimaryExpr()
-	if primExpr == nil {
-		return nil, utils.MakeError("defer statement must be primary expression")
-	}
-	primExpr2 := primExpr.PrimaryExpr()
-	if primExpr2 == nil {
-		return nil, utils.MakeError("defer statement must be function or method call")
-	}
-	exprs, blocks, err := v.genCtx.GeneratePrimaryExpr(block, primExpr2)
+	v.deferCounter++
+	name := fmt.Sprintf("__df_wrpr_%s.%d", v.currentFuncIR.Name(), v.deferCounter)
+	wrapper, argsStruct, blocks, err := v.genStmtCall(block, "defer", name, ctx.Expression())
 	if err != nil {
 		return nil, err
 	} else if blocks != nil {
 		block = blocks[len(blocks)-1]
 	}
-	args, blocks, err := v.genCtx.GenerateArguments(block, primExpr.Arguments())
-	if err != nil {
-		return nil, err
-	}
-	err = v.pushDeferCall(block, exprs[0].(*ir.Func), args)
-	if err != nil {
-		return nil, err
-	}
+	v.pushDeferNode(block, wrapper, argsStruct)
 	return blocks, nil
 }
@@ -0,0 +1,393 @@
+package passes
+
+import (
+	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func (v *CodeGenVisitor) VisitSwitchStmt(block *ir.Block, ctx parser.ISwitchStmtContext) ([]*ir.Block, error) {
+	if ctx.TypeSwitchStmt() != nil {
+		return v.VisitTypeSwitchStmt(block, ctx.TypeSwitchStmt())
+	}
+	return v.VisitExprSwitchStmt(block, ctx.ExprSwitchStmt())
+}
+
+func (v *CodeGenVisitor) VisitExprSwitchStmt(block *ir.Block, ctx parser.IExprSwitchStmtContext) ([]*ir.Block, error) {
+	stmtUID := v.branchManager.UID
+	v.branchManager.UID++
+
+	// init statement variables are scoped to switch statement
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+
+	var newBlocks []*ir.Block
+	if ctx.SimpleStmt() != nil {
+		blocks, err := v.VisitSimpleStatement(block, ctx.SimpleStmt())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse switch init statement")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+	}
+	// tag is evaluated once, missing tag means 'true'
+	var tag value.Value
+	if ctx.Expression() != nil {
+		vals, blocks, err := v.genCtx.GenerateExpr(block, ctx.Expression())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse switch tag")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		// untyped constant gets its default type
+		if tag, err = defaultConst(vals[0]); err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse switch tag")
+		}
+	}
+
+	clauses := ctx.AllExprCaseClause()
+	bodies := make([]*ir.Block, len(clauses))
+	var bdefault *ir.Block
+	for i, clause := range clauses {
+		bodies[i] = ir.NewBlock(fmt.Sprintf("switch.case.%d.%d", stmtUID, i))
+		if clause.ExprSwitchCase().DEFAULT() != nil {
+			if bdefault != nil {
+				return nil, utils.MakeErrorTrace(clause, nil, "multiple defaults in switch")
+			}
+			bdefault = bodies[i]
+		}
+	}
+	bend := ir.NewBlock(fmt.Sprintf("switch.end.%d", stmtUID))
+	if bdefault == nil {
+		bdefault = bend
+	}
+
+	// jump to matching case
+	blocks, err := v.genSwitchDispatch(block, stmtUID, tag, clauses, bodies, bdefault)
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse switch cases")
+	} else if blocks != nil {
+		newBlocks = append(newBlocks, blocks...)
+	}
+
+	// break leaves switch, while continue refers to enclosing loop
+	var bcont *ir.Block
+	if len(v.loopStack) > 0 {
+		bcont = v.topLoopBlocks().cond
+	}
+	v.pushLoopStack(bcont, bend)
+	defer v.popLoopStack()
+
+	for i, clause := range clauses {
+		newBlocks = append(newBlocks, bodies[i])
+		blocks, fallsThrough, err := v.visitCaseClauseBody(bodies[i], clause.StatementList())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(clause, err, "failed to parse switch case")
+		}
+		block = bodies[i]
+		if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		if fallsThrough && i+1 == len(clauses) {
+			return nil, utils.MakeErrorTrace(clause, nil, "cannot fallthrough final case in switch")
+		}
+		if block.Term == nil {
+			if fallsThrough {
+				block.NewBr(bodies[i+1])
+			} else {
+				block.NewBr(bend)
+			}
+		}
+	}
+
+	newBlocks = append(newBlocks, bend)
+	return newBlocks, nil
+}
+
+// genSwitchDispatch emits single LLVM switch instruction if all case values are
+// integer constants. Otherwise case values are compared with tag one by one in order.
+func (v *CodeGenVisitor) genSwitchDispatch(block *ir.Block, stmtUID int, tag value.Value, clauses []parser.IExprCaseClauseContext, bodies []*ir.Block, bdefault *ir.Block) ([]*ir.Block, error) {
+	if tag != nil {
+		cases, ok, err := v.constSwitchCases(tag, clauses, bodies)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			block.NewSwitch(tag, bdefault, cases...)
+			return nil, nil
+		}
+	}
+	var newBlocks []*ir.Block
+	for i, clause := range clauses {
+		if clause.ExprSwitchCase().DEFAULT() != nil {
+			continue
+		}
+		for j, expr := range clause.ExprSwitchCase().ExpressionList().AllExpression() {
+			vals, blocks, err := v.genCtx.GenerateExpr(block, expr)
+			if err != nil {
+				return nil, utils.MakeErrorTrace(expr, err, "failed to parse case expression")
+			} else if blocks != nil {
+				newBlocks = append(newBlocks, blocks...)
+				block = newBlocks[len(newBlocks)-1]
+			}
+			var cond value.Value
+			if tag == nil {
+				cond = vals[0]
+			} else {
+				val, err := adaptConstant(vals[0], tag.Type())
+				if err == nil {
+					cond, err = v.genCtx.GenerateCompare(block, parser.GoParserEQUALS, tag, val)
+				}
+				if err != nil {
+					return nil, utils.MakeErrorTrace(expr, err, "invalid case %s in switch", expr.GetText())
+				}
+			}
+			bnext := ir.NewBlock(fmt.Sprintf("switch.next.%d.%d.%d", stmtUID, i, j))
+			block.NewCondBr(cond, bodies[i], bnext)
+			newBlocks = append(newBlocks, bnext)
+			block = bnext
+		}
+	}
+	block.NewBr(bdefault)
+	return newBlocks, nil
+}
+
+// constSwitchCases collects cases of LLVM switch instruction. Reports false
+// if tag is not integer or some case value is not integer constant.
+func (v *CodeGenVisitor) constSwitchCases(tag value.Value, clauses []parser.IExprCaseClauseContext, bodies []*ir.Block) ([]*ir.Case, bool, error) {
+	if _, ok := typesystem.UnderlyingIntType(tag.Type()); !ok || typesystem.IsBoolType(tag.Type()) {
+		return nil, false, nil
+	}
+	// case values are evaluated in scratch block, that is thrown away
+	scratch := ir.NewBlock("")
+	var cases []*ir.Case
+	seen := make(map[string]bool)
+	for i, clause := range clauses {
+		if clause.ExprSwitchCase().DEFAULT() != nil {
+			continue
+		}
+		for _, expr := range clause.ExprSwitchCase().ExpressionList().AllExpression() {
+			vals, blocks, err := v.genCtx.GenerateExpr(scratch, expr)
+			if err != nil || blocks != nil {
+				return nil, false, nil
+			}
+			val, err := adaptConstant(vals[0], tag.Type())
+			if err != nil {
+				return nil, false, utils.MakeErrorTrace(expr, err, "invalid case %s in switch", expr.GetText())
+			}
+			c, ok := val.(*typesystem.Const)
+			if !ok || !c.Type().Equal(tag.Type()) {
+				return nil, false, nil
+			}
+			key := c.Val.ExactString()
+			if seen[key] {
+				return nil, false, utils.MakeErrorTrace(expr, nil, "duplicate case %s in switch", expr.GetText())
+			}
+			seen[key] = true
+			cases = append(cases, ir.NewCase(c.IR(), bodies[i]))
+		}
+	}
+	return cases, true, nil
+}
+
+// visitCaseClauseBody generates statements of case clause in its own scope.
+// Reports whether clause ends with fallthrough statement.
+func (v *CodeGenVisitor) visitCaseClauseBody(block *ir.Block, ctx parser.IStatementListContext) ([]*ir.Block, bool, error) {
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+
+	if ctx == nil {
+		return nil, false, nil
+	}
+	stmts := ctx.AllStatement()
+	fallsThrough := false
+	if len(stmts) > 0 {
+		if _, ok := stmts[len(stmts)-1].GetChild(0).(parser.IFallthroughStmtContext); ok {
+			fallsThrough = true
+			stmts = stmts[:len(stmts)-1]
+		}
+	}
+	var blocks []*ir.Block
+	for _, stmt := range stmts {
+		if newBlocks, err := v.VisitStatement(block, stmt); err != nil {
+			return nil, false, utils.MakeErrorTrace(stmt, err, "failed to parse statement")
+		} else if newBlocks != nil {
+			blocks = append(blocks, newBlocks...)
+			block = blocks[len(blocks)-1]
+		}
+	}
+	return blocks, fallsThrough, nil
+}
+
+func (v *CodeGenVisitor) VisitTypeSwitchStmt(block *ir.Block, ctx parser.ITypeSwitchStmtContext) ([]*ir.Block, error) {
+	stmtUID := v.branchManager.UID
+	v.branchManager.UID++
+
+	// init statement variables are scoped to switch statement
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+
+	var newBlocks []*ir.Block
+	if ctx.SimpleStmt() != nil {
+		blocks, err := v.VisitSimpleStatement(block, ctx.SimpleStmt())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse switch init statement")
+		} else if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+	}
+	// guard is evaluated once
+	guard := ctx.TypeSwitchGuard()
+	vals, blocks, err := v.genCtx.GeneratePrimaryExpr(block, guard.PrimaryExpr())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse type switch guard")
+	} else if blocks != nil {
+		newBlocks = append(newBlocks, blocks...)
+		block = newBlocks[len(newBlocks)-1]
+	}
+	iface := vals[0]
+	itp := iface.Type().(*typesystem.InterfaceType)
+	tab, data := v.genCtx.GenerateIfaceParts(block, iface)
+	dyn, blocks := v.genCtx.generateDynType(block, tab)
+	newBlocks = append(newBlocks, blocks...)
+	block = newBlocks[len(newBlocks)-1]
+
+	clauses := ctx.AllTypeCaseClause()
+	bodies := make([]*ir.Block, len(clauses))
+	var bdefault *ir.Block
+	for i, clause := range clauses {
+		bodies[i] = ir.NewBlock(fmt.Sprintf("typeswitch.case.%d.%d", stmtUID, i))
+		if clause.TypeSwitchCase().DEFAULT() != nil {
+			if bdefault != nil {
+				return nil, utils.MakeErrorTrace(clause, nil, "multiple defaults in switch")
+			}
+			bdefault = bodies[i]
+		}
+	}
+	bend := ir.NewBlock(fmt.Sprintf("typeswitch.end.%d", stmtUID))
+	if bdefault == nil {
+		bdefault = bend
+	}
+
+	// jump to first matching case, guard variable gets case type in single type clauses
+	caseVals := make([]value.Value, len(clauses))
+	seen := make(map[string]bool)
+	for i, clause := range clauses {
+		if clause.TypeSwitchCase().DEFAULT() != nil {
+			continue
+		}
+		typeList := clause.TypeSwitchCase().TypeList()
+		single := len(typeList.AllType_()) == 1 && len(typeList.AllNIL_LIT()) == 0
+		for j, child := range typeList.GetChildren() {
+			var cond value.Value
+			caseName := "nil"
+			if typeCtx, ok := child.(parser.IType_Context); ok {
+				tp, err := v.genCtx.PackageData.ParseType(typeCtx)
+				if err != nil {
+					return nil, utils.MakeErrorTrace(typeCtx, err, "failed to parse type switch case")
+				}
+				caseName = v.genCtx.PackageData.typeName(tp)
+				if ctp, ok := tp.(*typesystem.InterfaceType); ok {
+					assertI2I, err := v.genCtx.LookupFunc("runtime_assertI2I")
+					if err != nil {
+						return nil, err
+					}
+					newTab := block.NewCall(assertI2I, tab, v.genCtx.ifaceDesc(ctp), constant.NewBool(true))
+					cond = block.NewICmp(enum.IPredNE, newTab, constant.NewNull(types.I8Ptr))
+					if single {
+						caseVals[i] = v.genCtx.GenerateIfacePair(bodies[i], ctp, newTab, data)
+					}
+				} else {
+					if _, err := v.genCtx.PackageData.implements(tp, itp); err != nil {
+						return nil, utils.MakeErrorTrace(typeCtx, err, "impossible type switch case")
+					}
+					want, err := v.genCtx.typeDesc(tp)
+					if err != nil {
+						return nil, utils.MakeErrorTrace(typeCtx, err, "failed to parse type switch case")
+					}
+					cond = block.NewICmp(enum.IPredEQ, dyn, want)
+					if single {
+						ptr := typesystem.NewTypedValue(bodies[i].NewBitCast(data, types.NewPointer(tp)), types.NewPointer(tp))
+						caseVals[i] = typesystem.NewTypedValue(bodies[i].NewLoad(tp, ptr), tp)
+					}
+				}
+			} else if term, ok := child.(antlr.TerminalNode); ok && term.GetSymbol().GetTokenType() == parser.GoParserNIL_LIT {
+				cond = block.NewICmp(enum.IPredEQ, tab, constant.NewNull(types.I8Ptr))
+			} else {
+				continue
+			}
+			if seen[caseName] {
+				return nil, utils.MakeErrorTrace(clause, nil, "duplicate case %s in type switch", caseName)
+			}
+			seen[caseName] = true
+			bnext := ir.NewBlock(fmt.Sprintf("typeswitch.next.%d.%d.%d", stmtUID, i, j))
+			block.NewCondBr(cond, bodies[i], bnext)
+			newBlocks = append(newBlocks, bnext)
+			block = bnext
+		}
+	}
+	block.NewBr(bdefault)
+
+	// break leaves switch, while continue refers to enclosing loop
+	var bcont *ir.Block
+	if len(v.loopStack) > 0 {
+		bcont = v.topLoopBlocks().cond
+	}
+	v.pushLoopStack(bcont, bend)
+	defer v.popLoopStack()
+
+	for i, clause := range clauses {
+		newBlocks = append(newBlocks, bodies[i])
+		blocks, err := v.visitTypeCaseClause(bodies[i], guard, iface, caseVals[i], clause)
+		if err != nil {
+			return nil, utils.MakeErrorTrace(clause, err, "failed to parse type switch case")
+		}
+		block = bodies[i]
+		if blocks != nil {
+			newBlocks = append(newBlocks, blocks...)
+			block = newBlocks[len(newBlocks)-1]
+		}
+		if block.Term == nil {
+			block.NewBr(bend)
+		}
+	}
+
+	newBlocks = append(newBlocks, bend)
+	return newBlocks, nil
+}
+
+// visitTypeCaseClause generates body of type switch clause, where guard variable
+// holds value of case type or interface value itself if caseVal is nil.
+func (v *CodeGenVisitor) visitTypeCaseClause(block *ir.Block, guard parser.ITypeSwitchGuardContext, iface, caseVal value.Value, clause parser.ITypeCaseClauseContext) ([]*ir.Block, error) {
+	v.genCtx.PushLexicalScope()
+	defer v.genCtx.PopLexicalScope()
+
+	if guard.IDENTIFIER() != nil && guard.IDENTIFIER().GetText() != "_" {
+		if caseVal == nil {
+			caseVal = iface
+		}
+		mem := v.genCtx.NewTemp(caseVal.Type())
+		block.NewStore(caseVal, mem)
+		if err := v.genCtx.Vars.Add(guard.IDENTIFIER().GetText(), mem); err != nil {
+			return nil, err
+		}
+	}
+	blocks, fallsThrough, err := v.visitCaseClauseBody(block, clause.StatementList())
+	if err != nil {
+		return nil, err
+	} else if fallsThrough {
+		return nil, utils.MakeErrorTrace(clause, nil, "cannot fallthrough in type switch")
+	}
+	return blocks, nil
+}
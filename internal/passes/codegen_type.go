@@ -2,37 +2,53 @@ package passes
 
 import (
 	"fmt"
+	goconstant "go/constant"
 	"gocomp/internal/parser"
 	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
-	"strconv"
+	"sort"
+	"strings"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/types"
 )
 
 type typeManager struct {
-	// aliases to base types, created with 'type' keyword
-	userAliases map[string]types.Type
+	// import path of package, which declares types
+	pkgPath string
+	// named non-struct types and aliases, created with 'type' keyword
+	userTypes map[string]types.Type
 	// struct types created with 'type' keyword
 	userStructs map[string]*typesystem.StructInfo
+	// evaluator of constant expressions, like array lengths, in current
+	// scope (set by package listener and code generator)
+	evalConst func(ctx parser.IExpressionContext) (*typesystem.Const, bool, error)
 }
 
-func newTypeManager() *typeManager {
+func newTypeManager(pkgPath string) *typeManager {
 	return &typeManager{
-		userAliases: make(map[string]types.Type),
+		pkgPath:     pkgPath,
+		userTypes:   make(map[string]types.Type),
 		userStructs: make(map[string]*typesystem.StructInfo),
 	}
 }
 
 func (m *typeManager) UpdateModule(module *ir.Module) {
-	for _, tp := range m.userAliases {
-		module.TypeDefs = append(module.TypeDefs, tp)
+	// non-struct types have no named LLVM type, aliases and imported
+	// types may repeat, so type defs are sorted by name
+	seen := make(map[*typesystem.StructInfo]bool)
+	var defs []*typesystem.StructInfo
+	for _, tp := range m.userStructs {
+		if !seen[tp] && tp.Name() != "" {
+			seen[tp] = true
+			defs = append(defs, tp)
+		}
 	}
-	for name, tp := range m.userStructs {
-		lltp := tp
-		lltp.TypeName = name
-		module.TypeDefs = append(module.TypeDefs, lltp)
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Name() < defs[j].Name()
+	})
+	for _, tp := range defs {
+		module.TypeDefs = append(module.TypeDefs, tp)
 	}
 }
 
@@ -60,43 +76,63 @@ func (m *typeManager) ParseAliasDecl(ctx parser.IAliasDeclContext) error {
 	if err != nil {
 		return err
 	}
-	if _, ok := m.userAliases[name]; ok {
-		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined as alias to primary type", name))
+	if _, ok := m.userTypes[name]; ok {
+		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined", name))
 	}
 	if _, ok := m.userStructs[name]; ok {
-		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined as alias to struct type", name))
+		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined as struct type", name))
 	}
 	if stp, ok := tp.(*typesystem.StructInfo); ok {
 		m.userStructs[name] = stp
 	} else {
-		m.userAliases[name] = tp
+		m.userTypes[name] = tp
 	}
 	return nil
 }
 
 func (m *typeManager) ParseTypeDef(ctx parser.ITypeDefContext) error {
 	name := ctx.IDENTIFIER().GetText()
-	if _, ok := m.userAliases[name]; ok {
-		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined as alias to primary type", name))
+	if _, ok := m.userTypes[name]; ok {
+		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined", name))
 	}
 	if _, ok := m.userStructs[name]; ok {
-		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined as alias to struct type", name))
+		return utils.MakeErrorTrace(ctx, nil, fmt.Sprintf("type %s already defined as struct type", name))
 	}
 	// look ahead for recursive struct parsing
 	tmpInfo := &typesystem.StructInfo{}
 	tmpInfo.TypeName = name
+	tmpInfo.StructType.SetName(m.pkgPath + "." + name)
 	m.userStructs[name] = tmpInfo
 
 	tp, err := m.ParseType(ctx.Type_())
 	if err != nil {
+		// type may be declared again, once types it refers to are known
+		delete(m.userStructs, name)
 		return err
 	}
-	if stp, ok := tp.(*typesystem.StructInfo); ok {
-		stp.SetName(name)
-		m.userStructs[name] = stp
-		stp.UpdateRecursiveRef(tmpInfo)
-	} else {
-		m.userAliases[name] = tp
+	switch utp := tp.(type) {
+	case *typesystem.StructInfo:
+		if utp.TypeName != "" {
+			// new named type has fields, but not methods of other struct type
+			utp = typesystem.NewStructInfo(name, utp.Fields)
+		}
+		utp.SetName(name)
+		utp.Pkg = m.pkgPath
+		// LLVM type names are unique in program
+		utp.StructType.SetName(m.pkgPath + "." + name)
+		m.userStructs[name] = utp
+		utp.UpdateRecursiveRef(tmpInfo)
+	case *typesystem.InterfaceType:
+		delete(m.userStructs, name)
+		if utp.TypeName != "" {
+			utp = &typesystem.InterfaceType{StructType: utp.StructType, Methods: utp.Methods}
+		}
+		utp.TypeName = name
+		utp.Pkg = m.pkgPath
+		m.userTypes[name] = utp
+	default:
+		delete(m.userStructs, name)
+		m.userTypes[name] = typesystem.NewNamedType(m.pkgPath, name, tp)
 	}
 	return nil
 }
@@ -105,16 +141,7 @@ func (m *typeManager) ParseType(ctx parser.IType_Context) (types.Type, error) {
 	if ctx.L_PAREN() != nil {
 		return m.ParseType(ctx.Type_())
 	} else if ctx.TypeName() != nil {
-		typename := ctx.TypeName().GetText()
-		tp, ok := m.userAliases[typename]
-		if ok {
-			return tp, nil
-		}
-		tp, ok = m.userStructs[typename]
-		if ok {
-			return tp, nil
-		}
-		tp, err := typesystem.GoTypeToIR(typename)
+		tp, err := m.ParseTypeName(ctx.TypeName().GetText())
 		if err == nil {
 			return tp, nil
 		}
@@ -122,29 +149,82 @@ func (m *typeManager) ParseType(ctx parser.IType_Context) (types.Type, error) {
 		switch tp := ctx.TypeLit().GetChild(0).(type) {
 		case parser.IArrayTypeContext:
 			return m.ParseArrayType(tp)
+		case parser.ISliceTypeContext:
+			return m.ParseSliceType(tp)
+		case parser.IMapTypeContext:
+			return m.ParseMapType(tp)
 		case parser.IPointerTypeContext:
 			return m.ParsePointerType(tp)
 		case parser.IStructTypeContext:
 			return m.ParseStructType(tp)
+		case parser.IInterfaceTypeContext:
+			return m.ParseInterfaceType(tp)
+		case parser.IFunctionTypeContext:
+			return m.ParseFunctionType(tp)
+		case parser.IChannelTypeContext:
+			return m.ParseChannelType(tp)
 		}
 	}
 	return nil, utils.MakeErrorTrace(ctx, nil, "failed to parse type: %s", ctx.GetText())
 }
 
-func (m *typeManager) ParseLiteralType(ctx parser.ILiteralTypeContext) (types.Type, error) {
+// ParseTypeName resolves user defined or primitive type by its name.
+func (m *typeManager) ParseTypeName(typename string) (types.Type, error) {
+	if tp, ok := m.userTypes[typename]; ok {
+		return tp, nil
+	}
+	if tp, ok := m.userStructs[typename]; ok {
+		return tp, nil
+	}
+	switch typename {
+	case "any":
+		return typesystem.Any, nil
+	case "error":
+		return typesystem.Error, nil
+	}
+	return typesystem.GoTypeToIR(typename)
+}
+
+// identicalUnderlying checks if types t and u have identical underlying types,
+// values of such types are convertible to each other.
+func identicalUnderlying(t, u types.Type) bool {
+	t, u = typesystem.Underlying(t), typesystem.Underlying(u)
+	if tstp, ok := t.(*typesystem.StructInfo); ok {
+		ustp, ok := u.(*typesystem.StructInfo)
+		return ok && tstp.Identical(ustp)
+	}
+	return t.Equal(u)
+}
+
+// IsUserType reports whether typename is declared with 'type' keyword.
+func (m *typeManager) IsUserType(typename string) bool {
+	_, isNamed := m.userTypes[typename]
+	_, isStruct := m.userStructs[typename]
+	return isNamed || isStruct
+}
+
+// ParseLiteralType parses type of composite literal. Length of array
+// literal [...]T is computed from its value.
+func (m *typeManager) ParseLiteralType(ctx parser.ILiteralTypeContext, value parser.ILiteralValueContext) (types.Type, error) {
 	if ctx.ELLIPSIS() != nil {
-		return nil, utils.MakeErrorTrace(ctx, nil, "array literals with ellipsis length not supported yet")
+		elemType, err := m.ParseType(ctx.ElementType().Type_())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse array type")
+		}
+		return types.NewArray(uint64(m.literalLen(value)), elemType), nil
 	} else if ctx.MapType() != nil {
-		return nil, utils.MakeErrorTrace(ctx, nil, "maps not supported yet")
+		return m.ParseMapType(ctx.MapType())
 	} else if ctx.StructType() != nil {
 		return m.ParseStructType(ctx.StructType())
 	} else if ctx.ArrayType() != nil {
 		return m.ParseArrayType(ctx.ArrayType())
+	} else if ctx.SliceType() != nil {
+		return m.ParseSliceType(ctx.SliceType())
 	} else if ctx.TypeName() != nil {
 		tpName := ctx.TypeName().GetText()
 		if stp, ok := m.userStructs[tpName]; ok {
 			return stp, nil
-		} else if atp, ok := m.userAliases[tpName]; ok {
+		} else if atp, ok := m.userTypes[tpName]; ok {
 			return atp, nil
 		}
 		return nil, utils.MakeErrorTrace(ctx, nil, "unknown type name: %s", tpName)
@@ -152,6 +232,42 @@ func (m *typeManager) ParseLiteralType(ctx parser.ILiteralTypeContext) (types.Ty
 	return nil, utils.MakeErrorTrace(ctx, nil, "unimplemented literal type: %s", ctx.GetText())
 }
 
+// literalLen returns length of array literal, which is the highest index
+// of its elements plus one.
+func (m *typeManager) literalLen(ctx parser.ILiteralValueContext) int64 {
+	if ctx.ElementList() == nil {
+		return 0
+	}
+	var idx, length int64
+	for _, elem := range ctx.ElementList().AllKeyedElement() {
+		if elem.Key() != nil {
+			// invalid indices are reported by checker
+			if i, ok := m.literalIndex(elem.Key()); ok {
+				idx = i
+			}
+		}
+		idx++
+		length = max(length, idx)
+	}
+	return length
+}
+
+// literalIndex evaluates index of element of array or slice literal.
+func (m *typeManager) literalIndex(ctx parser.IKeyContext) (int64, bool) {
+	if ctx.Expression() == nil {
+		return 0, false
+	}
+	c, ok, err := m.evalConst(ctx.Expression())
+	if err != nil || !ok {
+		return 0, false
+	}
+	c, err = convertConst(c, typesystem.Int)
+	if err != nil {
+		return 0, false
+	}
+	return goconstant.Int64Val(c.Val)
+}
+
 func (m *typeManager) ParsePointerType(ctx parser.IPointerTypeContext) (types.Type, error) {
 	if underlying, err := m.ParseType(ctx.Type_()); err != nil {
 		return nil, err
@@ -161,10 +277,18 @@ func (m *typeManager) ParsePointerType(ctx parser.IPointerTypeContext) (types.Ty
 }
 
 func (m *typeManager) ParseArrayType(ctx parser.IArrayTypeContext) (types.Type, error) {
-	len, err := strconv.Atoi(ctx.ArrayLength().GetText())
+	c, ok, err := m.evalConst(ctx.ArrayLength().Expression())
 	if err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse array type")
-	} else if len < 0 {
+	} else if !ok {
+		return nil, utils.MakeErrorTrace(ctx, nil, "array length %s must be constant", ctx.ArrayLength().GetText())
+	}
+	c, err = convertConst(c, typesystem.Int)
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "invalid array length %s", ctx.ArrayLength().GetText())
+	}
+	len, _ := goconstant.Int64Val(c.Val)
+	if len < 0 {
 		return nil, utils.MakeErrorTrace(ctx, nil, "negative array length not allowed")
 	} else if underlying, err := m.ParseType(ctx.ElementType().Type_()); err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse array type")
@@ -173,12 +297,41 @@ func (m *typeManager) ParseArrayType(ctx parser.IArrayTypeContext) (types.Type,
 	}
 }
 
+func (m *typeManager) ParseSliceType(ctx parser.ISliceTypeContext) (types.Type, error) {
+	if underlying, err := m.ParseType(ctx.ElementType().Type_()); err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse slice type")
+	} else {
+		return typesystem.NewSliceType(underlying), nil
+	}
+}
+
+func (m *typeManager) ParseMapType(ctx parser.IMapTypeContext) (types.Type, error) {
+	keyType, err := m.ParseType(ctx.Type_())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse map key type")
+	} else if !typesystem.IsComparable(keyType) {
+		return nil, utils.MakeErrorTrace(ctx, nil, "invalid map key type %s", ctx.Type_().GetText())
+	}
+	elemType, err := m.ParseType(ctx.ElementType().Type_())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse map element type")
+	}
+	return typesystem.NewMapType(keyType, elemType), nil
+}
+
 func (m *typeManager) ParseStructType(ctx parser.IStructTypeContext) (types.Type, error) {
 	fields := []typesystem.StructFieldInfo{}
 	offset := 0
 	for _, field := range ctx.AllFieldDecl() {
-		if field.EmbeddedField() != nil {
-			return nil, utils.MakeErrorTrace(ctx, nil, "embedded fields not supported yet")
+		if emb := field.EmbeddedField(); emb != nil {
+			fieldInfo, err := m.parseEmbeddedField(emb)
+			if err != nil {
+				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse struct type")
+			}
+			fieldInfo.Offset = offset
+			fields = append(fields, fieldInfo)
+			offset += 1
+			continue
 		}
 		for _, ident := range field.IdentifierList().AllIDENTIFIER() {
 			fieldType, err := m.ParseType(field.Type_())
@@ -204,3 +357,132 @@ func (m *typeManager) ParseStructType(ctx parser.IStructTypeContext) (types.Type
 	}
 	return typesystem.NewStructInfo("", fields), nil
 }
+
+// parseEmbeddedField parses embedded field T or *T, which is named by
+// unqualified name of type T.
+func (m *typeManager) parseEmbeddedField(ctx parser.IEmbeddedFieldContext) (typesystem.StructFieldInfo, error) {
+	typeName := ctx.TypeName().GetText()
+	if ctx.TypeArgs() != nil {
+		return typesystem.StructFieldInfo{}, utils.MakeErrorTrace(ctx, nil, "generic types not supported")
+	}
+	tp, err := m.ParseTypeName(typeName)
+	if err != nil {
+		return typesystem.StructFieldInfo{}, utils.MakeErrorTrace(ctx, err, "undefined: %s", typeName)
+	}
+	if _, ok := typesystem.Underlying(tp).(*types.PointerType); ok {
+		return typesystem.StructFieldInfo{}, utils.MakeErrorTrace(ctx, nil, "embedded field type cannot be a pointer")
+	} else if typesystem.IsInterfaceType(tp) {
+		return typesystem.StructFieldInfo{}, utils.MakeErrorTrace(ctx, nil, "embedded interface fields not supported yet")
+	}
+	field := typesystem.StructFieldInfo{
+		Name:       typeName[strings.LastIndex(typeName, ".")+1:],
+		IsEmbedded: true,
+	}
+	if stp, ok := tp.(*typesystem.StructInfo); ok && ctx.STAR() == nil {
+		field.IsStruct = true
+		field.Struct = stp
+	} else if ctx.STAR() != nil {
+		field.Primitive = types.NewPointer(tp)
+	} else {
+		field.Primitive = tp
+	}
+	return field, nil
+}
+
+func (m *typeManager) ParseInterfaceType(ctx parser.IInterfaceTypeContext) (types.Type, error) {
+	var methods []typesystem.InterfaceMethod
+	for _, spec := range ctx.AllMethodSpec() {
+		argTypes, err := m.parseParamTypes(spec.Parameters())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(ctx, err, "failed to parse interface method %s", spec.IDENTIFIER().GetText())
+		}
+		var retTypes []types.Type
+		if res := spec.Result(); res != nil && res.Type_() != nil {
+			tp, err := m.ParseType(res.Type_())
+			if err != nil {
+				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse interface method %s", spec.IDENTIFIER().GetText())
+			}
+			retTypes = []types.Type{tp}
+		} else if res != nil {
+			retTypes, err = m.parseParamTypes(res.Parameters())
+			if err != nil {
+				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse interface method %s", spec.IDENTIFIER().GetText())
+			}
+		}
+		methods = append(methods, typesystem.InterfaceMethod{
+			Name:        spec.IDENTIFIER().GetText(),
+			ArgTypes:    argTypes,
+			ReturnTypes: retTypes,
+		})
+	}
+	// embedded interfaces
+	for _, elem := range ctx.AllTypeElement() {
+		if len(elem.AllTypeTerm()) != 1 || elem.TypeTerm(0).UNDERLYING() != nil {
+			return nil, utils.MakeErrorTrace(elem, nil, "type constraints not supported")
+		}
+		tp, err := m.ParseType(elem.TypeTerm(0).Type_())
+		if err != nil {
+			return nil, utils.MakeErrorTrace(elem, err, "failed to parse embedded interface")
+		}
+		itp, ok := tp.(*typesystem.InterfaceType)
+		if !ok {
+			return nil, utils.MakeErrorTrace(elem, nil, "cannot embed non-interface type %s", elem.GetText())
+		}
+		methods = append(methods, itp.Methods...)
+	}
+	// methods of embedded interfaces may repeat with identical signatures
+	var uniq []typesystem.InterfaceMethod
+	seen := make(map[string]typesystem.InterfaceMethod)
+	for _, method := range methods {
+		if prev, ok := seen[method.Name]; ok {
+			if !prev.Equal(method) {
+				return nil, utils.MakeErrorTrace(ctx, nil, "duplicate method %s", method.Name)
+			}
+			continue
+		}
+		seen[method.Name] = method
+		uniq = append(uniq, method)
+	}
+	return typesystem.NewInterfaceType(uniq), nil
+}
+
+func (m *typeManager) ParseFunctionType(ctx parser.IFunctionTypeContext) (types.Type, error) {
+	decl, err := m.ParseSignature(ctx.Signature())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse func type")
+	}
+	return typesystem.NewFuncType(decl.ArgTypes, decl.ReturnTypes), nil
+}
+
+func (m *typeManager) ParseChannelType(ctx parser.IChannelTypeContext) (types.Type, error) {
+	elemType, err := m.ParseType(ctx.ElementType().Type_())
+	if err != nil {
+		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse channel element type")
+	}
+	dir := typesystem.ChanBoth
+	if ctx.RECEIVE() != nil && ctx.GetChild(0) == ctx.RECEIVE() {
+		dir = typesystem.ChanRecv
+	} else if ctx.RECEIVE() != nil {
+		dir = typesystem.ChanSend
+	}
+	return typesystem.NewChanType(elemType, dir), nil
+}
+
+// parseParamTypes parses types of parameters, names are ignored.
+func (m *typeManager) parseParamTypes(ctx parser.IParametersContext) ([]types.Type, error) {
+	var tps []types.Type
+	for _, decl := range ctx.AllParameterDecl() {
+		tp, err := m.ParseType(decl.Type_())
+		if err != nil {
+			return nil, err
+		}
+		count := 1
+		if decl.IdentifierList() != nil {
+			count = len(decl.IdentifierList().AllIDENTIFIER())
+		}
+		for range count {
+			tps = append(tps, tp)
+		}
+	}
+	return tps, nil
+}
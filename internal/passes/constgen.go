@@ -0,0 +1,526 @@
+package passes
+
+import (
+	goconstant "go/constant"
+	"go/token"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+	"unicode/utf8"
+
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// maxConstShift limits shift count of constants, so that values stay reasonably small.
+const maxConstShift = 1023
+
+// constTokens maps parser tokens of operators to tokens of go/constant operations.
+var constTokens = map[int]token.Token{
+	parser.GoParserPLUS:              token.ADD,
+	parser.GoParserMINUS:             token.SUB,
+	parser.GoParserSTAR:              token.MUL,
+	parser.GoParserDIV:               token.QUO,
+	parser.GoParserMOD:               token.REM,
+	parser.GoParserAMPERSAND:         token.AND,
+	parser.GoParserOR:                token.OR,
+	parser.GoParserCARET:             token.XOR,
+	parser.GoParserBIT_CLEAR:         token.AND_NOT,
+	parser.GoParserLSHIFT:            token.SHL,
+	parser.GoParserRSHIFT:            token.SHR,
+	parser.GoParserLOGICAL_AND:       token.LAND,
+	parser.GoParserLOGICAL_OR:        token.LOR,
+	parser.GoParserEQUALS:            token.EQL,
+	parser.GoParserNOT_EQUALS:        token.NEQ,
+	parser.GoParserLESS:              token.LSS,
+	parser.GoParserLESS_OR_EQUALS:    token.LEQ,
+	parser.GoParserGREATER:           token.GTR,
+	parser.GoParserGREATER_OR_EQUALS: token.GEQ,
+	parser.GoParserEXCLAMATION:       token.NOT,
+}
+
+// constEvaluator folds constant expressions with arbitrary precision.
+type constEvaluator struct {
+	pdata *PackageData
+	// lookup finds declaration of name in current scope, returns nil
+	// constant for names, which are not constants
+	lookup func(name string) (*typesystem.Const, bool)
+	// value of iota inside constant declaration, nil elsewhere
+	iota *typesystem.Const
+}
+
+// constEvaluator returns evaluator of constant expressions in current scope.
+func (genCtx *GenContext) constEvaluator() *constEvaluator {
+	return &constEvaluator{
+		pdata: genCtx.PackageData,
+		lookup: func(name string) (*typesystem.Const, bool) {
+			val, ok := genCtx.Vars.Lookup(name)
+			c, _ := val.(*typesystem.Const)
+			return c, ok
+		},
+	}
+}
+
+// GenerateConst returns value of constant. Strings are stored in globals,
+// other constants are used directly as operands.
+func (genCtx *GenContext) GenerateConst(c *typesystem.Const) value.Value {
+	if c.Val.Kind() == goconstant.String {
+		return typesystem.NewTypedValue(genCtx.GenerateStringConst(goconstant.StringVal(c.Val)), c.Type())
+	}
+	return c
+}
+
+// lookupConst finds constant declared in current scope.
+func (genCtx *GenContext) lookupConst(name string) (*typesystem.Const, bool) {
+	val, ok := genCtx.Vars.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	c, ok := val.(*typesystem.Const)
+	return c, ok
+}
+
+// defaultConst converts untyped constant to its default type, other values
+// are returned as is.
+func defaultConst(val value.Value) (value.Value, error) {
+	if c, ok := val.(*typesystem.Const); ok && c.IsUntyped() {
+		return convertConst(c, c.Type())
+	}
+	return val, nil
+}
+
+// Eval evaluates constant expression. Reports false without error if
+// expression is not constant.
+func (e *constEvaluator) Eval(ctx parser.IExpressionContext) (*typesystem.Const, bool, error) {
+	if ctx.PrimaryExpr() != nil {
+		return e.evalPrimary(ctx.PrimaryExpr())
+	} else if ctx.GetUnary_op() != nil {
+		x, ok, err := e.Eval(ctx.Expression(0))
+		if !ok || err != nil {
+			return nil, ok, err
+		}
+		res, err := e.unary(ctx.GetUnary_op().GetTokenType(), x)
+		if err != nil {
+			return nil, false, utils.MakeErrorTrace(ctx, err, "invalid constant expression %s", ctx.GetText())
+		}
+		return res, res != nil, nil
+	}
+	var op int
+	if ctx.GetMul_op() != nil {
+		op = ctx.GetMul_op().GetTokenType()
+	} else if ctx.GetAdd_op() != nil {
+		op = ctx.GetAdd_op().GetTokenType()
+	} else if ctx.GetRel_op() != nil {
+		op = ctx.GetRel_op().GetTokenType()
+	} else if ctx.LOGICAL_AND() != nil {
+		op = parser.GoParserLOGICAL_AND
+	} else if ctx.LOGICAL_OR() != nil {
+		op = parser.GoParserLOGICAL_OR
+	}
+	x, ok, err := e.Eval(ctx.Expression(0))
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	y, ok, err := e.Eval(ctx.Expression(1))
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	var res *typesystem.Const
+	if op == parser.GoParserLSHIFT || op == parser.GoParserRSHIFT {
+		res, err = e.shift(op, x, y)
+	} else {
+		res, err = e.binary(op, x, y)
+	}
+	if err != nil {
+		return nil, false, utils.MakeErrorTrace(ctx, err, "invalid constant expression %s", ctx.GetText())
+	}
+	return res, true, nil
+}
+
+func (e *constEvaluator) evalPrimary(ctx parser.IPrimaryExprContext) (*typesystem.Const, bool, error) {
+	if ctx.Operand() != nil {
+		return e.evalOperand(ctx.Operand())
+	} else if ctx.Conversion() != nil {
+		tp, err := e.pdata.ParseType(ctx.Conversion().Type_())
+		if err != nil {
+			return nil, false, nil
+		}
+		return e.evalConversion(ctx, tp, ctx.Conversion().Expression())
+	} else if ctx.IDENTIFIER() != nil {
+		return e.evalQualified(ctx)
+	} else if ctx.Arguments() == nil || ctx.PrimaryExpr().Operand() == nil || ctx.PrimaryExpr().Operand().OperandName() == nil {
+		return nil, false, nil
+	}
+	args := ctx.Arguments()
+	if args.ExpressionList() == nil || len(args.ExpressionList().AllExpression()) != 1 || args.Type_() != nil {
+		return nil, false, nil
+	}
+	arg := args.ExpressionList().Expression(0)
+	name := ctx.PrimaryExpr().Operand().OperandName().GetText()
+	if _, ok := e.lookup(name); ok {
+		return nil, false, nil
+	} else if _, ok := e.pdata.Functions[e.pdata.symbol(name)]; ok {
+		return nil, false, nil
+	}
+	if name == "len" {
+		// length of constant string is constant
+		x, ok, err := e.Eval(arg)
+		if !ok || err != nil || x.Val.Kind() != goconstant.String {
+			return nil, false, err
+		}
+		return typesystem.NewConst(goconstant.MakeInt64(int64(len(goconstant.StringVal(x.Val)))), typesystem.Int), true, nil
+	}
+	tp, err := e.pdata.ParseTypeName(name)
+	if err != nil {
+		return nil, false, nil
+	}
+	return e.evalConversion(ctx, tp, arg)
+}
+
+// evalQualified evaluates exported constant of imported package.
+func (e *constEvaluator) evalQualified(ctx parser.IPrimaryExprContext) (*typesystem.Const, bool, error) {
+	if ctx.PrimaryExpr() == nil || ctx.PrimaryExpr().Operand() == nil || ctx.PrimaryExpr().Operand().OperandName() == nil {
+		return nil, false, nil
+	}
+	name := ctx.PrimaryExpr().Operand().OperandName().GetText()
+	if _, ok := e.lookup(name); ok {
+		return nil, false, nil
+	}
+	module, ok := e.pdata.LookupModule(name)
+	if !ok {
+		return nil, false, nil
+	}
+	pkg, ok := e.pdata.lookupPackage(module.Name)
+	if !ok {
+		return nil, false, nil
+	}
+	member := ctx.IDENTIFIER().GetText()
+	if !token.IsExported(member) {
+		return nil, false, nil
+	}
+	c, ok := pkg.Constants[member]
+	return c, ok, nil
+}
+
+func (e *constEvaluator) evalOperand(ctx parser.IOperandContext) (*typesystem.Const, bool, error) {
+	if ctx.Expression() != nil {
+		return e.Eval(ctx.Expression())
+	} else if ctx.OperandName() != nil {
+		name := ctx.OperandName().GetText()
+		if c, ok := e.lookup(name); ok {
+			return c, c != nil, nil
+		} else if name == "iota" && e.iota != nil {
+			return e.iota, true, nil
+		}
+		return nil, false, nil
+	} else if ctx.Literal().BasicLit() != nil {
+		return constBasicLit(ctx.Literal().BasicLit())
+	}
+	return nil, false, nil
+}
+
+// constBasicLit evaluates literal of untyped constant. Reports false for nil.
+func constBasicLit(ctx parser.IBasicLitContext) (*typesystem.Const, bool, error) {
+	var val goconstant.Value
+	switch {
+	case ctx.NIL_LIT() != nil:
+		return nil, false, nil
+	case ctx.TRUE_LIT() != nil:
+		val = goconstant.MakeBool(true)
+	case ctx.FALSE_LIT() != nil:
+		val = goconstant.MakeBool(false)
+	case ctx.String_() != nil:
+		val = goconstant.MakeFromLiteral(ctx.String_().GetText(), token.STRING, 0)
+	case ctx.FLOAT_LIT() != nil:
+		val = goconstant.MakeFromLiteral(ctx.GetText(), token.FLOAT, 0)
+	case ctx.Integer().RUNE_LIT() != nil:
+		val = goconstant.MakeFromLiteral(ctx.GetText(), token.CHAR, 0)
+		if val.Kind() == goconstant.Unknown {
+			break
+		}
+		c := typesystem.NewConst(val, nil)
+		c.Rune = true
+		return c, true, nil
+	case ctx.Integer().IMAGINARY_LIT() != nil:
+		return nil, false, utils.MakeErrorTrace(ctx, nil, "complex numbers not supported yet")
+	default:
+		val = goconstant.MakeFromLiteral(ctx.GetText(), token.INT, 0)
+	}
+	if val.Kind() == goconstant.Unknown {
+		return nil, false, utils.MakeErrorTrace(ctx, nil, "invalid literal %s", ctx.GetText())
+	}
+	return typesystem.NewConst(val, nil), true, nil
+}
+
+// evalConversion evaluates conversion of constant to basic type.
+func (e *constEvaluator) evalConversion(ctx parser.IPrimaryExprContext, tp types.Type, arg parser.IExpressionContext) (*typesystem.Const, bool, error) {
+	if !isBasicType(tp) {
+		return nil, false, nil
+	}
+	x, ok, err := e.Eval(arg)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	if typesystem.IsStringType(tp) && x.Val.Kind() == goconstant.Int {
+		// integer is converted to UTF-8 encoding of rune
+		r, exact := goconstant.Int64Val(x.Val)
+		if !exact || r < 0 || r > utf8.MaxRune {
+			r = utf8.RuneError
+		}
+		return typesystem.NewConst(goconstant.MakeString(string(rune(r))), tp), true, nil
+	}
+	res, err := convertConst(x, tp)
+	if err != nil {
+		return nil, false, utils.MakeErrorTrace(ctx, err, "cannot convert %s to type %s", arg.GetText(), constTypeName(tp))
+	}
+	return res, true, nil
+}
+
+func (e *constEvaluator) unary(op int, x *typesystem.Const) (*typesystem.Const, error) {
+	var prec uint
+	switch op {
+	case parser.GoParserPLUS, parser.GoParserMINUS:
+		if !isNumericConst(x) {
+			return nil, utils.MakeError("operator %s not defined on %s", constTokens[op], constKindName(x))
+		} else if op == parser.GoParserPLUS {
+			return x, nil
+		}
+	case parser.GoParserCARET:
+		if !isIntegerConst(x) {
+			return nil, utils.MakeError("operator ^ not defined on %s", constKindName(x))
+		} else if typesystem.IsUintType(x.Type()) && !x.IsUntyped() {
+			// complement of unsigned value keeps its bit size
+			itp, _ := typesystem.UnderlyingIntType(x.Type())
+			prec = uint(itp.BitSize)
+		}
+	case parser.GoParserEXCLAMATION:
+		if x.Val.Kind() != goconstant.Bool {
+			return nil, utils.MakeError("operator ! not defined on %s", constKindName(x))
+		}
+	default:
+		// address, dereference and receive are never constant
+		return nil, nil
+	}
+	res, err := e.typed(goconstant.UnaryOp(constTokens[op], x.Val, prec), x.Typ)
+	return asRune(res, x.Rune), err
+}
+
+func (e *constEvaluator) binary(op int, x, y *typesystem.Const) (*typesystem.Const, error) {
+	// untyped operand gets type of typed one
+	tp := x.Typ
+	var err error
+	if x.Typ != nil && y.Typ != nil && !x.Typ.Equal(y.Typ) {
+		return nil, utils.MakeError("mismatched types %s and %s", constTypeName(x.Typ), constTypeName(y.Typ))
+	} else if x.Typ != nil {
+		y, err = convertConst(y, x.Typ)
+	} else if y.Typ != nil {
+		tp = y.Typ
+		x, err = convertConst(x, y.Typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if x.Val.Kind() != y.Val.Kind() && !(isNumericConst(x) && isNumericConst(y)) {
+		return nil, utils.MakeError("mismatched types %s and %s", constKindName(x), constKindName(y))
+	}
+	tok := constTokens[op]
+	switch op {
+	case parser.GoParserEQUALS, parser.GoParserNOT_EQUALS:
+		return typesystem.NewConst(goconstant.MakeBool(goconstant.Compare(x.Val, tok, y.Val)), nil), nil
+	case parser.GoParserLESS, parser.GoParserLESS_OR_EQUALS, parser.GoParserGREATER, parser.GoParserGREATER_OR_EQUALS:
+		if x.Val.Kind() == goconstant.Bool {
+			return nil, utils.MakeError("operator %s not defined on %s", tok, constKindName(x))
+		}
+		return typesystem.NewConst(goconstant.MakeBool(goconstant.Compare(x.Val, tok, y.Val)), nil), nil
+	case parser.GoParserLOGICAL_AND, parser.GoParserLOGICAL_OR:
+		if x.Val.Kind() != goconstant.Bool {
+			return nil, utils.MakeError("operator %s not defined on %s", tok, constKindName(x))
+		}
+	case parser.GoParserPLUS:
+		if x.Val.Kind() != goconstant.String && !isNumericConst(x) {
+			return nil, utils.MakeError("operator + not defined on %s", constKindName(x))
+		}
+	case parser.GoParserMINUS, parser.GoParserSTAR:
+		if !isNumericConst(x) {
+			return nil, utils.MakeError("operator %s not defined on %s", tok, constKindName(x))
+		}
+	case parser.GoParserDIV:
+		if !isNumericConst(x) {
+			return nil, utils.MakeError("operator / not defined on %s", constKindName(x))
+		} else if goconstant.Sign(y.Val) == 0 {
+			return nil, utils.MakeError("division by zero")
+		} else if isIntegerConst(x) && isIntegerConst(y) {
+			// integer division truncates
+			tok = token.QUO_ASSIGN
+		}
+	default:
+		// remaining operators are defined on integers only
+		if !isIntegerConst(x) || !isIntegerConst(y) {
+			return nil, utils.MakeError("operator %s not defined on %s", tok, constKindName(x))
+		} else if op == parser.GoParserMOD && goconstant.Sign(y.Val) == 0 {
+			return nil, utils.MakeError("division by zero")
+		}
+	}
+	res, err := e.typed(goconstant.BinaryOp(x.Val, tok, y.Val), tp)
+	return asRune(res, x.Rune || y.Rune), err
+}
+
+func (e *constEvaluator) shift(op int, x, y *typesystem.Const) (*typesystem.Const, error) {
+	count := goconstant.ToInt(y.Val)
+	if count.Kind() != goconstant.Int || !(y.IsUntyped() || isIntegerConst(y)) {
+		return nil, utils.MakeError("invalid shift count %s", y.Val)
+	} else if goconstant.Sign(count) < 0 {
+		return nil, utils.MakeError("invalid negative shift count %s", y.Val)
+	}
+	s, ok := goconstant.Uint64Val(count)
+	if !ok || s > maxConstShift {
+		return nil, utils.MakeError("shift count %s too large", y.Val)
+	}
+	val := x.Val
+	if x.IsUntyped() {
+		// untyped value must be integer, but not necessarily of integer kind
+		val = goconstant.ToInt(val)
+	}
+	if val.Kind() != goconstant.Int || !(x.IsUntyped() || isIntegerConst(x)) {
+		return nil, utils.MakeError("shifted operand %s must be integer", x.Val)
+	}
+	res, err := e.typed(goconstant.Shift(val, constTokens[op], uint(s)), x.Typ)
+	return asRune(res, x.Rune), err
+}
+
+// typed checks that result of operation on constants of type tp fits in it.
+func (e *constEvaluator) typed(val goconstant.Value, tp types.Type) (*typesystem.Const, error) {
+	if tp == nil {
+		return typesystem.NewConst(val, nil), nil
+	}
+	res, ok := typesystem.ConvertConst(val, tp)
+	if !ok {
+		return nil, utils.MakeError("constant %s overflows %s", val, constTypeName(tp))
+	}
+	return typesystem.NewConst(res, tp), nil
+}
+
+// asRune marks untyped result of operation on untyped rune constant, default
+// type of which is rune too.
+func asRune(c *typesystem.Const, isRune bool) *typesystem.Const {
+	if c != nil && c.IsUntyped() && isRune {
+		c.Rune = true
+	}
+	return c
+}
+
+// EvalConstDecl evaluates constant declaration and declares its constants.
+// Specs without expressions repeat previous expressions with next iota.
+func (e *constEvaluator) EvalConstDecl(ctx parser.IConstDeclContext, declare func(name string, c *typesystem.Const) error) error {
+	var last parser.IConstSpecContext
+	for i, spec := range ctx.AllConstSpec() {
+		if spec.ExpressionList() != nil {
+			last = spec
+		} else if last == nil {
+			return utils.MakeErrorTrace(spec, nil, "missing init expr for const declaration")
+		}
+		ids := spec.IdentifierList().AllIDENTIFIER()
+		exprs := last.ExpressionList().AllExpression()
+		if len(ids) > len(exprs) {
+			return utils.MakeErrorTrace(spec, nil, "missing init expr for const declaration")
+		} else if len(ids) < len(exprs) {
+			return utils.MakeErrorTrace(spec, nil, "extra init expr")
+		}
+		var tp types.Type
+		if last.Type_() != nil {
+			var err error
+			if tp, err = e.pdata.ParseType(last.Type_()); err != nil {
+				return utils.MakeErrorTrace(spec, err, "invalid constant type %s", last.Type_().GetText())
+			} else if !isBasicType(tp) {
+				return utils.MakeErrorTrace(spec, nil, "invalid constant type %s", last.Type_().GetText())
+			}
+		}
+		e.iota = typesystem.NewConst(goconstant.MakeInt64(int64(i)), nil)
+		for j, id := range ids {
+			c, ok, err := e.Eval(exprs[j])
+			if err != nil {
+				return utils.MakeErrorTrace(spec, err, "failed to evaluate constant %s", id.GetText())
+			} else if !ok {
+				return utils.MakeErrorTrace(spec, nil, "%s is not constant", exprs[j].GetText())
+			}
+			if tp != nil {
+				if c, err = convertConst(c, tp); err != nil {
+					return utils.MakeErrorTrace(spec, err, "failed to evaluate constant %s", id.GetText())
+				}
+			}
+			if id.GetText() == "_" {
+				continue
+			}
+			if err := declare(id.GetText(), c); err != nil {
+				return utils.MakeErrorTrace(spec, err, "failed to declare constant %s", id.GetText())
+			}
+		}
+	}
+	e.iota = nil
+	return nil
+}
+
+// convertConst converts constant to basic type tp, which must represent its value.
+func convertConst(c *typesystem.Const, tp types.Type) (*typesystem.Const, error) {
+	val, ok := typesystem.ConvertConst(c.Val, tp)
+	if ok {
+		return typesystem.NewConst(val, tp), nil
+	}
+	tpName := constTypeName(tp)
+	_, isInt := typesystem.UnderlyingIntType(tp)
+	if !isNumericConst(c) || typesystem.IsBoolType(tp) || !(isInt || typesystem.IsFloatType(tp)) {
+		return nil, utils.MakeError("cannot use %s (%s constant) as %s value", c.Val, constKindName(c), tpName)
+	} else if goconstant.ToInt(c.Val).Kind() != goconstant.Int {
+		return nil, utils.MakeError("constant %s truncated to %s", c.Val, tpName)
+	}
+	return nil, utils.MakeError("constant %s overflows %s", c.Val, tpName)
+}
+
+// constTypeName returns Go name of type of constant.
+func constTypeName(tp types.Type) string {
+	if ntp, ok := tp.(*typesystem.NamedType); ok {
+		return ntp.TypeName
+	} else if name, ok := basicTypeName(tp); ok {
+		return name
+	}
+	return tp.String()
+}
+
+// isBasicType checks if underlying type of tp is boolean, numeric or string
+// type, values of which may be constant.
+func isBasicType(tp types.Type) bool {
+	_, ok := basicTypeName(typesystem.Underlying(tp))
+	return ok
+}
+
+// constKindName describes type of constant, like "untyped int".
+func constKindName(c *typesystem.Const) string {
+	if !c.IsUntyped() {
+		return constTypeName(c.Typ)
+	}
+	switch c.Val.Kind() {
+	case goconstant.Bool:
+		return "untyped bool"
+	case goconstant.String:
+		return "untyped string"
+	case goconstant.Float:
+		return "untyped float"
+	}
+	if c.Rune {
+		return "untyped rune"
+	}
+	return "untyped int"
+}
+
+func isNumericConst(c *typesystem.Const) bool {
+	return c.Val.Kind() == goconstant.Int || c.Val.Kind() == goconstant.Float
+}
+
+// isIntegerConst checks if constant is of integer type, or untyped integer.
+func isIntegerConst(c *typesystem.Const) bool {
+	if c.IsUntyped() {
+		return c.Val.Kind() == goconstant.Int
+	}
+	_, ok := typesystem.UnderlyingIntType(c.Typ)
+	return ok && !typesystem.IsBoolType(c.Typ)
+}
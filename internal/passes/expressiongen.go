@@ -29,40 +29,22 @@ func (genCtx *GenContext) GenerateLValue(block *ir.Block, ctx parser.IExpression
 		return []value.Value{
 			typesystem.NewTypedValue(vals[0], ptrtp),
 		}, blocks, nil
-	} else if ctx.PrimaryExpr() != nil && ctx.PrimaryExpr().Index() != nil {
-		// array indexing
-		subexprs, blocks, err := genCtx.GeneratePrimaryLValue(block, ctx.PrimaryExpr().PrimaryExpr())
+	}
+	switch s := ctx.GetChild(0).(type) {
+	case parser.IPrimaryExprContext:
+		return genCtx.GeneratePrimaryLValue(block, s)
+	case parser.IExpressionContext:
+		vals, blocks, err := genCtx.GenerateExpr(block, ctx)
 		if err != nil {
-			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
+			return nil, nil, err
 		} else if blocks != nil {
 			block = blocks[len(blocks)-1]
 		}
-		idxs, newBlocks, err := genCtx.GenerateExpr(block, ctx.PrimaryExpr().Index().Expression())
-		if err != nil {
-			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
-		} else if newBlocks != nil {
-			blocks = append(blocks, newBlocks...)
-			block = blocks[len(blocks)-1]
-		}
-		tp := subexprs[0].Type().(*types.PointerType).ElemType
-		arrtp, ok := tp.(*types.ArrayType)
-		if !ok {
-			ptp, ok := tp.(*types.PointerType)
-			if !ok {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid type for indexing: %s", tp)
-			}
-			arrtp, ok = ptp.ElemType.(*types.ArrayType)
-			if !ok {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid type for indexing: %s", tp)
-			}
+		if !typesystem.IsStringType(vals[0].Type()) {
+			return nil, nil, utils.MakeErrorTrace(ctx, nil, "cannot take address of %s", ctx.GetText())
 		}
-		return []value.Value{
-			block.NewGetElementPtr(arrtp.ElemType, subexprs[0], idxs[0]),
-		}, blocks, nil
-	}
-	switch s := ctx.GetChild(0).(type) {
-	case parser.IPrimaryExprContext:
-		return genCtx.GeneratePrimaryLValue(block, s)
+		// string value is not addressable - spill it to temporary
+		return []value.Value{spillValue(block, vals[0])}, blocks, nil
 	default:
 		fmt.Println(ctx.GetText())
 		return nil, nil, utils.MakeErrorTrace(ctx, nil, "this kind of lvalue not implemented")
@@ -84,8 +66,16 @@ func (genCtx *GenContext) GeneratePrimaryLValue(block *ir.Block, ctx parser.IPri
 		} else if ctx.Operand().L_PAREN() != nil {
 			return genCtx.GenerateLValue(block, ctx.Operand().Expression())
 		} else if ctx.Operand().Literal() != nil {
-			// check for literal syntax (dynamic allocation)
 			lit := ctx.Operand().Literal()
+			if lit.BasicLit() != nil && lit.BasicLit().String_() != nil {
+				// string literal is not addressable - spill it to temporary
+				vals, _, err := genCtx.GenerateBasicLiteralExpr(block, lit.BasicLit())
+				if err != nil {
+					return nil, nil, err
+				}
+				return []value.Value{spillValue(block, vals[0])}, nil, nil
+			}
+			// check for literal syntax (dynamic allocation)
 			if lit != nil && lit.CompositeLit() != nil {
 				vals, blocks, err := genCtx.GenerateCompositeLiteralExpr(block, lit.CompositeLit())
 				if err != nil {
@@ -97,22 +87,21 @@ func (genCtx *GenContext) GeneratePrimaryLValue(block *ir.Block, ctx parser.IPri
 				obj := vals[0]
 				objTp := obj.Type()
 				if tp, ok := objTp.(*typesystem.StructInfo); ok {
-					size, err := tp.Size()
-					if err != nil {
-						return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to compute struct size")
-					}
 					malloc, err := genCtx.LookupFunc("GC_malloc")
 					if err != nil {
 						return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to lookup GC_malloc function")
 					}
 					memPtr := typesystem.NewTypedValue(
-						block.NewCall(malloc, constant.NewInt(types.I64, int64(size))),
+						block.NewCall(malloc, sizeOf(tp)),
 						types.NewPointer(tp),
 					)
 					block.NewStore(obj, memPtr)
 					return []value.Value{memPtr}, blocks, nil
 				} else if _, ok := objTp.(*types.ArrayType); ok {
 					return nil, nil, utils.MakeErrorTrace(ctx, nil, "dynamic arrays not supported yet")
+				} else if typesystem.IsSliceType(objTp) || typesystem.IsMapType(objTp) {
+					// slice or map literal is not addressable - spill it to temporary
+					return []value.Value{spillValue(block, obj)}, blocks, nil
 				}
 				return vals, blocks, nil
 			}
@@ -123,58 +112,44 @@ func (genCtx *GenContext) GeneratePrimaryLValue(block *ir.Block, ctx parser.IPri
 			vals, blocks, err := genCtx.GeneratePrimaryExpr(block, ctx)
 			if err != nil {
 				return nil, nil, err
+			} else if blocks != nil {
+				block = blocks[len(blocks)-1]
+			}
+			if tp := vals[0].Type(); typesystem.IsSliceType(tp) || typesystem.IsMapType(tp) || typesystem.IsStringType(tp) {
+				// returned slice, map or string is not addressable - spill it to temporary
+				return []value.Value{spillValue(block, vals[0])}, blocks, nil
 			}
 			if _, ok := vals[0].Type().(*types.PointerType); !ok {
 				return nil, nil, utils.MakeErrorTrace(ctx, nil, "pointer type required for lvalue")
 			}
 			return []value.Value{vals[0], vals[0]}, blocks, nil
 		} else if ctx.Index() != nil {
-			// array indexing
-			vals, blocks, err := genCtx.GeneratePrimaryLValue(block, ctx.PrimaryExpr())
+			return genCtx.GenerateIndexLValue(block, ctx, true)
+		} else if ctx.Slice_() != nil || ctx.TypeAssertion() != nil {
+			// slice expression and type assertion are not addressable - spill them to temporary
+			vals, blocks, err := genCtx.GeneratePrimaryExpr(block, ctx)
 			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, err, "faiiled to parse array indexing")
+				return nil, nil, err
 			} else if blocks != nil {
 				block = blocks[len(blocks)-1]
 			}
-			idx, newBlocks, err := genCtx.GenerateExpr(block, ctx.Index().Expression())
-			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
-			} else if newBlocks != nil {
-				blocks = append(blocks, newBlocks...)
-				block = blocks[len(blocks)-1]
-			}
-			tp := vals[0].Type()
-			ptp, ok := tp.(*types.PointerType)
-			if !ok {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "must be pointer type")
-			}
-			atp, ok := ptp.ElemType.(*types.ArrayType)
-			if !ok {
-				// try pointer-to-pointer-to-struct
-				// to avoid syntax (*var).field
-				ptptp, ok := ptp.ElemType.(*types.PointerType)
-				if !ok {
-					return nil, nil, utils.MakeErrorTrace(ctx, nil, "must be pointer type")
-				}
-				atp, ok = ptptp.ElemType.(*types.ArrayType)
+			return []value.Value{spillValue(block, vals[0])}, blocks, nil
+		} else if ctx.DOT() != nil {
+			// variable of imported package
+			if module, ok := genCtx.lookupModuleOperand(ctx.PrimaryExpr()); ok {
+				name := ctx.IDENTIFIER().GetText()
+				addr, ok := genCtx.lookupModuleVar(module.Name, name)
 				if !ok {
-					return nil, nil, utils.MakeErrorTrace(ctx, nil, "must be pointer to array type")
+					return nil, nil, utils.MakeErrorTrace(ctx, nil, "cannot assign to %s", ctx.GetText())
 				}
-				ptp = ptptp
-				vals[0] = block.NewLoad(ptptp, vals[0])
+				return []value.Value{addr}, nil, nil
 			}
-			return []value.Value{
-				// invalid source type when indexing array
-				typesystem.NewTypedValue(
-					block.NewGetElementPtr(ptp.ElemType, vals[0], constant.NewInt(types.I32, 0), idx[0]),
-					types.NewPointer(atp.ElemType),
-				),
-			}, blocks, nil
-		} else if ctx.DOT() != nil {
 			// accessor to struct field
-			vals, newBlocks, err := genCtx.GeneratePrimaryLValue(block, ctx.PrimaryExpr())
+			vals, newBlocks, err := genCtx.generateBaseLValue(block, ctx.PrimaryExpr())
 			if err != nil {
 				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse accessor")
+			} else if newBlocks != nil {
+				block = newBlocks[len(newBlocks)-1]
 			}
 			tp := vals[0].Type()
 			ptp, ok := tp.(*types.PointerType)
@@ -197,28 +172,38 @@ func (genCtx *GenContext) GeneratePrimaryLValue(block *ir.Block, ctx parser.IPri
 				vals[0] = block.NewLoad(ptptp, vals[0])
 			}
 			fieldIdent := ctx.IDENTIFIER().GetText()
-			offset, fieldType, err := stp.ComputeOffset(fieldIdent)
+			path, _, err := stp.ComputeOffset(fieldIdent)
 			if err != nil {
 				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to compute struct field offset")
 			}
-			elem, ok := ptp.ElemType.(*typesystem.StructInfo)
-			if ok {
-				tp = &elem.StructType
-			} else {
-				tp = ptp.ElemType
-			}
-			// generate GEP
-			return []value.Value{
-				typesystem.NewTypedValue(
-					block.NewGetElementPtr(tp, vals[0], constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(offset))),
-					types.NewPointer(fieldType),
-				),
-			}, newBlocks, nil
+			return []value.Value{genCtx.generateFieldAddr(block, vals[0], stp, path)}, newBlocks, nil
 		}
 	}
 	return nil, nil, utils.MakeErrorTrace(ctx, nil, "lvalue for primary expression not implemented")
 }
 
+// generateFieldAddr generates address of field of struct, which ptr points
+// to. Promoted fields are reached through path of embedded fields, embedded
+// pointers are followed.
+func (genCtx *GenContext) generateFieldAddr(block *ir.Block, ptr value.Value, stp *typesystem.StructInfo, path []int) value.Value {
+	for i, idx := range path {
+		fieldType := stp.Fields[idx].Type()
+		ptr = typesystem.NewTypedValue(
+			block.NewGetElementPtr(&stp.StructType, ptr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(idx))),
+			types.NewPointer(fieldType),
+		)
+		if i == len(path)-1 {
+			break
+		}
+		if ptp, ok := fieldType.(*types.PointerType); ok {
+			ptr = block.NewLoad(ptp, ptr)
+			fieldType = ptp.ElemType
+		}
+		stp = fieldType.(*typesystem.StructInfo)
+	}
+	return ptr
+}
+
 func (genCtx *GenContext) GenerateIdentList(ctx parser.IIdentifierListContext) []string {
 	var ids []string
 	for i := range ctx.AllIDENTIFIER() {
@@ -260,62 +245,181 @@ func (genCtx *GenContext) GenerateExprList(block *ir.Block, ctx parser.IExpressi
 }
 
 func (genCtx *GenContext) GenerateExpr(block *ir.Block, ctx parser.IExpressionContext) ([]value.Value, []*ir.Block, error) {
+	vals, blocks, err := genCtx.generateExpr(block, ctx)
+	if err != nil || len(vals) != 1 {
+		return vals, blocks, err
+	}
+	// values get type assigned by type checker, untyped constants and nil
+	// get type of context they are used in
+	tp, ok := genCtx.PackageData.ExprTypes[ctx]
+	if !ok {
+		return vals, blocks, nil
+	}
+	if vals[0], err = adaptConstant(vals[0], tp); err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid constant %s", ctx.GetText())
+	}
+	if !vals[0].Type().Equal(tp) && identicalUnderlying(vals[0].Type(), tp) {
+		if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		res, newBlocks, err := genCtx.GenerateTypeCast(block, tp, vals[0])
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "cannot use %s as %s value", ctx.GetText(), genCtx.PackageData.typeName(tp))
+		}
+		return res, append(blocks, newBlocks...), nil
+	}
+	return vals, blocks, nil
+}
+
+func (genCtx *GenContext) generateExpr(block *ir.Block, ctx parser.IExpressionContext) ([]value.Value, []*ir.Block, error) {
+	// constant expressions are folded
+	if c, ok, err := genCtx.constEvaluator().Eval(ctx); err != nil {
+		return nil, nil, err
+	} else if ok {
+		return []value.Value{genCtx.GenerateConst(c)}, nil, nil
+	}
 	if ctx.PrimaryExpr() != nil {
 		return genCtx.GeneratePrimaryExpr(block, ctx.PrimaryExpr())
 	} else if ctx.GetUnary_op() != nil {
 		return genCtx.GenerateUnaryExpr(block, ctx)
 	}
-	//TODO: lazy logical expression evaluation
-	left, leftBlocks, err := genCtx.GenerateExpr(block, ctx.Expression(0))
-	_ = leftBlocks
+	if ctx.LOGICAL_AND() != nil || ctx.LOGICAL_OR() != nil {
+		return genCtx.GenerateLogicalExpr(block, ctx)
+	}
+	left, blocks, err := genCtx.GenerateExpr(block, ctx.Expression(0))
 	if err != nil {
 		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse expression")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
 	}
-	right, rightBlocks, err := genCtx.GenerateExpr(block, ctx.Expression(1))
-	_ = rightBlocks
+	right, newBlocks, err := genCtx.GenerateExpr(block, ctx.Expression(1))
 	if err != nil {
 		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse expression")
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
 	}
-	if ctx.LOGICAL_AND() != nil {
-		return genCtx.GenerateAndExpr(block, left[0], right[0])
-	} else if ctx.LOGICAL_OR() != nil {
-		return genCtx.GenerateOrExpr(block, left[0], right[0])
-	}
+	var op int
 	if ctx.GetMul_op() != nil {
-		if ctx.STAR() != nil {
-			return genCtx.GenerateMulExpr(block, left[0], right[0])
-		} else if ctx.DIV() != nil {
-			return genCtx.GenerateDivExpr(block, left[0], right[0])
-		} else if ctx.MOD() != nil {
-			return genCtx.GenerateModExpr(block, left[0], right[0])
-		} else {
-			return nil, nil, utils.MakeErrorTrace(ctx, nil, "unimplemented instruction: %s", ctx.GetText())
-		}
+		op = ctx.GetMul_op().GetTokenType()
 	} else if ctx.GetAdd_op() != nil {
-		if ctx.PLUS() != nil {
-			return genCtx.GenerateAddExpr(block, left[0], right[0])
-		} else if ctx.MINUS() != nil {
-			return genCtx.GenerateSubExpr(block, left[0], right[0])
-		} else {
-			return nil, nil, utils.MakeErrorTrace(ctx, nil, "unimplemented instruction: %s", ctx.GetText())
-		}
+		op = ctx.GetAdd_op().GetTokenType()
 	} else if ctx.GetRel_op() != nil {
-		return genCtx.GenerateRelExpr(block, left[0], right[0], ctx)
+		op = ctx.GetRel_op().GetTokenType()
+	} else {
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "other types of expression not implemented")
+	}
+	// arithmetic is done in type of result, comparison in type of operands
+	tp := genCtx.PackageData.ExprTypes[ctx]
+	if isComparison(op) {
+		tp = left[0].Type()
+		if _, ok := left[0].(*constant.Null); ok {
+			tp = right[0].Type()
+		}
+	}
+	vals, newBlocks, err := genCtx.generateBinaryExpr(block, op, tp, left[0], right[0])
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid operation %s", ctx.GetText())
+	}
+	return vals, append(blocks, newBlocks...), nil
+}
+
+// isComparison checks if binary operator op is relational.
+func isComparison(op int) bool {
+	switch op {
+	case parser.GoParserEQUALS, parser.GoParserNOT_EQUALS, parser.GoParserLESS,
+		parser.GoParserLESS_OR_EQUALS, parser.GoParserGREATER, parser.GoParserGREATER_OR_EQUALS:
+		return true
 	}
+	return false
+}
 
-	return nil, nil, utils.MakeErrorTrace(ctx, nil, "other types of expression not implemented")
+// generateBinaryExpr generates arithmetic, bitwise or relational operation
+// on evaluated operands, op is parser token type (like parser.GoParserPLUS).
+// Operation is done in type tp assigned by type checker, which is type of
+// shifted value for shifts.
+func (genCtx *GenContext) generateBinaryExpr(block *ir.Block, op int, tp types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	// untyped constants get type of operation, except for shift count,
+	// nil is compared as it is
+	var err error
+	if _, ok := left.(*typesystem.Const); ok {
+		if left, err = adaptConstant(left, tp); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, ok := right.(*typesystem.Const); ok && op != parser.GoParserLSHIFT && op != parser.GoParserRSHIFT {
+		if right, err = adaptConstant(right, tp); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch op {
+	case parser.GoParserSTAR:
+		return genCtx.GenerateMulExpr(block, tp, left, right)
+	case parser.GoParserDIV:
+		return genCtx.GenerateDivExpr(block, tp, left, right)
+	case parser.GoParserMOD:
+		return genCtx.GenerateModExpr(block, tp, left, right)
+	case parser.GoParserLSHIFT, parser.GoParserRSHIFT:
+		return genCtx.GenerateShiftExpr(block, op, tp, left, right)
+	case parser.GoParserAMPERSAND, parser.GoParserBIT_CLEAR, parser.GoParserOR, parser.GoParserCARET:
+		return genCtx.GenerateBitwiseExpr(block, op, tp, left, right)
+	case parser.GoParserPLUS:
+		return genCtx.GenerateAddExpr(block, tp, left, right)
+	case parser.GoParserMINUS:
+		return genCtx.GenerateSubExpr(block, tp, left, right)
+	case parser.GoParserEQUALS, parser.GoParserNOT_EQUALS, parser.GoParserLESS,
+		parser.GoParserLESS_OR_EQUALS, parser.GoParserGREATER, parser.GoParserGREATER_OR_EQUALS:
+		res, err := genCtx.GenerateCompare(block, op, left, right)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []value.Value{res}, nil, nil
+	}
+	return nil, nil, utils.MakeError("unimplemented operator")
 }
 
 func (genCtx *GenContext) GeneratePrimaryExpr(block *ir.Block, ctx parser.IPrimaryExprContext) ([]value.Value, []*ir.Block, error) {
 	if ctx.Operand() != nil {
 		return genCtx.GenerateOperand(block, ctx.Operand())
 	} else if ctx.Conversion() != nil {
-		return nil, nil, utils.MakeErrorTrace(ctx, nil, "type conversions are not supported yet")
+		conv := ctx.Conversion()
+		tp, err := genCtx.PackageData.ParseType(conv.Type_())
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid type of conversion")
+		}
+		vals, blocks, err := genCtx.GenerateExpr(block, conv.Expression())
+		if err != nil {
+			return nil, nil, err
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		val, err := genCtx.GenerateAssignConv(block, vals[0], tp)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "cannot convert %s to type %s", conv.Expression().GetText(), conv.Type_().GetText())
+		}
+		res, newBlocks, err := genCtx.GenerateTypeCast(block, tp, val)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "cannot convert %s to type %s", conv.Expression().GetText(), conv.Type_().GetText())
+		}
+		return res, append(blocks, newBlocks...), nil
 	} else if ctx.MethodExpr() != nil {
-		return nil, nil, utils.MakeErrorTrace(ctx, nil, "method call expressions not supported yet")
+		typeName, isPtr, ok := receiverTypeName(ctx.MethodExpr().Type_())
+		if !ok {
+			return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid method expression %s", ctx.GetText())
+		}
+		val, err := genCtx.GenerateMethodExprValue(ctx, typeName, isPtr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []value.Value{val}, nil, nil
 	} else if ctx.PrimaryExpr() != nil {
 		// function call or type cast
 		if ctx.Arguments() != nil {
+			if name, ok := genCtx.isBuiltinCall(ctx.PrimaryExpr()); ok {
+				return genCtx.GenerateBuiltinCall(block, name, ctx.Arguments())
+			} else if genCtx.isMethodCall(ctx.PrimaryExpr()) {
+				return genCtx.GenerateMethodCall(block, ctx)
+			}
 			args, blocks, err := genCtx.GenerateArguments(block, ctx.Arguments())
 			if err != nil {
 				return nil, nil, err
@@ -324,108 +428,93 @@ func (genCtx *GenContext) GeneratePrimaryExpr(block *ir.Block, ctx parser.IPrima
 			}
 			// check for type cast first
 			if tp, err := typesystem.GoTypeToIR(ctx.PrimaryExpr().GetText()); err == nil {
-				return genCtx.GenerateTypeCast(block, tp, args[0])
-			}
-			// not a type cast
-			exprs, blocks, err := genCtx.GeneratePrimaryExpr(block, ctx.PrimaryExpr())
-			if err != nil {
-				return nil, nil, err
-			} else if blocks != nil {
-				block = blocks[len(blocks)-1]
-			}
-			funRef, ok := exprs[0].(*ir.Func)
-			if !ok {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "value %+v is not a func ref", exprs[0])
-			}
-			funDecl, err := genCtx.LookupFuncDeclByIR(funRef)
-			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "function declaration for %s not found", funRef.String())
-			}
-			if funDecl.ReturnTypes == nil {
-				block.NewCall(funRef, args...)
-				return nil, blocks, nil
-			} else if len(funDecl.ReturnTypes) == 1 {
-				res := block.NewCall(funRef, args...)
-				return []value.Value{typesystem.NewTypedValue(res, funRef.Sig.RetType)}, blocks, nil
-			} else if len(funDecl.ReturnTypes) > 1 {
-				// additional out parameters in front of explicit ones
-				outParams := []value.Value{}
-				for i := range funDecl.ReturnTypes {
-					ref := block.NewAlloca(funRef.Params[i].Type().(*types.PointerType).ElemType)
-					outParams = append(outParams, ref)
+				vals, newBlocks, err := genCtx.GenerateTypeCast(block, tp, args[0])
+				return vals, append(blocks, newBlocks...), err
+			} else if tp, ok := genCtx.conversionType(ctx.PrimaryExpr()); ok {
+				val, err := genCtx.GenerateAssignConv(block, args[0], tp)
+				if err != nil {
+					return nil, nil, utils.MakeErrorTrace(ctx, err, "cannot convert %s to type %s", ctx.Arguments().GetText(), ctx.PrimaryExpr().GetText())
 				}
-				args = append(outParams, args...)
-				block.NewCall(funRef, args...)
-				resVals := []value.Value{}
-				for _, ref := range outParams {
-					resVals = append(resVals, block.NewLoad(ref.Type().(*types.PointerType).ElemType, ref))
+				vals, newBlocks, err := genCtx.GenerateTypeCast(block, tp, val)
+				if err != nil {
+					return nil, nil, utils.MakeErrorTrace(ctx, err, "cannot convert %s to type %s", ctx.Arguments().GetText(), ctx.PrimaryExpr().GetText())
 				}
-				return resVals, blocks, nil
+				return vals, append(blocks, newBlocks...), nil
 			}
-		} else if ctx.Index() != nil {
-			// array indexing
-			exprs, blocks, err := genCtx.GeneratePrimaryLValue(block, ctx.PrimaryExpr())
-			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
-			} else if blocks != nil {
-				block = blocks[len(blocks)-1]
-			}
-			idxs, newBlocks, err := genCtx.GenerateExpr(block, ctx.Index().Expression())
-			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array index")
-			} else if newBlocks != nil {
-				blocks = append(blocks, newBlocks...)
-				block = blocks[len(blocks)-1]
-			}
-			tp := exprs[0].Type()
-			ptp, ok := tp.(*types.PointerType)
-			if !ok {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "must be pointer type")
-			}
-			atp, ok := ptp.ElemType.(*types.ArrayType)
+			// not a type cast: declared functions are called directly
+			funRef, ok := genCtx.lookupFuncOperand(ctx.PrimaryExpr())
 			if !ok {
-				// try pointer-to-pointer-to-struct
-				// to avoid syntax (*var).field
-				ptptp, ok := ptp.ElemType.(*types.PointerType)
-				if !ok {
-					return nil, nil, utils.MakeErrorTrace(ctx, nil, "must be pointer type")
+				exprs, newBlocks, err := genCtx.GeneratePrimaryExpr(block, ctx.PrimaryExpr())
+				if err != nil {
+					return nil, nil, err
+				} else if newBlocks != nil {
+					blocks = append(blocks, newBlocks...)
+					block = blocks[len(blocks)-1]
 				}
-				atp, ok = ptptp.ElemType.(*types.ArrayType)
+				if typesystem.IsFuncType(exprs[0].Type()) {
+					vals, err := genCtx.GenerateFuncValueCall(block, exprs[0], args)
+					if err != nil {
+						return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid arguments in call to %s", ctx.PrimaryExpr().GetText())
+					}
+					return vals, blocks, nil
+				}
+				funRef, ok = exprs[0].(*ir.Func)
 				if !ok {
-					return nil, nil, utils.MakeErrorTrace(ctx, nil, "must be pointer to array type")
+					return nil, nil, utils.MakeErrorTrace(ctx, nil, "cannot call non-function %s", ctx.PrimaryExpr().GetText())
 				}
-				ptp = ptptp
-				exprs[0] = block.NewLoad(ptptp, exprs[0])
 			}
-			return []value.Value{
-				typesystem.NewTypedValue(
-					block.NewLoad(
-						atp.ElemType,
-						block.NewGetElementPtr(atp.ElemType, exprs[0], idxs[0]),
-					),
-					atp.ElemType,
-				),
-			}, blocks, nil
-		} else if ctx.DOT() != nil {
-			exprs, blocks, err := genCtx.GeneratePrimaryExpr(block, ctx.PrimaryExpr())
+			funDecl, err := genCtx.LookupFuncDeclByIR(funRef)
 			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse accessor syntax")
-			} else if blocks != nil {
-				block = blocks[len(blocks)-1]
+				return nil, nil, utils.MakeErrorTrace(ctx, nil, "function declaration for %s not found", funRef.String())
 			}
+			vals, err := genCtx.generateFuncCall(block, funRef, funDecl, args)
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid arguments in call to %s", ctx.PrimaryExpr().GetText())
+			}
+			return vals, blocks, nil
+		} else if ctx.Index() != nil {
+			return genCtx.GenerateIndexExpr(block, ctx, false)
+		} else if ctx.Slice_() != nil {
+			return genCtx.GenerateSliceExpr(block, ctx)
+		} else if ctx.TypeAssertion() != nil {
+			return genCtx.GenerateTypeAssertion(block, ctx, false)
+		} else if ctx.DOT() != nil {
 			// module name resolution
-			if exprs[0].Type().Equal(typesystem.GoModuleType) {
+			if module, ok := genCtx.lookupModuleOperand(ctx.PrimaryExpr()); ok {
 				name := ctx.IDENTIFIER().GetText()
-				val, err := genCtx.LookupNameInModule(exprs[0].(*typesystem.GoModule).Name, name)
+				if addr, ok := genCtx.lookupModuleVar(module.Name, name); ok {
+					elTp := addr.Type().(*types.PointerType).ElemType
+					return []value.Value{typesystem.NewTypedValue(block.NewLoad(elTp, addr), elTp)}, nil, nil
+				}
+				val, err := genCtx.LookupNameInModule(module.Name, name)
+				if err != nil {
+					return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to resolve name %s in module %s", name, module.Name)
+				}
+				if fun, ok := val.(*ir.Func); ok && genCtx.externFuncs[fun.Name()] == fun {
+					// function of imported package used as value
+					return []value.Value{genCtx.GenerateFuncValue(fun, genCtx.externDecls[fun.Name()])}, nil, nil
+				}
+				return []value.Value{val}, nil, nil
+			}
+			if typeName, isPtr, ok := genCtx.methodExprType(ctx); ok {
+				val, err := genCtx.GenerateMethodExprValue(ctx, typeName, isPtr)
 				if err != nil {
-					return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to resolve name %s in module %s", name, exprs[0].(*typesystem.GoModule).Name)
+					return nil, nil, err
+				}
+				return []value.Value{val}, nil, nil
+			} else if genCtx.PackageData.MethodValues[ctx] {
+				val, blocks, err := genCtx.GenerateMethodValue(block, ctx)
+				if err != nil {
+					return nil, nil, err
 				}
 				return []value.Value{val}, blocks, nil
 			}
 			// struct field accessor
-			vals, newBlocks, err := genCtx.GeneratePrimaryLValue(block, ctx)
+			vals, blocks, err := genCtx.GeneratePrimaryLValue(block, ctx)
 			if err != nil {
 				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse accessor")
+			} else if blocks != nil {
+				block = blocks[len(blocks)-1]
 			}
 			// generate load
 			return []value.Value{
@@ -433,52 +522,314 @@ func (genCtx *GenContext) GeneratePrimaryExpr(block *ir.Block, ctx parser.IPrima
 					vals[0].Type().(*types.PointerType).ElemType,
 					vals[0],
 				),
-			}, newBlocks, nil
+			}, blocks, nil
 		}
 	}
 	return nil, nil, utils.MakeError("unimplemented primary expression: %s", ctx.GetText())
 }
 
+// generateFuncCall generates call of declared function or method,
+// converting arguments to types of parameters.
+func (genCtx *GenContext) generateFuncCall(block *ir.Block, funRef *ir.Func, funDecl *FunctionDecl, args []value.Value) ([]value.Value, error) {
+	args, err := genCtx.generateFuncArgs(block, funRef, funDecl, args)
+	if err != nil {
+		return nil, err
+	}
+	return genCtx.generateCall(block, funRef, funDecl.ReturnTypes, args), nil
+}
+
+// generateFuncArgs converts arguments to types of parameters of declared function.
+// Variadic functions of C library take NUL-terminated strings.
+func (genCtx *GenContext) generateFuncArgs(block *ir.Block, funRef *ir.Func, funDecl *FunctionDecl, args []value.Value) ([]value.Value, error) {
+	args, err := genCtx.generateAssignConvs(block, args, funDecl.ArgTypes)
+	if err != nil || !funRef.Sig.Variadic {
+		return args, err
+	}
+	for i, arg := range args {
+		if arg, err = defaultConst(arg); err != nil {
+			return nil, err
+		}
+		if ptp, ok := arg.Type().(*types.PointerType); ok && typesystem.IsStringType(ptp.ElemType) {
+			return nil, utils.MakeError("cannot pass *string to %s", funRef.Name())
+		} else if !typesystem.IsStringType(arg.Type()) {
+			args[i] = promoteVarArg(block, arg)
+			continue
+		}
+		args[i], err = genCtx.GenerateCString(block, arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// promoteVarArg applies C default argument promotions: integers narrower
+// than int are extended according to their signedness, float becomes double.
+func promoteVarArg(block *ir.Block, arg value.Value) value.Value {
+	if itp, ok := typesystem.UnderlyingIntType(arg.Type()); ok && itp.BitSize < types.I32.BitSize {
+		if typesystem.IsUintType(arg.Type()) || typesystem.IsBoolType(arg.Type()) {
+			return block.NewZExt(typesystem.Raw(arg), types.I32)
+		}
+		return block.NewSExt(arg, types.I32)
+	} else if arg.Type().Equal(types.Float) {
+		return block.NewFPExt(arg, types.Double)
+	}
+	return arg
+}
+
+// generateCall generates call of function with given arguments. Multiple
+// results are returned in struct or through out parameters.
+func (genCtx *GenContext) generateCall(block *ir.Block, callee value.Value, retTypes []types.Type, args []value.Value) []value.Value {
+	if len(retTypes) == 0 {
+		block.NewCall(callee, args...)
+		return nil
+	} else if len(retTypes) == 1 {
+		res := block.NewCall(callee, args...)
+		return []value.Value{typesystem.NewTypedValue(res, retTypes[0])}
+	}
+	resVals := []value.Value{}
+	if !typesystem.OutParamResults {
+		res := block.NewCall(callee, args...)
+		for i, tp := range retTypes {
+			resVals = append(resVals, typesystem.NewTypedValue(block.NewExtractValue(res, uint64(i)), tp))
+		}
+		return resVals
+	}
+	// additional out parameters in front of explicit ones
+	outParams := []value.Value{}
+	for _, tp := range retTypes {
+		outParams = append(outParams, block.NewAlloca(tp))
+	}
+	args = append(outParams, args...)
+	block.NewCall(callee, args...)
+	for i, ref := range outParams {
+		resVals = append(resVals, block.NewLoad(retTypes[i], ref))
+	}
+	return resVals
+}
+
+// GenerateIndexLValue generates address of indexed element. Map elements are
+// inserted when assigned to, otherwise they are read into temporary memory.
+func (genCtx *GenContext) GenerateIndexLValue(block *ir.Block, ctx parser.IPrimaryExprContext, assign bool) ([]value.Value, []*ir.Block, error) {
+	vals, blocks, err := genCtx.generateBaseLValue(block, ctx.PrimaryExpr())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	idx, newBlocks, err := genCtx.GenerateExpr(block, ctx.Index().Expression())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+	}
+	if m, ok := genCtx.loadMap(block, vals[0]); ok {
+		if assign {
+			addr, err := genCtx.GenerateMapAssignAddr(block, m, idx[0])
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse map indexing")
+			}
+			return []value.Value{addr}, blocks, nil
+		}
+		val, _, newBlocks, err := genCtx.GenerateMapAccess(block, m, idx[0])
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse map indexing")
+		}
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+		mem := genCtx.NewTemp(val.Type())
+		block.NewStore(val, mem)
+		return []value.Value{mem}, blocks, nil
+	}
+	addr, newBlocks, err := genCtx.GenerateIndexAddr(block, vals[0], idx[0])
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
+	}
+	return []value.Value{addr}, append(blocks, newBlocks...), nil
+}
+
+// generateBaseLValue generates address of operand of index, slice or selector expression.
+// Map elements are not addressable, so they are read instead of being inserted.
+func (genCtx *GenContext) generateBaseLValue(block *ir.Block, ctx parser.IPrimaryExprContext) ([]value.Value, []*ir.Block, error) {
+	if ctx.Index() != nil {
+		return genCtx.GenerateIndexLValue(block, ctx, false)
+	} else if ctx.Operand() != nil && ctx.Operand().OperandName() != nil {
+		if c, ok := genCtx.lookupConst(ctx.Operand().OperandName().GetText()); ok {
+			// constant is not addressable - spill it to temporary
+			return []value.Value{spillValue(block, genCtx.GenerateConst(c))}, nil, nil
+		}
+	}
+	return genCtx.GeneratePrimaryLValue(block, ctx)
+}
+
+// GenerateIndexExpr generates indexing of arrays, slices and maps.
+// Comma-ok form returns additional flag whether key is present in map.
+func (genCtx *GenContext) GenerateIndexExpr(block *ir.Block, ctx parser.IPrimaryExprContext, commaOk bool) ([]value.Value, []*ir.Block, error) {
+	exprs, blocks, err := genCtx.generateBaseLValue(block, ctx.PrimaryExpr())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	idxs, newBlocks, err := genCtx.GenerateExpr(block, ctx.Index().Expression())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array index")
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+	}
+	if m, ok := genCtx.loadMap(block, exprs[0]); ok {
+		val, found, newBlocks, err := genCtx.GenerateMapAccess(block, m, idxs[0])
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse map indexing")
+		}
+		blocks = append(blocks, newBlocks...)
+		if commaOk {
+			return []value.Value{val, found}, blocks, nil
+		}
+		return []value.Value{val}, blocks, nil
+	}
+	addr, newBlocks, err := genCtx.GenerateIndexAddr(block, exprs[0], idxs[0])
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse array indexing")
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+	}
+	elemType := addr.Type().(*types.PointerType).ElemType
+	return []value.Value{
+		typesystem.NewTypedValue(block.NewLoad(elemType, addr), elemType),
+	}, blocks, nil
+}
+
+// GenerateAssignedExprList generates values of expression list assigned to count variables.
+// Handles special forms, where single expression yields two values.
+func (genCtx *GenContext) GenerateAssignedExprList(block *ir.Block, ctx parser.IExpressionListContext, count int) ([]value.Value, []*ir.Block, error) {
+	exprs := ctx.AllExpression()
+	if count == 2 && len(exprs) == 1 {
+		if pexpr := exprs[0].PrimaryExpr(); pexpr != nil && pexpr.Index() != nil {
+			return genCtx.GenerateIndexExpr(block, pexpr, true)
+		} else if pexpr != nil && pexpr.TypeAssertion() != nil {
+			return genCtx.GenerateTypeAssertion(block, pexpr, true)
+		} else if isRecvExpr(exprs[0]) {
+			return genCtx.GenerateRecvExpr(block, exprs[0], true)
+		}
+	}
+	return genCtx.GenerateExprList(block, ctx)
+}
+
+// conversionType checks if callee names user defined or predeclared interface type,
+// not shadowed by variable.
+func (genCtx *GenContext) conversionType(ctx parser.IPrimaryExprContext) (types.Type, bool) {
+	if ctx.Operand() == nil || ctx.Operand().OperandName() == nil {
+		return nil, false
+	}
+	name := ctx.Operand().OperandName().GetText()
+	if _, ok := genCtx.Vars.Lookup(name); ok {
+		return nil, false
+	} else if name != "any" && name != "error" && !genCtx.PackageData.IsUserType(name) {
+		return nil, false
+	}
+	tp, err := genCtx.PackageData.ParseTypeName(name)
+	return tp, err == nil
+}
+
+// lookupModuleOperand checks if expression names imported module, not shadowed by variable.
+func (genCtx *GenContext) lookupModuleOperand(ctx parser.IPrimaryExprContext) (*typesystem.GoModule, bool) {
+	if ctx.Operand() == nil || ctx.Operand().OperandName() == nil {
+		return nil, false
+	}
+	name := ctx.Operand().OperandName().GetText()
+	if _, ok := genCtx.Vars.Lookup(name); ok {
+		return nil, false
+	}
+	return genCtx.PackageData.LookupModule(name)
+}
+
 func (genCtx *GenContext) GenerateTypeCast(block *ir.Block, tp types.Type, val value.Value) ([]value.Value, []*ir.Block, error) {
 	// do nothing if types are the same
 	if tp.Equal(val.Type()) {
 		return []value.Value{val}, nil, nil
 	}
-	if typesystem.IsIntType(tp) && typesystem.IsIntType(val.Type()) {
-		tpi := tp.(*types.IntType)
-		tpv := val.Type().(*types.IntType)
-		if tpi.BitSize > tpv.BitSize {
-			return []value.Value{block.NewSExt(val, tp)}, nil, nil
-		} else {
-			return []value.Value{block.NewTrunc(val, tp)}, nil, nil
+	if c, ok := val.(*typesystem.Const); ok && isBasicType(tp) && !(typesystem.IsStringType(tp) && !typesystem.IsStringType(c.Type())) {
+		// conversion of constant is still constant
+		res, err := convertConst(typesystem.NewConst(c.Val, nil), tp)
+		if err != nil {
+			return nil, nil, err
 		}
+		return []value.Value{res}, nil, nil
 	}
-	if typesystem.IsFloatType(tp) && typesystem.IsFloatType(val.Type()) {
-		tpf := tp.(*types.FloatType)
-		tpv := val.Type().(*types.FloatType)
-		if tpf.Kind > tpv.Kind {
-			return []value.Value{block.NewFPExt(val, tp)}, nil, nil
-		} else {
-			return []value.Value{block.NewFPTrunc(val, tp)}, nil, nil
+	// values of types with identical underlying types, or pointers to them,
+	// have the same representation
+	from, to := typesystem.Underlying(val.Type()), typesystem.Underlying(tp)
+	fptp, fok := from.(*types.PointerType)
+	tptp, tok := to.(*types.PointerType)
+	if fstp, ok := from.(*typesystem.StructInfo); ok && identicalUnderlying(from, to) && fstp != to {
+		// named LLVM struct types differ, value is reinterpreted in memory
+		mem := genCtx.NewTemp(from)
+		block.NewStore(val, mem)
+		return []value.Value{block.NewLoad(to, block.NewBitCast(mem, types.NewPointer(to)))}, nil, nil
+	} else if fok && tok && identicalUnderlying(fptp.ElemType, tptp.ElemType) {
+		return []value.Value{typesystem.NewTypedValue(block.NewBitCast(val, tptp), tp)}, nil, nil
+	} else if identicalUnderlying(from, to) {
+		return []value.Value{typesystem.NewTypedValue(val, tp)}, nil, nil
+	}
+	if typesystem.IsStringType(tp) || typesystem.IsStringType(val.Type()) {
+		res, err := genCtx.GenerateStringConv(block, tp, val)
+		if err != nil {
+			return nil, nil, err
 		}
+		return []value.Value{res}, nil, nil
 	}
-	// inter-number conversions
-	if typesystem.IsIntType(tp) && typesystem.IsFloatType(val.Type()) {
-		return []value.Value{block.NewFPToSI(val, tp)}, nil, nil
+	var res value.Value
+	if fromInt, ok := typesystem.UnderlyingIntType(val.Type()); ok {
+		raw := typesystem.Raw(val)
+		if toInt, ok := typesystem.UnderlyingIntType(tp); ok {
+			if toInt.BitSize > fromInt.BitSize && typesystem.IsUintType(val.Type()) {
+				res = block.NewZExt(raw, toInt)
+			} else if toInt.BitSize > fromInt.BitSize {
+				res = block.NewSExt(raw, toInt)
+			} else if toInt.BitSize < fromInt.BitSize {
+				res = block.NewTrunc(raw, toInt)
+			} else {
+				res = val
+			}
+		} else if typesystem.IsFloatType(tp) && typesystem.IsUintType(val.Type()) {
+			res = block.NewUIToFP(raw, to)
+		} else if typesystem.IsFloatType(tp) {
+			res = block.NewSIToFP(raw, to)
+		}
+	} else if fromFloat, ok := from.(*types.FloatType); ok {
+		raw := typesystem.Raw(val)
+		if toFloat, ok := to.(*types.FloatType); ok {
+			if toFloat.Kind > fromFloat.Kind {
+				res = block.NewFPExt(raw, toFloat)
+			} else if toFloat.Kind < fromFloat.Kind {
+				res = block.NewFPTrunc(raw, toFloat)
+			} else {
+				res = val
+			}
+		} else if toInt, ok := typesystem.UnderlyingIntType(tp); ok && typesystem.IsUintType(tp) {
+			res = block.NewFPToUI(raw, toInt)
+		} else if ok {
+			res = block.NewFPToSI(raw, toInt)
+		}
 	}
-	if typesystem.IsFloatType(tp) && typesystem.IsIntType(val.Type()) {
-		return []value.Value{block.NewSIToFP(val, tp)}, nil, nil
+	if res != nil {
+		return []value.Value{typesystem.NewTypedValue(res, tp)}, nil, nil
 	}
 	return nil, nil, utils.MakeError("invalid typecast")
 }
 
 func (genCtx *GenContext) GenerateArguments(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
-	if ctx.ExpressionList() == nil {
-		return nil, nil, nil
+	var exprs []parser.IExpressionContext
+	if ctx.ExpressionList() != nil {
+		exprs = ctx.ExpressionList().AllExpression()
 	}
 	var vals []value.Value
 	var blocks []*ir.Block
-	for _, expr := range ctx.ExpressionList().AllExpression() {
+	for _, expr := range exprs {
 		tval, newBlocks, err := genCtx.GenerateExpr(block, expr)
 		if err != nil {
 			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse arguments")
@@ -488,15 +839,47 @@ func (genCtx *GenContext) GenerateArguments(block *ir.Block, ctx parser.IArgumen
 		}
 		vals = append(vals, tval...)
 	}
+	if ftp, ok := genCtx.PackageData.VariadicCalls[ctx]; ok {
+		vals, err := genCtx.generateVariadicArgs(block, ftp, vals)
+		return vals, blocks, err
+	}
 	return vals, blocks, nil
 }
 
+// generateVariadicArgs packs extra arguments of variadic function into
+// slice, which is passed as its last parameter.
+func (genCtx *GenContext) generateVariadicArgs(block *ir.Block, ftp *typesystem.FuncType, args []value.Value) ([]value.Value, error) {
+	fixed := len(ftp.ArgTypes) - 1
+	stp := ftp.ArgTypes[fixed].(*typesystem.SliceType)
+	if len(args) == fixed {
+		// nil slice
+		return append(args, typesystem.NewTypedValue(constant.NewZeroInitializer(&stp.StructType), stp)), nil
+	}
+	n := constant.NewInt(typesystem.Int, int64(len(args)-fixed))
+	slice, err := genCtx.GenerateMakeSlice(block, stp, n, n)
+	if err != nil {
+		return nil, err
+	}
+	ptr, _, _ := genCtx.GenerateSliceParts(block, slice)
+	for i, arg := range args[fixed:] {
+		elem, err := genCtx.GenerateAssignConv(block, arg, stp.ElemType)
+		if err != nil {
+			return nil, err
+		}
+		addr := block.NewGetElementPtr(stp.ElemType, ptr, constant.NewInt(typesystem.Int, int64(i)))
+		block.NewStore(elem, addr)
+	}
+	return append(args[:fixed:fixed], slice), nil
+}
+
 func (genCtx *GenContext) GenerateOperand(block *ir.Block, ctx parser.IOperandContext) ([]value.Value, []*ir.Block, error) {
 	if ctx.Literal() != nil {
 		return genCtx.GenerateLiteralExpr(block, ctx.Literal())
 	} else if ctx.OperandName() != nil {
 		operandName := ctx.OperandName().IDENTIFIER().GetText()
-		if val, ok := genCtx.Vars.Lookup(operandName); ok {
+		if c, ok := genCtx.lookupConst(operandName); ok {
+			return []value.Value{genCtx.GenerateConst(c)}, nil, nil
+		} else if val, ok := genCtx.Vars.Lookup(operandName); ok {
 			elTp := val.Type().(*types.PointerType).ElemType
 			return []value.Value{
 				typesystem.NewTypedValue(
@@ -509,6 +892,10 @@ func (genCtx *GenContext) GenerateOperand(block *ir.Block, ctx parser.IOperandCo
 			return []value.Value{module}, nil, nil
 		}
 		if funRef, err := genCtx.LookupFunc(operandName); err == nil {
+			if decl, ok := genCtx.PackageData.Functions[funRef.Name()]; ok && genCtx.Funcs[funRef.Name()] == funRef {
+				// function used as value
+				return []value.Value{genCtx.GenerateFuncValue(funRef, decl)}, nil, nil
+			}
 			return []value.Value{funRef}, nil, nil
 		}
 		return nil, nil, utils.MakeErrorTrace(ctx, nil, "name %s not defined in this scope", operandName)
@@ -529,17 +916,32 @@ func (genCtx *GenContext) GenerateUnaryExpr(block *ir.Block, ctx parser.IExpress
 			block = blocks[len(blocks)-1]
 		}
 		tp := vals[0].Type()
-		if typesystem.IsIntType(tp) {
+		if itp, ok := typesystem.UnderlyingIntType(tp); ok {
 			return []value.Value{
-				block.NewSub(constant.NewInt(tp.(*types.IntType), 0), vals[0]),
+				typesystem.NewTypedValue(block.NewSub(constant.NewInt(itp, 0), vals[0]), tp),
 			}, blocks, nil
 		} else if typesystem.IsFloatType(tp) {
 			return []value.Value{
-				block.NewFSub(constant.NewFloat(tp.(*types.FloatType), 0), vals[0]),
+				typesystem.NewTypedValue(block.NewFSub(constant.NewFloat(typesystem.Underlying(tp).(*types.FloatType), 0), typesystem.Raw(vals[0])), tp),
 			}, blocks, nil
 		} else {
 			return nil, nil, utils.MakeErrorTrace(ctx, nil, "unsupported type for unary minus: %s", tp.String())
 		}
+	} else if ctx.CARET() != nil {
+		vals, blocks, err := genCtx.GenerateExpr(block, ctx.Expression(0))
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to generate unary expression")
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		tp := vals[0].Type()
+		itp, ok := typesystem.UnderlyingIntType(tp)
+		if !ok || typesystem.IsBoolType(tp) {
+			return nil, nil, utils.MakeErrorTrace(ctx, nil, "unsupported type for bitwise complement: %s", tp.String())
+		}
+		return []value.Value{
+			typesystem.NewTypedValue(block.NewXor(vals[0], constant.NewInt(itp, -1)), tp),
+		}, blocks, nil
 	} else if ctx.EXCLAMATION() != nil {
 		vals, blocks, err := genCtx.GenerateExpr(block, ctx.Expression(0))
 		if err != nil {
@@ -562,10 +964,14 @@ func (genCtx *GenContext) GenerateUnaryExpr(block *ir.Block, ctx parser.IExpress
 			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse unary expression")
 		}
 		return []value.Value{exprs[0]}, blocks, nil
+	} else if ctx.RECEIVE() != nil {
+		return genCtx.GenerateRecvExpr(block, ctx, false)
 	} else if ctx.STAR() != nil {
 		lvals, blocks, err := genCtx.GenerateLValue(block, ctx.Expression(0))
 		if err != nil {
 			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse unary expression")
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
 		}
 		varRef := lvals[0]
 		ptrtp, ok := varRef.Type().(*types.PointerType)
@@ -586,10 +992,8 @@ func (genCtx *GenContext) GenerateUnaryExpr(block *ir.Block, ctx parser.IExpress
 	return nil, nil, utils.MakeErrorTrace(ctx, nil, "unimplemented unary expression: %s", ctx.GetText())
 }
 
-func (genCtx *GenContext) GenerateMulExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if resType, ok := typesystem.CommonSupertype(left, right); !ok {
-		return nil, nil, utils.MakeError("failed to deduce common type for %v and %v", left.Type(), right.Type())
-	} else if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
+func (genCtx *GenContext) GenerateMulExpr(block *ir.Block, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
 		return []value.Value{
 			typesystem.NewTypedValue(block.NewMul(left, right), resType),
 		}, nil, nil
@@ -602,17 +1006,30 @@ func (genCtx *GenContext) GenerateMulExpr(block *ir.Block, left, right value.Val
 	}
 }
 
-func (genCtx *GenContext) GenerateDivExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if resType, ok := typesystem.CommonSupertype(left, right); !ok {
-		return nil, nil, utils.MakeError("failed to deduce common type for %v and %v", left.Type(), right.Type())
-	} else if typesystem.IsIntType(resType) {
-		return []value.Value{
-			typesystem.NewTypedValue(block.NewSDiv(left, right), resType),
-		}, nil, nil
+func (genCtx *GenContext) GenerateDivExpr(block *ir.Block, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	if typesystem.IsIntType(resType) {
+		divisor, minusOne, blocks, err := genCtx.generateDivisor(block, right, true)
+		if err != nil {
+			return nil, nil, err
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		var res value.Value = block.NewSDiv(left, divisor)
+		if minusOne != nil {
+			itp, _ := typesystem.UnderlyingIntType(resType)
+			res = block.NewSelect(minusOne, block.NewSub(constant.NewInt(itp, 0), typesystem.Raw(left)), res)
+		}
+		return []value.Value{typesystem.NewTypedValue(res, resType)}, blocks, nil
 	} else if typesystem.IsUintType(resType) {
+		divisor, _, blocks, err := genCtx.generateDivisor(block, right, false)
+		if err != nil {
+			return nil, nil, err
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
 		return []value.Value{
-			typesystem.NewTypedValue(block.NewUDiv(left, right), resType),
-		}, nil, nil
+			typesystem.NewTypedValue(block.NewUDiv(left, divisor), resType),
+		}, blocks, nil
 	} else if typesystem.IsFloatType(resType) {
 		return []value.Value{
 			typesystem.NewTypedValue(block.NewFDiv(left, right), resType),
@@ -622,26 +1039,60 @@ func (genCtx *GenContext) GenerateDivExpr(block *ir.Block, left, right value.Val
 	}
 }
 
-func (genCtx *GenContext) GenerateModExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if resType, ok := typesystem.CommonSupertype(left, right); !ok {
-		return nil, nil, utils.MakeError("failed to deduce common type for %v and %v", left.Type(), right.Type())
-	} else if typesystem.IsIntType(resType) {
-		return []value.Value{
-			typesystem.NewTypedValue(block.NewSRem(left, right), resType),
-		}, nil, nil
-	} else if typesystem.IsUintType(resType) {
-		return []value.Value{
-			typesystem.NewTypedValue(block.NewURem(left, right), resType),
-		}, nil, nil
+func (genCtx *GenContext) GenerateModExpr(block *ir.Block, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
+		// remainder of division by -1 is 0, so divisor 1 replaces it
+		signed := typesystem.IsIntType(resType)
+		divisor, _, blocks, err := genCtx.generateDivisor(block, right, signed)
+		if err != nil {
+			return nil, nil, err
+		} else if blocks != nil {
+			block = blocks[len(blocks)-1]
+		}
+		var res value.Value
+		if signed {
+			res = block.NewSRem(left, divisor)
+		} else {
+			res = block.NewURem(left, divisor)
+		}
+		return []value.Value{typesystem.NewTypedValue(res, resType)}, blocks, nil
 	} else {
 		return nil, nil, utils.MakeError("not implemented behavior for mod")
 	}
 }
 
-func (genCtx *GenContext) GenerateAddExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if resType, ok := typesystem.CommonSupertype(left, right); !ok {
-		return nil, nil, utils.MakeError("failed to deduce common type for %v and %v", left.Type(), right.Type())
-	} else if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
+// generateDivisor panics, if integer divisor of / or % is zero. Signed
+// division of minimal value by -1 overflows, so divisor -1 is replaced by 1
+// and condition of replacement is returned, quotient must be negated then.
+// Constant divisor is not zero and needs no checks.
+func (genCtx *GenContext) generateDivisor(block *ir.Block, divisor value.Value, signed bool) (value.Value, value.Value, []*ir.Block, error) {
+	if _, ok := divisor.(*typesystem.Const); ok {
+		return divisor, nil, nil, nil
+	}
+	itp, ok := typesystem.UnderlyingIntType(divisor.Type())
+	if !ok {
+		return nil, nil, nil, utils.MakeError("invalid divisor type %s", divisor.Type())
+	}
+	panicdivide, err := genCtx.LookupFunc("runtime_panicdivide")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bpanic := genCtx.NewBlock("div.panic")
+	bok := genCtx.NewBlock("div.ok")
+	divisor = typesystem.Raw(divisor)
+	block.NewCondBr(block.NewICmp(enum.IPredEQ, divisor, constant.NewInt(itp, 0)), bpanic, bok)
+	bpanic.NewCall(panicdivide)
+	bpanic.NewUnreachable()
+	if !signed {
+		return divisor, nil, []*ir.Block{bpanic, bok}, nil
+	}
+	minusOne := bok.NewICmp(enum.IPredEQ, divisor, constant.NewInt(itp, -1))
+	divisor = bok.NewSelect(minusOne, constant.NewInt(itp, 1), divisor)
+	return divisor, minusOne, []*ir.Block{bpanic, bok}, nil
+}
+
+func (genCtx *GenContext) GenerateAddExpr(block *ir.Block, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
 		return []value.Value{
 			typesystem.NewTypedValue(block.NewAdd(left, right), resType),
 		}, nil, nil
@@ -649,15 +1100,19 @@ func (genCtx *GenContext) GenerateAddExpr(block *ir.Block, left, right value.Val
 		return []value.Value{
 			typesystem.NewTypedValue(block.NewFAdd(left, right), resType),
 		}, nil, nil
+	} else if typesystem.IsStringType(resType) {
+		res, err := genCtx.GenerateStringConcat(block, left, right)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []value.Value{typesystem.NewTypedValue(res, resType)}, nil, nil
 	} else {
 		return nil, nil, utils.MakeError("not implemented add for type %+v", resType)
 	}
 }
 
-func (genCtx *GenContext) GenerateSubExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if resType, ok := typesystem.CommonSupertype(left, right); !ok {
-		return nil, nil, utils.MakeError("failed to deduce common type for %v and %v", left.Type(), right.Type())
-	} else if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
+func (genCtx *GenContext) GenerateSubExpr(block *ir.Block, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	if typesystem.IsIntType(resType) || typesystem.IsUintType(resType) {
 		return []value.Value{
 			typesystem.NewTypedValue(block.NewSub(left, right), resType),
 		}, nil, nil
@@ -670,99 +1125,283 @@ func (genCtx *GenContext) GenerateSubExpr(block *ir.Block, left, right value.Val
 	}
 }
 
-func (genCtx *GenContext) GenerateRelExpr(block *ir.Block, left, right value.Value, ctx parser.IExpressionContext) ([]value.Value, []*ir.Block, error) {
-	resType, ok := typesystem.CommonSupertype(left, right)
-	if !ok {
-		return nil, nil, utils.MakeErrorTrace(ctx, nil, "failed to deduce common type for %v and %v", left.Type(), right.Type())
+// GenerateBitwiseExpr generates &, |, ^ and &^ operators on integers,
+// given as parser token type.
+func (genCtx *GenContext) GenerateBitwiseExpr(block *ir.Block, op int, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	itp, ok := typesystem.UnderlyingIntType(resType)
+	if !ok || typesystem.IsBoolType(resType) {
+		return nil, nil, utils.MakeError("not implemented bitwise operation for type %+v", resType)
 	}
-	if _, ok := resType.(*types.FloatType); ok {
+	var res value.Value
+	switch op {
+	case parser.GoParserAMPERSAND:
+		res = block.NewAnd(left, right)
+	case parser.GoParserOR:
+		res = block.NewOr(left, right)
+	case parser.GoParserCARET:
+		res = block.NewXor(left, right)
+	case parser.GoParserBIT_CLEAR:
+		res = block.NewAnd(left, block.NewXor(right, constant.NewInt(itp, -1)))
+	default:
+		return nil, nil, utils.MakeError("must never happen")
+	}
+	return []value.Value{typesystem.NewTypedValue(res, resType)}, nil, nil
+}
+
+// GenerateShiftExpr generates << and >> operators. Shift by count not less
+// than width of value gives 0, or -1 for negative signed value shifted right.
+// Shift by negative count panics.
+func (genCtx *GenContext) GenerateShiftExpr(block *ir.Block, op int, resType types.Type, left, right value.Value) ([]value.Value, []*ir.Block, error) {
+	itp, ok := typesystem.UnderlyingIntType(resType)
+	if !ok || typesystem.IsBoolType(resType) {
+		return nil, nil, utils.MakeError("invalid shift of %s", resType)
+	}
+	ctp, _ := typesystem.UnderlyingIntType(right.Type())
+	var blocks []*ir.Block
+	if _, ok := right.(*typesystem.Const); !ok && typesystem.IsIntType(right.Type()) {
+		panicshift, err := genCtx.LookupFunc("runtime_panicshift")
+		if err != nil {
+			return nil, nil, err
+		}
+		bpanic := genCtx.NewBlock("shift.panic")
+		bok := genCtx.NewBlock("shift.ok")
+		block.NewCondBr(block.NewICmp(enum.IPredSLT, right, constant.NewInt(ctp, 0)), bpanic, bok)
+		bpanic.NewCall(panicshift)
+		bpanic.NewUnreachable()
+		blocks = []*ir.Block{bpanic, bok}
+		block = bok
+	}
+	// count is not negative here, so it is compared as unsigned
+	width := constant.NewInt(ctp, int64(itp.BitSize))
+	big := block.NewICmp(enum.IPredUGE, typesystem.Raw(right), width)
+	var count value.Value = right
+	if ctp.BitSize > itp.BitSize {
+		count = block.NewTrunc(right, itp)
+	} else if ctp.BitSize < itp.BitSize {
+		count = block.NewZExt(right, itp)
+	}
+	var res value.Value
+	if op == parser.GoParserLSHIFT {
+		count = block.NewSelect(big, constant.NewInt(itp, 0), count)
+		res = block.NewSelect(big, constant.NewInt(itp, 0), block.NewShl(left, count))
+	} else if typesystem.IsUintType(resType) {
+		count = block.NewSelect(big, constant.NewInt(itp, 0), count)
+		res = block.NewSelect(big, constant.NewInt(itp, 0), block.NewLShr(left, count))
+	} else {
+		// arithmetic shift by width-1 fills value with sign bit
+		count = block.NewSelect(big, constant.NewInt(itp, int64(itp.BitSize-1)), count)
+		res = block.NewAShr(left, count)
+	}
+	return []value.Value{typesystem.NewTypedValue(res, resType)}, blocks, nil
+}
+
+// GenerateCompare compares two values with relational operator op,
+// given as parser token type (like parser.GoParserEQUALS).
+func (genCtx *GenContext) GenerateCompare(block *ir.Block, op int, left, right value.Value) (value.Value, error) {
+	if typesystem.IsInterfaceType(left.Type()) || typesystem.IsInterfaceType(right.Type()) {
+		return genCtx.GenerateIfaceCompare(block, op, left, right)
+	}
+	if typesystem.IsChanType(left.Type()) || typesystem.IsChanType(right.Type()) {
+		return genCtx.GenerateChanCompare(block, op, left, right)
+	}
+	// operands have the same type assigned by type checker, or one is nil
+	resType := left.Type()
+	if _, ok := left.(*constant.Null); ok {
+		resType = right.Type()
+	}
+	if typesystem.IsSliceType(resType) || typesystem.IsMapType(resType) || typesystem.IsFuncType(resType) {
+		return genCtx.GenerateNilCmp(block, op, left, right)
+	} else if typesystem.IsStringType(resType) {
+		return genCtx.GenerateStringCompare(block, op, left, right)
+	}
+	switch typesystem.Underlying(resType).(type) {
+	case *typesystem.StructInfo, *types.ArrayType:
+		return genCtx.GenerateAggregateCompare(block, op, left, right, resType)
+	}
+	if typesystem.IsFloatType(resType) {
 		var cmpPred enum.FPred
-		if ctx.EQUALS() != nil {
+		switch op {
+		case parser.GoParserEQUALS:
 			cmpPred = enum.FPredOEQ
-		} else if ctx.NOT_EQUALS() != nil {
+		case parser.GoParserNOT_EQUALS:
 			cmpPred = enum.FPredONE
-		} else if ctx.LESS() != nil {
+		case parser.GoParserLESS:
 			cmpPred = enum.FPredOLT
-		} else if ctx.LESS_OR_EQUALS() != nil {
+		case parser.GoParserLESS_OR_EQUALS:
 			cmpPred = enum.FPredOLE
-		} else if ctx.GREATER() != nil {
+		case parser.GoParserGREATER:
 			cmpPred = enum.FPredOGT
-		} else if ctx.GREATER_OR_EQUALS() != nil {
+		case parser.GoParserGREATER_OR_EQUALS:
 			cmpPred = enum.FPredOGE
-		} else {
-			return nil, nil, utils.MakeError("must never happen")
+		default:
+			return nil, utils.MakeError("must never happen")
 		}
-		return []value.Value{
-			typesystem.NewTypedValue(
-				block.NewFCmp(cmpPred, left, right),
-				typesystem.Bool,
-			),
-		}, nil, nil
+		return typesystem.NewTypedValue(
+			block.NewFCmp(cmpPred, typesystem.Raw(left), typesystem.Raw(right)),
+			typesystem.Bool,
+		), nil
 	} else {
+		signed := typesystem.IsIntType(resType)
 		var cmpPred enum.IPred
-		if ctx.EQUALS() != nil {
+		switch op {
+		case parser.GoParserEQUALS:
 			cmpPred = enum.IPredEQ
-		} else if ctx.NOT_EQUALS() != nil {
+		case parser.GoParserNOT_EQUALS:
 			cmpPred = enum.IPredNE
-		} else if ctx.LESS() != nil {
-			if _, ok := resType.(*types.IntType); ok {
+		case parser.GoParserLESS:
+			if signed {
 				cmpPred = enum.IPredSLT
 			} else {
 				cmpPred = enum.IPredULT
 			}
-		} else if ctx.LESS_OR_EQUALS() != nil {
-			if _, ok := resType.(*types.IntType); ok {
+		case parser.GoParserLESS_OR_EQUALS:
+			if signed {
 				cmpPred = enum.IPredSLE
 			} else {
-				// TODO: fix unsigned int handling
 				cmpPred = enum.IPredULE
 			}
-		} else if ctx.GREATER() != nil {
-			if _, ok := resType.(*types.IntType); ok {
+		case parser.GoParserGREATER:
+			if signed {
 				cmpPred = enum.IPredSGT
 			} else {
 				cmpPred = enum.IPredUGT
 			}
-		} else if ctx.GREATER_OR_EQUALS() != nil {
-			if _, ok := resType.(*types.IntType); ok {
+		case parser.GoParserGREATER_OR_EQUALS:
+			if signed {
 				cmpPred = enum.IPredSGE
 			} else {
 				cmpPred = enum.IPredUGE
 			}
-		} else {
-			return nil, nil, utils.MakeError("must never happen")
+		default:
+			return nil, utils.MakeError("must never happen")
 		}
-		return []value.Value{
-			typesystem.NewTypedValue(
-				block.NewICmp(cmpPred, left, right),
-				typesystem.Bool,
-			),
-		}, nil, nil
+		return typesystem.NewTypedValue(
+			block.NewICmp(cmpPred, typesystem.Raw(left), typesystem.Raw(right)),
+			typesystem.Bool,
+		), nil
+	}
+}
+
+// equalityPred returns predicate of == or != operator, other operators
+// are not defined on types compared by identity.
+func equalityPred(op int) enum.IPred {
+	if op == parser.GoParserNOT_EQUALS {
+		return enum.IPredNE
 	}
+	return enum.IPredEQ
 }
 
-func (genCtx *GenContext) GenerateAndExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if !left.Type().Equal(typesystem.Bool) {
-		return nil, nil, utils.MakeError("left value not of type bool: (got %v)", left.Type())
+// GenerateAggregateCompare compares struct or array values of type tp field
+// by field or element by element. Runtime compares them as map keys, so
+// floats, strings and interfaces have semantics of ==.
+func (genCtx *GenContext) GenerateAggregateCompare(block *ir.Block, op int, left, right value.Value, tp types.Type) (value.Value, error) {
+	desc, err := genCtx.mapKeyDesc(tp)
+	if err != nil {
+		return nil, err
+	}
+	var args []value.Value
+	for _, val := range []value.Value{left, right} {
+		val, err := genCtx.GenerateAssignConv(block, val, tp)
+		if err != nil {
+			return nil, err
+		}
+		mem := genCtx.NewTemp(tp)
+		block.NewStore(val, mem)
+		args = append(args, block.NewBitCast(mem, types.I8Ptr))
 	}
-	if !right.Type().Equal(typesystem.Bool) {
-		return nil, nil, utils.MakeError("right value not of type bool: (got %v)", left.Type())
+	var res value.Value = block.NewCall(genCtx.SpecialFuncs["runtime_keyequal"], desc, args[0], args[1])
+	if op == parser.GoParserNOT_EQUALS {
+		res = block.NewXor(res, constant.True)
 	}
-	return []value.Value{
-		typesystem.NewTypedValue(block.NewAnd(left, right), typesystem.Bool),
-	}, nil, nil
+	return typesystem.NewTypedValue(res, typesystem.Bool), nil
 }
 
-func (genCtx *GenContext) GenerateOrExpr(block *ir.Block, left, right value.Value) ([]value.Value, []*ir.Block, error) {
-	if !left.Type().Equal(typesystem.Bool) {
-		return nil, nil, utils.MakeError("left value not of type bool: (got %v)", left.Type())
+// GenerateNilCmp compares slice, map, func or interface with nil.
+func (genCtx *GenContext) GenerateNilCmp(block *ir.Block, op int, left, right value.Value) (value.Value, error) {
+	val := left
+	if _, ok := left.(*constant.Null); ok {
+		val = right
+	}
+	pred := equalityPred(op)
+	var ptr value.Value
+	if typesystem.IsSliceType(val.Type()) {
+		ptr, _, _ = genCtx.GenerateSliceParts(block, val)
+	} else if typesystem.IsInterfaceType(val.Type()) {
+		ptr, _ = genCtx.GenerateIfaceParts(block, val)
+	} else if typesystem.IsFuncType(val.Type()) {
+		ptr = typesystem.NewTypedValue(block.NewExtractValue(typesystem.Raw(val), 0), types.I8Ptr)
+	} else {
+		ptr = typesystem.NewTypedValue(val, types.I8Ptr)
 	}
-	if !right.Type().Equal(typesystem.Bool) {
-		return nil, nil, utils.MakeError("right value not of type bool: (got %v)", left.Type())
+	return typesystem.NewTypedValue(
+		block.NewICmp(pred, ptr, constant.NewNull(ptr.Type().(*types.PointerType))),
+		typesystem.Bool,
+	), nil
+}
+
+// GenerateLogicalExpr generates && and || operators. Right operand is
+// evaluated only if result is not determined by left one.
+func (genCtx *GenContext) GenerateLogicalExpr(block *ir.Block, ctx parser.IExpressionContext) ([]value.Value, []*ir.Block, error) {
+	left, blocks, err := genCtx.GenerateExpr(block, ctx.Expression(0))
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse expression")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
 	}
+	cond := typesystem.Raw(left[0])
+	brhs := genCtx.NewBlock("logic.rhs")
+	bend := genCtx.NewBlock("logic.end")
+	isOr := ctx.LOGICAL_OR() != nil
+	if isOr {
+		block.NewCondBr(cond, bend, brhs)
+	} else {
+		block.NewCondBr(cond, brhs, bend)
+	}
+	blocks = append(blocks, brhs)
+	right, newBlocks, err := genCtx.GenerateExpr(brhs, ctx.Expression(1))
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse expression")
+	}
+	blocks = append(blocks, newBlocks...)
+	rblock := blocks[len(blocks)-1]
+	rblock.NewBr(bend)
+	res := bend.NewPhi(
+		ir.NewIncoming(constant.NewBool(isOr), block),
+		ir.NewIncoming(typesystem.Raw(right[0]), rblock),
+	)
 	return []value.Value{
-		typesystem.NewTypedValue(block.NewOr(left, right), typesystem.Bool),
-	}, nil, nil
+		typesystem.NewTypedValue(res, typesystem.Bool),
+	}, append(blocks, bend), nil
+}
+
+// spillValue stores value in temporary memory to make it addressable.
+func spillValue(block *ir.Block, val value.Value) value.Value {
+	mem := block.NewAlloca(val.Type())
+	block.NewStore(val, mem)
+	return typesystem.NewTypedValue(mem, types.NewPointer(val.Type()))
+}
+
+// adaptConstant gives nil and untyped constants the type expected by context.
+func adaptConstant(val value.Value, tp types.Type) (value.Value, error) {
+	switch c := val.(type) {
+	case *typesystem.Const:
+		if isBasicType(tp) && c.IsUntyped() {
+			return convertConst(c, tp)
+		}
+	case *constant.Null:
+		if val.Type().Equal(tp) {
+			break
+		} else if ptp, ok := tp.(*types.PointerType); ok {
+			return constant.NewNull(ptp), nil
+		} else if ptp, ok := typesystem.Underlying(tp).(*types.PointerType); ok {
+			return typesystem.NewTypedValue(constant.NewNull(ptp), tp), nil
+		} else if typesystem.IsSliceType(tp) || typesystem.IsFuncType(tp) {
+			return constant.NewZeroInitializer(tp), nil
+		} else if typesystem.IsChanType(tp) {
+			return typesystem.NewTypedValue(constant.NewNull(types.I8Ptr), tp), nil
+		}
+	}
+	return val, nil
 }
 
 // helper function for debugging to print out current context state (position)
@@ -2,9 +2,14 @@ package passes
 
 import (
 	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
+	"strings"
 
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
@@ -14,12 +19,41 @@ type GenContext struct {
 
 	module           *ir.Module
 	Funcs            map[string]*ir.Func
+	Methods          map[string]*ir.Func // by mangled name
 	SpecialFuncs     map[string]*ir.Func
 	SpecialFuncDecls map[string]*FunctionDecl
 	Consts           map[string]*ir.Global
 
+	// functions and variables of other packages declared in module, by symbol
+	externFuncs map[string]*ir.Func
+	externDecls map[string]*FunctionDecl
+	externVars  map[string]*ir.Global
+
 	// global variable context
 	Vars *VariableContext
+
+	// counter for unique names of blocks created inside expressions
+	blockUID int
+
+	// entry block of function being generated, holds temporaries
+	entryBlock *ir.Block
+
+	// map key descriptors for runtime hashing, by key type
+	keyDescs map[types.Type]*ir.Global
+
+	// interface support: descriptors by type symbol, itabs by type and
+	// interface symbols and method wrappers by symbol name
+	typeDescs  map[string]*ir.Global
+	ifaceDescs map[string]*ir.Global
+	itabs      map[string]*ir.Global
+	ifaceFuncs map[string]*ir.Func
+
+	// closure support: names of variables possibly captured by function
+	// literals in current function, counters of literals by enclosing
+	// function and generator of literal bodies (set by code generator)
+	captured   map[string]bool
+	funcLits   map[string]int
+	funcLitGen func(block *ir.Block, ctx parser.IFunctionLitContext) (value.Value, error)
 }
 
 func NewGenContext(pdata *PackageData) (*GenContext, error) {
@@ -27,35 +61,55 @@ func NewGenContext(pdata *PackageData) (*GenContext, error) {
 		PackageData:      pdata,
 		module:           ir.NewModule(),
 		Funcs:            make(map[string]*ir.Func),
+		Methods:          make(map[string]*ir.Func),
 		SpecialFuncs:     make(map[string]*ir.Func),
 		SpecialFuncDecls: make(map[string]*FunctionDecl),
 		Consts:           make(map[string]*ir.Global),
+		externFuncs:      make(map[string]*ir.Func),
+		externDecls:      make(map[string]*FunctionDecl),
+		externVars:       make(map[string]*ir.Global),
 		Vars:             NewVarContext(nil),
+		keyDescs:         make(map[types.Type]*ir.Global),
+		typeDescs:        make(map[string]*ir.Global),
+		ifaceDescs:       make(map[string]*ir.Global),
+		itabs:            make(map[string]*ir.Global),
+		ifaceFuncs:       make(map[string]*ir.Func),
+		funcLits:         make(map[string]int),
+	}
+	// global constants are evaluated by package listener
+	for name, c := range pdata.Constants {
+		if err := ctx.Vars.Add(name, c); err != nil {
+			return nil, err
+		}
 	}
 
 	// populate global functions (like printf)
-	fun := ir.NewFunc("printf", types.I32, ir.NewParam("format", types.I8Ptr))
-	fun.Sig.Variadic = true
-	ctx.SpecialFuncs["fmt__Printf"] = fun
-	ctx.SpecialFuncDecls["fmt__Printf"] = &FunctionDecl{
-		Name:        "fmt__Printf",
-		ArgNames:    []string{"format"},
-		ArgTypes:    []types.Type{types.I8Ptr},
-		ReturnTypes: []types.Type{types.I32},
-	}
-
-	fun = ir.NewFunc("scanf", types.I32, ir.NewParam("format", types.I8Ptr))
-	fun.Sig.Variadic = true
-	ctx.SpecialFuncs["fmt__Scanf"] = fun
-	ctx.SpecialFuncDecls["fmt__Scanf"] = &FunctionDecl{
-		Name:        "fmt__Scanf",
-		ArgNames:    []string{"format"},
-		ArgTypes:    []types.Type{types.I8Ptr},
-		ReturnTypes: []types.Type{types.I32},
+	for pkgName, funcs := range stdlibFuncs {
+		for name, def := range funcs {
+			decl := &FunctionDecl{
+				Name:        pkgName + "__" + name,
+				ArgTypes:    def.argTypes,
+				ReturnTypes: []types.Type{def.retType},
+				Variadic:    def.variadic,
+			}
+			var params []*ir.Param
+			for i, tp := range def.argTypes {
+				decl.ArgNames = append(decl.ArgNames, fmt.Sprintf("arg%d", i))
+				// strings are passed to C library as NUL-terminated ones
+				if typesystem.IsStringType(tp) {
+					tp = types.I8Ptr
+				}
+				params = append(params, ir.NewParam(decl.ArgNames[i], tp))
+			}
+			fun := ir.NewFunc(def.cname, def.retType, params...)
+			fun.Sig.Variadic = def.variadic
+			ctx.SpecialFuncs[decl.Name] = fun
+			ctx.SpecialFuncDecls[decl.Name] = decl
+		}
 	}
 
 	// garbage-collector-related stuff
-	fun = ir.NewFunc("GC_init", types.I1)
+	fun := ir.NewFunc("GC_init", types.I1)
 	fun.Type()
 	ctx.SpecialFuncs["GC_init"] = fun
 	ctx.SpecialFuncDecls["GC_init"] = &FunctionDecl{
@@ -72,6 +126,220 @@ func NewGenContext(pdata *PackageData) (*GenContext, error) {
 		ReturnTypes: []types.Type{types.I8Ptr},
 	}
 
+	// slice runtime support
+	ctx.declareSpecialFunc("runtime_makeslice", types.I8Ptr,
+		ir.NewParam("elemsize", types.I64),
+		ir.NewParam("cap", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_growcap", typesystem.Int,
+		ir.NewParam("cap", typesystem.Int),
+		ir.NewParam("newlen", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_slicecopy", typesystem.Int,
+		ir.NewParam("dst", types.I8Ptr),
+		ir.NewParam("dstlen", typesystem.Int),
+		ir.NewParam("src", types.I8Ptr),
+		ir.NewParam("srclen", typesystem.Int),
+		ir.NewParam("elemsize", types.I64),
+	)
+
+	// map runtime support
+	ctx.declareSpecialFunc("runtime_makemap", types.I8Ptr,
+		ir.NewParam("keydesc", types.I32Ptr),
+		ir.NewParam("keysize", types.I64),
+		ir.NewParam("valsize", types.I64),
+		ir.NewParam("hint", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_mapaccess", types.I8Ptr,
+		ir.NewParam("m", types.I8Ptr),
+		ir.NewParam("key", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_mapassign", types.I8Ptr,
+		ir.NewParam("m", types.I8Ptr),
+		ir.NewParam("key", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_mapdelete", types.Void,
+		ir.NewParam("m", types.I8Ptr),
+		ir.NewParam("key", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_maplen", typesystem.Int,
+		ir.NewParam("m", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_mapentries", types.Void,
+		ir.NewParam("m", types.I8Ptr),
+		ir.NewParam("keys", types.NewPointer(types.I8Ptr)),
+		ir.NewParam("vals", types.NewPointer(types.I8Ptr)),
+	)
+
+	// interface runtime support
+	ctx.declareSpecialFunc("runtime_convI2I", types.I8Ptr,
+		ir.NewParam("tab", types.I8Ptr),
+		ir.NewParam("iface", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_assertI2I", types.I8Ptr,
+		ir.NewParam("tab", types.I8Ptr),
+		ir.NewParam("iface", types.I8Ptr),
+		ir.NewParam("canfail", types.I1),
+	)
+	ctx.declareSpecialFunc("runtime_panicassert", types.Void,
+		ir.NewParam("have", types.I8Ptr),
+		ir.NewParam("want", types.I8Ptr),
+		ir.NewParam("iface", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_ifaceeq", types.I1,
+		ir.NewParam("tab1", types.I8Ptr),
+		ir.NewParam("data1", types.I8Ptr),
+		ir.NewParam("tab2", types.I8Ptr),
+		ir.NewParam("data2", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_keyequal", types.I1,
+		ir.NewParam("keydesc", types.I32Ptr),
+		ir.NewParam("key1", types.I8Ptr),
+		ir.NewParam("key2", types.I8Ptr),
+	)
+
+	// goroutine and channel runtime support
+	ctx.declareSpecialFunc("runtime_newproc", types.Void,
+		ir.NewParam("fn", types.NewPointer(types.NewFunc(types.Void, types.I8Ptr))),
+		ir.NewParam("arg", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_makechan", types.I8Ptr,
+		ir.NewParam("elemsize", types.I64),
+		ir.NewParam("cap", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_chansend", types.Void,
+		ir.NewParam("c", types.I8Ptr),
+		ir.NewParam("elem", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_chanrecv", types.I1,
+		ir.NewParam("c", types.I8Ptr),
+		ir.NewParam("elem", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_closechan", types.Void,
+		ir.NewParam("c", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_chanlen", typesystem.Int,
+		ir.NewParam("c", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_chancap", typesystem.Int,
+		ir.NewParam("c", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_select", typesystem.Int,
+		ir.NewParam("cases", types.I8Ptr),
+		ir.NewParam("ncases", typesystem.Int),
+		ir.NewParam("block", types.I1),
+		ir.NewParam("recvok", types.NewPointer(types.I1)),
+	)
+
+	// panic runtime support
+	ctx.declareSpecialFunc("runtime_pushframe", types.Void,
+		ir.NewParam("f", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_popframe", types.Void,
+		ir.NewParam("f", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_unwind", types.Void,
+		ir.NewParam("f", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_gopanic", types.Void,
+		ir.NewParam("tab", types.I8Ptr),
+		ir.NewParam("data", types.I8Ptr),
+	)
+	ctx.declareSpecialFunc("runtime_panicshift", types.Void)
+	ctx.declareSpecialFunc("runtime_panicdivide", types.Void)
+	ctx.declareSpecialFunc("runtime_panicbounds", types.Void,
+		ir.NewParam("kind", types.I32),
+		ir.NewParam("x", typesystem.Int),
+		ir.NewParam("y", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_recover", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.Any.StructType)),
+	)
+	ctx.declareSpecialFunc("_setjmp", types.I32,
+		ir.NewParam("env", types.I8Ptr),
+	)
+	ctx.SpecialFuncs["_setjmp"].FuncAttrs = append(ctx.SpecialFuncs["_setjmp"].FuncAttrs, enum.FuncAttrReturnsTwice)
+
+	// string runtime support
+	ctx.declareSpecialFunc("runtime_decoderune", typesystem.Int,
+		ir.NewParam("s", types.I8Ptr),
+		ir.NewParam("len", typesystem.Int),
+		ir.NewParam("pos", typesystem.Int),
+		ir.NewParam("rune", types.NewPointer(typesystem.Rune)),
+	)
+	ctx.declareSpecialFunc("runtime_concatstrings", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.String.StructType)),
+		ir.NewParam("s1", types.I8Ptr),
+		ir.NewParam("len1", typesystem.Int),
+		ir.NewParam("s2", types.I8Ptr),
+		ir.NewParam("len2", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_cmpstring", typesystem.Int,
+		ir.NewParam("s1", types.I8Ptr),
+		ir.NewParam("len1", typesystem.Int),
+		ir.NewParam("s2", types.I8Ptr),
+		ir.NewParam("len2", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_cstring", types.I8Ptr,
+		ir.NewParam("s", types.I8Ptr),
+		ir.NewParam("len", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_slicebytetostring", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.String.StructType)),
+		ir.NewParam("ptr", types.I8Ptr),
+		ir.NewParam("len", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_slicerunetostring", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.String.StructType)),
+		ir.NewParam("ptr", types.NewPointer(typesystem.Rune)),
+		ir.NewParam("len", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_intstring", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.String.StructType)),
+		ir.NewParam("r", types.I64),
+	)
+	ctx.declareSpecialFunc("runtime_stringtoslicebyte", types.I8Ptr,
+		ir.NewParam("s", types.I8Ptr),
+		ir.NewParam("len", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_stringtoslicerune", types.NewPointer(typesystem.Rune),
+		ir.NewParam("s", types.I8Ptr),
+		ir.NewParam("len", typesystem.Int),
+		ir.NewParam("n", types.NewPointer(typesystem.Int)),
+	)
+
+	// fmt runtime support
+	ctx.declareSpecialFunc("runtime_print", typesystem.Int,
+		ir.NewParam("mode", types.I32),
+		ir.NewParam("format", types.I8Ptr),
+		ir.NewParam("formatlen", typesystem.Int),
+		ir.NewParam("args", types.I8Ptr),
+		ir.NewParam("nargs", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_fprint", typesystem.Int,
+		ir.NewParam("f", types.I8Ptr),
+		ir.NewParam("mode", types.I32),
+		ir.NewParam("format", types.I8Ptr),
+		ir.NewParam("formatlen", typesystem.Int),
+		ir.NewParam("args", types.I8Ptr),
+		ir.NewParam("nargs", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_sprint", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.String.StructType)),
+		ir.NewParam("mode", types.I32),
+		ir.NewParam("format", types.I8Ptr),
+		ir.NewParam("formatlen", typesystem.Int),
+		ir.NewParam("args", types.I8Ptr),
+		ir.NewParam("nargs", typesystem.Int),
+	)
+	ctx.declareSpecialFunc("runtime_errorf", types.Void,
+		ir.NewParam("res", types.NewPointer(&typesystem.Error.StructType)),
+		ir.NewParam("format", types.I8Ptr),
+		ir.NewParam("formatlen", typesystem.Int),
+		ir.NewParam("args", types.I8Ptr),
+		ir.NewParam("nargs", typesystem.Int),
+	)
+
 	// generate references to functions first
 	for _, fn := range pdata.Functions {
 		irFun, err := genFunDef(fn)
@@ -81,10 +349,34 @@ func NewGenContext(pdata *PackageData) (*GenContext, error) {
 		irFun.Parent = ctx.module
 		ctx.Funcs[fn.Name] = irFun
 	}
+	for _, methods := range pdata.Methods {
+		for _, fn := range methods {
+			irFun, err := genFunDef(fn)
+			if err != nil {
+				return nil, err
+			}
+			irFun.Parent = ctx.module
+			ctx.Methods[fn.Name] = irFun
+		}
+	}
 
 	return &ctx, nil
 }
 
+// declareSpecialFunc adds declaration of runtime function implemented in C.
+func (ctx *GenContext) declareSpecialFunc(name string, retType types.Type, params ...*ir.Param) {
+	decl := &FunctionDecl{Name: name}
+	for _, param := range params {
+		decl.ArgNames = append(decl.ArgNames, param.Name())
+		decl.ArgTypes = append(decl.ArgTypes, param.Type())
+	}
+	if !retType.Equal(types.Void) {
+		decl.ReturnTypes = []types.Type{retType}
+	}
+	ctx.SpecialFuncs[name] = ir.NewFunc(name, retType, params...)
+	ctx.SpecialFuncDecls[name] = decl
+}
+
 func (ctx *GenContext) Module() *ir.Module {
 	// link all function defs
 	if len(ctx.module.Funcs) == 0 {
@@ -96,10 +388,48 @@ func (ctx *GenContext) Module() *ir.Module {
 			fun.Parent = ctx.module
 			ctx.module.Funcs = append(ctx.module.Funcs, fun)
 		}
+		for _, fun := range ctx.Methods {
+			fun.Parent = ctx.module
+			ctx.module.Funcs = append(ctx.module.Funcs, fun)
+		}
 	}
 	return ctx.module
 }
 
+// localSymbol returns name of global, which is private to package.
+func (ctx *GenContext) localSymbol(format string, args ...any) string {
+	return ctx.PackageData.Path + "." + fmt.Sprintf(format, args...)
+}
+
+// NewBlock creates basic block with unique name for expression code generation.
+func (ctx *GenContext) NewBlock(prefix string) *ir.Block {
+	ctx.blockUID++
+	return ir.NewBlock(fmt.Sprintf("%s.%d", prefix, ctx.blockUID))
+}
+
+// SetEntryBlock sets entry block of function being generated.
+func (ctx *GenContext) SetEntryBlock(block *ir.Block) {
+	ctx.entryBlock = block
+}
+
+// NewTemp allocates temporary memory in function entry block,
+// so it is not allocated again on each loop iteration.
+func (ctx *GenContext) NewTemp(tp types.Type) value.Value {
+	mem := ir.NewAlloca(tp)
+	ctx.entryBlock.Insts = append([]ir.Instruction{mem}, ctx.entryBlock.Insts...)
+	return typesystem.NewTypedValue(mem, types.NewPointer(tp))
+}
+
+// NewVar allocates memory for local variable. Variables captured by function
+// literals may outlive function call, so they are allocated by GC.
+func (ctx *GenContext) NewVar(block *ir.Block, name string, tp types.Type) value.Value {
+	if !ctx.captured[name] {
+		return block.NewAlloca(tp)
+	}
+	mem := block.NewCall(ctx.SpecialFuncs["GC_malloc"], sizeOf(tp))
+	return typesystem.NewTypedValue(block.NewBitCast(mem, types.NewPointer(tp)), types.NewPointer(tp))
+}
+
 func (ctx *GenContext) PushLexicalScope() {
 	ctx.Vars = NewVarContext(ctx.Vars)
 }
@@ -108,20 +438,77 @@ func (ctx *GenContext) PopLexicalScope() {
 	ctx.Vars = ctx.Vars.Parent
 }
 
+// LookupNameInModule resolves exported function or constant of imported package.
 func (ctx *GenContext) LookupNameInModule(moduleName, name string) (value.Value, error) {
-	// checks only functions for now
-	if fun, err := ctx.LookupFunc(fmt.Sprintf("%s__%s", moduleName, name)); err != nil {
-		return nil, err
-	} else {
+	if fun, ok := ctx.SpecialFuncs[moduleName+"__"+name]; ok {
 		return fun, nil
 	}
+	pkg, ok := ctx.PackageData.lookupPackage(moduleName)
+	if !ok {
+		return nil, utils.MakeError("package %s not imported", moduleName)
+	}
+	if c, ok := pkg.Constants[name]; ok {
+		return ctx.GenerateConst(c), nil
+	} else if decl, ok := pkg.Functions[pkg.symbol(name)]; ok {
+		return ctx.funcRef(decl), nil
+	}
+	return nil, utils.MakeError("%s not declared by package %s", name, pkg.PackageName)
+}
+
+// lookupModuleVar returns address of global variable of imported package.
+func (ctx *GenContext) lookupModuleVar(moduleName, name string) (value.Value, bool) {
+	pkg, ok := ctx.PackageData.lookupPackage(moduleName)
+	if !ok {
+		return nil, false
+	}
+	tp, ok := pkg.Globals[name]
+	if !ok {
+		return nil, false
+	}
+	sym := pkg.symbol(name)
+	glob, ok := ctx.externVars[sym]
+	if !ok {
+		// defined by module of package, which declares variable, or
+		// by runtime for standard library
+		glob = ctx.module.NewGlobal(sym, tp)
+		if pkg.stdlib {
+			glob.Linkage = enum.LinkageExternal
+		}
+		ctx.externVars[sym] = glob
+	}
+	return glob, true
+}
+
+// funcRef returns IR function of declared function or method. Functions of
+// other packages are declared in module on first use.
+func (ctx *GenContext) funcRef(decl *FunctionDecl) *ir.Func {
+	if fun, ok := ctx.Funcs[decl.Name]; ok {
+		return fun
+	} else if fun, ok := ctx.Methods[decl.Name]; ok {
+		return fun
+	} else if fun, ok := ctx.externFuncs[decl.Name]; ok {
+		return fun
+	}
+	fun, _ := genFunDef(decl)
+	fun.Parent = ctx.module
+	ctx.module.Funcs = append(ctx.module.Funcs, fun)
+	ctx.externFuncs[decl.Name] = fun
+	ctx.externDecls[decl.Name] = decl
+	if name, ok := strings.CutPrefix(decl.Name, "fmt__"); ok {
+		if def, ok := printFuncs[name]; ok {
+			ctx.generatePrintFunc(fun, decl, def)
+		}
+	} else if decl.Promoted != nil {
+		ctx.generatePromotedMethod(fun, decl)
+	}
+	return fun
 }
 
 func (ctx *GenContext) LookupFuncDecl(funName string) (*FunctionDecl, error) {
 	if f, ok := ctx.SpecialFuncDecls[funName]; ok {
 		return f, nil
 	}
-	packageFunName := ctx.PackageData.PackageName + "__" + funName
+	packageFunName := ctx.PackageData.symbol(funName)
 	if f, ok := ctx.PackageData.Functions[packageFunName]; ok {
 		return f, nil
 	}
@@ -132,7 +519,7 @@ func (ctx *GenContext) LookupFunc(funName string) (*ir.Func, error) {
 	if f, ok := ctx.SpecialFuncs[funName]; ok {
 		return f, nil
 	}
-	packageFunName := ctx.PackageData.PackageName + "__" + funName
+	packageFunName := ctx.PackageData.symbol(funName)
 	if f, ok := ctx.Funcs[packageFunName]; ok {
 		return f, nil
 	}
@@ -150,29 +537,73 @@ func (ctx *GenContext) LookupFuncDeclByIR(fun *ir.Func) (*FunctionDecl, error) {
 			return ctx.PackageData.Functions[name], nil
 		}
 	}
+	for _, methods := range ctx.PackageData.Methods {
+		for _, decl := range methods {
+			if decl.Name == fun.Name() {
+				return decl, nil
+			}
+		}
+	}
+	if decl, ok := ctx.externDecls[fun.Name()]; ok && ctx.externFuncs[fun.Name()] == fun {
+		return decl, nil
+	}
 	return nil, utils.MakeError("function declaration not found for %s", fun.String())
 }
 
+// LookupMethod finds method of named type, values of which have type tp.
+func (ctx *GenContext) LookupMethod(tp types.Type, name string) (*FunctionDecl, *ir.Func, error) {
+	decl, err := ctx.PackageData.LookupMethod(tp, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decl, ctx.funcRef(decl), nil
+}
+
 func genFunDef(fun *FunctionDecl) (*ir.Func, error) {
-	var retType types.Type = types.Void
 	var params []*ir.Param
-	if len(fun.ReturnTypes) == 1 {
-		retType = fun.ReturnTypes[0]
-	} else if len(fun.ReturnTypes) > 1 {
-		retType = types.Void
-		// use func params to return values from function
-		for i, p := range fun.ReturnTypes {
-			// generate param name if it is not given
-			name := fun.ReturnNames[i]
-			if name == "" {
-				name = fmt.Sprintf("%s__ret_%d", fun.Name, i)
-			}
-			params = append(params, ir.NewParam(name, types.NewPointer(p)))
+	for i, p := range typesystem.OutParams(fun.ReturnTypes) {
+		// generate param name if it is not given, results are
+		// copied to out params on return
+		name := fun.ReturnNames[i]
+		if name == "" || name == "_" {
+			name = fmt.Sprintf("%s__ret_%d", fun.Name, i)
 		}
+		params = append(params, ir.NewParam(name, p))
 	}
 	for i, p := range fun.ArgTypes {
 		name := fun.ArgNames[i]
 		params = append(params, ir.NewParam(name, p))
 	}
-	return ir.NewFunc(fun.Name, retType, params...), nil
+	return ir.NewFunc(fun.Name, typesystem.ResultType(fun.ReturnTypes), params...), nil
+}
+
+// generateReturn returns result values vals from function fun. Multiple
+// results are either stored in out parameters or returned in struct.
+func generateReturn(block *ir.Block, fun *ir.Func, vals []value.Value) {
+	if len(vals) == 0 {
+		block.NewRet(nil)
+	} else if len(vals) == 1 {
+		block.NewRet(vals[0])
+	} else if typesystem.OutParamResults {
+		for i, val := range vals {
+			block.NewStore(val, fun.Params[i])
+		}
+		block.NewRet(nil)
+	} else {
+		var res value.Value = constant.NewUndef(fun.Sig.RetType)
+		for i, val := range vals {
+			res = block.NewInsertValue(res, val, uint64(i))
+		}
+		block.NewRet(res)
+	}
+}
+
+// returnCall returns results of call made by wrapper, which has calling
+// convention of callee.
+func returnCall(block *ir.Block, res *ir.InstCall) {
+	if res.Type().Equal(types.Void) {
+		block.NewRet(nil)
+	} else {
+		block.NewRet(res)
+	}
 }
@@ -0,0 +1,765 @@
+package passes
+
+import (
+	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+	"sort"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// typeName returns Go name of type, used by type descriptors and runtime panics.
+func (pd *PackageData) typeName(tp types.Type) string {
+	return pd.formatType(tp, qualifiedByName)
+}
+
+// typeSymbol returns name of type qualified by import paths, which is unique
+// in program and names type descriptors.
+func (pd *PackageData) typeSymbol(tp types.Type) string {
+	// assembler does not accept separators of fields and parameters in
+	// symbol names
+	return symbolReplacer.Replace(pd.formatType(tp, qualifiedByPath))
+}
+
+var symbolReplacer = strings.NewReplacer(";", "|", ",", "|")
+
+func qualifiedByName(pkg *PackageData) string { return pkg.PackageName }
+
+func qualifiedByPath(pkg *PackageData) string { return pkg.Path }
+
+// formatType returns Go name of type, named types are qualified by result of qualify.
+func (pd *PackageData) formatType(tp types.Type, qualify func(pkg *PackageData) string) string {
+	switch tp := tp.(type) {
+	case *typesystem.StructInfo:
+		if tp.TypeName != "" {
+			return qualify(pd.typePackage(tp.Pkg)) + "." + tp.TypeName
+		}
+		var fields []string
+		for _, field := range tp.Fields {
+			fieldType := field.Primitive
+			if field.IsStruct {
+				fieldType = field.Struct
+			}
+			if field.IsEmbedded {
+				fields = append(fields, pd.formatType(fieldType, qualify))
+				continue
+			}
+			fields = append(fields, field.Name+" "+pd.formatType(fieldType, qualify))
+		}
+		return "struct { " + strings.Join(fields, "; ") + " }"
+	case *typesystem.NamedType:
+		return qualify(pd.typePackage(tp.Pkg)) + "." + tp.TypeName
+	case *typesystem.InterfaceType:
+		if tp.TypeName == "error" {
+			return tp.TypeName
+		} else if tp.TypeName != "" {
+			return qualify(pd.typePackage(tp.Pkg)) + "." + tp.TypeName
+		} else if len(tp.Methods) == 0 {
+			return "interface {}"
+		}
+		var methods []string
+		for _, method := range tp.Methods {
+			methods = append(methods, method.Name+strings.TrimPrefix(pd.formatSignature(method.ArgTypes, method.ReturnTypes, false, qualify), "func"))
+		}
+		return "interface { " + strings.Join(methods, "; ") + " }"
+	case *typesystem.SliceType:
+		return "[]" + pd.formatType(tp.ElemType, qualify)
+	case *typesystem.MapType:
+		return "map[" + pd.formatType(tp.KeyType, qualify) + "]" + pd.formatType(tp.ElemType, qualify)
+	case *typesystem.FuncType:
+		return pd.formatSignature(tp.ArgTypes, tp.ReturnTypes, tp.Variadic, qualify)
+	case *types.ArrayType:
+		return fmt.Sprintf("[%d]%s", tp.Len, pd.formatType(tp.ElemType, qualify))
+	case *types.PointerType:
+		return "*" + pd.formatType(tp.ElemType, qualify)
+	case *typesystem.ChanType:
+		switch tp.Dir {
+		case typesystem.ChanSend:
+			return "chan<- " + pd.formatType(tp.ElemType, qualify)
+		case typesystem.ChanRecv:
+			return "<-chan " + pd.formatType(tp.ElemType, qualify)
+		}
+		return "chan " + pd.formatType(tp.ElemType, qualify)
+	}
+	if name, ok := basicTypeName(tp); ok {
+		return name
+	}
+	return tp.String()
+}
+
+// basicTypeName returns Go name of boolean, numeric or string type.
+func basicTypeName(tp types.Type) (string, bool) {
+	switch tp := tp.(type) {
+	case *typesystem.UintType:
+		if tp.Word {
+			return "uint", true
+		}
+		return fmt.Sprintf("uint%d", tp.BitSize), true
+	case *typesystem.IntType:
+		return fmt.Sprintf("int%d", tp.BitSize), true
+	case *types.IntType:
+		if tp.BitSize == 1 {
+			return "bool", true
+		} else if tp.BitSize == typesystem.Int.BitSize {
+			return "int", true
+		}
+		return fmt.Sprintf("int%d", tp.BitSize), true
+	case *types.FloatType:
+		if tp.Kind == types.FloatKindFloat {
+			return "float32", true
+		}
+		return "float64", true
+	case *typesystem.StringType:
+		return "string", true
+	}
+	return "", false
+}
+
+// signature returns Go signature of function, without receiver.
+func (pd *PackageData) signature(argTypes, retTypes []types.Type) string {
+	return pd.formatSignature(argTypes, retTypes, false, qualifiedByName)
+}
+
+// formatSignature returns Go signature of function, named types are
+// qualified by result of qualify. Last parameter of variadic function is ...T.
+func (pd *PackageData) formatSignature(argTypes, retTypes []types.Type, variadic bool, qualify func(pkg *PackageData) string) string {
+	var args, rets []string
+	for i, tp := range argTypes {
+		if stp, ok := tp.(*typesystem.SliceType); ok && variadic && i == len(argTypes)-1 {
+			args = append(args, "..."+pd.formatType(stp.ElemType, qualify))
+			continue
+		}
+		args = append(args, pd.formatType(tp, qualify))
+	}
+	for _, tp := range retTypes {
+		rets = append(rets, pd.formatType(tp, qualify))
+	}
+	sig := "func(" + strings.Join(args, ", ") + ")"
+	if len(rets) == 1 {
+		sig += " " + rets[0]
+	} else if len(rets) > 1 {
+		sig += " (" + strings.Join(rets, ", ") + ")"
+	}
+	return sig
+}
+
+// methodSet returns methods of values of type tp sorted by name. Methods with
+// pointer receivers belong to method set of pointer type only.
+func (pd *PackageData) methodSet(tp types.Type) []*FunctionDecl {
+	named, isPtr := tp, false
+	if ptp, ok := tp.(*types.PointerType); ok {
+		named, isPtr = ptp.ElemType, true
+	}
+	var set []*FunctionDecl
+	for _, decl := range pd.NamedMethods(named) {
+		if isPtr || !decl.PtrReceiver {
+			set = append(set, decl)
+		}
+	}
+	sort.Slice(set, func(i, j int) bool {
+		return set[i].Name < set[j].Name
+	})
+	return set
+}
+
+// methodName returns name of method from its mangled symbol.
+func methodName(decl *FunctionDecl) string {
+	return decl.Name[strings.LastIndex(decl.Name, "__")+2:]
+}
+
+// implements checks that values of type tp have all methods of interface itp
+// and returns them in order of interface methods.
+func (pd *PackageData) implements(tp types.Type, itp *typesystem.InterfaceType) ([]*FunctionDecl, error) {
+	set := pd.methodSet(tp)
+	var methods []*FunctionDecl
+	for _, imethod := range itp.Methods {
+		var found *FunctionDecl
+		for _, decl := range set {
+			if methodName(decl) == imethod.Name {
+				found = decl
+				break
+			}
+		}
+		if found == nil {
+			if _, ok := tp.(*types.PointerType); !ok {
+				for _, decl := range pd.methodSet(types.NewPointer(tp)) {
+					if methodName(decl) == imethod.Name {
+						return nil, utils.MakeError("%s does not implement %s (method %s has pointer receiver)", pd.typeName(tp), pd.typeName(itp), imethod.Name)
+					}
+				}
+			}
+			return nil, utils.MakeError("%s does not implement %s (missing method %s)", pd.typeName(tp), pd.typeName(itp), imethod.Name)
+		}
+		have := pd.signature(found.ArgTypes[1:], found.ReturnTypes)
+		want := pd.signature(imethod.ArgTypes, imethod.ReturnTypes)
+		if have != want {
+			return nil, utils.MakeError("%s does not implement %s (wrong type for method %s: have %s, want %s)", pd.typeName(tp), pd.typeName(itp), imethod.Name, have, want)
+		}
+		methods = append(methods, found)
+	}
+	return methods, nil
+}
+
+// stringConst returns pointer to global NUL-terminated string.
+func (genCtx *GenContext) stringConst(s string) constant.Constant {
+	glob := genCtx.stringGlobal(s)
+	return constant.NewGetElementPtr(glob.ContentType, glob, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+}
+
+// ifaceMethodFunc returns function, which calls method decl with receiver
+// loaded from data word of interface value with dynamic type tp.
+func (genCtx *GenContext) ifaceMethodFunc(tp types.Type, decl *FunctionDecl) *ir.Func {
+	name := decl.Name + "__iface"
+	if _, ok := tp.(*types.PointerType); ok {
+		name = decl.Name + "__ifaceptr"
+	}
+	if fun, ok := genCtx.ifaceFuncs[name]; ok {
+		return fun
+	}
+	target := genCtx.funcRef(decl)
+	wrapperDecl := &FunctionDecl{
+		Name:        name,
+		ReturnNames: make([]string, len(decl.ReturnTypes)),
+		ReturnTypes: decl.ReturnTypes,
+		ArgNames:    []string{"data"},
+		ArgTypes:    []types.Type{types.I8Ptr},
+	}
+	for i, tp := range decl.ArgTypes[1:] {
+		wrapperDecl.ArgNames = append(wrapperDecl.ArgNames, fmt.Sprintf("arg%d", i))
+		wrapperDecl.ArgTypes = append(wrapperDecl.ArgTypes, tp)
+	}
+	fun, _ := genFunDef(wrapperDecl)
+	fun.Linkage = enum.LinkageLinkOnceODR
+	fun.Parent = genCtx.module
+	genCtx.module.Funcs = append(genCtx.module.Funcs, fun)
+	genCtx.ifaceFuncs[name] = fun
+
+	block := fun.NewBlock("entry")
+	outCount := len(typesystem.OutParams(decl.ReturnTypes))
+	// data points to dynamic value, which is either receiver or pointer to it
+	var recv value.Value = block.NewBitCast(fun.Params[outCount], types.NewPointer(tp))
+	recv = block.NewLoad(tp, recv)
+	if ptp, ok := tp.(*types.PointerType); ok && !decl.PtrReceiver {
+		recv = block.NewLoad(ptp.ElemType, recv)
+	}
+	var args []value.Value
+	for i, param := range fun.Params {
+		if i == outCount {
+			args = append(args, recv)
+		} else {
+			args = append(args, param)
+		}
+	}
+	returnCall(block, block.NewCall(target, args...))
+	return fun
+}
+
+// type kinds, must match KIND_* constants in runtime.h
+const (
+	kindBool = iota + 1
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindPointer
+	kindSlice
+	kindArray
+	kindStruct
+	kindMap
+	kindChan
+	kindFunc
+	kindIface
+)
+
+// typeKind returns kind of type for runtime, which walks values by their
+// type descriptors.
+func typeKind(tp types.Type) int64 {
+	switch tp := typesystem.Underlying(tp).(type) {
+	case *types.IntType:
+		if tp.BitSize == 1 {
+			return kindBool
+		}
+		return kindInt
+	case *typesystem.IntType:
+		return kindInt
+	case *typesystem.UintType:
+		return kindUint
+	case *types.FloatType:
+		return kindFloat
+	case *typesystem.StringType:
+		return kindString
+	case *typesystem.SliceType:
+		return kindSlice
+	case *types.ArrayType:
+		return kindArray
+	case *typesystem.StructInfo:
+		return kindStruct
+	case *typesystem.MapType:
+		return kindMap
+	case *typesystem.ChanType:
+		return kindChan
+	case *typesystem.FuncType:
+		return kindFunc
+	case *typesystem.InterfaceType:
+		return kindIface
+	}
+	return kindPointer
+}
+
+// typeDesc returns type descriptor of dynamic type of interface values.
+// Descriptors of element, key and field types are referenced, so runtime
+// can print values of any type.
+func (genCtx *GenContext) typeDesc(tp types.Type) (constant.Constant, error) {
+	sym := genCtx.PackageData.typeSymbol(tp)
+	glob, ok := genCtx.typeDescs[sym]
+	if ok {
+		return constant.NewBitCast(glob, types.I8Ptr), nil
+	}
+	set := genCtx.PackageData.methodSet(tp)
+	methodType := types.NewStruct(types.I8Ptr, types.I8Ptr, types.I8Ptr)
+	methodsType := types.NewArray(uint64(len(set)), methodType)
+	descType := types.NewStruct(types.I8Ptr, types.I32Ptr, types.I32, types.I64, types.I8Ptr, types.I8Ptr, types.I64, types.I32, types.I8Ptr, types.I32, methodsType)
+	// recursive types refer to descriptor being built
+	// descriptors are shared by packages, linker keeps one of them
+	glob = genCtx.module.NewGlobalDef("typedesc."+sym, constant.NewZeroInitializer(descType))
+	glob.Linkage = enum.LinkageLinkOnceODR
+	genCtx.typeDescs[sym] = glob
+
+	var keydesc constant.Constant = constant.NewNull(types.I32Ptr)
+	if typesystem.IsComparable(tp) {
+		var err error
+		keydesc, err = genCtx.mapKeyDesc(tp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var elem, key constant.Constant = constant.NewNull(types.I8Ptr), constant.NewNull(types.I8Ptr)
+	var elemType, keyType types.Type
+	var length int64
+	switch utp := typesystem.Underlying(tp).(type) {
+	case *types.PointerType:
+		elemType = utp.ElemType
+	case *typesystem.SliceType:
+		elemType = utp.ElemType
+	case *types.ArrayType:
+		elemType, length = utp.ElemType, int64(utp.Len)
+	case *typesystem.ChanType:
+		elemType = utp.ElemType
+	case *typesystem.MapType:
+		elemType, keyType = utp.ElemType, utp.KeyType
+	}
+	var err error
+	if elemType != nil {
+		if elem, err = genCtx.typeDesc(elemType); err != nil {
+			return nil, err
+		}
+	}
+	if keyType != nil {
+		if key, err = genCtx.typeDesc(keyType); err != nil {
+			return nil, err
+		}
+	}
+	nfields, fields, err := genCtx.typeFields(tp, sym)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []constant.Constant
+	for _, decl := range set {
+		methods = append(methods, constant.NewStruct(methodType,
+			genCtx.stringConst(methodName(decl)),
+			genCtx.stringConst(genCtx.PackageData.signature(decl.ArgTypes[1:], decl.ReturnTypes)),
+			constant.NewBitCast(genCtx.ifaceMethodFunc(tp, decl), types.I8Ptr),
+		))
+	}
+	glob.Init = constant.NewStruct(descType,
+		genCtx.stringConst(genCtx.PackageData.typeName(tp)),
+		keydesc,
+		constant.NewInt(types.I32, typeKind(tp)),
+		sizeOf(tp),
+		elem,
+		key,
+		constant.NewInt(types.I64, length),
+		constant.NewInt(types.I32, nfields),
+		fields,
+		constant.NewInt(types.I32, int64(len(methods))),
+		constant.NewArray(methodsType, methods...),
+	)
+	return constant.NewBitCast(glob, types.I8Ptr), nil
+}
+
+// typeFields returns number of fields of struct type and pointer to array of
+// their names, type descriptors and offsets.
+func (genCtx *GenContext) typeFields(tp types.Type, sym string) (int64, constant.Constant, error) {
+	stp, ok := typesystem.Underlying(tp).(*typesystem.StructInfo)
+	if !ok || len(stp.Fields) == 0 {
+		return 0, constant.NewNull(types.I8Ptr), nil
+	}
+	fieldType := types.NewStruct(types.I8Ptr, types.I8Ptr, types.I64)
+	var fields []constant.Constant
+	for i, field := range stp.Fields {
+		var ftp types.Type = field.Primitive
+		if field.IsStruct {
+			ftp = field.Struct
+		}
+		desc, err := genCtx.typeDesc(ftp)
+		if err != nil {
+			return 0, nil, err
+		}
+		offset := constant.NewPtrToInt(
+			constant.NewGetElementPtr(&stp.StructType, constant.NewNull(types.NewPointer(&stp.StructType)), constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))),
+			types.I64,
+		)
+		fields = append(fields, constant.NewStruct(fieldType, genCtx.stringConst(field.Name), desc, offset))
+	}
+	arr := constant.NewArray(types.NewArray(uint64(len(fields)), fieldType), fields...)
+	glob := genCtx.module.NewGlobalDef("typefields."+sym, arr)
+	glob.Linkage = enum.LinkageLinkOnceODR
+	return int64(len(fields)), constant.NewBitCast(glob, types.I8Ptr), nil
+}
+
+// ifaceDesc returns interface descriptor used to build itabs at runtime.
+func (genCtx *GenContext) ifaceDesc(itp *typesystem.InterfaceType) constant.Constant {
+	sym := genCtx.PackageData.typeSymbol(itp)
+	glob, ok := genCtx.ifaceDescs[sym]
+	if !ok {
+		methodType := types.NewStruct(types.I8Ptr, types.I8Ptr)
+		var methods []constant.Constant
+		for _, method := range itp.Methods {
+			methods = append(methods, constant.NewStruct(methodType,
+				genCtx.stringConst(method.Name),
+				genCtx.stringConst(genCtx.PackageData.signature(method.ArgTypes, method.ReturnTypes)),
+			))
+		}
+		methodsType := types.NewArray(uint64(len(methods)), methodType)
+		desc := constant.NewStruct(
+			types.NewStruct(types.I8Ptr, types.I32, methodsType),
+			genCtx.stringConst(genCtx.PackageData.typeName(itp)),
+			constant.NewInt(types.I32, int64(len(methods))),
+			constant.NewArray(methodsType, methods...),
+		)
+		glob = genCtx.module.NewGlobalDef("ifacedesc."+sym, desc)
+		glob.Linkage = enum.LinkageLinkOnceODR
+		genCtx.ifaceDescs[sym] = glob
+	}
+	return constant.NewBitCast(glob, types.I8Ptr)
+}
+
+// itab returns itab for conversion of values of type tp to interface itp.
+func (genCtx *GenContext) itab(tp types.Type, itp *typesystem.InterfaceType) (constant.Constant, error) {
+	key := genCtx.PackageData.typeSymbol(tp) + "/" + genCtx.PackageData.typeSymbol(itp)
+	glob, ok := genCtx.itabs[key]
+	if !ok {
+		methods, err := genCtx.PackageData.implements(tp, itp)
+		if err != nil {
+			return nil, err
+		}
+		desc, err := genCtx.typeDesc(tp)
+		if err != nil {
+			return nil, err
+		}
+		var fns []constant.Constant
+		for _, decl := range methods {
+			fns = append(fns, constant.NewBitCast(genCtx.ifaceMethodFunc(tp, decl), types.I8Ptr))
+		}
+		fnsType := types.NewArray(uint64(len(fns)), types.I8Ptr)
+		tab := constant.NewStruct(
+			types.NewStruct(types.I8Ptr, fnsType),
+			desc,
+			constant.NewArray(fnsType, fns...),
+		)
+		glob = genCtx.module.NewGlobalDef("itab."+key, tab)
+		glob.Linkage = enum.LinkageLinkOnceODR
+		genCtx.itabs[key] = glob
+	}
+	return constant.NewBitCast(glob, types.I8Ptr), nil
+}
+
+// GenerateIfacePair builds interface value from itab and data pointers.
+func (genCtx *GenContext) GenerateIfacePair(block *ir.Block, itp *typesystem.InterfaceType, tab, data value.Value) value.Value {
+	var pair value.Value = constant.NewUndef(&itp.StructType)
+	pair = block.NewInsertValue(pair, tab, 0)
+	pair = block.NewInsertValue(pair, data, 1)
+	return typesystem.NewTypedValue(pair, itp)
+}
+
+// GenerateIfaceParts extracts itab and data pointers from interface value.
+func (genCtx *GenContext) GenerateIfaceParts(block *ir.Block, val value.Value) (value.Value, value.Value) {
+	pair := typesystem.Raw(val)
+	return block.NewExtractValue(pair, 0), block.NewExtractValue(pair, 1)
+}
+
+// GenerateIfaceConv converts value to interface type. Concrete values are
+// copied to heap, so data word of interface value always points to value.
+func (genCtx *GenContext) GenerateIfaceConv(block *ir.Block, val value.Value, itp *typesystem.InterfaceType) (value.Value, error) {
+	if _, ok := val.(*constant.Null); ok {
+		return typesystem.NewTypedValue(constant.NewZeroInitializer(&itp.StructType), itp), nil
+	}
+	if vtp, ok := val.Type().(*typesystem.InterfaceType); ok {
+		if itp.Equal(vtp) {
+			return typesystem.NewTypedValue(val, itp), nil
+		}
+		// interface to interface with fewer methods
+		for _, imethod := range itp.Methods {
+			idx, ok := vtp.MethodIndex(imethod.Name)
+			if !ok || !vtp.Methods[idx].Equal(imethod) {
+				return nil, utils.MakeError("%s does not implement %s (missing method %s)", genCtx.PackageData.typeName(vtp), genCtx.PackageData.typeName(itp), imethod.Name)
+			}
+		}
+		convI2I, err := genCtx.LookupFunc("runtime_convI2I")
+		if err != nil {
+			return nil, err
+		}
+		tab, data := genCtx.GenerateIfaceParts(block, val)
+		return genCtx.GenerateIfacePair(block, itp, block.NewCall(convI2I, tab, genCtx.ifaceDesc(itp)), data), nil
+	}
+	tp := val.Type()
+	if _, ok := val.(*typesystem.GoModule); ok {
+		return nil, utils.MakeError("invalid value for interface %s", genCtx.PackageData.typeName(itp))
+	} else if _, ok := val.(*ir.Func); ok {
+		return nil, utils.MakeError("invalid value for interface %s", genCtx.PackageData.typeName(itp))
+	}
+	tab, err := genCtx.itab(tp, itp)
+	if err != nil {
+		return nil, err
+	}
+	if zeroSize(tp) {
+		// values of zero size share address, as GC can't allocate them
+		return genCtx.GenerateIfacePair(block, itp, tab, genCtx.zeroBase()), nil
+	}
+	malloc, err := genCtx.LookupFunc("GC_malloc")
+	if err != nil {
+		return nil, err
+	}
+	data := block.NewCall(malloc, sizeOf(tp))
+	block.NewStore(val, typesystem.NewTypedValue(block.NewBitCast(data, types.NewPointer(tp)), types.NewPointer(tp)))
+	return genCtx.GenerateIfacePair(block, itp, tab, data), nil
+}
+
+// zeroSize reports whether values of type tp occupy no memory.
+func zeroSize(tp types.Type) bool {
+	switch utp := typesystem.Underlying(tp).(type) {
+	case *typesystem.StructInfo:
+		for _, ftp := range utp.StructType.Fields {
+			if !zeroSize(ftp) {
+				return false
+			}
+		}
+		return true
+	case *types.ArrayType:
+		return utp.Len == 0 || zeroSize(utp.ElemType)
+	}
+	return false
+}
+
+// zeroBase returns address of values of zero size. Global is defined by
+// each module using it with linkonce_odr linkage, linker keeps one definition.
+func (genCtx *GenContext) zeroBase() constant.Constant {
+	for _, glob := range genCtx.module.Globals {
+		if glob.Name() == "zerobase" {
+			return constant.NewBitCast(glob, types.I8Ptr)
+		}
+	}
+	glob := genCtx.module.NewGlobalDef("zerobase", constant.NewInt(types.I64, 0))
+	glob.Linkage = enum.LinkageLinkOnceODR
+	return constant.NewBitCast(glob, types.I8Ptr)
+}
+
+// GenerateAssignConv converts value to type of variable it is assigned to:
+// constants get expected type and concrete values are converted to interfaces.
+func (genCtx *GenContext) GenerateAssignConv(block *ir.Block, val value.Value, tp types.Type) (value.Value, error) {
+	if itp, ok := tp.(*typesystem.InterfaceType); ok {
+		val, err := defaultConst(val)
+		if err != nil {
+			return nil, err
+		}
+		return genCtx.GenerateIfaceConv(block, val, itp)
+	}
+	val, err := adaptConstant(val, tp)
+	if err != nil {
+		return nil, err
+	}
+	if !val.Type().Equal(tp) && identicalUnderlying(val.Type(), tp) {
+		// value of unnamed type assigned to named one
+		vals, _, err := genCtx.GenerateTypeCast(block, tp, val)
+		if err != nil {
+			return nil, err
+		}
+		return vals[0], nil
+	}
+	return val, nil
+}
+
+// generateAssignConvs converts values to types of variables they are assigned to.
+// Values without variable types (like variadic arguments) are left intact.
+func (genCtx *GenContext) generateAssignConvs(block *ir.Block, vals []value.Value, tps []types.Type) ([]value.Value, error) {
+	res := make([]value.Value, len(vals))
+	for i, val := range vals {
+		if i >= len(tps) {
+			res[i] = val
+			continue
+		}
+		var err error
+		res[i], err = genCtx.GenerateAssignConv(block, val, tps[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// generateDynType loads type descriptor of dynamic type from itab, nil for nil interface.
+func (genCtx *GenContext) generateDynType(block *ir.Block, tab value.Value) (value.Value, []*ir.Block) {
+	bload := genCtx.NewBlock("dyntype.load")
+	bdone := genCtx.NewBlock("dyntype.done")
+	block.NewCondBr(block.NewICmp(enum.IPredNE, tab, constant.NewNull(types.I8Ptr)), bload, bdone)
+
+	dyn := bload.NewLoad(types.I8Ptr, bload.NewBitCast(tab, types.NewPointer(types.I8Ptr)))
+	bload.NewBr(bdone)
+
+	res := bdone.NewPhi(
+		ir.NewIncoming(constant.NewNull(types.I8Ptr), block),
+		ir.NewIncoming(dyn, bload),
+	)
+	return typesystem.NewTypedValue(res, types.I8Ptr), []*ir.Block{bload, bdone}
+}
+
+// generateIfaceCall calls method of dynamic type of interface value through itab.
+func (genCtx *GenContext) generateIfaceCall(block *ir.Block, iface value.Value, name string, args []value.Value) ([]value.Value, error) {
+	itp := iface.Type().(*typesystem.InterfaceType)
+	idx, ok := itp.MethodIndex(name)
+	if !ok {
+		return nil, utils.MakeError("%s has no method %s", genCtx.PackageData.typeName(itp), name)
+	}
+	method := itp.Methods[idx]
+	args, err := genCtx.generateAssignConvs(block, args, method.ArgTypes)
+	if err != nil {
+		return nil, err
+	}
+	tab, data := genCtx.GenerateIfaceParts(block, iface)
+
+	// method table follows type descriptor in itab
+	fnAddr := block.NewGetElementPtr(types.I8Ptr,
+		block.NewBitCast(tab, types.NewPointer(types.I8Ptr)),
+		constant.NewInt(types.I32, int64(idx+1)),
+	)
+	params := typesystem.OutParams(method.ReturnTypes)
+	params = append(params, types.I8Ptr)
+	params = append(params, method.ArgTypes...)
+	fnType := types.NewPointer(types.NewFunc(typesystem.ResultType(method.ReturnTypes), params...))
+	fn := block.NewBitCast(block.NewLoad(types.I8Ptr, fnAddr), fnType)
+	return genCtx.generateCall(block, fn, method.ReturnTypes, append([]value.Value{data}, args...)), nil
+}
+
+// GenerateTypeAssertion generates x.(T), comma-ok form returns additional
+// flag whether assertion holds instead of panicking.
+func (genCtx *GenContext) GenerateTypeAssertion(block *ir.Block, ctx parser.IPrimaryExprContext, commaOk bool) ([]value.Value, []*ir.Block, error) {
+	vals, blocks, err := genCtx.GeneratePrimaryExpr(block, ctx.PrimaryExpr())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse type assertion")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	iface := vals[0]
+	itp := iface.Type().(*typesystem.InterfaceType)
+	tp, err := genCtx.PackageData.ParseType(ctx.TypeAssertion().Type_())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse type assertion")
+	}
+	tab, data := genCtx.GenerateIfaceParts(block, iface)
+
+	if ttp, ok := tp.(*typesystem.InterfaceType); ok {
+		assertI2I, err := genCtx.LookupFunc("runtime_assertI2I")
+		if err != nil {
+			return nil, nil, err
+		}
+		newTab := block.NewCall(assertI2I, tab, genCtx.ifaceDesc(ttp), constant.NewBool(commaOk))
+		if !commaOk {
+			return []value.Value{genCtx.GenerateIfacePair(block, ttp, newTab, data)}, blocks, nil
+		}
+		found := block.NewICmp(enum.IPredNE, newTab, constant.NewNull(types.I8Ptr))
+		data = block.NewSelect(found, data, constant.NewNull(types.I8Ptr))
+		return []value.Value{
+			genCtx.GenerateIfacePair(block, ttp, newTab, data),
+			typesystem.NewTypedValue(found, typesystem.Bool),
+		}, blocks, nil
+	}
+
+	if _, err := genCtx.PackageData.implements(tp, itp); err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "impossible type assertion")
+	}
+	want, err := genCtx.typeDesc(tp)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse type assertion")
+	}
+	dyn, newBlocks := genCtx.generateDynType(block, tab)
+	blocks = append(blocks, newBlocks...)
+	block = blocks[len(blocks)-1]
+	found := block.NewICmp(enum.IPredEQ, dyn, want)
+
+	bok := genCtx.NewBlock("typeassert.ok")
+	ptr := typesystem.NewTypedValue(bok.NewBitCast(data, types.NewPointer(tp)), types.NewPointer(tp))
+	val := bok.NewLoad(tp, ptr)
+	if !commaOk {
+		bfail := genCtx.NewBlock("typeassert.fail")
+		block.NewCondBr(found, bok, bfail)
+		panicassert, err := genCtx.LookupFunc("runtime_panicassert")
+		if err != nil {
+			return nil, nil, err
+		}
+		bfail.NewCall(panicassert, dyn, want, genCtx.stringConst(genCtx.PackageData.typeName(itp)))
+		bfail.NewUnreachable()
+		blocks = append(blocks, bfail, bok)
+		return []value.Value{typesystem.NewTypedValue(val, tp)}, blocks, nil
+	}
+	bdone := genCtx.NewBlock("typeassert.done")
+	block.NewCondBr(found, bok, bdone)
+	bok.NewBr(bdone)
+	res := bdone.NewPhi(
+		ir.NewIncoming(constant.NewZeroInitializer(tp), block),
+		ir.NewIncoming(val, bok),
+	)
+	blocks = append(blocks, bok, bdone)
+	return []value.Value{
+		typesystem.NewTypedValue(res, tp),
+		typesystem.NewTypedValue(found, typesystem.Bool),
+	}, blocks, nil
+}
+
+// GenerateIfaceCompare compares interface value with nil, another interface
+// or concrete value, which is converted to interface first.
+func (genCtx *GenContext) GenerateIfaceCompare(block *ir.Block, op int, left, right value.Value) (value.Value, error) {
+	itp, ok := left.Type().(*typesystem.InterfaceType)
+	if !ok {
+		left, right = right, left
+		itp = left.Type().(*typesystem.InterfaceType)
+	}
+	if _, ok := right.(*constant.Null); ok {
+		return genCtx.GenerateNilCmp(block, op, left, right)
+	}
+	if !typesystem.IsInterfaceType(right.Type()) {
+		var err error
+		right, err = genCtx.GenerateIfaceConv(block, right, itp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ifaceeq, err := genCtx.LookupFunc("runtime_ifaceeq")
+	if err != nil {
+		return nil, err
+	}
+	tab1, data1 := genCtx.GenerateIfaceParts(block, left)
+	tab2, data2 := genCtx.GenerateIfaceParts(block, right)
+	var res value.Value = block.NewCall(ifaceeq, tab1, data1, tab2, data2)
+	if op == parser.GoParserNOT_EQUALS {
+		res = block.NewXor(res, constant.True)
+	}
+	return typesystem.NewTypedValue(res, typesystem.Bool), nil
+}
@@ -0,0 +1,114 @@
+package passes
+
+import (
+	"gocomp/internal/parser"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+// varInit is package-level variable spec with source file declaring it.
+type varInit struct {
+	file parser.ISourceFileContext
+	spec parser.IVarSpecContext
+}
+
+// initOrder returns package-level variable specs of files in order of their
+// initialization. Spec is initialized after variables it refers to, directly
+// or through bodies of referenced functions and methods, otherwise specs keep
+// order of declaration. Cycles are broken by order of declaration too.
+func initOrder(files []parser.ISourceFileContext) []varInit {
+	var inits []varInit
+	declaredBy := make(map[string]int)
+	bodies := make(map[string][]antlr.Tree)
+	for _, file := range files {
+		for _, decl := range file.AllDeclaration() {
+			if decl.VarDecl() == nil {
+				continue
+			}
+			for _, spec := range decl.VarDecl().AllVarSpec() {
+				for _, id := range spec.IdentifierList().AllIDENTIFIER() {
+					declaredBy[id.GetText()] = len(inits)
+				}
+				inits = append(inits, varInit{file: file, spec: spec})
+			}
+		}
+		for _, fun := range file.AllFunctionDecl() {
+			if fun.Block() != nil {
+				name := fun.IDENTIFIER().GetText()
+				bodies[name] = append(bodies[name], fun.Block())
+			}
+		}
+		for _, meth := range file.AllMethodDecl() {
+			if meth.Block() != nil {
+				name := meth.IDENTIFIER().GetText()
+				bodies[name] = append(bodies[name], meth.Block())
+			}
+		}
+	}
+
+	// names referenced by initializers, functions are followed by name
+	deps := make([]map[int]bool, len(inits))
+	for i, init := range inits {
+		deps[i] = make(map[int]bool)
+		if init.spec.ExpressionList() == nil {
+			continue
+		}
+		names := make(map[string]bool)
+		identNames(init.spec.ExpressionList(), names)
+		var queue []string
+		for name := range names {
+			queue = append(queue, name)
+		}
+		for ; len(queue) > 0; queue = queue[1:] {
+			for _, body := range bodies[queue[0]] {
+				found := make(map[string]bool)
+				identNames(body, found)
+				for name := range found {
+					if !names[name] {
+						names[name] = true
+						queue = append(queue, name)
+					}
+				}
+			}
+		}
+		for name := range names {
+			if j, ok := declaredBy[name]; ok && j != i {
+				deps[i][j] = true
+			}
+		}
+	}
+
+	// first spec in declaration order, which is ready for initialization
+	order := make([]varInit, 0, len(inits))
+	done := make([]bool, len(inits))
+	for len(order) < len(inits) {
+		next := -1
+		for i := range inits {
+			if !done[i] && ready(deps[i], done) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			for i := range inits {
+				if !done[i] {
+					next = i
+					break
+				}
+			}
+		}
+		done[next] = true
+		order = append(order, inits[next])
+	}
+	return order
+}
+
+// ready reports whether all specs in deps are initialized.
+func ready(deps map[int]bool, done []bool) bool {
+	for j := range deps {
+		if !done[j] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,11 +1,9 @@
 package passes
 
 import (
-	"fmt"
 	"gocomp/internal/parser"
 	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
-	"strconv"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
@@ -23,6 +21,12 @@ func (genCtx *GenContext) GenerateLiteralExpr(block *ir.Block, ctx parser.ILiter
 		return genCtx.GenerateBasicLiteralExpr(block, ctx.BasicLit())
 	} else if ctx.CompositeLit() != nil {
 		return genCtx.GenerateCompositeLiteralExpr(block, ctx.CompositeLit())
+	} else if ctx.FunctionLit() != nil {
+		val, err := genCtx.funcLitGen(block, ctx.FunctionLit())
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to generate function literal")
+		}
+		return []value.Value{val}, nil, nil
 	}
 	return nil, nil, utils.MakeErrorTrace(ctx, nil, "unimplemented basic literal: %s", ctx.GetText())
 }
@@ -30,55 +34,17 @@ func (genCtx *GenContext) GenerateLiteralExpr(block *ir.Block, ctx parser.ILiter
 func (genCtx *GenContext) GenerateBasicLiteralExpr(block *ir.Block, ctx parser.IBasicLitContext) ([]value.Value, []*ir.Block, error) {
 	if ctx.NIL_LIT() != nil {
 		return []value.Value{constant.NewNull(types.I32Ptr)}, nil, nil
-	} else if ctx.Integer() != nil {
-		istr := ctx.Integer().GetText()
-		// adjust for hexadecimals
-		if len(istr) >= 2 && (istr[:2] == "0x" || istr[:2] == "0X") {
-			istr = "s" + istr
-		}
-		val, err := constant.NewIntFromString(types.I32, istr)
-		if err != nil {
-			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse basic integer literal expression")
-		}
-		return []value.Value{val}, nil, nil
-	} else if ctx.FLOAT_LIT() != nil {
-		val, err := constant.NewFloatFromString(types.Double, ctx.FLOAT_LIT().GetText())
-		if err != nil {
-			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse basic float literal expression")
-		}
-		return []value.Value{
-			typesystem.NewTypedValue(val, typesystem.Float64),
-		}, nil, nil
-	} else if ctx.FALSE_LIT() != nil {
-		return []value.Value{
-			typesystem.NewTypedValue(constant.False, typesystem.Bool),
-		}, nil, nil
-	} else if ctx.TRUE_LIT() != nil {
-		return []value.Value{
-			typesystem.NewTypedValue(constant.True, typesystem.Bool),
-		}, nil, nil
-	} else if ctx.String_() != nil {
-		strVal, err := strconv.Unquote(ctx.String_().GetText())
-		if err != nil {
-			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse basic string literal expression")
-		}
-		glob, ok := genCtx.Consts[strVal]
-		if !ok {
-			val := constant.NewCharArray(append([]byte(strVal), 0))
-			glob = genCtx.module.NewGlobalDef(fmt.Sprintf("str.%d", len(genCtx.Consts)), val)
-			genCtx.Consts[strVal] = glob
-		}
-		addr := constant.NewGetElementPtr(glob.ContentType, glob, constant.NewInt(types.I32, 0))
-		return []value.Value{
-			typesystem.NewTypedValue(addr, types.I8Ptr),
-		}, nil, nil
 	}
-	return nil, nil, utils.MakeErrorTrace(ctx, nil, "not implemented basic literal: %s", ctx.GetText())
+	c, _, err := constBasicLit(ctx)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse basic literal expression")
+	}
+	return []value.Value{genCtx.GenerateConst(c)}, nil, nil
 }
 
 func (genCtx *GenContext) GenerateCompositeLiteralExpr(block *ir.Block, ctx parser.ICompositeLitContext) ([]value.Value, []*ir.Block, error) {
 	// parse literal type
-	ltp, err := genCtx.PackageData.typeManager.ParseLiteralType(ctx.LiteralType())
+	ltp, err := genCtx.PackageData.typeManager.ParseLiteralType(ctx.LiteralType(), ctx.LiteralValue())
 	if err != nil {
 		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to generate composite literal expression")
 	}
@@ -91,13 +57,26 @@ func (genCtx *GenContext) GenerateCompositeLiteralExpr(block *ir.Block, ctx pars
 }
 
 func (genCtx *GenContext) GenerateCompositeLiteralValue(block *ir.Block, tp types.Type, ctx parser.ILiteralValueContext) (value.Value, []*ir.Block, error) {
-	if stp, ok := tp.(*typesystem.StructInfo); ok {
-		return genCtx.GenerateStructLiteralValue(block, stp, ctx)
+	var val value.Value
+	var blocks []*ir.Block
+	var err error
+	switch utp := typesystem.Underlying(tp).(type) {
+	case *typesystem.StructInfo:
+		return genCtx.GenerateStructLiteralValue(block, utp, ctx)
+	case *types.ArrayType:
+		val, blocks, err = genCtx.GenerateArrayLiteralValue(block, utp, ctx)
+	case *typesystem.SliceType:
+		val, blocks, err = genCtx.GenerateSliceLiteralValue(block, utp, ctx)
+	case *typesystem.MapType:
+		val, blocks, err = genCtx.GenerateMapLiteralValue(block, utp, ctx)
+	default:
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "unimplemented composite literal value: %s", ctx.GetText())
 	}
-	if atp, ok := tp.(*types.ArrayType); ok {
-		return genCtx.GenerateArrayLiteralValue(block, atp, ctx)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil, nil, utils.MakeErrorTrace(ctx, nil, "unimplemented composite literal value: %s", ctx.GetText())
+	// literal of named type
+	return typesystem.NewTypedValue(val, tp), blocks, nil
 }
 
 func (genCtx *GenContext) GenerateStructLiteralValue(block *ir.Block, stp *typesystem.StructInfo, ctx parser.ILiteralValueContext) (value.Value, []*ir.Block, error) {
@@ -109,42 +88,32 @@ func (genCtx *GenContext) GenerateStructLiteralValue(block *ir.Block, stp *types
 	keyedElems := []keyedElement{}
 	var blocks []*ir.Block
 	for _, kElemCtx := range ctx.ElementList().AllKeyedElement() {
-		kelem, newBlocks, err := genCtx.ParseKeyedElement(block, stp, kElemCtx)
+		key := ""
+		if kElemCtx.Key() != nil {
+			key = kElemCtx.Key().GetText()
+		} else {
+			// key by field position in literal
+			key = stp.Fields[len(keyedElems)].Name
+		}
+		path, tp, err := stp.ComputeOffset(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		kelem, newBlocks, err := genCtx.ParseKeyedElement(block, tp, kElemCtx)
 		if err != nil {
 			return nil, nil, err
 		} else if newBlocks != nil {
 			blocks = append(blocks, newBlocks...)
 			block = blocks[len(blocks)-1]
 		}
-		// check for duplicate key names
-		for _, k := range keyedElems {
-			if k.key == kelem.key {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "duplicate field name in struct literal")
-			}
-		}
-		// build up struct value
-		var off int
-		if kelem.key != "" {
-			var tp types.Type
-			var err error
-			off, tp, err = stp.ComputeOffset(kelem.key)
-			if err != nil {
-				return nil, nil, err
-			}
-			// TODO: check types for tp and keyed element
-			_ = tp
-		} else {
-			// offset by field position in literal
-			off = len(keyedElems)
-			kelem.key = stp.Fields[off].Name
-		}
-		// TODO: fix this hack for nil values
-		if nilElem, ok := kelem.element.(*constant.Null); ok {
-			nilElem.Typ = stp.Fields[off].Primitive.(*types.PointerType)
+		kelem.key = key
+		elem, err := genCtx.GenerateAssignConv(block, kelem.element, tp)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid value for field %s", key)
 		}
 		block.NewStore(
-			kelem.element,
-			block.NewGetElementPtr(&stp.StructType, slitAddr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(off))),
+			elem,
+			block.NewGetElementPtr(&stp.StructType, slitAddr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(path[0]))),
 		)
 		keyedElems = append(keyedElems, *kelem)
 	}
@@ -162,7 +131,7 @@ func (genCtx *GenContext) GenerateArrayLiteralValue(block *ir.Block, atp *types.
 	var blocks []*ir.Block
 	i := 0
 	for _, kElemCtx := range ctx.ElementList().AllKeyedElement() {
-		kelem, newBlocks, err := genCtx.ParseKeyedElement(block, atp, kElemCtx)
+		kelem, newBlocks, err := genCtx.ParseKeyedElement(block, atp.ElemType, kElemCtx)
 		if err != nil {
 			return nil, nil, err
 		} else if newBlocks != nil {
@@ -170,9 +139,9 @@ func (genCtx *GenContext) GenerateArrayLiteralValue(block *ir.Block, atp *types.
 			block = blocks[len(blocks)-1]
 		}
 		if kelem.key != "" {
-			ki, err := strconv.ParseInt(kelem.key, 10, 64)
-			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid string for array index: %s", kelem.key)
+			ki, ok := genCtx.PackageData.literalIndex(kElemCtx.Key())
+			if !ok {
+				return nil, nil, utils.MakeErrorTrace(ctx, nil, "index %s must be integer constant", kelem.key)
 			}
 			i = int(ki)
 		}
@@ -187,8 +156,12 @@ func (genCtx *GenContext) GenerateArrayLiteralValue(block *ir.Block, atp *types.
 		}
 		// build up array value
 		// TODO: check types for array element and keyed element
+		elem, err := genCtx.GenerateAssignConv(block, kelem.element, atp.ElemType)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid array literal element")
+		}
 		block.NewStore(
-			kelem.element,
+			elem,
 			block.NewGetElementPtr(atp, alitAddr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))),
 		)
 		initedIndices = append(initedIndices, i)
@@ -198,42 +171,27 @@ func (genCtx *GenContext) GenerateArrayLiteralValue(block *ir.Block, atp *types.
 	return alitVal, blocks, nil
 }
 
-func (genCtx *GenContext) ParseKeyedElement(block *ir.Block, parentType types.Type, ctx parser.IKeyedElementContext) (*keyedElement, []*ir.Block, error) {
+// ParseKeyedElement generates element of composite literal, which is
+// assigned to type tp.
+func (genCtx *GenContext) ParseKeyedElement(block *ir.Block, tp types.Type, ctx parser.IKeyedElementContext) (*keyedElement, []*ir.Block, error) {
 	key := ""
 	if ctx.COLON() != nil {
 		key = ctx.Key().GetText()
 	}
-	if ctx.Element().LiteralValue() != nil {
-		// if parent type is array or struct - handle element types accordingly
-		if atp, ok := parentType.(*types.ArrayType); ok {
-			etp := atp.ElemType
-			element, blocks, err := genCtx.GenerateCompositeLiteralValue(block, etp, ctx.Element().LiteralValue())
-			if err != nil {
-				return nil, nil, err
-			}
-			return &keyedElement{key: key, element: element}, blocks, nil
-		} else if stp, ok := parentType.(*typesystem.StructInfo); ok {
-			// check if key exists and references existing struct field
-			if key == "" {
-				return nil, nil, utils.MakeErrorTrace(ctx, nil, "implicit keys not supported in struct literals")
-			}
-			off, tp, err := stp.ComputeOffset(key)
-			if err != nil {
-				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to compute struct field offset")
-			}
-			_, _ = off, tp
-			return nil, nil, utils.MakeErrorTrace(ctx, nil, "nested struct literal values not supported yet")
-		} else {
-			return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid parent type")
-		}
-	} else {
-		exprs, blocks, err := genCtx.GenerateExpr(block, ctx.Element().Expression())
-		if err != nil {
-			return nil, nil, err
-		}
-		return &keyedElement{
-			key:     key,
-			element: exprs[0],
-		}, blocks, nil
+	element, blocks, err := genCtx.generateLiteralElement(block, tp, ctx.Element().Expression(), ctx.Element().LiteralValue())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &keyedElement{key: key, element: element}, blocks, nil
+}
+
+// stringGlobal returns global holding NUL-terminated string, shared by equal strings.
+func (genCtx *GenContext) stringGlobal(s string) *ir.Global {
+	glob, ok := genCtx.Consts[s]
+	if !ok {
+		val := constant.NewCharArray(append([]byte(s), 0))
+		glob = genCtx.module.NewGlobalDef(genCtx.localSymbol("str.%d", len(genCtx.Consts)), val)
+		genCtx.Consts[s] = glob
 	}
+	return glob
 }
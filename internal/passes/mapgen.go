@@ -0,0 +1,263 @@
+package passes
+
+import (
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// map key field kinds, must match MAP_KEY_* constants in runtime.h
+const (
+	mapKeyMem = iota
+	mapKeyFloat32
+	mapKeyFloat64
+	mapKeyString
+	mapKeyIface
+)
+
+// isPlainMemory reports whether values of type can be hashed and compared byte-by-byte.
+func isPlainMemory(tp types.Type) bool {
+	switch tp := typesystem.Underlying(tp).(type) {
+	case *types.IntType, *typesystem.IntType, *typesystem.UintType:
+		return true
+	case *types.PointerType, *typesystem.ChanType:
+		return true
+	case *types.ArrayType:
+		return isPlainMemory(tp.ElemType)
+	}
+	return false
+}
+
+// appendKeyLayout describes fields of key type at given offset for runtime hashing.
+func appendKeyLayout(layout []constant.Constant, tp types.Type, offset constant.Constant) ([]constant.Constant, error) {
+	field := func(kind int64, tp types.Type) []constant.Constant {
+		return append(layout,
+			constant.NewInt(types.I32, kind),
+			offset,
+			constant.NewTrunc(sizeOf(tp), types.I32),
+		)
+	}
+	if typesystem.IsStringType(tp) {
+		return field(mapKeyString, tp), nil
+	} else if isPlainMemory(tp) {
+		return field(mapKeyMem, tp), nil
+	}
+	switch tp := typesystem.Underlying(tp).(type) {
+	case *typesystem.InterfaceType:
+		return field(mapKeyIface, tp), nil
+	case *types.FloatType:
+		if tp.Kind == types.FloatKindFloat {
+			return field(mapKeyFloat32, tp), nil
+		}
+		return field(mapKeyFloat64, tp), nil
+	case *types.ArrayType:
+		var err error
+		for i := range int64(tp.Len) {
+			elemOffset := constant.NewPtrToInt(
+				constant.NewGetElementPtr(tp, constant.NewNull(types.NewPointer(tp)), constant.NewInt(types.I32, 0), constant.NewInt(types.I32, i)),
+				types.I32,
+			)
+			layout, err = appendKeyLayout(layout, tp.ElemType, constant.NewAdd(offset, elemOffset))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return layout, nil
+	case *typesystem.StructInfo:
+		var err error
+		for i, field := range tp.Fields {
+			fieldOffset := constant.NewPtrToInt(
+				constant.NewGetElementPtr(&tp.StructType, constant.NewNull(types.NewPointer(&tp.StructType)), constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i))),
+				types.I32,
+			)
+			var fieldType types.Type = field.Primitive
+			if field.IsStruct {
+				fieldType = field.Struct
+			}
+			layout, err = appendKeyLayout(layout, fieldType, constant.NewAdd(offset, fieldOffset))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return layout, nil
+	}
+	return nil, utils.MakeError("invalid map key type %s", tp)
+}
+
+// mapKeyDesc returns pointer to key descriptor used by runtime to hash and compare keys.
+func (genCtx *GenContext) mapKeyDesc(keyType types.Type) (constant.Constant, error) {
+	glob, ok := genCtx.keyDescs[keyType]
+	if !ok {
+		layout, err := appendKeyLayout(nil, keyType, constant.NewInt(types.I32, 0))
+		if err != nil {
+			return nil, err
+		}
+		layout = append([]constant.Constant{constant.NewInt(types.I32, int64(len(layout)/3))}, layout...)
+		desc := constant.NewArray(types.NewArray(uint64(len(layout)), types.I32), layout...)
+		glob = genCtx.module.NewGlobalDef(genCtx.localSymbol("mapkey.%d", len(genCtx.keyDescs)), desc)
+		glob.Immutable = true
+		genCtx.keyDescs[keyType] = glob
+	}
+	return constant.NewGetElementPtr(glob.ContentType, glob, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0)), nil
+}
+
+// GenerateMakeMap allocates empty map with space for hint elements.
+func (genCtx *GenContext) GenerateMakeMap(block *ir.Block, mtp *typesystem.MapType, hint value.Value) (value.Value, error) {
+	makemap, err := genCtx.LookupFunc("runtime_makemap")
+	if err != nil {
+		return nil, err
+	}
+	desc, err := genCtx.mapKeyDesc(mtp.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	return typesystem.NewTypedValue(
+		block.NewCall(makemap, desc, sizeOf(mtp.KeyType), sizeOf(mtp.ElemType), hint),
+		mtp,
+	), nil
+}
+
+// generateMapKey stores key in temporary memory and returns pointer to it for runtime calls.
+func (genCtx *GenContext) generateMapKey(block *ir.Block, mtp *typesystem.MapType, key value.Value) (value.Value, error) {
+	key, err := genCtx.GenerateAssignConv(block, key, mtp.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	mem := genCtx.NewTemp(mtp.KeyType)
+	block.NewStore(key, mem)
+	return block.NewBitCast(mem, types.I8Ptr), nil
+}
+
+// GenerateMapAccess generates m[key] read, returning zero value for missing keys
+// and flag whether key was present.
+func (genCtx *GenContext) GenerateMapAccess(block *ir.Block, m, key value.Value) (value.Value, value.Value, []*ir.Block, error) {
+	mtp := typesystem.Underlying(m.Type()).(*typesystem.MapType)
+	mapaccess, err := genCtx.LookupFunc("runtime_mapaccess")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	kptr, err := genCtx.generateMapKey(block, mtp, key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ptr := block.NewCall(mapaccess, m, kptr)
+	ok := block.NewICmp(enum.IPredNE, ptr, constant.NewNull(types.I8Ptr))
+
+	bfound := genCtx.NewBlock("mapaccess.found")
+	bdone := genCtx.NewBlock("mapaccess.done")
+	block.NewCondBr(ok, bfound, bdone)
+
+	found := bfound.NewLoad(mtp.ElemType, typesystem.NewTypedValue(ptr, types.NewPointer(mtp.ElemType)))
+	bfound.NewBr(bdone)
+
+	val := bdone.NewPhi(
+		ir.NewIncoming(constant.NewZeroInitializer(mtp.ElemType), block),
+		ir.NewIncoming(found, bfound),
+	)
+	return typesystem.NewTypedValue(val, mtp.ElemType),
+		typesystem.NewTypedValue(ok, typesystem.Bool),
+		[]*ir.Block{bfound, bdone}, nil
+}
+
+// GenerateMapAssignAddr returns address of value for key in map, inserting key if needed.
+func (genCtx *GenContext) GenerateMapAssignAddr(block *ir.Block, m, key value.Value) (value.Value, error) {
+	mtp := typesystem.Underlying(m.Type()).(*typesystem.MapType)
+	mapassign, err := genCtx.LookupFunc("runtime_mapassign")
+	if err != nil {
+		return nil, err
+	}
+	kptr, err := genCtx.generateMapKey(block, mtp, key)
+	if err != nil {
+		return nil, err
+	}
+	return typesystem.NewTypedValue(
+		block.NewCall(mapassign, m, kptr),
+		types.NewPointer(mtp.ElemType),
+	), nil
+}
+
+// loadMap loads map value if base is pointer to map.
+func (genCtx *GenContext) loadMap(block *ir.Block, base value.Value) (value.Value, bool) {
+	ptp, ok := base.Type().(*types.PointerType)
+	if !ok {
+		return nil, false
+	}
+	if !typesystem.IsMapType(ptp.ElemType) {
+		return nil, false
+	}
+	return typesystem.NewTypedValue(block.NewLoad(ptp.ElemType, base), ptp.ElemType), true
+}
+
+func (genCtx *GenContext) GenerateMapLiteralValue(block *ir.Block, mtp *typesystem.MapType, ctx parser.ILiteralValueContext) (value.Value, []*ir.Block, error) {
+	var kelems []parser.IKeyedElementContext
+	if ctx.ElementList() != nil {
+		kelems = ctx.ElementList().AllKeyedElement()
+	}
+	m, err := genCtx.GenerateMakeMap(block, mtp, constant.NewInt(typesystem.Int, int64(len(kelems))))
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to allocate map literal")
+	}
+	var blocks []*ir.Block
+	for _, kElemCtx := range kelems {
+		if kElemCtx.Key() == nil {
+			return nil, nil, utils.MakeErrorTrace(kElemCtx, nil, "missing key in map literal")
+		}
+		key, newBlocks, err := genCtx.generateLiteralElement(block, mtp.KeyType, kElemCtx.Key().Expression(), kElemCtx.Key().LiteralValue())
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(kElemCtx, err, "failed to parse map literal key")
+		} else if newBlocks != nil {
+			blocks = append(blocks, newBlocks...)
+			block = blocks[len(blocks)-1]
+		}
+		elem, newBlocks, err := genCtx.generateLiteralElement(block, mtp.ElemType, kElemCtx.Element().Expression(), kElemCtx.Element().LiteralValue())
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(kElemCtx, err, "failed to parse map literal element")
+		} else if newBlocks != nil {
+			blocks = append(blocks, newBlocks...)
+			block = blocks[len(blocks)-1]
+		}
+		addr, err := genCtx.GenerateMapAssignAddr(block, m, key)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(kElemCtx, err, "failed to generate map literal")
+		}
+		elem, err = genCtx.GenerateAssignConv(block, elem, mtp.ElemType)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(kElemCtx, err, "failed to parse map literal element")
+		}
+		block.NewStore(elem, addr)
+	}
+	return m, blocks, nil
+}
+
+// generateLiteralElement generates key or element of composite literal,
+// where type of nested literal values may be omitted.
+func (genCtx *GenContext) generateLiteralElement(block *ir.Block, tp types.Type, expr parser.IExpressionContext, lit parser.ILiteralValueContext) (value.Value, []*ir.Block, error) {
+	if lit == nil {
+		vals, blocks, err := genCtx.GenerateExpr(block, expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return vals[0], blocks, nil
+	}
+	ptp, ok := typesystem.Underlying(tp).(*types.PointerType)
+	if !ok {
+		return genCtx.GenerateCompositeLiteralValue(block, tp, lit)
+	}
+	// &T is elided, value is allocated by GC
+	val, blocks, err := genCtx.GenerateCompositeLiteralValue(block, ptp.ElemType, lit)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	mem := block.NewCall(genCtx.SpecialFuncs["GC_malloc"], sizeOf(ptp.ElemType))
+	ptr := typesystem.NewTypedValue(block.NewBitCast(mem, ptp), ptp)
+	block.NewStore(val, ptr)
+	return typesystem.NewTypedValue(ptr, tp), blocks, nil
+}
@@ -0,0 +1,408 @@
+package passes
+
+import (
+	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// isMethodCall checks if callee is selector, which does not refer to imported module.
+func (genCtx *GenContext) isMethodCall(ctx parser.IPrimaryExprContext) bool {
+	if ctx.MethodExpr() != nil {
+		return true
+	} else if ctx.DOT() == nil {
+		return false
+	}
+	_, isModule := genCtx.lookupModuleOperand(ctx.PrimaryExpr())
+	return !isModule
+}
+
+// GenerateMethodCall generates call x.M(args) or method expression call T.M(x, args).
+func (genCtx *GenContext) GenerateMethodCall(block *ir.Block, ctx parser.IPrimaryExprContext) ([]value.Value, []*ir.Block, error) {
+	sel := ctx.PrimaryExpr()
+	if typeName, isPtr, ok := genCtx.methodExprType(sel); ok {
+		return genCtx.generateMethodExprCall(block, ctx, typeName, isPtr)
+	}
+	methodName := sel.IDENTIFIER().GetText()
+
+	// receiver is evaluated before arguments
+	recv, blocks, err := genCtx.generateReceiver(block, sel.PrimaryExpr())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse method receiver")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	recvType := recv.Type().(*types.PointerType).ElemType
+	if typesystem.IsInterfaceType(recvType) {
+		// dynamic dispatch through itab
+		iface := typesystem.NewTypedValue(block.NewLoad(recvType, recv), recvType)
+		args, newBlocks, err := genCtx.GenerateArguments(block, ctx.Arguments())
+		if err != nil {
+			return nil, nil, err
+		} else if newBlocks != nil {
+			blocks = append(blocks, newBlocks...)
+			block = blocks[len(blocks)-1]
+		}
+		vals, err := genCtx.generateIfaceCall(block, iface, methodName, args)
+		if err != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to call method %s", methodName)
+		}
+		return vals, blocks, nil
+	}
+	if ptp, ok := recvType.(*types.PointerType); ok {
+		// method of pointed value: p.M() means (*p).M()
+		recv = block.NewLoad(ptp, recv)
+		recvType = ptp.ElemType
+	}
+	if stp, ok := recvType.(*typesystem.StructInfo); ok {
+		if path, fieldType, err := genCtx.PackageData.selectField(stp, methodName); err == nil && typesystem.IsFuncType(fieldType) {
+			// call of func value stored in struct field
+			addr := genCtx.generateFieldAddr(block, recv, stp, path)
+			fv := typesystem.NewTypedValue(block.NewLoad(fieldType, addr), fieldType)
+			args, newBlocks, err := genCtx.GenerateArguments(block, ctx.Arguments())
+			if err != nil {
+				return nil, nil, err
+			} else if newBlocks != nil {
+				blocks = append(blocks, newBlocks...)
+				block = blocks[len(blocks)-1]
+			}
+			vals, err := genCtx.GenerateFuncValueCall(block, fv, args)
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid arguments in call to %s", methodName)
+			}
+			return vals, blocks, nil
+		}
+	}
+	decl, fun, err := genCtx.LookupMethod(recvType, methodName)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to resolve method %s", methodName)
+	}
+	if !decl.PtrReceiver {
+		// value receiver gets a copy
+		recv = block.NewLoad(recvType, recv)
+	}
+
+	args, newBlocks, err := genCtx.GenerateArguments(block, ctx.Arguments())
+	if err != nil {
+		return nil, nil, err
+	} else if newBlocks != nil {
+		blocks = append(blocks, newBlocks...)
+		block = blocks[len(blocks)-1]
+	}
+	vals, err := genCtx.generateFuncCall(block, fun, decl, append([]value.Value{recv}, args...))
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid arguments in call to %s", methodName)
+	}
+	return vals, blocks, nil
+}
+
+// methodExprMethod resolves method of method expression T.M or (*T).M.
+func (genCtx *GenContext) methodExprMethod(ctx parser.IPrimaryExprContext, typeName string, isPtr bool) (*FunctionDecl, *ir.Func, error) {
+	var methodName string
+	if ctx.MethodExpr() != nil {
+		methodName = ctx.MethodExpr().IDENTIFIER().GetText()
+	} else {
+		methodName = ctx.IDENTIFIER().GetText()
+	}
+	tp, err := genCtx.PackageData.ParseTypeName(typeName)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid method expression type %s", typeName)
+	}
+	decl, fun, err := genCtx.LookupMethod(tp, methodName)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to resolve method %s", methodName)
+	} else if decl.PtrReceiver && !isPtr {
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid method expression %s.%s (needs pointer receiver (*%s).%s)", typeName, methodName, typeName, methodName)
+	}
+	return decl, fun, nil
+}
+
+// generateMethodExprCall generates call of method expression, where receiver is first argument.
+func (genCtx *GenContext) generateMethodExprCall(block *ir.Block, ctx parser.IPrimaryExprContext, typeName string, isPtr bool) ([]value.Value, []*ir.Block, error) {
+	decl, fun, err := genCtx.methodExprMethod(ctx.PrimaryExpr(), typeName, isPtr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args, blocks, err := genCtx.GenerateArguments(block, ctx.Arguments())
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	if isPtr && !decl.PtrReceiver {
+		// (*T).M takes pointer even for value receiver
+		args[0] = block.NewLoad(decl.Receiver, args[0])
+	}
+	vals, err := genCtx.generateFuncCall(block, fun, decl, args)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid arguments in call to %s.%s", typeName, methodName(decl))
+	}
+	return vals, blocks, nil
+}
+
+// GenerateMethodExprValue generates func value of method expression T.M or
+// (*T).M used other than in call. Value receiver of (*T).M is loaded by wrapper.
+func (genCtx *GenContext) GenerateMethodExprValue(ctx parser.IPrimaryExprContext, typeName string, isPtr bool) (value.Value, error) {
+	decl, fun, err := genCtx.methodExprMethod(ctx, typeName, isPtr)
+	if err != nil {
+		return nil, err
+	} else if !isPtr || decl.PtrReceiver {
+		return genCtx.GenerateFuncValue(fun, decl), nil
+	}
+	argTypes := append([]types.Type{types.NewPointer(decl.Receiver)}, decl.ArgTypes[1:]...)
+	ftp := typesystem.NewFuncType(argTypes, decl.ReturnTypes)
+	ftp.Variadic = decl.Ellipsis
+	name := decl.Name + "__ptrexpr"
+	wrapper, ok := genCtx.ifaceFuncs[name]
+	if !ok {
+		wrapper = genClosureDef(boundMethodDecl(name, argTypes, decl.ReturnTypes))
+		wrapper.Linkage = enum.LinkageLinkOnceODR
+		wrapper.Parent = genCtx.module
+		genCtx.module.Funcs = append(genCtx.module.Funcs, wrapper)
+		genCtx.ifaceFuncs[name] = wrapper
+
+		block := wrapper.NewBlock("entry")
+		recvIdx := len(typesystem.OutParams(decl.ReturnTypes)) + 1
+		var args []value.Value
+		for i, param := range wrapper.Params {
+			if i == recvIdx {
+				args = append(args, block.NewLoad(decl.Receiver, param))
+			} else if param.Name() != envParamName {
+				args = append(args, param)
+			}
+		}
+		returnCall(block, block.NewCall(fun, args...))
+	}
+	return typesystem.NewTypedValue(
+		constant.NewStruct(&ftp.StructType, constant.NewBitCast(wrapper, types.I8Ptr), constant.NewNull(types.I8Ptr)),
+		ftp,
+	), nil
+}
+
+// methodExprType checks if selector is method expression T.M or (*T).M
+// and returns name of receiver base type.
+func (genCtx *GenContext) methodExprType(ctx parser.IPrimaryExprContext) (string, bool, bool) {
+	if ctx.MethodExpr() != nil {
+		typeName, isPtr, ok := receiverTypeName(ctx.MethodExpr().Type_())
+		return typeName, isPtr, ok && genCtx.PackageData.IsUserType(typeName)
+	}
+	base := ctx.PrimaryExpr()
+	if base.Operand() == nil {
+		return "", false, false
+	}
+	isPtr := false
+	name := base.Operand().GetText()
+	if expr := base.Operand().Expression(); expr != nil && expr.GetUnary_op() != nil && expr.STAR() != nil {
+		isPtr = true
+		name = expr.Expression(0).GetText()
+	} else if base.Operand().OperandName() == nil {
+		return "", false, false
+	}
+	if _, ok := genCtx.Vars.Lookup(name); ok {
+		return "", false, false
+	}
+	return name, isPtr, genCtx.PackageData.IsUserType(name)
+}
+
+// generateReceiver generates address of method receiver. Values, which are
+// not addressable, are stored in temporary memory.
+func (genCtx *GenContext) generateReceiver(block *ir.Block, ctx parser.IPrimaryExprContext) (value.Value, []*ir.Block, error) {
+	addressable := ctx.Index() != nil || ctx.DOT() != nil
+	if op := ctx.Operand(); op != nil {
+		if op.OperandName() != nil {
+			addressable = true
+		} else if expr := op.Expression(); expr != nil {
+			addressable = expr.PrimaryExpr() != nil ||
+				expr.GetUnary_op() != nil && (expr.STAR() != nil || expr.AMPERSAND() != nil)
+		}
+	}
+	if addressable {
+		vals, blocks, err := genCtx.generateBaseLValue(block, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := vals[0].Type().(*types.PointerType); !ok {
+			return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid method receiver %s", ctx.GetText())
+		}
+		return vals[0], blocks, nil
+	}
+	vals, blocks, err := genCtx.GeneratePrimaryExpr(block, ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	if len(vals) != 1 {
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "single value expected as method receiver")
+	}
+	mem := genCtx.NewTemp(vals[0].Type())
+	block.NewStore(vals[0], mem)
+	return mem, blocks, nil
+}
+
+// GenerateMethodValue generates func value of x.M, which calls method with
+// receiver evaluated now. Pointer receiver is bound to address of x.
+func (genCtx *GenContext) GenerateMethodValue(block *ir.Block, ctx parser.IPrimaryExprContext) (value.Value, []*ir.Block, error) {
+	if ctx.IDENTIFIER() == nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "invalid method value %s", ctx.GetText())
+	}
+	methodName := ctx.IDENTIFIER().GetText()
+	recv, blocks, err := genCtx.generateReceiver(block, ctx.PrimaryExpr())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse method receiver")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	recvType := recv.Type().(*types.PointerType).ElemType
+	if itp, ok := recvType.(*typesystem.InterfaceType); ok {
+		idx, ok := itp.MethodIndex(methodName)
+		if !ok {
+			return nil, nil, utils.MakeErrorTrace(ctx, nil, "%s has no method %s", genCtx.PackageData.typeName(itp), methodName)
+		}
+		method := itp.Methods[idx]
+		iface := typesystem.NewTypedValue(block.NewLoad(itp, recv), itp)
+		ftp := typesystem.NewFuncType(method.ArgTypes, method.ReturnTypes)
+		return genCtx.bindReceiver(block, ftp, genCtx.boundIfaceMethod(itp, method), iface), blocks, nil
+	}
+	if ptp, ok := recvType.(*types.PointerType); ok {
+		recv = block.NewLoad(ptp, recv)
+		recvType = ptp.ElemType
+	}
+	if stp, ok := recvType.(*typesystem.StructInfo); ok {
+		if path, fieldType, err := genCtx.PackageData.selectField(stp, methodName); err == nil && typesystem.IsFuncType(fieldType) {
+			// func value stored in struct field
+			addr := genCtx.generateFieldAddr(block, recv, stp, path)
+			return typesystem.NewTypedValue(block.NewLoad(fieldType, addr), fieldType), blocks, nil
+		}
+	}
+	decl, fun, err := genCtx.LookupMethod(recvType, methodName)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to resolve method %s", methodName)
+	}
+	if !decl.PtrReceiver {
+		// value receiver is copied
+		recv = typesystem.NewTypedValue(block.NewLoad(recvType, recv), recvType)
+	}
+	ftp := typesystem.NewFuncType(decl.ArgTypes[1:], decl.ReturnTypes)
+	return genCtx.bindReceiver(block, ftp, genCtx.boundMethod(fun, decl), recv), blocks, nil
+}
+
+// bindReceiver builds func value of bound method, environment of which holds receiver.
+func (genCtx *GenContext) bindReceiver(block *ir.Block, ftp *typesystem.FuncType, wrapper *ir.Func, recv value.Value) value.Value {
+	env := block.NewCall(genCtx.SpecialFuncs["GC_malloc"], sizeOf(recv.Type()))
+	block.NewStore(recv, block.NewBitCast(env, types.NewPointer(recv.Type())))
+	return newFuncValue(block, ftp, constant.NewBitCast(wrapper, types.I8Ptr), env)
+}
+
+// boundMethodDecl returns declaration of wrapper called through func value of bound method.
+func boundMethodDecl(name string, argTypes, retTypes []types.Type) *FunctionDecl {
+	decl := &FunctionDecl{
+		Name:        name,
+		ReturnNames: make([]string, len(retTypes)),
+		ReturnTypes: retTypes,
+	}
+	for i, tp := range argTypes {
+		decl.ArgNames = append(decl.ArgNames, fmt.Sprintf("arg%d", i))
+		decl.ArgTypes = append(decl.ArgTypes, tp)
+	}
+	return decl
+}
+
+// boundMethod returns wrapper, which calls method with receiver loaded from environment.
+func (genCtx *GenContext) boundMethod(fun *ir.Func, decl *FunctionDecl) *ir.Func {
+	name := decl.Name + "__bound"
+	if wrapper, ok := genCtx.ifaceFuncs[name]; ok {
+		return wrapper
+	}
+	wrapper := genClosureDef(boundMethodDecl(name, decl.ArgTypes[1:], decl.ReturnTypes))
+	wrapper.Linkage = enum.LinkageLinkOnceODR
+	wrapper.Parent = genCtx.module
+	genCtx.module.Funcs = append(genCtx.module.Funcs, wrapper)
+	genCtx.ifaceFuncs[name] = wrapper
+
+	// receiver takes place of environment
+	block := wrapper.NewBlock("entry")
+	var args []value.Value
+	for _, param := range wrapper.Params {
+		if param.Name() == envParamName {
+			recv := block.NewBitCast(param, types.NewPointer(decl.Receiver))
+			args = append(args, block.NewLoad(decl.Receiver, recv))
+		} else {
+			args = append(args, param)
+		}
+	}
+	returnCall(block, block.NewCall(fun, args...))
+	return wrapper
+}
+
+// boundIfaceMethod returns wrapper, which calls method of interface value loaded from environment.
+func (genCtx *GenContext) boundIfaceMethod(itp *typesystem.InterfaceType, method typesystem.InterfaceMethod) *ir.Func {
+	key := "bound:" + genCtx.PackageData.typeName(itp) + "." + method.Name
+	if wrapper, ok := genCtx.ifaceFuncs[key]; ok {
+		return wrapper
+	}
+	name := "bound." + genCtx.PackageData.typeSymbol(itp) + "." + method.Name
+	wrapper := genClosureDef(boundMethodDecl(name, method.ArgTypes, method.ReturnTypes))
+	wrapper.Linkage = enum.LinkageLinkOnceODR
+	wrapper.Parent = genCtx.module
+	genCtx.module.Funcs = append(genCtx.module.Funcs, wrapper)
+	genCtx.ifaceFuncs[key] = wrapper
+
+	block := wrapper.NewBlock("entry")
+	outCount := len(typesystem.OutParams(method.ReturnTypes))
+	env := wrapper.Params[outCount]
+	iface := typesystem.NewTypedValue(block.NewLoad(itp, block.NewBitCast(env, types.NewPointer(itp))), itp)
+	var args []value.Value
+	for _, param := range wrapper.Params[outCount+1:] {
+		args = append(args, param)
+	}
+	// method signature matches wrapper, so arguments need no conversion
+	res, _ := genCtx.generateIfaceCall(block, iface, method.Name, args)
+	generateReturn(block, wrapper, res)
+	return wrapper
+}
+
+// generatePromotedMethod defines method promoted from embedded field, which
+// calls method of embedded value. Method is defined by each module calling
+// it, linker keeps one definition.
+func (genCtx *GenContext) generatePromotedMethod(fun *ir.Func, decl *FunctionDecl) {
+	fun.Linkage = enum.LinkageLinkOnceODR
+	block := fun.NewBlock("entry")
+	outCount := len(typesystem.OutParams(decl.ReturnTypes))
+	stp := decl.Receiver
+	var recv value.Value = fun.Params[outCount]
+	if ptp, ok := stp.(*types.PointerType); ok {
+		stp = ptp.ElemType
+	} else {
+		recv = spillValue(block, recv)
+	}
+	addr := genCtx.generateFieldAddr(block, recv, stp.(*typesystem.StructInfo), decl.Promoted.path)
+	embType := addr.Type().(*types.PointerType).ElemType
+	if ptp, ok := embType.(*types.PointerType); ok {
+		// embedded pointer
+		addr = block.NewLoad(ptp, addr)
+		embType = ptp.ElemType
+	}
+	target := decl.Promoted.method
+	recv = addr
+	if !target.PtrReceiver {
+		recv = block.NewLoad(embType, addr)
+	}
+	var args []value.Value
+	for i, param := range fun.Params {
+		if i == outCount {
+			args = append(args, recv)
+		} else {
+			args = append(args, param)
+		}
+	}
+	returnCall(block, block.NewCall(genCtx.funcRef(target), args...))
+}
@@ -1,20 +1,42 @@
 package passes
 
 import (
+	"go/token"
 	"gocomp/internal/parser"
 	"gocomp/internal/typesystem"
 	"gocomp/internal/utils"
 	"strings"
 
+	"github.com/antlr4-go/antlr/v4"
 	"github.com/llir/llvm/ir/types"
 )
 
 type PackageData struct {
 	PackageName string
-	Imports     []ImportAlias
+	Path        string        // import path, "main" for main package
+	Imports     []ImportAlias // imports of current source file
+
+	// imports of source files, import names are local to file
+	fileImports map[parser.ISourceFileContext][]ImportAlias
+
+	// packages of program compiled before this one, in initialization order
+	Packages []*PackageData
+	// package of standard library implemented by runtime
+	stdlib bool
 
 	Functions map[string]*FunctionDecl
 	Methods   map[string]map[string]*FunctionDecl // receiver type -> method name -> decl
+	Constants map[string]*typesystem.Const        // global constants
+	Globals   map[string]types.Type               // types of global variables, set by type checker
+
+	// types of expressions assigned by type checker, untyped constants
+	// and nil have type of context they are used in
+	ExprTypes map[parser.IExpressionContext]types.Type
+	// calls of variadic functions, which extra arguments are packed into
+	// slice of last parameter
+	VariadicCalls map[parser.IArgumentsContext]*typesystem.FuncType
+	// selectors x.M, which denote methods bound to receiver x
+	MethodValues map[parser.IPrimaryExprContext]bool
 
 	*typeManager
 }
@@ -32,100 +54,488 @@ func (pd *PackageData) LookupModule(module string) (*typesystem.GoModule, bool)
 	return nil, false
 }
 
+// lookupPackage finds package of program by import path.
+func (pd *PackageData) lookupPackage(path string) (*PackageData, bool) {
+	if path == pd.Path {
+		return pd, true
+	}
+	for _, pkg := range pd.Packages {
+		if pkg.Path == path {
+			return pkg, true
+		}
+	}
+	return nil, false
+}
+
+// UseFile makes imports of source file visible to lookups of qualified names.
+func (pd *PackageData) UseFile(file parser.ISourceFileContext) {
+	pd.Imports = pd.fileImports[file]
+	for name := range pd.userTypes {
+		if strings.Contains(name, ".") {
+			delete(pd.userTypes, name)
+		}
+	}
+	for name := range pd.userStructs {
+		if strings.Contains(name, ".") {
+			delete(pd.userStructs, name)
+		}
+	}
+	for _, imp := range pd.Imports {
+		if pkg, ok := pd.lookupPackage(imp.Path); ok {
+			pd.importTypes(pkg, imp.Alias)
+		}
+	}
+}
+
+// typePackage returns package, which declares named type of package pkg.
+func (pd *PackageData) typePackage(pkg string) *PackageData {
+	if owner, ok := pd.lookupPackage(pkg); ok {
+		return owner
+	}
+	return pd
+}
+
+// namedTypePkg returns import path of package declaring named type tp,
+// empty for other types.
+func namedTypePkg(tp types.Type) string {
+	switch tp := tp.(type) {
+	case *typesystem.StructInfo:
+		return tp.Pkg
+	case *typesystem.NamedType:
+		return tp.Pkg
+	case *typesystem.InterfaceType:
+		return tp.Pkg
+	}
+	return ""
+}
+
+// symbol returns linker name of package-level function or variable.
+func (pd *PackageData) symbol(name string) string {
+	return pd.Path + "__" + name
+}
+
+// NamedMethods returns all methods of named type tp, including methods
+// promoted from embedded fields of struct.
+func (pd *PackageData) NamedMethods(tp types.Type) map[string]*FunctionDecl {
+	stp, ok := tp.(*typesystem.StructInfo)
+	if !ok {
+		return pd.ownMethods(tp)
+	}
+	promoted := pd.promotedMethods(stp)
+	if len(promoted) == 0 {
+		return pd.ownMethods(tp)
+	}
+	for name, decl := range pd.ownMethods(tp) {
+		promoted[name] = decl
+	}
+	return promoted
+}
+
+// ownMethods returns methods declared for named type tp.
+func (pd *PackageData) ownMethods(tp types.Type) map[string]*FunctionDecl {
+	switch tp := tp.(type) {
+	case *typesystem.StructInfo:
+		if tp.TypeName == "" {
+			return nil
+		}
+		return pd.typePackage(tp.Pkg).Methods[tp.TypeName]
+	case *typesystem.NamedType:
+		return pd.typePackage(tp.Pkg).Methods[tp.TypeName]
+	}
+	return nil
+}
+
+// promotedMethods returns methods of types embedded in struct stp. Method is
+// promoted, if it is the only field or method of its name at the shallowest
+// depth, where the name is found.
+func (pd *PackageData) promotedMethods(stp *typesystem.StructInfo) map[string]*FunctionDecl {
+	methods := make(map[string]*FunctionDecl)
+	taken := make(map[string]bool)
+	for depth, level := range stp.EmbeddingLevels() {
+		count := make(map[string]int)
+		found := make(map[string]*FunctionDecl)
+		for _, emb := range level {
+			if emb.Struct != nil {
+				for _, field := range emb.Struct.Fields {
+					count[field.Name]++
+				}
+			}
+			for name, decl := range pd.ownMethods(emb.Type) {
+				count[name]++
+				if depth > 0 {
+					found[name] = pd.promotedMethod(stp, emb, decl)
+				}
+			}
+		}
+		for name, n := range count {
+			if decl, ok := found[name]; ok && n == 1 && !taken[name] {
+				methods[name] = decl
+			}
+			taken[name] = true
+		}
+	}
+	return methods
+}
+
+// promotedMethod returns declaration of method of struct stp, which calls
+// method decl of embedded type. Pointer receiver is needed, unless embedded
+// value is reached through embedded pointer.
+func (pd *PackageData) promotedMethod(stp *typesystem.StructInfo, emb typesystem.Embedding, decl *FunctionDecl) *FunctionDecl {
+	var recv types.Type = stp
+	ptrRecv := decl.PtrReceiver && !emb.Indirect
+	if ptrRecv {
+		recv = types.NewPointer(stp)
+	}
+	return &FunctionDecl{
+		Name:        pd.typeSymbol(stp) + "__" + methodName(decl),
+		Receiver:    recv,
+		PtrReceiver: ptrRecv,
+		ReturnNames: make([]string, len(decl.ReturnTypes)),
+		ReturnTypes: decl.ReturnTypes,
+		ArgNames:    append([]string{"recv"}, decl.ArgNames[1:]...),
+		ArgTypes:    append([]types.Type{recv}, decl.ArgTypes[1:]...),
+		Ellipsis:    decl.Ellipsis,
+		Promoted:    &promotion{path: emb.Path, method: decl},
+	}
+}
+
+// selectField finds field of struct selected by x.name, which is not hidden
+// by method at shallower depth.
+func (pd *PackageData) selectField(stp *typesystem.StructInfo, name string) ([]int, types.Type, error) {
+	path, tp, err := stp.ComputeOffset(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if decl, ok := pd.NamedMethods(stp)[name]; ok && (decl.Promoted == nil || len(decl.Promoted.path) < len(path)-1) {
+		return nil, nil, utils.MakeError("%s is method of type %s", name, pd.typeName(stp))
+	}
+	return path, tp, nil
+}
+
+// LookupMethod finds method of named type tp.
+func (pd *PackageData) LookupMethod(tp types.Type, name string) (*FunctionDecl, error) {
+	if decl, ok := pd.NamedMethods(tp)[name]; ok {
+		return decl, nil
+	}
+	return nil, utils.MakeError("type %s has no method %s", pd.typeName(tp), name)
+}
+
+// FunctionDecl describes signature of function or method.
+// Method receiver is its first argument.
 type FunctionDecl struct {
 	Name        string
-	Receiver    types.Type
+	Receiver    types.Type // nil for functions, T or *T for methods
+	PtrReceiver bool
 	ReturnNames []string
 	ReturnTypes []types.Type
 	ArgNames    []string
 	ArgTypes    []types.Type
+	Variadic    bool       // C variadic function, extra arguments are not checked
+	Ellipsis    bool       // last parameter is ...T, extra arguments are packed into slice
+	Promoted    *promotion // method promoted from embedded field
+}
+
+// promotion describes promoted method, which calls method of embedded value
+// reached from receiver through path of embedded fields.
+type promotion struct {
+	path   []int
+	method *FunctionDecl
 }
 
 type PackageListener struct {
 	parser.BaseGoParserListener
 	pdata *PackageData
 	err   error
+
+	// source file being walked
+	file parser.ISourceFileContext
+	// declarations are resolved after all source files of package are
+	// walked, so they may refer to declarations of other files
+	decls []pendingDecl
+}
+
+// pendingDecl is package-level declaration of source file.
+type pendingDecl struct {
+	file parser.ISourceFileContext
+	ctx  antlr.ParserRuleContext
 }
 
 var _ parser.GoParserListener = new(PackageListener)
 
-func NewPackageListener() *PackageListener {
+// NewPackageListener creates listener, which collects declarations of package
+// with import path. Packages it imports must be among packages compiled before.
+func NewPackageListener(path string, packages []*PackageData) *PackageListener {
+	pdata := newPackageData(path)
+	pdata.Packages = packages
+	// only global constants are known before code generation
+	pdata.evalConst = (&constEvaluator{pdata: pdata, lookup: pdata.lookupConstant}).Eval
 	return &PackageListener{
 		BaseGoParserListener: parser.BaseGoParserListener{},
-		pdata: &PackageData{
-			Functions:   make(map[string]*FunctionDecl),
-			Methods:     make(map[string]map[string]*FunctionDecl),
-			typeManager: newTypeManager(),
-		},
+		pdata:                pdata,
+	}
+}
+
+func newPackageData(path string) *PackageData {
+	return &PackageData{
+		Path:        path,
+		Functions:   make(map[string]*FunctionDecl),
+		Methods:     make(map[string]map[string]*FunctionDecl),
+		Constants:   make(map[string]*typesystem.Const),
+		Globals:     make(map[string]types.Type),
+		fileImports: make(map[parser.ISourceFileContext][]ImportAlias),
+		typeManager: newTypeManager(path),
 	}
 }
 
+func (pd *PackageData) lookupConstant(name string) (*typesystem.Const, bool) {
+	c, ok := pd.Constants[name]
+	return c, ok
+}
+
+// PackageData resolves declarations of walked source files and returns them.
 func (v *PackageListener) PackageData() (*PackageData, error) {
 	if v.err != nil {
 		return nil, v.err
 	}
+	if err := v.resolve(); err != nil {
+		return nil, err
+	}
 	return v.pdata, nil
 }
 
+// resolve declares constants and types, which may refer to each other
+// regardless of order, and then signatures of functions and methods.
+func (v *PackageListener) resolve() error {
+	var pending, funcs []pendingDecl
+	for _, decl := range v.decls {
+		switch decl.ctx.(type) {
+		case parser.IConstDeclContext, parser.ITypeSpecContext:
+			pending = append(pending, decl)
+		default:
+			funcs = append(funcs, decl)
+		}
+	}
+	for len(pending) > 0 {
+		var failed []pendingDecl
+		var firstErr error
+		for _, decl := range pending {
+			v.pdata.UseFile(decl.file)
+			var err error
+			switch ctx := decl.ctx.(type) {
+			case parser.IConstDeclContext:
+				err = v.declareConsts(ctx)
+			case parser.ITypeSpecContext:
+				err = v.pdata.ParseTypeSpec(ctx)
+			}
+			if err != nil {
+				failed = append(failed, decl)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		if len(failed) == len(pending) {
+			return firstErr
+		}
+		pending = failed
+	}
+	for _, decl := range funcs {
+		v.pdata.UseFile(decl.file)
+		var err error
+		switch ctx := decl.ctx.(type) {
+		case parser.IFunctionDeclContext:
+			err = v.declareFunc(ctx)
+		case parser.IMethodDeclContext:
+			err = v.declareMethod(ctx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *PackageListener) EnterSourceFile(ctx *parser.SourceFileContext) {
+	v.file = ctx
+	v.pdata.fileImports[ctx] = nil
+}
+
 func (v *PackageListener) EnterPackageClause(ctx *parser.PackageClauseContext) {
-	v.pdata.PackageName = ctx.GetPackageName().GetText()
+	name := ctx.GetPackageName().GetText()
+	if v.pdata.PackageName != "" && v.pdata.PackageName != name {
+		if v.err == nil {
+			v.err = utils.MakeErrorTrace(ctx, nil, "package %s; expected package %s", name, v.pdata.PackageName)
+		}
+		return
+	}
+	v.pdata.PackageName = name
 }
 
 func (v *PackageListener) EnterImportSpec(ctx *parser.ImportSpecContext) {
 	path := strings.Join(strings.Split(ctx.ImportPath().GetText(), "\""), "")
-	alias := path
+	pkg, ok := v.pdata.lookupPackage(path)
+	if !ok || pkg == v.pdata {
+		if v.err == nil {
+			v.err = utils.MakeErrorTrace(ctx, nil, "could not import %s", path)
+		}
+		return
+	}
+	alias := pkg.PackageName
 	if ctx.GetAlias() != nil {
 		alias = ctx.GetAlias().GetText()
 	}
-	v.pdata.Imports = append(v.pdata.Imports, ImportAlias{
+	v.pdata.fileImports[v.file] = append(v.pdata.fileImports[v.file], ImportAlias{
 		Path:  path,
 		Alias: alias,
 	})
 }
 
-func (v *PackageListener) EnterTypeDecl(ctx *parser.TypeDeclContext) {
-	err := v.pdata.ParseTypeDecl(ctx)
+// importTypes makes exported types of imported package available by names
+// qualified with alias.
+func (pd *PackageData) importTypes(pkg *PackageData, alias string) {
+	for name, tp := range pkg.userTypes {
+		if token.IsExported(name) && !strings.Contains(name, ".") {
+			pd.userTypes[alias+"."+name] = tp
+		}
+	}
+	for name, stp := range pkg.userStructs {
+		if token.IsExported(name) && !strings.Contains(name, ".") {
+			pd.userStructs[alias+"."+name] = stp
+		}
+	}
+}
+
+func (v *PackageListener) EnterConstDecl(ctx *parser.ConstDeclContext) {
+	// local constants are declared by code generator
+	if _, ok := ctx.GetParent().GetParent().(*parser.SourceFileContext); ok {
+		v.decls = append(v.decls, pendingDecl{file: v.file, ctx: ctx})
+	}
+}
+
+// declareConsts declares constants of declaration, none of them if some
+// constant cannot be evaluated yet.
+func (v *PackageListener) declareConsts(ctx parser.IConstDeclContext) error {
+	consts := make(map[string]*typesystem.Const)
+	lookup := func(name string) (*typesystem.Const, bool) {
+		if c, ok := consts[name]; ok {
+			return c, true
+		}
+		return v.pdata.lookupConstant(name)
+	}
+	e := &constEvaluator{pdata: v.pdata, lookup: lookup}
+	err := e.EvalConstDecl(ctx, func(name string, c *typesystem.Const) error {
+		if _, ok := lookup(name); ok {
+			return utils.MakeError("%s redeclared in this block", name)
+		}
+		consts[name] = c
+		return nil
+	})
 	if err != nil {
-		print("error is not nil!! on enter type decl")
+		return err
+	}
+	for name, c := range consts {
+		v.pdata.Constants[name] = c
+	}
+	return nil
+}
+
+func (v *PackageListener) EnterTypeDecl(ctx *parser.TypeDeclContext) {
+	for _, spec := range ctx.AllTypeSpec() {
+		v.decls = append(v.decls, pendingDecl{file: v.file, ctx: spec})
 	}
 }
 
 func (v *PackageListener) EnterFunctionDecl(ctx *parser.FunctionDeclContext) {
-	fundec, err := v.ParseSignature(ctx.Signature().(*parser.SignatureContext))
+	v.decls = append(v.decls, pendingDecl{file: v.file, ctx: ctx})
+}
+
+func (v *PackageListener) declareFunc(ctx parser.IFunctionDeclContext) error {
+	fundec, err := v.pdata.ParseSignature(ctx.Signature())
 	if err != nil {
-		v.err = err
-		return
+		return err
+	}
+	fundec.Name = v.pdata.symbol(ctx.IDENTIFIER().GetText())
+	if _, ok := v.pdata.Functions[fundec.Name]; ok {
+		return utils.MakeErrorTrace(ctx, nil, "%s redeclared in this block", ctx.IDENTIFIER().GetText())
 	}
-	fundec.Name = v.pdata.PackageName + "__" + ctx.IDENTIFIER().GetText()
 	v.pdata.Functions[fundec.Name] = fundec
+	return nil
 }
 
 func (v *PackageListener) EnterMethodDecl(ctx *parser.MethodDeclContext) {
-	var err error
-	fundec, err := v.ParseSignature(ctx.Signature())
+	v.decls = append(v.decls, pendingDecl{file: v.file, ctx: ctx})
+}
+
+func (v *PackageListener) declareMethod(ctx parser.IMethodDeclContext) error {
+	fundec, err := v.pdata.ParseSignature(ctx.Signature())
 	if err != nil {
-		v.err = err
-		return
+		return err
 	}
-	fundec.Name = ctx.IDENTIFIER().GetText()
 	// parse receiver
-	rDecl := ctx.Receiver().Parameters().ParameterDecl(0)
-	fundec.Receiver, err = v.pdata.ParseType(rDecl.Type_())
-	if err != nil {
-		v.err = err
-		return
+	params := ctx.Receiver().Parameters().AllParameterDecl()
+	if len(params) != 1 {
+		return utils.MakeErrorTrace(ctx, nil, "method has multiple receivers")
+	}
+	typeName, isPtr, ok := receiverTypeName(params[0].Type_())
+	if !ok {
+		return utils.MakeErrorTrace(ctx, nil, "invalid receiver type %s", params[0].Type_().GetText())
+	}
+	baseType, err := v.pdata.ParseTypeName(typeName)
+	if err == nil && strings.Contains(typeName, ".") {
+		return utils.MakeErrorTrace(ctx, nil, "cannot define new methods on non-local type %s", typeName)
+	} else if err != nil || !v.pdata.IsUserType(typeName) {
+		return utils.MakeErrorTrace(ctx, err, "invalid receiver type %s", typeName)
+	} else if _, ok := typesystem.Underlying(baseType).(*types.PointerType); ok || typesystem.IsInterfaceType(baseType) {
+		return utils.MakeErrorTrace(ctx, nil, "invalid receiver type %s (pointer or interface type)", typeName)
+	}
+	fundec.Receiver = baseType
+	if isPtr {
+		fundec.Receiver = types.NewPointer(baseType)
+	}
+	fundec.PtrReceiver = isPtr
+	recvName := ""
+	if params[0].IdentifierList() != nil {
+		recvName = params[0].IdentifierList().IDENTIFIER(0).GetText()
+	}
+	// receiver is passed as first argument
+	fundec.ArgNames = append([]string{recvName}, fundec.ArgNames...)
+	fundec.ArgTypes = append([]types.Type{fundec.Receiver}, fundec.ArgTypes...)
+
+	methodName := ctx.IDENTIFIER().GetText()
+	fundec.Name = MethodSymbol(v.pdata.Path, typeName, methodName)
+	if _, ok := v.pdata.Methods[typeName]; !ok {
+		v.pdata.Methods[typeName] = make(map[string]*FunctionDecl)
 	}
-	// else if rDecl.IdentifierList() != nil {
-	// fundec.Receiver.Name = rDecl.IdentifierList().IDENTIFIER(0).GetText()
-	// }
-	// if _, ok := v.pdata.Methods[fundec.Receiver.Type]; !ok {
-	// 	v.pdata.Methods[fundec.Receiver.Type] = make(map[string]FunctionDecl)
-	// }
-	// v.pdata.Methods[fundec.Receiver.Type][fundec.Name] = fundec
+	if _, ok := v.pdata.Methods[typeName][methodName]; ok {
+		return utils.MakeErrorTrace(ctx, nil, "method %s.%s already declared", typeName, methodName)
+	}
+	v.pdata.Methods[typeName][methodName] = fundec
+	return nil
+}
+
+// receiverTypeName extracts name of base type from receiver T or *T.
+func receiverTypeName(ctx parser.IType_Context) (string, bool, bool) {
+	for ctx.L_PAREN() != nil {
+		ctx = ctx.Type_()
+	}
+	if ctx.TypeName() != nil {
+		return ctx.TypeName().GetText(), false, true
+	}
+	if ctx.TypeLit() != nil && ctx.TypeLit().PointerType() != nil {
+		name, isPtr, ok := receiverTypeName(ctx.TypeLit().PointerType().Type_())
+		return name, true, ok && !isPtr
+	}
+	return "", false, false
+}
+
+// MethodSymbol returns mangled name of method of named type.
+func MethodSymbol(pkgPath, typeName, methodName string) string {
+	return pkgPath + "__" + typeName + "__" + methodName
 }
 
-func (v *PackageListener) ParseSignature(ctx parser.ISignatureContext) (*FunctionDecl, error) {
-	names, types, err := v.ParseParameters(ctx.Parameters())
+// ParseSignature parses types and names of parameters and results of function.
+func (m *typeManager) ParseSignature(ctx parser.ISignatureContext) (*FunctionDecl, error) {
+	names, types, err := m.ParseParameters(ctx.Parameters())
 	if err != nil {
 		return nil, utils.MakeErrorTrace(ctx, err, "failed to parse signature")
 	}
@@ -136,7 +546,7 @@ func (v *PackageListener) ParseSignature(ctx parser.ISignatureContext) (*Functio
 	if ctx.Result() != nil {
 		// single return value
 		if ctx.Result().Type_() != nil {
-			tp, err := v.pdata.ParseType(ctx.Result().Type_())
+			tp, err := m.ParseType(ctx.Result().Type_())
 			if err != nil {
 				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse signature")
 			}
@@ -144,7 +554,7 @@ func (v *PackageListener) ParseSignature(ctx parser.ISignatureContext) (*Functio
 			fundec.ReturnTypes = append(fundec.ReturnTypes, tp)
 		} else {
 			// multiple return values
-			names, types, err := v.ParseParameters(ctx.Result().Parameters())
+			names, types, err := m.ParseParameters(ctx.Result().Parameters())
 			if err != nil {
 				return nil, utils.MakeErrorTrace(ctx, err, "failed to parse signature")
 			}
@@ -155,11 +565,11 @@ func (v *PackageListener) ParseSignature(ctx parser.ISignatureContext) (*Functio
 	return &fundec, nil
 }
 
-func (v *PackageListener) ParseParameters(ctx parser.IParametersContext) ([]string, []types.Type, error) {
+func (m *typeManager) ParseParameters(ctx parser.IParametersContext) ([]string, []types.Type, error) {
 	var names []string
 	var types []types.Type
 	for _, child := range ctx.AllParameterDecl() {
-		newNames, newTypes, err := v.ParseParameterDecl(child)
+		newNames, newTypes, err := m.ParseParameterDecl(child)
 		if err != nil {
 			return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse parameters")
 		}
@@ -169,8 +579,8 @@ func (v *PackageListener) ParseParameters(ctx parser.IParametersContext) ([]stri
 	return names, types, nil
 }
 
-func (v *PackageListener) ParseParameterDecl(ctx parser.IParameterDeclContext) ([]string, []types.Type, error) {
-	type_, err := v.pdata.ParseType(ctx.Type_())
+func (m *typeManager) ParseParameterDecl(ctx parser.IParameterDeclContext) ([]string, []types.Type, error) {
+	type_, err := m.ParseType(ctx.Type_())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,52 @@
+package passes
+
+import (
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// GeneratePanic generates panic(v), which converts v to empty interface and
+// unwinds stack of goroutine running deferred calls.
+func (genCtx *GenContext) GeneratePanic(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	args, blocks, err := genCtx.builtinArgs(block, "panic", ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	arg, err := genCtx.GenerateAssignConv(block, args[0], typesystem.Any)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid argument for panic")
+	}
+	gopanic, err := genCtx.LookupFunc("runtime_gopanic")
+	if err != nil {
+		return nil, nil, err
+	}
+	tab, data := genCtx.GenerateIfaceParts(block, arg)
+	block.NewCall(gopanic, tab, data)
+	return nil, blocks, nil
+}
+
+// GenerateRecover generates recover(), which stops panicking and returns
+// panic value, or nil if goroutine is not panicking.
+func (genCtx *GenContext) GenerateRecover(block *ir.Block, ctx parser.IArgumentsContext) ([]value.Value, []*ir.Block, error) {
+	_, blocks, err := genCtx.builtinArgs(block, "recover", ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	recover, err := genCtx.LookupFunc("runtime_recover")
+	if err != nil {
+		return nil, nil, err
+	}
+	mem := genCtx.NewTemp(&typesystem.Any.StructType)
+	block.NewCall(recover, mem)
+	return []value.Value{
+		typesystem.NewTypedValue(block.NewLoad(&typesystem.Any.StructType, mem), typesystem.Any),
+	}, blocks, nil
+}
@@ -0,0 +1,53 @@
+package passes
+
+import (
+	"gocomp/internal/typesystem"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// generatePrintFunc defines printing function of package fmt, which passes
+// its operands to runtime. Function is defined by each module calling it,
+// linker keeps one definition.
+func (genCtx *GenContext) generatePrintFunc(fun *ir.Func, decl *FunctionDecl, def printFunc) {
+	fun.Linkage = enum.LinkageLinkOnceODR
+	block := fun.NewBlock("entry")
+	// skip out parameters of (n int, err error)
+	params := fun.Params[len(typesystem.OutParams(decl.ReturnTypes)):]
+	var file value.Value
+	if def.output == printToFile {
+		file = block.NewBitCast(params[0], types.I8Ptr)
+		params = params[1:]
+	}
+	var format, formatLen value.Value = constant.NewNull(types.I8Ptr), constant.NewInt(typesystem.Int, 0)
+	if def.mode == fmtPrintf {
+		format, formatLen = genCtx.GenerateStringParts(block, typesystem.NewTypedValue(params[0], typesystem.String))
+		params = params[1:]
+	}
+	ptr, n, _ := genCtx.GenerateSliceParts(block, typesystem.NewTypedValue(params[0], typesystem.NewSliceType(typesystem.Any)))
+	args := block.NewBitCast(ptr, types.I8Ptr)
+	mode := constant.NewInt(types.I32, def.mode)
+
+	switch def.output {
+	case printToStdout, printToFile:
+		var written value.Value
+		if def.output == printToStdout {
+			written = block.NewCall(genCtx.SpecialFuncs["runtime_print"], mode, format, formatLen, args, n)
+		} else {
+			written = block.NewCall(genCtx.SpecialFuncs["runtime_fprint"], file, mode, format, formatLen, args, n)
+		}
+		generateReturn(block, fun, []value.Value{written, constant.NewZeroInitializer(typesystem.Error)})
+	case printToString:
+		res := block.NewAlloca(&typesystem.String.StructType)
+		block.NewCall(genCtx.SpecialFuncs["runtime_sprint"], res, mode, format, formatLen, args, n)
+		block.NewRet(block.NewLoad(&typesystem.String.StructType, res))
+	case printToError:
+		res := block.NewAlloca(&typesystem.Error.StructType)
+		block.NewCall(genCtx.SpecialFuncs["runtime_errorf"], res, format, formatLen, args, n)
+		block.NewRet(block.NewLoad(&typesystem.Error.StructType, res))
+	}
+}
@@ -0,0 +1,356 @@
+package passes
+
+import (
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// sizeOf computes size of given type in bytes as constant expression
+// (offset of second element in array of such types).
+func sizeOf(tp types.Type) constant.Constant {
+	return constant.NewPtrToInt(
+		constant.NewGetElementPtr(tp, constant.NewNull(types.NewPointer(tp)), constant.NewInt(types.I32, 1)),
+		types.I64,
+	)
+}
+
+// GenerateSliceParts extracts pointer, length and capacity from slice header.
+func (genCtx *GenContext) GenerateSliceParts(block *ir.Block, val value.Value) (value.Value, value.Value, value.Value) {
+	hdr := typesystem.Raw(val)
+	return block.NewExtractValue(hdr, 0), block.NewExtractValue(hdr, 1), block.NewExtractValue(hdr, 2)
+}
+
+// GenerateSliceValue builds slice header from its parts.
+func (genCtx *GenContext) GenerateSliceValue(block *ir.Block, stp *typesystem.SliceType, ptr, len, cap value.Value) value.Value {
+	if !ptr.Type().Equal(stp.Fields[0]) {
+		ptr = block.NewBitCast(ptr, stp.Fields[0])
+	}
+	var hdr value.Value = constant.NewUndef(&stp.StructType)
+	hdr = block.NewInsertValue(hdr, ptr, 0)
+	hdr = block.NewInsertValue(hdr, len, 1)
+	hdr = block.NewInsertValue(hdr, cap, 2)
+	return typesystem.NewTypedValue(hdr, stp)
+}
+
+// GenerateMakeSlice allocates zeroed backing array with given capacity.
+func (genCtx *GenContext) GenerateMakeSlice(block *ir.Block, stp *typesystem.SliceType, len, cap value.Value) (value.Value, error) {
+	makeslice, err := genCtx.LookupFunc("runtime_makeslice")
+	if err != nil {
+		return nil, err
+	}
+	ptr := block.NewCall(makeslice, sizeOf(stp.ElemType), cap)
+	return genCtx.GenerateSliceValue(block, stp, ptr, len, cap), nil
+}
+
+// GenerateIntCast converts integer value to Go 'int' type, used for lengths and indices.
+func (genCtx *GenContext) GenerateIntCast(block *ir.Block, val value.Value) (value.Value, error) {
+	if c, ok := val.(*typesystem.Const); ok {
+		if !c.IsUntyped() && !isIntegerConst(c) {
+			return nil, utils.MakeError("integer value expected, got %s", c.Type())
+		}
+		conv, err := convertConst(typesystem.NewConst(c.Val, nil), typesystem.Int)
+		if err != nil {
+			return nil, err
+		}
+		// indices of LLVM instructions must be plain constants
+		return conv.IR(), nil
+	}
+	if !typesystem.IsIntType(val.Type()) && !typesystem.IsUintType(val.Type()) {
+		return nil, utils.MakeError("integer value expected, got %s", val.Type())
+	}
+	vals, _, err := genCtx.GenerateTypeCast(block, typesystem.Int, val)
+	if err != nil {
+		return nil, err
+	}
+	return vals[0], nil
+}
+
+// Kinds of failed bounds checks reported by runtime_panicbounds,
+// must match BOUNDS_* constants of runtime.
+const (
+	boundsIndex = iota
+	boundsSliceAlen
+	boundsSliceAcap
+	boundsSliceB
+	boundsSlice3Alen
+	boundsSlice3Acap
+	boundsSlice3B
+	boundsSlice3C
+)
+
+// boundsCheck is check of index or slice bound x against y.
+type boundsCheck struct {
+	kind int
+	x, y value.Value
+}
+
+// generateBoundsCheck panics with runtime error of kind of check unless
+// 0 <= x < y, or 0 <= x <= y for slice bounds. Values are compared as
+// unsigned, so negative x is out of range too. Checks of constants, which
+// hold, are omitted.
+func (genCtx *GenContext) generateBoundsCheck(block *ir.Block, check boundsCheck) ([]*ir.Block, error) {
+	kind, x, y := check.kind, check.x, check.y
+	cx, xok := x.(*constant.Int)
+	cy, yok := y.(*constant.Int)
+	if xok && yok && cx.X.Sign() >= 0 && (cx.X.Cmp(cy.X) < 0 || kind != boundsIndex && cx.X.Cmp(cy.X) == 0) {
+		return nil, nil
+	}
+	panicbounds, err := genCtx.LookupFunc("runtime_panicbounds")
+	if err != nil {
+		return nil, err
+	}
+	pred := enum.IPredUGT
+	if kind == boundsIndex {
+		pred = enum.IPredUGE
+	}
+	bpanic := genCtx.NewBlock("bounds.panic")
+	bok := genCtx.NewBlock("bounds.ok")
+	block.NewCondBr(block.NewICmp(pred, typesystem.Raw(x), typesystem.Raw(y)), bpanic, bok)
+	bpanic.NewCall(panicbounds, constant.NewInt(types.I32, int64(kind)), x, y)
+	bpanic.NewUnreachable()
+	return []*ir.Block{bpanic, bok}, nil
+}
+
+// GenerateIndexAddr computes address of indexed element, where base is a pointer
+// to array, pointer to pointer to array, pointer to slice or string header.
+// Index out of range panics.
+func (genCtx *GenContext) GenerateIndexAddr(block *ir.Block, base, idx value.Value) (value.Value, []*ir.Block, error) {
+	ptp, ok := base.Type().(*types.PointerType)
+	if !ok {
+		return nil, nil, utils.MakeError("must be pointer type")
+	}
+	idx, err := genCtx.GenerateIntCast(block, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	elemTp := typesystem.Underlying(ptp.ElemType)
+	if tp, ok := elemTp.(*types.PointerType); ok {
+		// pointer to array allows indexing without explicit dereference
+		atp, ok := typesystem.Underlying(tp.ElemType).(*types.ArrayType)
+		if !ok {
+			return nil, nil, utils.MakeError("must be pointer to array type")
+		}
+		base = block.NewLoad(tp, base)
+		elemTp = atp
+	}
+	var ptr, length value.Value
+	var elemType types.Type
+	switch tp := elemTp.(type) {
+	case *types.ArrayType:
+		ptr = block.NewGetElementPtr(tp, base, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+		length = constant.NewInt(typesystem.Int, int64(tp.Len))
+		elemType = tp.ElemType
+	case *typesystem.SliceType:
+		ptr, length, _ = genCtx.GenerateSliceParts(block, block.NewLoad(tp, base))
+		elemType = tp.ElemType
+	case *typesystem.StringType:
+		ptr, length = genCtx.GenerateStringParts(block, block.NewLoad(tp, base))
+		elemType = typesystem.Byte
+	default:
+		return nil, nil, utils.MakeError("invalid type for indexing: %s", ptp.ElemType)
+	}
+	blocks, err := genCtx.generateBoundsCheck(block, boundsCheck{boundsIndex, idx, length})
+	if err != nil {
+		return nil, nil, err
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	return typesystem.NewTypedValue(
+		block.NewGetElementPtr(elemType, ptr, idx),
+		types.NewPointer(elemType),
+	), blocks, nil
+}
+
+// GenerateSliceExpr generates a[lo:hi] and a[lo:hi:max] expressions
+// for arrays, pointers to arrays, slices and strings.
+func (genCtx *GenContext) GenerateSliceExpr(block *ir.Block, ctx parser.IPrimaryExprContext) ([]value.Value, []*ir.Block, error) {
+	bases, blocks, err := genCtx.generateBaseLValue(block, ctx.PrimaryExpr())
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse slice expression")
+	} else if blocks != nil {
+		block = blocks[len(blocks)-1]
+	}
+	// collect low, high and max indices separated by colons
+	var bounds [3]value.Value
+	pos := 0
+	for _, child := range ctx.Slice_().GetChildren() {
+		switch c := child.(type) {
+		case antlr.TerminalNode:
+			if c.GetSymbol().GetTokenType() == parser.GoParserCOLON {
+				pos++
+			}
+		case parser.IExpressionContext:
+			vals, newBlocks, err := genCtx.GenerateExpr(block, c)
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to parse slice index")
+			} else if newBlocks != nil {
+				blocks = append(blocks, newBlocks...)
+				block = blocks[len(blocks)-1]
+			}
+			bounds[pos], err = genCtx.GenerateIntCast(block, vals[0])
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(c, err, "invalid slice index")
+			}
+		}
+	}
+	// get pointer to first element, length and capacity of sliced object
+	var elemType types.Type
+	var ptr, length, capacity value.Value
+	base := bases[0]
+	ptp, ok := base.Type().(*types.PointerType)
+	if !ok {
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "cannot slice %s", base.Type())
+	}
+	if tp, ok := typesystem.Underlying(ptp.ElemType).(*types.PointerType); ok {
+		if _, ok := typesystem.Underlying(tp.ElemType).(*types.ArrayType); ok {
+			base = block.NewLoad(ptp.ElemType, base)
+			ptp = tp
+		}
+	}
+	switch tp := typesystem.Underlying(ptp.ElemType).(type) {
+	case *types.ArrayType:
+		elemType = tp.ElemType
+		ptr = block.NewGetElementPtr(tp, base, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+		length = constant.NewInt(typesystem.Int, int64(tp.Len))
+		capacity = length
+	case *typesystem.SliceType:
+		elemType = tp.ElemType
+		ptr, length, capacity = genCtx.GenerateSliceParts(block, block.NewLoad(tp, base))
+	case *typesystem.StringType:
+		if bounds[2] != nil {
+			return nil, nil, utils.MakeErrorTrace(ctx, nil, "3-index slice of string")
+		}
+		ptr, length = genCtx.GenerateStringParts(block, block.NewLoad(tp, base))
+	default:
+		return nil, nil, utils.MakeErrorTrace(ctx, nil, "cannot slice %s", ptp.ElemType)
+	}
+	lo, hi, max := bounds[0], bounds[1], bounds[2]
+	// bounds are checked from right to left like in Go, capacity of
+	// strings and arrays is their length
+	if capacity == nil {
+		capacity = length
+	}
+	isSlice := typesystem.IsSliceType(ptp.ElemType)
+	var checks []boundsCheck
+	if max != nil {
+		kind := boundsSlice3Alen
+		if isSlice {
+			kind = boundsSlice3Acap
+		}
+		checks = append(checks, boundsCheck{kind, max, capacity}, boundsCheck{boundsSlice3B, hi, max})
+	} else if hi != nil {
+		kind := boundsSliceAlen
+		if isSlice {
+			kind = boundsSliceAcap
+		}
+		checks = append(checks, boundsCheck{kind, hi, capacity})
+	}
+	if lo == nil {
+		lo = constant.NewInt(typesystem.Int, 0)
+	}
+	if hi == nil {
+		hi = length
+	}
+	if max != nil {
+		checks = append(checks, boundsCheck{boundsSlice3C, lo, hi})
+	} else {
+		checks = append(checks, boundsCheck{boundsSliceB, lo, hi})
+	}
+	for _, check := range checks {
+		newBlocks, err := genCtx.generateBoundsCheck(block, check)
+		if err != nil {
+			return nil, nil, err
+		} else if newBlocks != nil {
+			blocks = append(blocks, newBlocks...)
+			block = blocks[len(blocks)-1]
+		}
+	}
+	if typesystem.IsStringType(ptp.ElemType) {
+		return []value.Value{typesystem.NewTypedValue(
+			genCtx.GenerateStringValue(block, block.NewGetElementPtr(types.I8, ptr, lo), block.NewSub(hi, lo)),
+			ptp.ElemType,
+		)}, blocks, nil
+	}
+	if max == nil {
+		max = capacity
+	}
+	// slice of slice keeps its type, slice of array is unnamed
+	var resType types.Type = typesystem.NewSliceType(elemType)
+	if typesystem.IsSliceType(ptp.ElemType) {
+		resType = ptp.ElemType
+	}
+	return []value.Value{typesystem.NewTypedValue(
+		genCtx.GenerateSliceValue(block, typesystem.NewSliceType(elemType),
+			block.NewGetElementPtr(elemType, ptr, lo),
+			block.NewSub(hi, lo),
+			block.NewSub(max, lo),
+		),
+		resType,
+	)}, blocks, nil
+}
+
+func (genCtx *GenContext) GenerateSliceLiteralValue(block *ir.Block, stp *typesystem.SliceType, ctx parser.ILiteralValueContext) (value.Value, []*ir.Block, error) {
+	var blocks []*ir.Block
+	var elements []value.Value
+	var indices []int
+	i, length := 0, 0
+	if ctx.ElementList() != nil {
+		for _, kElemCtx := range ctx.ElementList().AllKeyedElement() {
+			kelem, newBlocks, err := genCtx.ParseKeyedElement(block, stp.ElemType, kElemCtx)
+			if err != nil {
+				return nil, nil, err
+			} else if newBlocks != nil {
+				blocks = append(blocks, newBlocks...)
+				block = blocks[len(blocks)-1]
+			}
+			if kelem.key != "" {
+				ki, ok := genCtx.PackageData.literalIndex(kElemCtx.Key())
+				if !ok {
+					return nil, nil, utils.MakeErrorTrace(ctx, nil, "index %s must be integer constant", kelem.key)
+				}
+				i = int(ki)
+			}
+			if i < 0 {
+				return nil, nil, utils.MakeErrorTrace(ctx, nil, "negative index in slice literal")
+			}
+			// check for duplicate slice indices
+			for _, ind := range indices {
+				if ind == i {
+					return nil, nil, utils.MakeErrorTrace(ctx, nil, "duplicate index in slice literal")
+				}
+			}
+			elem, err := genCtx.GenerateAssignConv(block, kelem.element, stp.ElemType)
+			if err != nil {
+				return nil, nil, utils.MakeErrorTrace(ctx, err, "invalid slice literal element")
+			}
+			elements = append(elements, elem)
+			indices = append(indices, i)
+			i++
+			if i > length {
+				length = i
+			}
+		}
+	}
+	// allocate backing array and fill it
+	llen := constant.NewInt(typesystem.Int, int64(length))
+	slice, err := genCtx.GenerateMakeSlice(block, stp, llen, llen)
+	if err != nil {
+		return nil, nil, utils.MakeErrorTrace(ctx, err, "failed to allocate slice literal")
+	}
+	ptr, _, _ := genCtx.GenerateSliceParts(block, slice)
+	for j, elem := range elements {
+		block.NewStore(
+			elem,
+			block.NewGetElementPtr(stp.ElemType, ptr, constant.NewInt(typesystem.Int, int64(indices[j]))),
+		)
+	}
+	return slice, blocks, nil
+}
@@ -0,0 +1,204 @@
+package passes
+
+import (
+	"gocomp/internal/typesystem"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// stdlibFunc describes function of standard library package, which is
+// implemented by C library function cname.
+type stdlibFunc struct {
+	cname    string
+	argTypes []types.Type
+	retType  types.Type
+	variadic bool
+}
+
+// stdlibFuncs lists functions of standard library packages by package name.
+var stdlibFuncs = map[string]map[string]stdlibFunc{
+	"fmt": {
+		"Scanf": {cname: "scanf", argTypes: []types.Type{typesystem.String}, retType: types.I32, variadic: true},
+	},
+}
+
+// print modes, must match FMT_* constants in runtime.h
+const (
+	fmtPrint = iota
+	fmtPrintln
+	fmtPrintf
+)
+
+// destinations of formatted output
+const (
+	printToStdout = iota
+	printToFile
+	printToString
+	printToError
+)
+
+// printFunc describes printing function of package fmt. Operands are passed
+// as slice of empty interface values, which are formatted by runtime.
+type printFunc struct {
+	mode   int64
+	output int
+}
+
+// printFuncs lists printing functions of package fmt by name.
+var printFuncs = map[string]printFunc{
+	"Print":    {mode: fmtPrint, output: printToStdout},
+	"Println":  {mode: fmtPrintln, output: printToStdout},
+	"Printf":   {mode: fmtPrintf, output: printToStdout},
+	"Fprint":   {mode: fmtPrint, output: printToFile},
+	"Fprintln": {mode: fmtPrintln, output: printToFile},
+	"Fprintf":  {mode: fmtPrintf, output: printToFile},
+	"Sprint":   {mode: fmtPrint, output: printToString},
+	"Sprintln": {mode: fmtPrintln, output: printToString},
+	"Sprintf":  {mode: fmtPrintf, output: printToString},
+	"Errorf":   {mode: fmtPrintf, output: printToError},
+}
+
+// printFuncDecl returns declaration of printing function of package fmt.
+func printFuncDecl(name string, def printFunc) *FunctionDecl {
+	decl := &FunctionDecl{Name: "fmt__" + name, Ellipsis: true}
+	if def.output == printToFile {
+		decl.ArgNames = append(decl.ArgNames, "w")
+		decl.ArgTypes = append(decl.ArgTypes, types.NewPointer(stdlibStruct("os", "File")))
+	}
+	if def.mode == fmtPrintf {
+		decl.ArgNames = append(decl.ArgNames, "format")
+		decl.ArgTypes = append(decl.ArgTypes, typesystem.String)
+	}
+	decl.ArgNames = append(decl.ArgNames, "a")
+	decl.ArgTypes = append(decl.ArgTypes, typesystem.NewSliceType(typesystem.Any))
+	switch def.output {
+	case printToString:
+		decl.ReturnTypes = []types.Type{typesystem.String}
+	case printToError:
+		decl.ReturnTypes = []types.Type{typesystem.Error}
+	default:
+		decl.ReturnTypes = []types.Type{typesystem.Int, typesystem.Error}
+	}
+	decl.ReturnNames = make([]string, len(decl.ReturnTypes))
+	return decl
+}
+
+// stdlibMethod describes method of standard library type implemented in runtime.
+type stdlibMethod struct {
+	name     string
+	argTypes []types.Type
+}
+
+// stdlibTypes lists struct types of standard library packages, which are
+// implemented in runtime. Layout of types must match runtime.h.
+var stdlibTypes = map[string]map[string]struct {
+	fields  []typesystem.StructFieldInfo
+	methods []stdlibMethod
+}{
+	"os": {
+		"File": {
+			fields: []typesystem.StructFieldInfo{
+				{Name: "fd", Offset: 0, Primitive: typesystem.Int},
+			},
+		},
+	},
+	"sync": {
+		// count or locked flag followed by queue of waiting goroutines
+		"WaitGroup": {
+			fields: []typesystem.StructFieldInfo{
+				{Name: "count", Offset: 0, Primitive: types.I32},
+				{Name: "first", Offset: 1, Primitive: types.I8Ptr},
+				{Name: "last", Offset: 2, Primitive: types.I8Ptr},
+			},
+			methods: []stdlibMethod{
+				{name: "Add", argTypes: []types.Type{typesystem.Int}},
+				{name: "Done"},
+				{name: "Wait"},
+			},
+		},
+		"Mutex": {
+			fields: []typesystem.StructFieldInfo{
+				{Name: "locked", Offset: 0, Primitive: types.I32},
+				{Name: "first", Offset: 1, Primitive: types.I8Ptr},
+				{Name: "last", Offset: 2, Primitive: types.I8Ptr},
+			},
+			methods: []stdlibMethod{
+				{name: "Lock"},
+				{name: "Unlock"},
+			},
+		},
+	},
+}
+
+// stdlibGlobals lists global variables of standard library packages, which
+// are defined in runtime, by names of struct types they point to.
+var stdlibGlobals = map[string]map[string]string{
+	"os": {
+		"Stdin":  "File",
+		"Stdout": "File",
+		"Stderr": "File",
+	},
+}
+
+// stdlibStructs holds struct types of standard library by qualified name.
+// Types are created once, so that they are identical in all packages.
+var stdlibStructs = make(map[string]*typesystem.StructInfo)
+
+// stdlibStruct returns struct type of standard library package.
+func stdlibStruct(path, typeName string) *typesystem.StructInfo {
+	name := path + "." + typeName
+	stp, ok := stdlibStructs[name]
+	if !ok {
+		stp = typesystem.NewStructInfo(typeName, stdlibTypes[path][typeName].fields)
+		stp.Pkg = path
+		stp.StructType.SetName(name)
+		stdlibStructs[name] = stp
+	}
+	return stp
+}
+
+// IsStdlibPackage reports whether path is import path of supported
+// standard library package.
+func IsStdlibPackage(path string) bool {
+	_, hasFuncs := stdlibFuncs[path]
+	_, hasTypes := stdlibTypes[path]
+	return hasFuncs || hasTypes
+}
+
+// NewStdlibPackage declares types of standard library package along with
+// their methods. Methods have pointer receivers and are implemented in runtime.
+// Printing functions of package fmt are defined by code generator, other
+// functions of package are declared by it.
+func NewStdlibPackage(path string) *PackageData {
+	pd := newPackageData(path)
+	pd.PackageName = path
+	pd.stdlib = true
+	for typeName, def := range stdlibTypes[path] {
+		stp := stdlibStruct(path, typeName)
+		pd.userStructs[typeName] = stp
+		pd.Methods[typeName] = make(map[string]*FunctionDecl)
+		for _, method := range def.methods {
+			argNames := []string{"recv"}
+			for range method.argTypes {
+				argNames = append(argNames, "")
+			}
+			pd.Methods[typeName][method.name] = &FunctionDecl{
+				Name:        MethodSymbol(path, typeName, method.name),
+				Receiver:    types.NewPointer(stp),
+				PtrReceiver: true,
+				ArgNames:    argNames,
+				ArgTypes:    append([]types.Type{types.NewPointer(stp)}, method.argTypes...),
+			}
+		}
+	}
+	for name, typeName := range stdlibGlobals[path] {
+		pd.Globals[name] = types.NewPointer(stdlibStruct(path, typeName))
+	}
+	if path == "fmt" {
+		for name, def := range printFuncs {
+			decl := printFuncDecl(name, def)
+			pd.Functions[decl.Name] = decl
+		}
+	}
+	return pd
+}
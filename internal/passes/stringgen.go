@@ -0,0 +1,173 @@
+package passes
+
+import (
+	goconstant "go/constant"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// isByteType checks if tp is 8-bit integer type, which is element type of strings.
+func isByteType(tp types.Type) bool {
+	itp, ok := typesystem.UnderlyingIntType(tp)
+	return ok && itp.BitSize == 8
+}
+
+// GenerateStringConst returns string header of global NUL-terminated string.
+func (genCtx *GenContext) GenerateStringConst(s string) value.Value {
+	return typesystem.NewTypedValue(
+		constant.NewStruct(&typesystem.String.StructType,
+			genCtx.stringConst(s),
+			constant.NewInt(typesystem.Int, int64(len(s))),
+		),
+		typesystem.String,
+	)
+}
+
+// GenerateStringParts extracts pointer and length from string header.
+func (genCtx *GenContext) GenerateStringParts(block *ir.Block, val value.Value) (value.Value, value.Value) {
+	hdr := typesystem.Raw(val)
+	return block.NewExtractValue(hdr, 0), block.NewExtractValue(hdr, 1)
+}
+
+// GenerateStringValue builds string header from its parts.
+func (genCtx *GenContext) GenerateStringValue(block *ir.Block, ptr, len value.Value) value.Value {
+	var hdr value.Value = constant.NewUndef(&typesystem.String.StructType)
+	hdr = block.NewInsertValue(hdr, ptr, 0)
+	hdr = block.NewInsertValue(hdr, len, 1)
+	return typesystem.NewTypedValue(hdr, typesystem.String)
+}
+
+// generateRuntimeString calls runtime function, which stores resulting
+// string header in memory passed as first argument.
+func (genCtx *GenContext) generateRuntimeString(block *ir.Block, name string, args ...value.Value) (value.Value, error) {
+	fun, err := genCtx.LookupFunc(name)
+	if err != nil {
+		return nil, err
+	}
+	mem := genCtx.NewTemp(&typesystem.String.StructType)
+	block.NewCall(fun, append([]value.Value{mem}, args...)...)
+	return typesystem.NewTypedValue(block.NewLoad(&typesystem.String.StructType, mem), typesystem.String), nil
+}
+
+// GenerateCString converts string to NUL-terminated one, as expected by C library.
+func (genCtx *GenContext) GenerateCString(block *ir.Block, val value.Value) (value.Value, error) {
+	cstring, err := genCtx.LookupFunc("runtime_cstring")
+	if err != nil {
+		return nil, err
+	}
+	ptr, length := genCtx.GenerateStringParts(block, val)
+	return block.NewCall(cstring, ptr, length), nil
+}
+
+// GenerateStringConcat generates s + t.
+func (genCtx *GenContext) GenerateStringConcat(block *ir.Block, left, right value.Value) (value.Value, error) {
+	ptr1, len1 := genCtx.GenerateStringParts(block, left)
+	ptr2, len2 := genCtx.GenerateStringParts(block, right)
+	return genCtx.generateRuntimeString(block, "runtime_concatstrings", ptr1, len1, ptr2, len2)
+}
+
+// GenerateStringCompare compares strings lexically by bytes.
+func (genCtx *GenContext) GenerateStringCompare(block *ir.Block, op int, left, right value.Value) (value.Value, error) {
+	var pred enum.IPred
+	switch op {
+	case parser.GoParserEQUALS:
+		pred = enum.IPredEQ
+	case parser.GoParserNOT_EQUALS:
+		pred = enum.IPredNE
+	case parser.GoParserLESS:
+		pred = enum.IPredSLT
+	case parser.GoParserLESS_OR_EQUALS:
+		pred = enum.IPredSLE
+	case parser.GoParserGREATER:
+		pred = enum.IPredSGT
+	case parser.GoParserGREATER_OR_EQUALS:
+		pred = enum.IPredSGE
+	default:
+		return nil, utils.MakeError("invalid operation on strings")
+	}
+	cmpstring, err := genCtx.LookupFunc("runtime_cmpstring")
+	if err != nil {
+		return nil, err
+	}
+	ptr1, len1 := genCtx.GenerateStringParts(block, left)
+	ptr2, len2 := genCtx.GenerateStringParts(block, right)
+	res := block.NewCall(cmpstring, ptr1, len1, ptr2, len2)
+	return typesystem.NewTypedValue(
+		block.NewICmp(pred, res, constant.NewInt(typesystem.Int, 0)),
+		typesystem.Bool,
+	), nil
+}
+
+// GenerateStringConv converts between strings, byte and rune slices and
+// integers, which are converted to UTF-8 encoding of rune.
+func (genCtx *GenContext) GenerateStringConv(block *ir.Block, tp types.Type, val value.Value) (value.Value, error) {
+	res, err := genCtx.generateStringConv(block, typesystem.Underlying(tp), val)
+	if err != nil {
+		return nil, err
+	} else if res.Type() != tp {
+		return typesystem.NewTypedValue(res, tp), nil
+	}
+	return res, nil
+}
+
+func (genCtx *GenContext) generateStringConv(block *ir.Block, tp types.Type, val value.Value) (value.Value, error) {
+	switch vtp := typesystem.Underlying(val.Type()).(type) {
+	case *typesystem.StringType:
+		stp, ok := tp.(*typesystem.SliceType)
+		if !ok {
+			break
+		}
+		ptr, length := genCtx.GenerateStringParts(block, val)
+		if isByteType(stp.ElemType) {
+			fun, err := genCtx.LookupFunc("runtime_stringtoslicebyte")
+			if err != nil {
+				return nil, err
+			}
+			return genCtx.GenerateSliceValue(block, stp, block.NewCall(fun, ptr, length), length, length), nil
+		} else if stp.ElemType.Equal(typesystem.Rune) {
+			fun, err := genCtx.LookupFunc("runtime_stringtoslicerune")
+			if err != nil {
+				return nil, err
+			}
+			countRef := genCtx.NewTemp(typesystem.Int)
+			data := block.NewCall(fun, ptr, length, countRef)
+			count := block.NewLoad(typesystem.Int, countRef)
+			return genCtx.GenerateSliceValue(block, stp, data, count, count), nil
+		}
+	case *typesystem.SliceType:
+		if !typesystem.IsStringType(tp) {
+			break
+		}
+		ptr, length, _ := genCtx.GenerateSliceParts(block, val)
+		if isByteType(vtp.ElemType) {
+			return genCtx.generateRuntimeString(block, "runtime_slicebytetostring", ptr, length)
+		} else if vtp.ElemType.Equal(typesystem.Rune) {
+			return genCtx.generateRuntimeString(block, "runtime_slicerunetostring", ptr, length)
+		}
+	case *types.IntType, *typesystem.IntType, *typesystem.UintType:
+		if !typesystem.IsStringType(tp) {
+			break
+		}
+		if c, ok := val.(*typesystem.Const); ok {
+			conv, err := convertConst(c, types.I64)
+			if err != nil {
+				return nil, err
+			}
+			i, _ := goconstant.Int64Val(conv.Val)
+			return genCtx.GenerateStringConst(string(rune(i))), nil
+		}
+		r, _, err := genCtx.GenerateTypeCast(block, types.I64, val)
+		if err != nil {
+			return nil, err
+		}
+		return genCtx.generateRuntimeString(block, "runtime_intstring", r[0])
+	}
+	return nil, utils.MakeError("cannot convert %s to %s", genCtx.PackageData.typeName(val.Type()), genCtx.PackageData.typeName(tp))
+}
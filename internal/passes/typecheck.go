@@ -0,0 +1,364 @@
+package passes
+
+import (
+	"errors"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+	"sort"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir/types"
+)
+
+// maxTypeErrors limits number of reported type errors, like Go compiler does.
+const maxTypeErrors = 10
+
+// objKind is kind of entity named by identifier.
+type objKind int
+
+const (
+	objVar objKind = iota
+	objConst
+	objType
+	objFunc
+	objPkg
+	objBuiltin
+)
+
+// checkObj is entity declared in scope of type checker.
+type checkObj struct {
+	kind objKind
+	name string
+	tp   types.Type
+	c    *typesystem.Const
+	// C variadic function of standard library
+	variadic bool
+	// local variable is used, unused ones are reported when scope is closed
+	used *bool
+	decl antlr.ParserRuleContext
+}
+
+// checkScope is lexical block of type checker. Package scope has nil parent,
+// it holds global variables, other package-level names are resolved lazily.
+type checkScope struct {
+	parent *checkScope
+	objs   map[string]*checkObj
+}
+
+// typeError is error reported by type checker at position in source.
+type typeError struct {
+	file         string
+	line, column int
+	err          error
+}
+
+// TypeChecker is semantic pass between package listener and code generator.
+// It resolves identifiers, assigns types to expressions and reports all type
+// errors of package. Types of expressions are recorded in package data.
+type TypeChecker struct {
+	pdata *PackageData
+	scope *checkScope
+	errs  []typeError
+
+	// results of function, which body is checked, and its named results
+	results    []types.Type
+	resultVars []*checkObj
+
+	// source file being checked and imported packages, which are
+	// referenced by each file
+	file        parser.ISourceFileContext
+	usedImports map[parser.ISourceFileContext]map[string]bool
+}
+
+func NewTypeChecker(pdata *PackageData) *TypeChecker {
+	c := &TypeChecker{
+		pdata:       pdata,
+		scope:       &checkScope{objs: make(map[string]*checkObj)},
+		usedImports: make(map[parser.ISourceFileContext]map[string]bool),
+	}
+	pdata.ExprTypes = make(map[parser.IExpressionContext]types.Type)
+	pdata.VariadicCalls = make(map[parser.IArgumentsContext]*typesystem.FuncType)
+	pdata.MethodValues = make(map[parser.IPrimaryExprContext]bool)
+	// array lengths may refer to local constants
+	pdata.evalConst = func(ctx parser.IExpressionContext) (*typesystem.Const, bool, error) {
+		return c.constEvaluator().Eval(ctx)
+	}
+	return c
+}
+
+// Check checks declarations of source files of package and returns all
+// errors found.
+func (c *TypeChecker) Check(files []parser.ISourceFileContext) error {
+	// global variables are initialized before functions are run, in order
+	// of their dependencies
+	for _, init := range initOrder(files) {
+		c.useFile(init.file)
+		c.varSpec(init.spec)
+	}
+	// global variables may be used by importing packages
+	for name, obj := range c.scope.objs {
+		if obj.kind == objVar && obj.tp != nil {
+			c.pdata.Globals[name] = obj.tp
+		}
+	}
+	for _, file := range files {
+		c.useFile(file)
+		for _, fun := range file.AllFunctionDecl() {
+			decl, ok := c.pdata.Functions[c.pdata.symbol(fun.IDENTIFIER().GetText())]
+			if ok && fun.Block() != nil {
+				c.funcBody(decl, fun.Block())
+			}
+		}
+		for _, meth := range file.AllMethodDecl() {
+			typeName, _, _ := receiverTypeName(meth.Receiver().Parameters().ParameterDecl(0).Type_())
+			decl, ok := c.pdata.Methods[typeName][meth.IDENTIFIER().GetText()]
+			if ok && meth.Block() != nil {
+				c.funcBody(decl, meth.Block())
+			}
+		}
+		c.markQualifiedIdents(file)
+		for _, imp := range file.AllImportDecl() {
+			for _, spec := range imp.AllImportSpec() {
+				c.checkImportUsed(spec)
+			}
+		}
+	}
+	return c.result()
+}
+
+// useFile switches checker to source file, imports are local to file.
+func (c *TypeChecker) useFile(file parser.ISourceFileContext) {
+	c.file = file
+	c.pdata.UseFile(file)
+	if c.usedImports[file] == nil {
+		c.usedImports[file] = make(map[string]bool)
+	}
+}
+
+// useImport marks imported package as referenced by current file.
+func (c *TypeChecker) useImport(path string) {
+	c.usedImports[c.file][path] = true
+}
+
+// checkImportUsed reports import, which is never referenced.
+func (c *TypeChecker) checkImportUsed(ctx parser.IImportSpecContext) {
+	path := ctx.ImportPath().GetText()
+	path = path[1 : len(path)-1]
+	alias := path
+	if ctx.GetAlias() != nil {
+		alias = ctx.GetAlias().GetText()
+		if alias == "_" {
+			return
+		}
+	}
+	if !c.usedImports[c.file][path] {
+		if alias != path {
+			c.errorf(ctx, "%q imported as %s and not used", path, alias)
+		} else {
+			c.errorf(ctx, "%q imported and not used", path)
+		}
+	}
+}
+
+// markQualifiedIdents marks packages referred to by qualified type names as used.
+func (c *TypeChecker) markQualifiedIdents(tree antlr.Tree) {
+	if q, ok := tree.(parser.IQualifiedIdentContext); ok {
+		if module, ok := c.pdata.LookupModule(q.IDENTIFIER(0).GetText()); ok {
+			c.useImport(module.Name)
+		}
+	}
+	for _, child := range tree.GetChildren() {
+		c.markQualifiedIdents(child)
+	}
+}
+
+// result joins reported errors ordered by position.
+func (c *TypeChecker) result() error {
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		if c.errs[i].file != c.errs[j].file {
+			return c.errs[i].file < c.errs[j].file
+		} else if c.errs[i].line != c.errs[j].line {
+			return c.errs[i].line < c.errs[j].line
+		}
+		return c.errs[i].column < c.errs[j].column
+	})
+	var errs []error
+	for i, e := range c.errs {
+		if i == maxTypeErrors {
+			errs = append(errs, utils.MakeError("too many errors"))
+			break
+		}
+		errs = append(errs, e.err)
+	}
+	return errors.Join(errs...)
+}
+
+// errorf reports type error at position of ctx.
+func (c *TypeChecker) errorf(ctx antlr.ParserRuleContext, format string, args ...any) {
+	c.errorAt(ctx.GetStart(), utils.MakeErrorTrace(ctx, nil, format, args...))
+}
+
+// errorAt reports error at position of token.
+func (c *TypeChecker) errorAt(tok antlr.Token, err error) {
+	c.errs = append(c.errs, typeError{
+		file:   utils.SourceName(tok),
+		line:   tok.GetLine(),
+		column: tok.GetColumn(),
+		err:    err,
+	})
+}
+
+// record stores type of expression for code generator.
+func (c *TypeChecker) record(ctx parser.IExpressionContext, tp types.Type) {
+	if ctx != nil && tp != nil {
+		c.pdata.ExprTypes[ctx] = tp
+	}
+}
+
+// typeName returns Go name of type for error messages. Types of checked
+// package are not qualified, like in messages of Go compiler.
+func (c *TypeChecker) typeName(tp types.Type) string {
+	return c.unqualify(c.pdata.typeName(tp))
+}
+
+// unqualify removes name of checked package from type names in s.
+func (c *TypeChecker) unqualify(name string) string {
+	prefix := c.pdata.PackageName + "."
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		atStart := i == 0 || !isIdentByte(name[i-1])
+		if atStart && strings.HasPrefix(name[i:], prefix) {
+			i += len(prefix) - 1
+			continue
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '.' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func (c *TypeChecker) openScope() {
+	c.scope = &checkScope{parent: c.scope, objs: make(map[string]*checkObj)}
+}
+
+// closeScope reports local variables of scope, which are never used.
+func (c *TypeChecker) closeScope() {
+	var unused []*checkObj
+	for _, obj := range c.scope.objs {
+		if obj.kind == objVar && obj.used != nil && !*obj.used {
+			unused = append(unused, obj)
+		}
+	}
+	for _, obj := range unused {
+		c.errorf(obj.decl, "declared and not used: %s", obj.name)
+	}
+	c.scope = c.scope.parent
+}
+
+// declare adds entity to current scope. Blank identifier is never declared.
+func (c *TypeChecker) declare(ctx antlr.ParserRuleContext, obj *checkObj) {
+	if obj.name == "_" {
+		return
+	}
+	if _, ok := c.scope.objs[obj.name]; ok {
+		c.errorf(ctx, "%s redeclared in this block", obj.name)
+		return
+	}
+	obj.decl = ctx
+	c.scope.objs[obj.name] = obj
+}
+
+// declareVar declares variable, local ones must be used.
+func (c *TypeChecker) declareVar(ctx antlr.ParserRuleContext, name string, tp types.Type) *checkObj {
+	obj := &checkObj{kind: objVar, name: name, tp: tp}
+	if c.scope.parent != nil {
+		obj.used = new(bool)
+	}
+	c.declare(ctx, obj)
+	return obj
+}
+
+// lookup resolves identifier in current scope.
+func (c *TypeChecker) lookup(name string) (*checkObj, bool) {
+	for s := c.scope; s != nil; s = s.parent {
+		if obj, ok := s.objs[name]; ok {
+			return obj, true
+		}
+	}
+	// other package-level and predeclared names
+	pd := c.pdata
+	if cst, ok := pd.Constants[name]; ok {
+		return &checkObj{kind: objConst, name: name, c: cst, tp: cst.Type()}, true
+	} else if decl, ok := pd.Functions[pd.symbol(name)]; ok {
+		return &checkObj{kind: objFunc, name: name, tp: typesystem.NewFuncType(decl.ArgTypes, decl.ReturnTypes)}, true
+	} else if module, ok := pd.LookupModule(name); ok {
+		return &checkObj{kind: objPkg, name: module.Name}, true
+	} else if builtinFuncs[name] {
+		return &checkObj{kind: objBuiltin, name: name}, true
+	} else if name == "_" || name == "iota" {
+		return nil, false
+	}
+	if tp, err := pd.ParseTypeName(name); err == nil {
+		return &checkObj{kind: objType, name: name, tp: tp}, true
+	}
+	return nil, false
+}
+
+// constEvaluator returns evaluator of constant expressions in current scope.
+func (c *TypeChecker) constEvaluator() *constEvaluator {
+	return &constEvaluator{
+		pdata: c.pdata,
+		lookup: func(name string) (*typesystem.Const, bool) {
+			obj, ok := c.lookup(name)
+			if !ok || obj.kind == objType || obj.kind == objBuiltin || obj.kind == objPkg {
+				return nil, false
+			}
+			return obj.c, true
+		},
+	}
+}
+
+// parseType resolves type, reporting error if it is invalid.
+func (c *TypeChecker) parseType(ctx parser.IType_Context) types.Type {
+	tp, err := c.pdata.ParseType(ctx)
+	if err != nil {
+		c.errorf(ctx, "invalid type %s", ctx.GetText())
+		return nil
+	}
+	return tp
+}
+
+// funcBody checks body of function or method with signature decl.
+func (c *TypeChecker) funcBody(decl *FunctionDecl, body parser.IBlockContext) {
+	results, resultVars := c.results, c.resultVars
+	c.results, c.resultVars = decl.ReturnTypes, nil
+	c.openScope()
+	// parameters and results need not be used
+	for i, name := range decl.ArgNames {
+		if name != "" {
+			c.declare(body, &checkObj{kind: objVar, name: name, tp: decl.ArgTypes[i]})
+		}
+	}
+	for i, name := range decl.ReturnNames {
+		if name != "" {
+			obj := &checkObj{kind: objVar, name: name, tp: decl.ReturnTypes[i]}
+			c.declare(body, obj)
+			c.resultVars = append(c.resultVars, obj)
+		}
+	}
+	if body.StatementList() != nil {
+		c.stmtList(body.StatementList())
+	}
+	if len(decl.ReturnTypes) > 0 && !c.isTerminatingList(body.StatementList(), "") {
+		// reported at closing brace
+		tok := body.R_CURLY().GetSymbol()
+		c.errorAt(tok, utils.MakeError("%s: missing return", utils.Position(tok)))
+	}
+	c.closeScope()
+	c.results, c.resultVars = results, resultVars
+}
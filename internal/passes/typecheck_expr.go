@@ -0,0 +1,1489 @@
+package passes
+
+import (
+	"errors"
+	goconstant "go/constant"
+	"go/token"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir/types"
+)
+
+// operandMode describes what expression denotes.
+type operandMode int
+
+const (
+	// invalid expression, error is already reported
+	modeInvalid operandMode = iota
+	// call of function without results
+	modeNoValue
+	modeValue
+	// addressable value
+	modeVar
+	modeMapIndex
+	modeConst
+	modeNil
+	modeType
+	modePkg
+	modeBuiltin
+	// call of function with multiple results
+	modeTuple
+	// blank identifier on left side of assignment
+	modeBlank
+)
+
+// operand is result of checking expression.
+type operand struct {
+	mode  operandMode
+	tp    types.Type
+	c     *typesystem.Const
+	tuple []types.Type
+	// path of package or name of builtin function
+	name string
+	// expression may be used in special form v, ok
+	commaOk bool
+	// expression is function call, name is set for builtins
+	call bool
+	// C variadic function of standard library, extra arguments are not checked
+	variadic bool
+	expr     parser.IExpressionContext
+}
+
+func isNumeric(tp types.Type) bool {
+	return isInteger(tp) || typesystem.IsFloatType(tp)
+}
+
+func isInteger(tp types.Type) bool {
+	_, ok := typesystem.UnderlyingIntType(tp)
+	return ok && !typesystem.IsBoolType(tp)
+}
+
+func isOrdered(tp types.Type) bool {
+	return isNumeric(tp) || typesystem.IsStringType(tp)
+}
+
+// isNilable checks if nil may be assigned to values of type tp.
+func isNilable(tp types.Type) bool {
+	switch typesystem.Underlying(tp).(type) {
+	case *types.PointerType, *typesystem.SliceType, *typesystem.MapType,
+		*typesystem.ChanType, *typesystem.FuncType, *typesystem.InterfaceType:
+		return true
+	}
+	return false
+}
+
+// text returns source of expression for error messages.
+func (x *operand) text() string {
+	if x.expr == nil {
+		return "value"
+	}
+	return x.expr.GetText()
+}
+
+// describe returns Go description of operand, like "variable of type int".
+func (c *TypeChecker) describe(x operand) string {
+	switch x.mode {
+	case modeNoValue:
+		return "no value"
+	case modeConst:
+		if x.c.IsUntyped() {
+			return constKindName(x.c) + " constant"
+		}
+		return "constant " + x.c.Val.ExactString() + " of type " + c.typeName(x.tp)
+	case modeNil:
+		return "untyped nil"
+	case modeType:
+		return "type"
+	case modePkg:
+		return "package"
+	case modeBuiltin:
+		return "built-in function"
+	case modeTuple:
+		return "value of type " + c.tupleName(x.tuple)
+	case modeMapIndex:
+		return "map index expression of type " + c.typeName(x.tp)
+	case modeVar:
+		if x.expr == nil {
+			break
+		} else if _, ok := operandName(x.expr); ok {
+			return "variable of type " + c.typeName(x.tp)
+		}
+	}
+	return "value of type " + c.typeName(x.tp)
+}
+
+// expr checks expression, which must have single value.
+func (c *TypeChecker) expr(ctx parser.IExpressionContext) operand {
+	return c.singleValue(ctx, c.rawExpr(ctx))
+}
+
+// singleValue reports operand of expression ctx, which can't be used as value.
+func (c *TypeChecker) singleValue(ctx antlr.ParserRuleContext, x operand) operand {
+	switch x.mode {
+	case modeTuple:
+		c.errorf(ctx, "multiple-value %s (%s) in single-value context", ctx.GetText(), c.describe(x))
+	case modeNoValue:
+		c.errorf(ctx, "%s (no value) used as value", ctx.GetText())
+	case modeType:
+		c.errorf(ctx, "%s (type) is not an expression", ctx.GetText())
+	case modePkg:
+		c.errorf(ctx, "use of package %s without selector", ctx.GetText())
+	case modeBuiltin:
+		c.errorf(ctx, "%s (built-in function) must be called", ctx.GetText())
+	default:
+		return x
+	}
+	return operand{}
+}
+
+// rawExpr checks expression, which may also denote type or several values.
+func (c *TypeChecker) rawExpr(ctx parser.IExpressionContext) operand {
+	x := c.exprOperand(ctx)
+	x.expr = ctx
+	switch x.mode {
+	case modeConst, modeValue, modeVar, modeMapIndex:
+		c.record(ctx, x.tp)
+	}
+	return x
+}
+
+func (c *TypeChecker) exprOperand(ctx parser.IExpressionContext) operand {
+	// constant expressions are folded
+	if cst, ok, err := c.constEvaluator().Eval(ctx); err != nil {
+		c.errorAt(ctx.GetStart(), err)
+		return operand{}
+	} else if ok {
+		return operand{mode: modeConst, tp: cst.Type(), c: cst}
+	}
+	if ctx.PrimaryExpr() != nil {
+		return c.rawPrimary(ctx.PrimaryExpr())
+	} else if ctx.GetUnary_op() != nil {
+		return c.unary(ctx)
+	}
+	var op int
+	if ctx.GetMul_op() != nil {
+		op = ctx.GetMul_op().GetTokenType()
+	} else if ctx.GetAdd_op() != nil {
+		op = ctx.GetAdd_op().GetTokenType()
+	} else if ctx.GetRel_op() != nil {
+		op = ctx.GetRel_op().GetTokenType()
+	} else if ctx.LOGICAL_AND() != nil {
+		op = parser.GoParserLOGICAL_AND
+	} else {
+		op = parser.GoParserLOGICAL_OR
+	}
+	x := c.expr(ctx.Expression(0))
+	y := c.expr(ctx.Expression(1))
+	return c.binary(ctx, op, x, y, ctx.GetText())
+}
+
+func (c *TypeChecker) unary(ctx parser.IExpressionContext) operand {
+	op := ctx.GetUnary_op().GetTokenType()
+	inner := ctx.Expression(0)
+	if op == parser.GoParserSTAR {
+		// pointer type or indirection
+		x := c.rawExpr(inner)
+		switch {
+		case x.mode == modeType:
+			return operand{mode: modeType, tp: types.NewPointer(x.tp)}
+		case x.mode == modeNil:
+			c.errorf(ctx, "invalid operation: cannot indirect nil")
+			return operand{}
+		}
+		if x = c.singleValue(inner, x); x.mode == modeInvalid {
+			return x
+		} else if ptp, ok := typesystem.Underlying(x.tp).(*types.PointerType); ok {
+			return operand{mode: modeVar, tp: ptp.ElemType}
+		}
+		c.errorf(ctx, "invalid operation: cannot indirect %s (%s)", inner.GetText(), c.describe(x))
+		return operand{}
+	}
+	x := c.expr(inner)
+	if x.mode == modeInvalid {
+		return x
+	}
+	switch op {
+	case parser.GoParserAMPERSAND:
+		if x.mode != modeVar && !isCompositeLit(inner) {
+			c.errorf(ctx, "invalid operation: cannot take address of %s (%s)", inner.GetText(), c.describe(x))
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: types.NewPointer(x.tp)}
+	case parser.GoParserRECEIVE:
+		ctp, ok := typesystem.Underlying(x.tp).(*typesystem.ChanType)
+		if !ok {
+			c.errorf(ctx, "invalid operation: cannot receive from non-channel %s (%s)", inner.GetText(), c.describe(x))
+			return operand{}
+		} else if ctp.Dir == typesystem.ChanSend {
+			c.errorf(ctx, "invalid operation: cannot receive from send-only channel %s (%s)", inner.GetText(), c.describe(x))
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: ctp.ElemType, commaOk: true}
+	case parser.GoParserEXCLAMATION:
+		if !typesystem.IsBoolType(x.tp) {
+			c.errorf(ctx, "invalid operation: operator ! not defined on %s (%s)", inner.GetText(), c.describe(x))
+			return operand{}
+		}
+	case parser.GoParserCARET:
+		if !isInteger(x.tp) {
+			c.errorf(ctx, "invalid operation: operator ^ not defined on %s (%s)", inner.GetText(), c.describe(x))
+			return operand{}
+		}
+	default:
+		if !isNumeric(x.tp) {
+			c.errorf(ctx, "invalid operation: operator %s not defined on %s (%s)", ctx.GetUnary_op().GetText(), inner.GetText(), c.describe(x))
+			return operand{}
+		}
+	}
+	return operand{mode: modeValue, tp: x.tp}
+}
+
+// isCompositeLit checks if expression is composite literal, which address may be taken.
+func isCompositeLit(ctx parser.IExpressionContext) bool {
+	for ctx.PrimaryExpr() != nil && ctx.PrimaryExpr().Operand() != nil {
+		op := ctx.PrimaryExpr().Operand()
+		if op.Expression() == nil {
+			return op.Literal() != nil && op.Literal().CompositeLit() != nil
+		}
+		ctx = op.Expression()
+	}
+	return false
+}
+
+// binary checks binary operation, which is not constant.
+func (c *TypeChecker) binary(ctx antlr.ParserRuleContext, op int, x, y operand, text string) operand {
+	if x.mode == modeInvalid || y.mode == modeInvalid {
+		return operand{}
+	}
+	switch op {
+	case parser.GoParserEQUALS, parser.GoParserNOT_EQUALS, parser.GoParserLESS,
+		parser.GoParserLESS_OR_EQUALS, parser.GoParserGREATER, parser.GoParserGREATER_OR_EQUALS:
+		return c.compare(ctx, op, x, y, text)
+	case parser.GoParserLSHIFT, parser.GoParserRSHIFT:
+		return c.shift(ctx, x, y, text)
+	}
+	if !c.matchOperands(ctx, &x, &y, text) {
+		return operand{}
+	}
+	tp := x.tp
+	var defined bool
+	switch op {
+	case parser.GoParserLOGICAL_AND, parser.GoParserLOGICAL_OR:
+		defined = typesystem.IsBoolType(tp)
+	case parser.GoParserPLUS:
+		defined = isOrdered(tp)
+	case parser.GoParserMINUS, parser.GoParserSTAR, parser.GoParserDIV:
+		defined = isNumeric(tp)
+	default:
+		defined = isInteger(tp)
+	}
+	if !defined {
+		opText := map[int]string{
+			parser.GoParserLOGICAL_AND: "&&", parser.GoParserLOGICAL_OR: "||",
+			parser.GoParserPLUS: "+", parser.GoParserMINUS: "-", parser.GoParserSTAR: "*",
+			parser.GoParserDIV: "/", parser.GoParserMOD: "%", parser.GoParserAMPERSAND: "&",
+			parser.GoParserOR: "|", parser.GoParserCARET: "^", parser.GoParserBIT_CLEAR: "&^",
+		}[op]
+		c.errorf(ctx, "invalid operation: operator %s not defined on %s (%s)", opText, x.text(), c.describe(x))
+		return operand{}
+	}
+	if (op == parser.GoParserDIV || op == parser.GoParserMOD) && y.mode == modeConst && goconstant.Sign(y.c.Val) == 0 {
+		c.errorf(ctx, "invalid operation: division by zero")
+		return operand{}
+	}
+	return operand{mode: modeValue, tp: tp}
+}
+
+// matchOperands converts untyped operand to type of other one and checks
+// that types of operands are identical.
+func (c *TypeChecker) matchOperands(ctx antlr.ParserRuleContext, x, y *operand, text string) bool {
+	if x.mode == modeNil && y.mode == modeNil {
+		return true
+	} else if isUntyped(*x) && isUntyped(*y) {
+		// untyped constants are folded, untyped nil matches no constant
+		if x.mode == modeNil || y.mode == modeNil {
+			c.errorf(ctx, "invalid operation: %s (mismatched types %s and %s)", text, c.operandTypeName(*x), c.operandTypeName(*y))
+			return false
+		}
+		return true
+	} else if isUntyped(*x) {
+		if !c.convertUntyped(x, y.tp) {
+			c.errorf(ctx, "invalid operation: %s (mismatched types %s and %s)", text, c.operandTypeName(*x), c.typeName(y.tp))
+			return false
+		}
+	} else if isUntyped(*y) {
+		if !c.convertUntyped(y, x.tp) {
+			c.errorf(ctx, "invalid operation: %s (mismatched types %s and %s)", text, c.typeName(x.tp), c.operandTypeName(*y))
+			return false
+		}
+	}
+	if x.mode == modeInvalid || y.mode == modeInvalid {
+		return false
+	} else if x.tp.Equal(y.tp) {
+		return true
+	}
+	c.errorf(ctx, "invalid operation: %s (mismatched types %s and %s)", text, c.operandTypeName(*x), c.operandTypeName(*y))
+	return false
+}
+
+func isUntyped(x operand) bool {
+	return x.mode == modeNil || x.mode == modeConst && x.c.IsUntyped()
+}
+
+// operandTypeName returns Go name of type of operand, untyped ones included.
+func (c *TypeChecker) operandTypeName(x operand) string {
+	if x.mode == modeNil {
+		return "untyped nil"
+	} else if x.mode == modeConst && x.c.IsUntyped() {
+		return constKindName(x.c)
+	}
+	return c.typeName(x.tp)
+}
+
+// convertUntyped gives untyped operand type tp, if it can represent its value.
+// Interface type gives constants their default type.
+func (c *TypeChecker) convertUntyped(x *operand, tp types.Type) bool {
+	if tp == nil {
+		return false
+	}
+	if x.mode == modeNil {
+		if !isNilable(tp) {
+			return false
+		}
+		x.tp = tp
+		c.record(x.expr, tp)
+		return true
+	}
+	if typesystem.IsInterfaceType(tp) {
+		tp = x.c.Type()
+	} else if !isBasicType(tp) {
+		return false
+	}
+	if _, ok := typesystem.ConvertConst(x.c.Val, tp); !ok && !(isNumericConst(x.c) && isNumeric(tp)) {
+		// kinds of constant and type differ
+		return false
+	}
+	conv, err := convertConst(x.c, tp)
+	if err != nil {
+		c.errorf(x.expr, "%s", err)
+		x.mode = modeInvalid
+		return true
+	}
+	x.c, x.tp = conv, tp
+	c.record(x.expr, tp)
+	return true
+}
+
+// defaultType returns type of variable initialized by operand without
+// explicit type.
+func (c *TypeChecker) defaultType(x operand, context string) types.Type {
+	switch x.mode {
+	case modeInvalid:
+		return nil
+	case modeNil:
+		c.errorf(x.expr, "use of untyped nil in %s", context)
+		return nil
+	case modeConst:
+		if x.c.IsUntyped() {
+			c.convertUntyped(&x, x.c.Type())
+		}
+	}
+	return x.tp
+}
+
+// assign checks that operand can be assigned to variable of type tp.
+func (c *TypeChecker) assign(x operand, tp types.Type, context string) {
+	if x.mode == modeInvalid || tp == nil {
+		return
+	}
+	if isUntyped(x) {
+		if !c.convertUntyped(&x, tp) {
+			c.errorf(x.expr, "cannot use %s (%s) as %s value in %s", x.text(), c.describe(x), c.typeName(tp), context)
+		}
+		return
+	}
+	if err := c.assignable(x.tp, tp); err != "" {
+		if err != "mismatch" {
+			err = ": " + err
+		} else {
+			err = ""
+		}
+		c.errorf(x.expr, "cannot use %s (%s) as %s value in %s%s", x.text(), c.describe(x), c.typeName(tp), context, err)
+	}
+}
+
+// assignable checks that values of type from may be assigned to variables of
+// type to. Returns reason, why they can't.
+func (c *TypeChecker) assignable(from, to types.Type) string {
+	if from.Equal(to) {
+		return ""
+	} else if identicalUnderlying(from, to) && (!isNamed(from) || !isNamed(to)) {
+		return ""
+	}
+	switch to := to.(type) {
+	case *typesystem.InterfaceType:
+		if fitp, ok := from.(*typesystem.InterfaceType); ok {
+			for _, m := range to.Methods {
+				idx, found := fitp.MethodIndex(m.Name)
+				if !found {
+					return c.typeName(from) + " does not implement " + c.typeName(to) + " (missing method " + m.Name + ")"
+				} else if !fitp.Methods[idx].Equal(m) {
+					return c.typeName(from) + " does not implement " + c.typeName(to) + " (wrong type for method " + m.Name + ")"
+				}
+			}
+			return ""
+		}
+		if _, err := c.pdata.implements(from, to); err != nil {
+			return c.unqualify(err.Error())
+		}
+		return ""
+	}
+	// bidirectional channel may be restricted
+	fctp, fok := typesystem.Underlying(from).(*typesystem.ChanType)
+	tctp, tok := typesystem.Underlying(to).(*typesystem.ChanType)
+	if fok && tok && fctp.Dir == typesystem.ChanBoth && fctp.ElemType.Equal(tctp.ElemType) && (!isNamed(from) || !isNamed(to)) {
+		return ""
+	}
+	return "mismatch"
+}
+
+// isNamed checks if tp is predeclared or declared type.
+func isNamed(tp types.Type) bool {
+	switch tp := tp.(type) {
+	case *typesystem.NamedType:
+		return true
+	case *typesystem.StructInfo:
+		return tp.TypeName != ""
+	case *typesystem.InterfaceType:
+		return tp.TypeName != ""
+	}
+	return isNumeric(tp) || typesystem.IsBoolType(tp) || typesystem.IsStringType(tp)
+}
+
+// isComparable checks if values of type tp may be compared with ==.
+func isComparable(tp types.Type) bool {
+	switch tp := typesystem.Underlying(tp).(type) {
+	case *typesystem.SliceType, *typesystem.MapType, *typesystem.FuncType:
+		return false
+	case *types.ArrayType:
+		return isComparable(tp.ElemType)
+	case *typesystem.StructInfo:
+		for _, field := range tp.Fields {
+			ftp := field.Primitive
+			if field.IsStruct {
+				ftp = field.Struct
+			}
+			if !isComparable(ftp) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compare checks comparison of operands.
+func (c *TypeChecker) compare(ctx antlr.ParserRuleContext, op int, x, y operand, text string) operand {
+	if x.mode == modeInvalid || y.mode == modeInvalid {
+		return operand{}
+	}
+	opText := map[int]string{
+		parser.GoParserEQUALS: "==", parser.GoParserNOT_EQUALS: "!=",
+		parser.GoParserLESS: "<", parser.GoParserLESS_OR_EQUALS: "<=",
+		parser.GoParserGREATER: ">", parser.GoParserGREATER_OR_EQUALS: ">=",
+	}[op]
+	if x.mode == modeNil && y.mode == modeNil {
+		c.errorf(ctx, "invalid operation: %s (operator %s not defined on nil)", text, opText)
+		return operand{}
+	} else if x.mode == modeNil || y.mode == modeNil {
+		// comparison with nil keeps nil untyped
+		other := x
+		if other.mode == modeNil {
+			other = y
+		}
+		if !isNilable(other.tp) {
+			c.errorf(ctx, "invalid operation: %s (mismatched types %s and untyped nil)", text, c.operandTypeName(other))
+			return operand{}
+		} else if op != parser.GoParserEQUALS && op != parser.GoParserNOT_EQUALS {
+			c.errorf(ctx, "invalid operation: %s (operator %s not defined on nil)", text, opText)
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: typesystem.Bool}
+	}
+	// interface is compared with values of types implementing it
+	if itp, ok := y.tp.(*typesystem.InterfaceType); ok && !isUntyped(y) && x.mode != modeNil {
+		if isUntyped(x) {
+			c.convertUntyped(&x, itp)
+		}
+		if x.mode != modeInvalid && c.assignable(x.tp, itp) == "" {
+			x.tp = itp
+		}
+	} else if itp, ok := x.tp.(*typesystem.InterfaceType); ok && !isUntyped(x) && y.mode != modeNil {
+		if isUntyped(y) {
+			c.convertUntyped(&y, itp)
+		}
+		if y.mode != modeInvalid && c.assignable(y.tp, itp) == "" {
+			y.tp = itp
+		}
+	}
+	// operand is converted to type of other one it is assignable to
+	if !isUntyped(x) && !isUntyped(y) && !x.tp.Equal(y.tp) {
+		if c.assignable(y.tp, x.tp) == "" {
+			y.tp = x.tp
+			c.record(y.expr, y.tp)
+		} else if c.assignable(x.tp, y.tp) == "" {
+			x.tp = y.tp
+			c.record(x.expr, x.tp)
+		}
+	}
+	if !c.matchOperands(ctx, &x, &y, text) {
+		return operand{}
+	}
+	tp := x.tp
+	if op == parser.GoParserEQUALS || op == parser.GoParserNOT_EQUALS {
+		if !isComparable(tp) {
+			switch utp := typesystem.Underlying(tp).(type) {
+			case *typesystem.SliceType:
+				c.errorf(ctx, "invalid operation: %s (slice can only be compared to nil)", text)
+			case *typesystem.MapType:
+				c.errorf(ctx, "invalid operation: %s (map can only be compared to nil)", text)
+			case *typesystem.FuncType:
+				c.errorf(ctx, "invalid operation: %s (func can only be compared to nil)", text)
+			case *typesystem.StructInfo:
+				// first field, which is not comparable
+				for _, field := range utp.Fields {
+					if !isComparable(field.Type()) {
+						c.errorf(ctx, "invalid operation: %s (struct containing %s cannot be compared)", text, c.typeName(field.Type()))
+						break
+					}
+				}
+			default:
+				c.errorf(ctx, "invalid operation: %s (%s cannot be compared)", text, c.typeName(tp))
+			}
+			return operand{}
+		}
+	} else if !isOrdered(tp) {
+		c.errorf(ctx, "invalid operation: %s (operator %s not defined on %s)", text, opText, c.describe(x))
+		return operand{}
+	}
+	return operand{mode: modeValue, tp: typesystem.Bool}
+}
+
+// shift checks shift of non-constant value or by non-constant count.
+func (c *TypeChecker) shift(ctx antlr.ParserRuleContext, x, y operand, text string) operand {
+	if y.mode == modeConst {
+		if !isIntegerConst(y.c) && goconstant.ToInt(y.c.Val).Kind() != goconstant.Int {
+			c.errorf(ctx, "invalid shift count %s (%s)", y.text(), c.describe(y))
+			return operand{}
+		} else if goconstant.Sign(y.c.Val) < 0 {
+			c.errorf(ctx, "invalid shift count %s (negative shift count)", y.text())
+			return operand{}
+		} else if y.c.IsUntyped() {
+			c.convertUntyped(&y, typesystem.Uint)
+		}
+	} else if !isInteger(y.tp) {
+		c.errorf(ctx, "invalid operation: shift count %s (%s) must be integer", y.text(), c.describe(y))
+		return operand{}
+	}
+	if isUntyped(x) && x.mode == modeConst {
+		// untyped constant shifted by variable count gets its default type
+		if goconstant.ToInt(x.c.Val).Kind() != goconstant.Int {
+			c.errorf(ctx, "invalid operation: shifted operand %s (%s) must be integer", x.text(), c.describe(x))
+			return operand{}
+		}
+		c.convertUntyped(&x, typesystem.Int)
+	}
+	if x.mode == modeInvalid {
+		return x
+	} else if x.mode == modeNil || !isInteger(x.tp) {
+		c.errorf(ctx, "invalid operation: shifted operand %s (%s) must be integer", x.text(), c.describe(x))
+		return operand{}
+	}
+	return operand{mode: modeValue, tp: x.tp}
+}
+
+// rawPrimary checks primary expression, which may also denote type or package.
+func (c *TypeChecker) rawPrimary(ctx parser.IPrimaryExprContext) operand {
+	switch {
+	case ctx.Operand() != nil:
+		return c.operand(ctx.Operand())
+	case ctx.Conversion() != nil:
+		conv := ctx.Conversion()
+		tp := c.parseType(conv.Type_())
+		x := c.expr(conv.Expression())
+		if tp == nil {
+			return operand{}
+		}
+		return c.conversion(ctx, x, tp)
+	case ctx.MethodExpr() != nil:
+		tp := c.parseType(ctx.MethodExpr().Type_())
+		if tp == nil {
+			return operand{}
+		}
+		return c.methodExpr(ctx, tp, ctx.MethodExpr().IDENTIFIER().GetText())
+	case ctx.Arguments() != nil:
+		return c.call(ctx)
+	}
+	x := c.rawPrimary(ctx.PrimaryExpr())
+	if ctx.DOT() != nil {
+		return c.selector(ctx, x, ctx.IDENTIFIER().GetText())
+	}
+	if x = c.singleValue(ctx.PrimaryExpr(), x); x.mode == modeInvalid {
+		// operands of invalid expression are still checked
+		if ctx.Index() != nil {
+			c.expr(ctx.Index().Expression())
+		} else if ctx.Slice_() != nil {
+			for _, expr := range ctx.Slice_().AllExpression() {
+				c.expr(expr)
+			}
+		}
+		return x
+	}
+	switch {
+	case ctx.Index() != nil:
+		return c.index(ctx, x)
+	case ctx.Slice_() != nil:
+		return c.sliceExpr(ctx, x)
+	}
+	// type assertion
+	tp := c.parseType(ctx.TypeAssertion().Type_())
+	itp, ok := x.tp.(*typesystem.InterfaceType)
+	if !ok {
+		c.errorf(ctx, "invalid operation: %s (%s) is not an interface", ctx.PrimaryExpr().GetText(), c.describe(x))
+		return operand{}
+	} else if tp == nil {
+		return operand{}
+	} else if !typesystem.IsInterfaceType(tp) {
+		if _, err := c.pdata.implements(tp, itp); err != nil {
+			c.errorf(ctx, "impossible type assertion: %s\n\t%s", ctx.GetText(), err)
+			return operand{}
+		}
+	}
+	return operand{mode: modeValue, tp: tp, commaOk: true}
+}
+
+// primary checks primary expression, which must have single value.
+func (c *TypeChecker) primary(ctx parser.IPrimaryExprContext) operand {
+	return c.singleValue(ctx, c.rawPrimary(ctx))
+}
+
+func (c *TypeChecker) operand(ctx parser.IOperandContext) operand {
+	switch {
+	case ctx.Expression() != nil:
+		return c.rawExpr(ctx.Expression())
+	case ctx.OperandName() != nil:
+		return c.ident(ctx, ctx.OperandName().GetText())
+	}
+	lit := ctx.Literal()
+	switch {
+	case lit.CompositeLit() != nil:
+		return c.compositeLit(lit.CompositeLit())
+	case lit.FunctionLit() != nil:
+		return c.funcLit(lit.FunctionLit())
+	case lit.BasicLit().NIL_LIT() != nil:
+		return operand{mode: modeNil}
+	}
+	// literal operand of index or selector, which is not folded
+	cst, _, err := constBasicLit(lit.BasicLit())
+	if err != nil {
+		c.errorf(ctx, "invalid literal %s", ctx.GetText())
+		return operand{}
+	}
+	return operand{mode: modeConst, tp: cst.Type(), c: cst}
+}
+
+// ident resolves identifier used in expression and marks it used.
+func (c *TypeChecker) ident(ctx antlr.ParserRuleContext, name string) operand {
+	if name == "_" {
+		c.errorf(ctx, "cannot use _ as value")
+		return operand{}
+	}
+	obj, ok := c.lookup(name)
+	if !ok {
+		c.errorf(ctx, "undefined: %s", name)
+		return operand{}
+	}
+	switch obj.kind {
+	case objVar:
+		if obj.used != nil {
+			*obj.used = true
+		}
+		if obj.tp == nil {
+			// type of variable is invalid, error is already reported
+			return operand{}
+		}
+		return operand{mode: modeVar, tp: obj.tp}
+	case objConst:
+		return operand{mode: modeConst, tp: obj.tp, c: obj.c}
+	case objType:
+		return operand{mode: modeType, tp: obj.tp}
+	case objPkg:
+		c.useImport(obj.name)
+		return operand{mode: modePkg, name: obj.name}
+	case objBuiltin:
+		return operand{mode: modeBuiltin, name: name}
+	}
+	return operand{mode: modeValue, tp: obj.tp}
+}
+
+// selector checks x.name.
+func (c *TypeChecker) selector(ctx parser.IPrimaryExprContext, x operand, name string) operand {
+	switch x.mode {
+	case modeInvalid:
+		return x
+	case modePkg:
+		if fn, ok := stdlibFuncs[x.name][name]; ok {
+			var results []types.Type
+			if fn.retType != nil {
+				results = []types.Type{fn.retType}
+			}
+			return operand{mode: modeValue, tp: typesystem.NewFuncType(fn.argTypes, results), variadic: fn.variadic}
+		}
+		if pkg, ok := c.pdata.lookupPackage(x.name); ok {
+			return c.packageMember(ctx, pkg, name)
+		}
+		c.errorf(ctx, "undefined: %s.%s", ctx.PrimaryExpr().GetText(), name)
+		return operand{}
+	case modeType:
+		return c.methodExpr(ctx, x.tp, name)
+	}
+	if x = c.singleValue(ctx.PrimaryExpr(), x); x.mode == modeInvalid {
+		return x
+	}
+	tp := x.tp
+	if itp, ok := tp.(*typesystem.InterfaceType); ok {
+		if idx, ok := itp.MethodIndex(name); ok {
+			m := itp.Methods[idx]
+			c.pdata.MethodValues[ctx] = true
+			return operand{mode: modeValue, tp: typesystem.NewFuncType(m.ArgTypes, m.ReturnTypes)}
+		}
+	}
+	mode := modeValue
+	if x.mode == modeVar {
+		mode = modeVar
+	}
+	if ptp, ok := typesystem.Underlying(tp).(*types.PointerType); ok {
+		switch ptp.ElemType.(type) {
+		case *typesystem.StructInfo, *typesystem.NamedType:
+			tp, mode = ptp.ElemType, modeVar
+		}
+	}
+	// unexported fields and methods of imported types are not accessible
+	hidden := !token.IsExported(name) && c.pdata.typePackage(namedTypePkg(tp)) != c.pdata
+	if stp, ok := tp.(*typesystem.StructInfo); ok {
+		_, ftp, err := c.pdata.selectField(stp, name)
+		var ambiguous *typesystem.AmbiguousSelectorError
+		if err == nil && hidden {
+			c.errorf(ctx, "%s.%s undefined (cannot refer to unexported field %s)", ctx.PrimaryExpr().GetText(), name, name)
+			return operand{}
+		} else if err == nil {
+			return operand{mode: mode, tp: ftp}
+		} else if errors.As(err, &ambiguous) {
+			c.errorf(ctx, "ambiguous selector %s", ctx.GetText())
+			return operand{}
+		}
+	}
+	if decl, err := c.pdata.LookupMethod(tp, name); err == nil && hidden {
+		c.errorf(ctx, "%s.%s undefined (cannot refer to unexported method %s)", ctx.PrimaryExpr().GetText(), name, name)
+		return operand{}
+	} else if err == nil {
+		c.pdata.MethodValues[ctx] = true
+		return operand{mode: modeValue, tp: typesystem.NewFuncType(decl.ArgTypes[1:], decl.ReturnTypes)}
+	}
+	c.errorf(ctx, "%s.%s undefined (type %s has no field or method %s)", ctx.PrimaryExpr().GetText(), name, c.typeName(x.tp), name)
+	return operand{}
+}
+
+// packageMember checks exported name of imported package.
+func (c *TypeChecker) packageMember(ctx parser.IPrimaryExprContext, pkg *PackageData, name string) operand {
+	declared := true
+	var res operand
+	if cst, ok := pkg.Constants[name]; ok {
+		res = operand{mode: modeConst, tp: cst.Type(), c: cst}
+	} else if decl, ok := pkg.Functions[pkg.symbol(name)]; ok {
+		ftp := typesystem.NewFuncType(decl.ArgTypes, decl.ReturnTypes)
+		ftp.Variadic = decl.Ellipsis
+		res = operand{mode: modeValue, tp: ftp}
+	} else if tp, ok := pkg.Globals[name]; ok {
+		res = operand{mode: modeVar, tp: tp}
+	} else if pkg.IsUserType(name) && !strings.Contains(name, ".") {
+		tp, _ := pkg.ParseTypeName(name)
+		res = operand{mode: modeType, tp: tp}
+	} else {
+		declared = false
+	}
+	if declared && !token.IsExported(name) {
+		c.errorf(ctx, "name %s not exported by package %s", name, pkg.PackageName)
+		return operand{}
+	} else if !declared {
+		c.errorf(ctx, "undefined: %s.%s", ctx.PrimaryExpr().GetText(), name)
+		return operand{}
+	}
+	return res
+}
+
+// methodExpr checks method expression T.M or (*T).M, which is function
+// taking receiver as first argument.
+func (c *TypeChecker) methodExpr(ctx antlr.ParserRuleContext, tp types.Type, name string) operand {
+	base, isPtr := tp, false
+	if ptp, ok := tp.(*types.PointerType); ok {
+		base, isPtr = ptp.ElemType, true
+	}
+	decl, err := c.pdata.LookupMethod(base, name)
+	if err != nil {
+		c.errorf(ctx, "%s.%s undefined (type %s has no method %s)", c.typeName(tp), name, c.typeName(tp), name)
+		return operand{}
+	} else if decl.PtrReceiver && !isPtr {
+		c.errorf(ctx, "invalid method expression %s.%s (needs pointer receiver (*%s).%s)", c.typeName(tp), name, c.typeName(tp), name)
+		return operand{}
+	}
+	args := append([]types.Type{tp}, decl.ArgTypes[1:]...)
+	return operand{mode: modeValue, tp: typesystem.NewFuncType(args, decl.ReturnTypes)}
+}
+
+// index checks x[i].
+func (c *TypeChecker) index(ctx parser.IPrimaryExprContext, x operand) operand {
+	idx := ctx.Index().Expression()
+	tp := typesystem.Underlying(x.tp)
+	if ptp, ok := tp.(*types.PointerType); ok {
+		if atp, ok := typesystem.Underlying(ptp.ElemType).(*types.ArrayType); ok {
+			tp, x.mode = atp, modeVar
+		}
+	}
+	switch tp := tp.(type) {
+	case *typesystem.MapType:
+		c.assign(c.expr(idx), tp.KeyType, "map index")
+		return operand{mode: modeMapIndex, tp: tp.ElemType, commaOk: true}
+	case *typesystem.StringType:
+		c.checkIndex(idx, -1)
+		return operand{mode: modeValue, tp: typesystem.Byte}
+	case *types.ArrayType:
+		c.checkIndex(idx, int64(tp.Len))
+		mode := modeValue
+		if x.mode == modeVar {
+			mode = modeVar
+		}
+		return operand{mode: mode, tp: tp.ElemType}
+	case *typesystem.SliceType:
+		c.checkIndex(idx, -1)
+		return operand{mode: modeVar, tp: tp.ElemType}
+	}
+	c.expr(idx)
+	c.errorf(ctx, "invalid operation: cannot index %s (%s)", ctx.PrimaryExpr().GetText(), c.describe(x))
+	return operand{}
+}
+
+// checkIndex checks index, which must be integer. Constant index must be
+// less than length, if it is not negative.
+func (c *TypeChecker) checkIndex(ctx parser.IExpressionContext, length int64) {
+	x := c.expr(ctx)
+	if x.mode == modeInvalid {
+		return
+	}
+	if x.mode == modeConst {
+		if !isIntegerConst(x.c) && goconstant.ToInt(x.c.Val).Kind() != goconstant.Int {
+			c.errorf(ctx, "invalid argument: index %s (%s) must be integer", ctx.GetText(), c.describe(x))
+			return
+		} else if goconstant.Sign(x.c.Val) < 0 {
+			c.errorf(ctx, "invalid argument: index %s (%s) must not be negative", ctx.GetText(), c.describe(x))
+			return
+		} else if i, ok := goconstant.Int64Val(goconstant.ToInt(x.c.Val)); length >= 0 && (!ok || i >= length) {
+			c.errorf(ctx, "invalid argument: index %s out of bounds [0:%d]", ctx.GetText(), length)
+			return
+		}
+		if x.c.IsUntyped() {
+			c.convertUntyped(&x, typesystem.Int)
+		}
+		return
+	}
+	if !isInteger(x.tp) {
+		c.errorf(ctx, "invalid argument: index %s (%s) must be integer", ctx.GetText(), c.describe(x))
+	}
+}
+
+// sliceExpr checks x[lo:hi:max].
+func (c *TypeChecker) sliceExpr(ctx parser.IPrimaryExprContext, x operand) operand {
+	sl := ctx.Slice_()
+	for _, expr := range sl.AllExpression() {
+		c.checkIndex(expr, -1)
+	}
+	full := len(sl.AllCOLON()) == 2
+	tp := typesystem.Underlying(x.tp)
+	if ptp, ok := tp.(*types.PointerType); ok {
+		if atp, ok := typesystem.Underlying(ptp.ElemType).(*types.ArrayType); ok {
+			tp, x.mode = atp, modeVar
+		}
+	}
+	switch utp := tp.(type) {
+	case *typesystem.StringType:
+		if full {
+			c.errorf(ctx, "invalid operation: 3-index slice of string")
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: x.tp}
+	case *types.ArrayType:
+		if x.mode != modeVar {
+			c.errorf(ctx, "invalid operation: %s (slice of unaddressable value)", ctx.GetText())
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: typesystem.NewSliceType(utp.ElemType)}
+	case *typesystem.SliceType:
+		return operand{mode: modeValue, tp: x.tp}
+	}
+	c.errorf(ctx, "cannot slice %s (%s)", ctx.PrimaryExpr().GetText(), c.describe(x))
+	return operand{}
+}
+
+// conversion checks conversion of operand to type tp.
+func (c *TypeChecker) conversion(ctx antlr.ParserRuleContext, x operand, tp types.Type) operand {
+	if x.mode == modeInvalid {
+		return operand{}
+	}
+	res := operand{mode: modeValue, tp: tp}
+	if isUntyped(x) {
+		if x.mode == modeConst && !typesystem.IsInterfaceType(tp) {
+			if isBasicType(tp) {
+				// constant conversions are folded by evaluator
+				c.convertUntyped(&x, x.c.Type())
+			} else if bs, ok := typesystem.Underlying(tp).(*typesystem.SliceType); !ok || x.c.Val.Kind() != goconstant.String || !(isByteType(bs.ElemType) || bs.ElemType.Equal(typesystem.Rune)) {
+				c.errorf(ctx, "cannot convert %s (%s) to type %s", x.text(), c.describe(x), c.typeName(tp))
+				return operand{}
+			} else {
+				c.convertUntyped(&x, typesystem.String)
+			}
+		} else if c.convertUntyped(&x, tp) {
+			return res
+		}
+	}
+	if x.mode == modeInvalid {
+		return operand{}
+	} else if x.mode != modeNil && c.convertible(x.tp, tp) {
+		return res
+	}
+	c.errorf(ctx, "cannot convert %s (%s) to type %s", x.text(), c.describe(x), c.typeName(tp))
+	return operand{}
+}
+
+// convertible checks if values of type from may be converted to type to.
+func (c *TypeChecker) convertible(from, to types.Type) bool {
+	if c.assignable(from, to) == "" {
+		return true
+	} else if isNumeric(from) && isNumeric(to) {
+		return true
+	}
+	// pointers to types with identical underlying types
+	fptp, fok := typesystem.Underlying(from).(*types.PointerType)
+	tptp, tok := typesystem.Underlying(to).(*types.PointerType)
+	if fok && tok && identicalUnderlying(fptp.ElemType, tptp.ElemType) {
+		return true
+	} else if identicalUnderlying(from, to) {
+		return true
+	}
+	isBytesOrRunes := func(tp types.Type) bool {
+		stp, ok := typesystem.Underlying(tp).(*typesystem.SliceType)
+		return ok && (isByteType(stp.ElemType) || stp.ElemType.Equal(typesystem.Rune))
+	}
+	if typesystem.IsStringType(to) {
+		return isInteger(from) || isBytesOrRunes(from)
+	} else if typesystem.IsStringType(from) {
+		return isBytesOrRunes(to)
+	}
+	return false
+}
+
+// call checks function call, conversion or call of builtin function.
+func (c *TypeChecker) call(ctx parser.IPrimaryExprContext) operand {
+	args := ctx.Arguments()
+	callee := c.rawPrimary(ctx.PrimaryExpr())
+	switch callee.mode {
+	case modeInvalid:
+		c.exprList(args)
+		return operand{}
+	case modeType:
+		if args.ExpressionList() == nil || len(args.ExpressionList().AllExpression()) != 1 || args.Type_() != nil {
+			c.exprList(args)
+			c.errorf(ctx, "wrong argument count in conversion to %s", c.typeName(callee.tp))
+			return operand{}
+		}
+		return c.conversion(ctx, c.expr(args.ExpressionList().Expression(0)), callee.tp)
+	case modeBuiltin:
+		x := c.builtinCall(ctx, callee.name)
+		x.call, x.name = true, callee.name
+		return x
+	}
+	if callee = c.singleValue(ctx.PrimaryExpr(), callee); callee.mode == modeInvalid {
+		c.exprList(args)
+		return operand{}
+	}
+	ftp, ok := typesystem.Underlying(callee.tp).(*typesystem.FuncType)
+	if !ok {
+		c.exprList(args)
+		c.errorf(ctx, "invalid operation: cannot call non-function %s (%s)", ctx.PrimaryExpr().GetText(), c.describe(callee))
+		return operand{}
+	}
+	if args.Type_() != nil {
+		c.errorf(args, "%s is not an expression", args.Type_().GetText())
+	} else if args.ELLIPSIS() != nil && !ftp.Variadic {
+		c.errorf(args, "have (...) arguments in call to non-variadic %s", ctx.PrimaryExpr().GetText())
+	}
+	c.arguments(ctx, args, ftp, callee.variadic)
+	res := operand{mode: modeValue, call: true}
+	switch len(ftp.ReturnTypes) {
+	case 0:
+		res.mode = modeNoValue
+	case 1:
+		res.tp = ftp.ReturnTypes[0]
+	default:
+		res.mode, res.tuple = modeTuple, ftp.ReturnTypes
+	}
+	return res
+}
+
+// exprList checks arguments of invalid call, so that their errors are reported.
+func (c *TypeChecker) exprList(ctx parser.IArgumentsContext) {
+	if ctx.ExpressionList() == nil {
+		return
+	}
+	for _, expr := range ctx.ExpressionList().AllExpression() {
+		c.rawExpr(expr)
+	}
+}
+
+// arguments checks arguments of call against types of parameters. Extra
+// arguments of variadic function are assigned to element type of its last
+// parameter, unless slice is passed as f(s...).
+func (c *TypeChecker) arguments(call parser.IPrimaryExprContext, ctx parser.IArgumentsContext, ftp *typesystem.FuncType, variadic bool) {
+	params := ftp.ArgTypes
+	var exprs []parser.IExpressionContext
+	if ctx.ExpressionList() != nil {
+		exprs = ctx.ExpressionList().AllExpression()
+	}
+	var args []operand
+	if len(exprs) == 1 {
+		// results of call may be passed as arguments f(g())
+		x := c.rawExpr(exprs[0])
+		if x.mode == modeTuple {
+			for _, tp := range x.tuple {
+				args = append(args, operand{mode: modeValue, tp: tp, expr: exprs[0]})
+			}
+		} else {
+			args = append(args, c.singleValue(exprs[0], x))
+		}
+	} else {
+		for _, expr := range exprs {
+			args = append(args, c.expr(expr))
+		}
+	}
+	packed := ftp.Variadic && ctx.ELLIPSIS() == nil
+	if packed {
+		// extra arguments are elements of last parameter
+		fixed := len(params) - 1
+		elem := params[fixed].(*typesystem.SliceType).ElemType
+		params = params[:fixed:fixed]
+		for len(params) < len(args) {
+			params = append(params, elem)
+		}
+	}
+	if len(args) < len(params) || len(args) > len(params) && !variadic {
+		msg := "not enough arguments"
+		if len(args) > len(params) {
+			msg = "too many arguments"
+		}
+		want := c.pdata.formatSignature(ftp.ArgTypes, nil, ftp.Variadic, qualifiedByName)
+		c.errorf(ctx, "%s in call to %s\n\thave (%s)\n\twant %s", msg, call.PrimaryExpr().GetText(), c.haveList(args), c.unqualify(strings.TrimPrefix(want, "func")))
+		return
+	}
+	if packed {
+		c.pdata.VariadicCalls[ctx] = ftp
+	}
+	for i, x := range args {
+		if i < len(params) {
+			c.assign(x, params[i], "argument to "+call.PrimaryExpr().GetText())
+		} else {
+			c.defaultType(x, "argument to "+call.PrimaryExpr().GetText())
+		}
+	}
+}
+
+// haveList returns types of values in Go notation of call and return errors.
+func (c *TypeChecker) haveList(vals []operand) string {
+	var have []string
+	for _, x := range vals {
+		have = append(have, c.argTypeName(x))
+	}
+	return strings.Join(have, ", ")
+}
+
+// argTypeName returns type of argument in Go notation of call errors.
+func (c *TypeChecker) argTypeName(x operand) string {
+	switch {
+	case x.mode == modeNil:
+		return "nil"
+	case x.mode == modeInvalid:
+		return "invalid type"
+	case x.mode == modeConst && x.c.IsUntyped() && isNumericConst(x.c):
+		return "number"
+	case x.mode == modeConst && x.c.IsUntyped():
+		return c.typeName(x.c.Type())
+	}
+	return c.typeName(x.tp)
+}
+
+// builtinArity is minimal and maximal number of arguments of builtin
+// functions, negative for unlimited.
+var builtinArity = map[string][2]int{
+	"append": {1, -1}, "cap": {1, 1}, "close": {1, 1}, "copy": {2, 2}, "delete": {2, 2},
+	"len": {1, 1}, "make": {1, 3}, "panic": {1, 1}, "recover": {0, 0},
+}
+
+// builtinCall checks call of builtin function.
+func (c *TypeChecker) builtinCall(ctx parser.IPrimaryExprContext, name string) operand {
+	args := ctx.Arguments()
+	var exprs []parser.IExpressionContext
+	if args.ExpressionList() != nil {
+		exprs = args.ExpressionList().AllExpression()
+	}
+	// type argument of make is either parsed as type or as expression
+	var tp types.Type
+	hasType := args.Type_() != nil
+	if hasType {
+		tp = c.parseType(args.Type_())
+	} else if name == "make" && len(exprs) > 0 {
+		x := c.rawExpr(exprs[0])
+		if x.mode == modeType {
+			tp, hasType = x.tp, true
+		} else if x.mode != modeInvalid {
+			c.errorf(exprs[0], "%s is not a type", exprs[0].GetText())
+			return operand{}
+		} else {
+			return operand{}
+		}
+		exprs = exprs[1:]
+	}
+	if hasType && name != "make" {
+		c.errorf(args, "%s is not an expression", args.Type_().GetText())
+		return operand{}
+	}
+	count := len(exprs)
+	if hasType {
+		count++
+	}
+	var vals []operand
+	for _, expr := range exprs {
+		vals = append(vals, c.expr(expr))
+	}
+	for _, x := range vals {
+		if x.mode == modeInvalid {
+			return operand{}
+		}
+	}
+	minArgs, maxArgs := builtinArity[name][0], builtinArity[name][1]
+	if count < minArgs {
+		c.errorf(ctx, "not enough arguments for %s (expected %d, found %d)", ctx.GetText(), minArgs, count)
+		return operand{}
+	} else if maxArgs >= 0 && count > maxArgs {
+		c.errorf(ctx, "too many arguments for %s (expected %d, found %d)", ctx.GetText(), maxArgs, count)
+		return operand{}
+	}
+	if args.ELLIPSIS() != nil && name != "append" {
+		c.errorf(ctx, "invalid operation: invalid use of ... with built-in %s", name)
+		return operand{}
+	}
+	switch name {
+	case "len", "cap":
+		x := vals[0]
+		tp := typesystem.Underlying(x.tp)
+		if ptp, ok := tp.(*types.PointerType); ok {
+			if atp, ok := typesystem.Underlying(ptp.ElemType).(*types.ArrayType); ok {
+				tp = atp
+			}
+		}
+		valid := false
+		switch tp.(type) {
+		case *types.ArrayType, *typesystem.SliceType, *typesystem.ChanType:
+			valid = x.mode != modeNil
+		case *typesystem.StringType, *typesystem.MapType:
+			valid = name == "len"
+		}
+		if !valid {
+			c.errorf(ctx, "invalid argument: %s (%s) for built-in %s", x.text(), c.describe(x), name)
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: typesystem.Int}
+	case "make":
+		if tp == nil {
+			return operand{}
+		}
+		switch typesystem.Underlying(tp).(type) {
+		case *typesystem.SliceType:
+			if count == 1 {
+				c.errorf(ctx, "invalid operation: %s expects 2 or 3 arguments; found 1", ctx.GetText())
+				return operand{}
+			}
+		case *typesystem.MapType, *typesystem.ChanType:
+			if count > 2 {
+				c.errorf(ctx, "invalid operation: %s expects 1 or 2 arguments; found %d", ctx.GetText(), count)
+				return operand{}
+			}
+		default:
+			c.errorf(ctx, "invalid argument: cannot make %s; type must be slice, map, or channel", c.typeName(tp))
+			return operand{}
+		}
+		for i, x := range vals {
+			if x.mode == modeConst {
+				c.checkIndex(exprs[i], -1)
+			} else if !isInteger(x.tp) {
+				c.errorf(exprs[i], "cannot convert %s (%s) to type int", x.text(), c.describe(x))
+			}
+		}
+		return operand{mode: modeValue, tp: tp}
+	case "append":
+		x := vals[0]
+		stp, ok := typesystem.Underlying(x.tp).(*typesystem.SliceType)
+		if x.mode == modeNil {
+			c.errorf(ctx, "first argument to append must be a typed slice; have untyped nil")
+			return operand{}
+		} else if !ok {
+			c.errorf(ctx, "invalid argument: %s (%s) is not a slice", x.text(), c.describe(x))
+			return operand{}
+		}
+		if args.ELLIPSIS() != nil {
+			if len(vals) != 2 {
+				c.errorf(ctx, "can only use ... with final argument in list")
+				return operand{}
+			}
+			y := vals[1]
+			if isByteType(stp.ElemType) && (typesystem.IsStringType(y.tp) || y.mode == modeConst && y.c.Val.Kind() == goconstant.String) {
+				if isUntyped(y) {
+					c.convertUntyped(&y, typesystem.String)
+				}
+				return operand{mode: modeValue, tp: x.tp}
+			}
+			c.assign(y, stp, "append")
+			return operand{mode: modeValue, tp: x.tp}
+		}
+		for _, y := range vals[1:] {
+			c.assign(y, stp.ElemType, "argument to append")
+		}
+		return operand{mode: modeValue, tp: x.tp}
+	case "copy":
+		dst, src := vals[0], vals[1]
+		dtp, ok := typesystem.Underlying(dst.tp).(*typesystem.SliceType)
+		if !ok || dst.mode == modeNil {
+			c.errorf(ctx, "invalid argument: copy expects slice arguments; found %s (%s) and %s (%s)", dst.text(), c.describe(dst), src.text(), c.describe(src))
+			return operand{}
+		}
+		if isUntyped(src) && src.mode == modeConst {
+			c.convertUntyped(&src, typesystem.String)
+		}
+		if typesystem.IsStringType(src.tp) && isByteType(dtp.ElemType) {
+			return operand{mode: modeValue, tp: typesystem.Int}
+		} else if stp, ok := typesystem.Underlying(src.tp).(*typesystem.SliceType); !ok || src.mode == modeNil || !stp.ElemType.Equal(dtp.ElemType) {
+			c.errorf(ctx, "invalid argument: arguments to copy %s (%s) and %s (%s) have different element types", dst.text(), c.describe(dst), src.text(), c.describe(src))
+			return operand{}
+		}
+		return operand{mode: modeValue, tp: typesystem.Int}
+	case "delete":
+		m := vals[0]
+		mtp, ok := typesystem.Underlying(m.tp).(*typesystem.MapType)
+		if !ok || m.mode == modeNil {
+			c.errorf(ctx, "invalid argument: %s (%s) is not a map", m.text(), c.describe(m))
+			return operand{}
+		}
+		c.assign(vals[1], mtp.KeyType, "argument to delete")
+		return operand{mode: modeNoValue}
+	case "close":
+		ch := vals[0]
+		ctp, ok := typesystem.Underlying(ch.tp).(*typesystem.ChanType)
+		if !ok || ch.mode == modeNil {
+			c.errorf(ctx, "invalid operation: non-chan argument %s (%s) to close", ch.text(), c.describe(ch))
+			return operand{}
+		} else if ctp.Dir == typesystem.ChanRecv {
+			c.errorf(ctx, "invalid operation: cannot close receive-only channel %s (%s)", ch.text(), c.describe(ch))
+			return operand{}
+		}
+		return operand{mode: modeNoValue}
+	case "panic":
+		c.assign(vals[0], typesystem.Any, "argument to panic")
+		return operand{mode: modeNoValue}
+	}
+	// recover
+	return operand{mode: modeValue, tp: typesystem.Any}
+}
+
+// compositeLit checks composite literal T{...}.
+func (c *TypeChecker) compositeLit(ctx parser.ICompositeLitContext) operand {
+	tp, err := c.pdata.ParseLiteralType(ctx.LiteralType(), ctx.LiteralValue())
+	if err != nil {
+		c.errorf(ctx, "invalid composite literal type %s", ctx.LiteralType().GetText())
+		return operand{}
+	}
+	c.literalValue(ctx.LiteralValue(), tp)
+	return operand{mode: modeValue, tp: tp}
+}
+
+// literalValue checks elements of composite literal of type tp.
+func (c *TypeChecker) literalValue(ctx parser.ILiteralValueContext, tp types.Type) {
+	var elems []parser.IKeyedElementContext
+	if ctx.ElementList() != nil {
+		elems = ctx.ElementList().AllKeyedElement()
+	}
+	switch utp := typesystem.Underlying(tp).(type) {
+	case *typesystem.StructInfo:
+		c.structLit(ctx, utp, elems)
+	case *types.ArrayType:
+		c.indexedElems(elems, utp.ElemType, int64(utp.Len))
+	case *typesystem.SliceType:
+		c.indexedElems(elems, utp.ElemType, -1)
+	case *typesystem.MapType:
+		seen := make(map[string]bool)
+		for _, elem := range elems {
+			if elem.Key() == nil {
+				c.errorf(elem, "missing key in map literal")
+				c.element(elem.Element().Expression(), elem.Element().LiteralValue(), utp.ElemType)
+				continue
+			}
+			key := c.element(elem.Key().Expression(), elem.Key().LiteralValue(), utp.KeyType)
+			if key.mode == modeConst {
+				if seen[key.c.Val.ExactString()] {
+					c.errorf(elem.Key(), "duplicate key %s in map literal", elem.Key().GetText())
+				}
+				seen[key.c.Val.ExactString()] = true
+			}
+			c.element(elem.Element().Expression(), elem.Element().LiteralValue(), utp.ElemType)
+		}
+	default:
+		c.errorf(ctx, "invalid composite literal type %s", c.typeName(tp))
+	}
+}
+
+// element checks element or key of composite literal, which is assigned
+// to type tp. Literals of elided type get type tp.
+func (c *TypeChecker) element(expr parser.IExpressionContext, lit parser.ILiteralValueContext, tp types.Type) operand {
+	if lit != nil {
+		elemType := tp
+		if ptp, ok := typesystem.Underlying(tp).(*types.PointerType); ok {
+			// &T is elided too
+			elemType = ptp.ElemType
+		}
+		switch typesystem.Underlying(elemType).(type) {
+		case *typesystem.StructInfo, *types.ArrayType, *typesystem.SliceType, *typesystem.MapType:
+			c.literalValue(lit, elemType)
+		default:
+			c.errorf(lit, "invalid composite literal element type %s", c.typeName(tp))
+		}
+		return operand{mode: modeValue, tp: tp}
+	}
+	x := c.expr(expr)
+	c.assign(x, tp, "array or slice literal")
+	return x
+}
+
+// promotedName returns name of promoted field qualified by names of embedded
+// fields on path to it.
+func promotedName(tp *typesystem.StructInfo, path []int) string {
+	var names []string
+	for _, idx := range path {
+		field := tp.Fields[idx]
+		names = append(names, field.Name)
+		ftp := field.Type()
+		if ptp, ok := ftp.(*types.PointerType); ok {
+			ftp = ptp.ElemType
+		}
+		tp, _ = ftp.(*typesystem.StructInfo)
+	}
+	return strings.Join(names, ".")
+}
+
+func (c *TypeChecker) structLit(ctx parser.ILiteralValueContext, tp *typesystem.StructInfo, elems []parser.IKeyedElementContext) {
+	if len(elems) == 0 {
+		return
+	}
+	keyed := elems[0].Key() != nil
+	seen := make(map[string]bool)
+	for i, elem := range elems {
+		if (elem.Key() != nil) != keyed {
+			c.errorf(elem, "mixture of field:value and value elements in struct literal")
+			return
+		}
+		var ftp types.Type
+		if keyed {
+			name := elem.Key().GetText()
+			path, t, err := tp.ComputeOffset(name)
+			if err != nil {
+				c.errorf(elem.Key(), "unknown field %s in struct literal of type %s", name, c.typeName(tp))
+				continue
+			} else if len(path) > 1 {
+				c.errorf(elem.Key(), "cannot use promoted field %s in struct literal of type %s", promotedName(tp, path), c.typeName(tp))
+				continue
+			} else if seen[name] {
+				c.errorf(elem.Key(), "duplicate field name %s in struct literal", name)
+				continue
+			}
+			seen[name] = true
+			ftp = t
+		} else if i >= len(tp.Fields) {
+			c.errorf(elem, "too many values in struct literal of type %s", c.typeName(tp))
+			return
+		} else if tp.Fields[i].IsStruct {
+			ftp = tp.Fields[i].Struct
+		} else {
+			ftp = tp.Fields[i].Primitive
+		}
+		if lit := elem.Element().LiteralValue(); lit != nil {
+			c.errorf(lit, "missing type in composite literal")
+			continue
+		}
+		c.assign(c.expr(elem.Element().Expression()), ftp, "struct literal")
+	}
+	if !keyed && len(elems) < len(tp.Fields) {
+		c.errorf(ctx, "too few values in struct literal of type %s", c.typeName(tp))
+	}
+}
+
+// indexedElems checks elements of array or slice literal, length is negative for slices.
+func (c *TypeChecker) indexedElems(elems []parser.IKeyedElementContext, elemType types.Type, length int64) {
+	seen := make(map[int64]bool)
+	var idx int64
+	for _, elem := range elems {
+		if key := elem.Key(); key != nil {
+			x := operand{}
+			if key.Expression() != nil {
+				x = c.expr(key.Expression())
+			}
+			if x.mode != modeConst || !isIntegerConst(x.c) && goconstant.ToInt(x.c.Val).Kind() != goconstant.Int {
+				if x.mode != modeInvalid || key.Expression() == nil {
+					c.errorf(key, "index %s must be integer constant", key.GetText())
+				}
+			} else if i, ok := goconstant.Int64Val(goconstant.ToInt(x.c.Val)); !ok || i < 0 {
+				c.errorf(key, "index %s must be non-negative integer constant", key.GetText())
+			} else {
+				idx = i
+				if x.c.IsUntyped() {
+					c.convertUntyped(&x, typesystem.Int)
+				}
+			}
+		}
+		if length >= 0 && idx >= length {
+			c.errorf(elem, "index %d out of bounds [0:%d]", idx, length)
+		} else if seen[idx] {
+			c.errorf(elem, "duplicate index %d in array or slice literal", idx)
+		}
+		seen[idx] = true
+		c.element(elem.Element().Expression(), elem.Element().LiteralValue(), elemType)
+		idx++
+	}
+}
+
+// funcLit checks function literal, which body may refer to enclosing variables.
+func (c *TypeChecker) funcLit(ctx parser.IFunctionLitContext) operand {
+	decl, err := c.pdata.ParseSignature(ctx.Signature())
+	if err != nil {
+		c.errorf(ctx, "invalid signature %s", ctx.Signature().GetText())
+		return operand{}
+	}
+	c.funcBody(decl, ctx.Block())
+	return operand{mode: modeValue, tp: typesystem.NewFuncType(decl.ArgTypes, decl.ReturnTypes)}
+}
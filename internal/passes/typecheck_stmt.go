@@ -0,0 +1,877 @@
+package passes
+
+import (
+	"fmt"
+	"gocomp/internal/parser"
+	"gocomp/internal/typesystem"
+	"gocomp/internal/utils"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/llir/llvm/ir/types"
+)
+
+func (c *TypeChecker) stmtList(ctx parser.IStatementListContext) {
+	stmts := ctx.AllStatement()
+	for i, stmt := range stmts {
+		if stmt.FallthroughStmt() != nil && i != len(stmts)-1 {
+			c.errorf(stmt, "fallthrough statement out of place")
+		}
+		c.stmt(stmt)
+	}
+}
+
+func (c *TypeChecker) stmt(ctx parser.IStatementContext) {
+	switch {
+	case ctx.Declaration() != nil:
+		c.declaration(ctx.Declaration())
+	case ctx.LabeledStmt() != nil:
+		if ctx.LabeledStmt().Statement() != nil {
+			c.stmt(ctx.LabeledStmt().Statement())
+		}
+	case ctx.SimpleStmt() != nil:
+		c.simpleStmt(ctx.SimpleStmt())
+	case ctx.GoStmt() != nil:
+		c.callStmt(ctx.GoStmt().Expression(), "go")
+	case ctx.DeferStmt() != nil:
+		c.callStmt(ctx.DeferStmt().Expression(), "defer")
+	case ctx.ReturnStmt() != nil:
+		c.returnStmt(ctx.ReturnStmt())
+	case ctx.Block() != nil:
+		c.block(ctx.Block())
+	case ctx.IfStmt() != nil:
+		c.ifStmt(ctx.IfStmt())
+	case ctx.SwitchStmt() != nil:
+		if ctx.SwitchStmt().ExprSwitchStmt() != nil {
+			c.exprSwitchStmt(ctx.SwitchStmt().ExprSwitchStmt())
+		} else {
+			c.typeSwitchStmt(ctx.SwitchStmt().TypeSwitchStmt())
+		}
+	case ctx.SelectStmt() != nil:
+		c.selectStmt(ctx.SelectStmt())
+	case ctx.ForStmt() != nil:
+		c.forStmt(ctx.ForStmt())
+	}
+}
+
+func (c *TypeChecker) block(ctx parser.IBlockContext) {
+	c.openScope()
+	if ctx.StatementList() != nil {
+		c.stmtList(ctx.StatementList())
+	}
+	c.closeScope()
+}
+
+func (c *TypeChecker) declaration(ctx parser.IDeclarationContext) {
+	if ctx.ConstDecl() != nil {
+		// global constants are declared by package listener
+		if c.scope.parent == nil {
+			return
+		}
+		err := c.constEvaluator().EvalConstDecl(ctx.ConstDecl(), func(name string, cst *typesystem.Const) error {
+			c.declare(ctx, &checkObj{kind: objConst, name: name, c: cst, tp: cst.Type()})
+			return nil
+		})
+		if err != nil {
+			c.errorAt(ctx.GetStart(), err)
+		}
+	} else if ctx.VarDecl() != nil {
+		for _, spec := range ctx.VarDecl().AllVarSpec() {
+			c.varSpec(spec)
+		}
+	}
+}
+
+func (c *TypeChecker) varSpec(ctx parser.IVarSpecContext) {
+	ids := ctx.IdentifierList().AllIDENTIFIER()
+	var tp types.Type
+	if ctx.Type_() != nil {
+		tp = c.parseType(ctx.Type_())
+	}
+	tps := make([]types.Type, len(ids))
+	if ctx.ExpressionList() != nil {
+		vals := c.assignedValues(ctx.ExpressionList(), len(ids))
+		for i, x := range vals {
+			if tp != nil {
+				c.assign(x, tp, "variable declaration")
+				tps[i] = tp
+			} else {
+				tps[i] = c.defaultType(x, "variable declaration")
+			}
+		}
+	} else {
+		for i := range tps {
+			tps[i] = tp
+		}
+	}
+	// variables are visible after their declaration
+	for i, id := range ids {
+		c.declareVar(ctx, id.GetText(), tps[i])
+	}
+}
+
+func (c *TypeChecker) simpleStmt(ctx parser.ISimpleStmtContext) {
+	switch {
+	case ctx.ExpressionStmt() != nil:
+		c.exprStmt(ctx.ExpressionStmt().Expression())
+	case ctx.SendStmt() != nil:
+		c.sendStmt(ctx.SendStmt())
+	case ctx.IncDecStmt() != nil:
+		stmt := ctx.IncDecStmt()
+		x := c.lvalue(stmt.Expression())
+		if x.mode != modeInvalid && !isNumeric(x.tp) {
+			c.errorf(stmt, "invalid operation: %s (non-numeric type %s)", stmt.GetText(), c.typeName(x.tp))
+		}
+	case ctx.Assignment() != nil:
+		c.assignment(ctx.Assignment())
+	case ctx.ShortVarDecl() != nil:
+		stmt := ctx.ShortVarDecl()
+		c.shortVarDecl(stmt, stmt.IdentifierList().AllIDENTIFIER(), c.assignedValues(stmt.ExpressionList(), len(stmt.IdentifierList().AllIDENTIFIER())))
+	}
+}
+
+// exprStmt checks expression used as statement, only calls and receives
+// may be used so.
+func (c *TypeChecker) exprStmt(ctx parser.IExpressionContext) {
+	x := c.rawExpr(ctx)
+	if x.mode == modeInvalid || x.mode == modeNoValue || x.mode == modeTuple {
+		return
+	} else if x.mode == modeValue && isRecvExpr(ctx) {
+		return
+	} else if x.call && (x.name == "" || x.name == "copy" || x.name == "recover") {
+		return
+	}
+	c.errorf(ctx, "%s (%s) is not used", ctx.GetText(), c.describe(x))
+}
+
+// callExpr returns call, which is expression, possibly parenthesized.
+func callExpr(ctx parser.IExpressionContext) parser.IPrimaryExprContext {
+	for ctx.PrimaryExpr() != nil && ctx.PrimaryExpr().Operand() != nil && ctx.PrimaryExpr().Operand().Expression() != nil {
+		ctx = ctx.PrimaryExpr().Operand().Expression()
+	}
+	if ctx.PrimaryExpr() != nil && ctx.PrimaryExpr().Arguments() != nil {
+		return ctx.PrimaryExpr()
+	}
+	return nil
+}
+
+// callStmt checks expression of go or defer statement.
+func (c *TypeChecker) callStmt(ctx parser.IExpressionContext, keyword string) {
+	call := callExpr(ctx)
+	if call == nil {
+		c.expr(ctx)
+		c.errorf(ctx, "expression in %s must be function call", keyword)
+		return
+	}
+	x := c.rawExpr(ctx)
+	if x.mode == modeInvalid {
+		return
+	} else if !x.call {
+		c.errorf(ctx, "%s requires function call, not conversion", keyword)
+	} else if x.name != "" && x.name != "copy" && x.name != "recover" && x.mode != modeNoValue {
+		c.errorf(ctx, "%s discards result of %s", keyword, ctx.GetText())
+	}
+}
+
+func (c *TypeChecker) sendStmt(ctx parser.ISendStmtContext) {
+	ch := c.expr(ctx.Expression(0))
+	val := c.expr(ctx.Expression(1))
+	if ch.mode == modeInvalid {
+		return
+	}
+	ctp, ok := typesystem.Underlying(ch.tp).(*typesystem.ChanType)
+	if !ok {
+		c.errorf(ctx, "invalid operation: cannot send to non-channel %s (%s)", ctx.Expression(0).GetText(), c.describe(ch))
+		return
+	} else if ctp.Dir == typesystem.ChanRecv {
+		c.errorf(ctx, "invalid operation: cannot send to receive-only channel %s (%s)", ctx.Expression(0).GetText(), c.describe(ch))
+		return
+	}
+	c.assign(val, ctp.ElemType, "send")
+}
+
+// assignedValues checks expressions assigned to count variables. Single
+// expression may yield several values: call with multiple results or
+// special form with additional boolean.
+func (c *TypeChecker) assignedValues(ctx parser.IExpressionListContext, count int) []operand {
+	exprs := ctx.AllExpression()
+	if len(exprs) == 1 && count > 1 {
+		x := c.rawExpr(exprs[0])
+		switch {
+		case x.mode == modeInvalid:
+			return invalidOperands(count)
+		case x.mode == modeTuple && len(x.tuple) == count:
+			vals := make([]operand, count)
+			for i, tp := range x.tuple {
+				vals[i] = operand{mode: modeValue, tp: tp, expr: exprs[0]}
+			}
+			return vals
+		case x.commaOk && count == 2:
+			if x.mode == modeMapIndex {
+				x.mode = modeValue
+			}
+			return []operand{x, {mode: modeValue, tp: typesystem.Bool, expr: exprs[0]}}
+		case x.mode == modeTuple:
+			c.errorf(ctx, "assignment mismatch: %s but %s returns %s", plural(count, "variable"), exprs[0].GetText(), plural(len(x.tuple), "value"))
+		default:
+			c.errorf(ctx, "assignment mismatch: %s but 1 value", plural(count, "variable"))
+		}
+		return invalidOperands(count)
+	}
+	vals := make([]operand, len(exprs))
+	for i, expr := range exprs {
+		vals[i] = c.expr(expr)
+	}
+	if len(exprs) != count {
+		c.errorf(ctx, "assignment mismatch: %s but %s", plural(count, "variable"), plural(len(exprs), "value"))
+		return invalidOperands(count)
+	}
+	return vals
+}
+
+func invalidOperands(count int) []operand {
+	return make([]operand, count)
+}
+
+// plural returns count of things, like "2 values".
+func plural(count int, thing string) string {
+	if count == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", count, thing)
+}
+
+func (c *TypeChecker) assignment(ctx parser.IAssignmentContext) {
+	lhs := ctx.ExpressionList(0).AllExpression()
+	op := ctx.Assign_op()
+	if op.GetChildCount() > 1 {
+		// compound assignment x op= y
+		if len(lhs) != 1 || len(ctx.ExpressionList(1).AllExpression()) != 1 {
+			c.errorf(ctx, "assignment operation %s requires single-valued expressions", op.GetText())
+			return
+		}
+		x := c.lvalue(lhs[0])
+		y := c.expr(ctx.ExpressionList(1).Expression(0))
+		if x.mode == modeInvalid {
+			return
+		}
+		x.mode = modeValue
+		tok := op.GetChild(0).(antlr.TerminalNode).GetSymbol().GetTokenType()
+		res := c.binary(ctx, tok, x, y, ctx.GetText())
+		if res.mode != modeInvalid {
+			c.assign(res, x.tp, "assignment")
+		}
+		return
+	}
+	targets := make([]operand, len(lhs))
+	for i, expr := range lhs {
+		if isBlank(expr) {
+			targets[i] = operand{mode: modeBlank}
+		} else {
+			targets[i] = c.lvalue(expr)
+		}
+	}
+	vals := c.assignedValues(ctx.ExpressionList(1), len(lhs))
+	for i, x := range vals {
+		switch targets[i].mode {
+		case modeBlank:
+			c.defaultType(x, "assignment")
+		case modeInvalid:
+		default:
+			c.assign(x, targets[i].tp, "assignment")
+		}
+	}
+}
+
+// isBlank checks if expression is blank identifier.
+func isBlank(ctx parser.IExpressionContext) bool {
+	return ctx.GetText() == "_"
+}
+
+// lvalue checks expression, which is assigned to.
+func (c *TypeChecker) lvalue(ctx parser.IExpressionContext) operand {
+	var x operand
+	if name, ok := operandName(ctx); ok {
+		// assignment does not count as use of variable
+		obj, found := c.lookup(name)
+		if !found {
+			c.errorf(ctx, "undefined: %s", name)
+			return operand{}
+		} else if obj.kind != objVar {
+			x = c.expr(ctx)
+		} else {
+			x = operand{mode: modeVar, tp: obj.tp}
+			c.record(ctx, obj.tp)
+		}
+	} else {
+		x = c.expr(ctx)
+	}
+	switch x.mode {
+	case modeInvalid, modeVar, modeMapIndex:
+		return x
+	case modeConst:
+		c.errorf(ctx, "cannot assign to %s (constant)", ctx.GetText())
+	default:
+		c.errorf(ctx, "cannot assign to %s (neither addressable nor a map index expression)", ctx.GetText())
+	}
+	return operand{}
+}
+
+// operandName returns identifier, which is expression.
+func operandName(ctx parser.IExpressionContext) (string, bool) {
+	if p := ctx.PrimaryExpr(); p != nil && p.Operand() != nil && p.Operand().OperandName() != nil {
+		return p.Operand().OperandName().GetText(), true
+	}
+	return "", false
+}
+
+func (c *TypeChecker) shortVarDecl(ctx antlr.ParserRuleContext, ids []antlr.TerminalNode, vals []operand) {
+	isNew := false
+	for i, id := range ids {
+		name := id.GetText()
+		if name == "_" {
+			c.defaultType(vals[i], "assignment")
+			continue
+		}
+		if obj, ok := c.scope.objs[name]; ok {
+			// redeclared variable is assigned
+			if obj.kind == objVar {
+				c.assign(vals[i], obj.tp, "assignment")
+				continue
+			}
+		}
+		isNew = true
+	}
+	if !isNew {
+		c.errorf(ctx, "no new variables on left side of :=")
+	}
+	for i, id := range ids {
+		name := id.GetText()
+		if _, ok := c.scope.objs[name]; ok || name == "_" {
+			continue
+		}
+		c.declareVar(ctx, name, c.defaultType(vals[i], "assignment"))
+	}
+}
+
+func (c *TypeChecker) returnStmt(ctx parser.IReturnStmtContext) {
+	if ctx.ExpressionList() == nil {
+		if len(c.results) > 0 && len(c.resultVars) == 0 {
+			c.errorf(ctx, "not enough return values\n\thave ()\n\twant %s", c.tupleName(c.results))
+		}
+		// bare return needs named results to be visible
+		for _, res := range c.resultVars {
+			if obj, _ := c.lookup(res.name); res.name != "_" && obj != res {
+				inner := res.name
+				if obj.kind == objVar {
+					inner = "var " + res.name + " " + c.typeName(obj.tp)
+				}
+				c.errorf(ctx, "result parameter %s not in scope at return\n\t%s: inner declaration of %s",
+					res.name, utils.Position(obj.decl.GetStart()), inner)
+			}
+		}
+		return
+	}
+	exprs := ctx.ExpressionList().AllExpression()
+	if len(exprs) == 1 && len(c.results) != 1 {
+		x := c.rawExpr(exprs[0])
+		if x.mode == modeTuple && len(x.tuple) == len(c.results) {
+			for i, tp := range x.tuple {
+				c.assign(operand{mode: modeValue, tp: tp, expr: exprs[0]}, c.results[i], "return statement")
+			}
+			return
+		} else if x.mode == modeInvalid {
+			return
+		} else if len(c.results) == 0 {
+			c.errorf(ctx, "too many return values\n\thave (%s)\n\twant ()", c.describeTuple(x))
+			return
+		}
+		c.errorf(ctx, "not enough return values\n\thave (%s)\n\twant %s", c.describeTuple(x), c.tupleName(c.results))
+		return
+	}
+	vals := make([]operand, len(exprs))
+	for i, expr := range exprs {
+		vals[i] = c.expr(expr)
+	}
+	if len(vals) != len(c.results) {
+		msg := "not enough return values"
+		if len(vals) > len(c.results) {
+			msg = "too many return values"
+		}
+		c.errorf(ctx, "%s\n\thave (%s)\n\twant %s", msg, c.haveList(vals), c.tupleName(c.results))
+		return
+	}
+	for i, x := range vals {
+		c.assign(x, c.results[i], "return statement")
+	}
+}
+
+// tupleName returns Go names of types in parentheses.
+func (c *TypeChecker) tupleName(tps []types.Type) string {
+	res := "("
+	for i, tp := range tps {
+		if i > 0 {
+			res += ", "
+		}
+		res += c.typeName(tp)
+	}
+	return res + ")"
+}
+
+// describeTuple returns types of values produced by expression.
+func (c *TypeChecker) describeTuple(x operand) string {
+	if x.mode == modeTuple {
+		name := c.tupleName(x.tuple)
+		return name[1 : len(name)-1]
+	} else if x.mode == modeNoValue {
+		return ""
+	}
+	return c.typeName(x.tp)
+}
+
+func (c *TypeChecker) ifStmt(ctx parser.IIfStmtContext) {
+	c.openScope()
+	defer c.closeScope()
+	if ctx.SimpleStmt() != nil {
+		c.simpleStmt(ctx.SimpleStmt())
+	}
+	c.condition(ctx.Expression(), "if statement")
+	c.block(ctx.Block(0))
+	if ctx.IfStmt() != nil {
+		c.ifStmt(ctx.IfStmt())
+	} else if ctx.Block(1) != nil {
+		c.block(ctx.Block(1))
+	}
+}
+
+// condition checks condition of if or for statement.
+func (c *TypeChecker) condition(ctx parser.IExpressionContext, stmt string) {
+	x := c.expr(ctx)
+	if x.mode != modeInvalid && !typesystem.IsBoolType(x.tp) {
+		c.errorf(ctx, "non-boolean condition in %s", stmt)
+	}
+	c.record(ctx, typesystem.Bool)
+}
+
+func (c *TypeChecker) forStmt(ctx parser.IForStmtContext) {
+	c.openScope()
+	defer c.closeScope()
+	switch {
+	case ctx.ForClause() != nil:
+		clause := ctx.ForClause()
+		if clause.GetInitStmt() != nil {
+			c.simpleStmt(clause.GetInitStmt())
+		}
+		if clause.Expression() != nil {
+			c.condition(clause.Expression(), "for statement")
+		}
+		if post := clause.GetPostStmt(); post != nil {
+			if post.ShortVarDecl() != nil {
+				c.errorf(post, "cannot declare in post statement of for loop")
+			} else {
+				c.simpleStmt(post)
+			}
+		}
+	case ctx.RangeClause() != nil:
+		c.rangeClause(ctx.RangeClause())
+	case ctx.Expression() != nil:
+		c.condition(ctx.Expression(), "for statement")
+	}
+	c.block(ctx.Block())
+}
+
+func (c *TypeChecker) rangeClause(ctx parser.IRangeClauseContext) {
+	x := c.expr(ctx.Expression())
+	var key, elem types.Type
+	count := 2
+	if x.mode == modeConst && x.c.IsUntyped() {
+		x.tp = c.defaultType(x, "range clause")
+	}
+	if x.mode != modeInvalid {
+		tp := typesystem.Underlying(x.tp)
+		if ptp, ok := tp.(*types.PointerType); ok {
+			if atp, ok := typesystem.Underlying(ptp.ElemType).(*types.ArrayType); ok {
+				tp = atp
+			}
+		}
+		switch tp := tp.(type) {
+		case *typesystem.StringType:
+			key, elem = typesystem.Int, typesystem.Rune
+		case *types.ArrayType:
+			key, elem = typesystem.Int, tp.ElemType
+		case *typesystem.SliceType:
+			key, elem = typesystem.Int, tp.ElemType
+		case *typesystem.MapType:
+			key, elem = tp.KeyType, tp.ElemType
+		case *typesystem.ChanType:
+			key, count = tp.ElemType, 1
+			if tp.Dir == typesystem.ChanSend {
+				c.errorf(ctx, "invalid operation: range %s receive from send-only channel", ctx.Expression().GetText())
+			}
+		default:
+			if isInteger(tp) {
+				key, count = x.tp, 1
+			} else {
+				c.errorf(ctx, "cannot range over %s (%s)", ctx.Expression().GetText(), c.describe(x))
+				x.mode = modeInvalid
+			}
+		}
+	}
+	tps := []types.Type{key, elem}
+	if ctx.IdentifierList() != nil {
+		ids := ctx.IdentifierList().AllIDENTIFIER()
+		if len(ids) > count {
+			c.errorf(ctx, "range over %s permits only one iteration variable", ctx.Expression().GetText())
+			return
+		}
+		for i, id := range ids {
+			c.declareVar(ctx, id.GetText(), tps[i])
+		}
+	} else if ctx.ExpressionList() != nil {
+		lhs := ctx.ExpressionList().AllExpression()
+		if len(lhs) > count {
+			c.errorf(ctx, "range over %s permits only one iteration variable", ctx.Expression().GetText())
+			return
+		}
+		for i, expr := range lhs {
+			if isBlank(expr) {
+				continue
+			}
+			y := c.lvalue(expr)
+			if y.mode != modeInvalid && x.mode != modeInvalid {
+				c.assign(operand{mode: modeValue, tp: tps[i], expr: ctx.Expression()}, y.tp, "range")
+			}
+		}
+	}
+}
+
+func (c *TypeChecker) exprSwitchStmt(ctx parser.IExprSwitchStmtContext) {
+	c.openScope()
+	defer c.closeScope()
+	if ctx.SimpleStmt() != nil {
+		c.simpleStmt(ctx.SimpleStmt())
+	}
+	tag := operand{mode: modeValue, tp: typesystem.Bool}
+	if ctx.Expression() != nil {
+		tag = c.expr(ctx.Expression())
+		if tag.mode != modeInvalid {
+			tag.tp = c.defaultType(tag, "switch expression")
+			tag.mode = modeValue
+		}
+	}
+	seen := make(map[string]bool)
+	clauses := ctx.AllExprCaseClause()
+	for i, clause := range clauses {
+		if list := clause.ExprSwitchCase().ExpressionList(); list != nil {
+			for _, expr := range list.AllExpression() {
+				x := c.expr(expr)
+				if tag.mode == modeInvalid || x.mode == modeInvalid {
+					continue
+				}
+				if ctx.Expression() == nil {
+					if !typesystem.IsBoolType(x.tp) {
+						c.errorf(expr, "invalid case %s in switch (mismatched types %s and bool)", expr.GetText(), c.typeName(x.tp))
+					}
+					continue
+				}
+				if res := c.compare(expr, parser.GoParserEQUALS, x, tag, expr.GetText()); res.mode == modeInvalid {
+					continue
+				}
+				if x.mode == modeConst {
+					key := x.c.Val.ExactString()
+					if seen[key] {
+						c.errorf(expr, "duplicate case %s in expression switch", expr.GetText())
+					}
+					seen[key] = true
+				}
+			}
+		}
+		c.caseBody(clause.StatementList(), i == len(clauses)-1)
+	}
+}
+
+// caseBody checks statements of switch clause in their own scope.
+func (c *TypeChecker) caseBody(ctx parser.IStatementListContext, last bool) {
+	c.openScope()
+	defer c.closeScope()
+	if ctx == nil {
+		return
+	}
+	c.stmtList(ctx)
+	stmts := ctx.AllStatement()
+	if stmt := stmts[len(stmts)-1]; stmt.FallthroughStmt() != nil && last {
+		c.errorf(stmt, "cannot fallthrough final case in switch")
+	}
+}
+
+func (c *TypeChecker) typeSwitchStmt(ctx parser.ITypeSwitchStmtContext) {
+	c.openScope()
+	defer c.closeScope()
+	if ctx.SimpleStmt() != nil {
+		c.simpleStmt(ctx.SimpleStmt())
+	}
+	guard := ctx.TypeSwitchGuard()
+	x := c.primary(guard.PrimaryExpr())
+	itp, ok := x.tp.(*typesystem.InterfaceType)
+	if x.mode != modeInvalid && !ok {
+		c.errorf(guard, "%s (%s) is not an interface", guard.PrimaryExpr().GetText(), c.describe(x))
+		x.mode = modeInvalid
+	}
+	var used *bool
+	var symbol string
+	if guard.IDENTIFIER() != nil {
+		symbol = guard.IDENTIFIER().GetText()
+		used = new(bool)
+	}
+	for _, clause := range ctx.AllTypeCaseClause() {
+		var caseTypes []types.Type
+		if list := clause.TypeSwitchCase().TypeList(); list != nil {
+			for _, child := range list.GetChildren() {
+				switch child := child.(type) {
+				case parser.IType_Context:
+					tp := c.parseType(child)
+					if tp != nil && x.mode != modeInvalid && !typesystem.IsInterfaceType(tp) {
+						if _, err := c.pdata.implements(tp, itp); err != nil {
+							c.errorf(child, "impossible type switch case: %s cannot have dynamic type %s (%s)", guard.PrimaryExpr().GetText(), c.typeName(tp), err)
+						}
+					}
+					caseTypes = append(caseTypes, tp)
+				case antlr.TerminalNode:
+					if child.GetSymbol().GetTokenType() == parser.GoParserNIL_LIT {
+						caseTypes = append(caseTypes, nil)
+					}
+				}
+			}
+		}
+		c.openScope()
+		if symbol != "" {
+			// symbol has case type in single-type clauses, type of guard otherwise
+			tp := x.tp
+			if len(caseTypes) == 1 && caseTypes[0] != nil {
+				tp = caseTypes[0]
+			}
+			obj := &checkObj{kind: objVar, name: symbol, tp: tp, used: used}
+			c.declare(guard, obj)
+		}
+		if clause.StatementList() != nil {
+			c.stmtList(clause.StatementList())
+		}
+		// usage of symbol is reported for all clauses together
+		delete(c.scope.objs, symbol)
+		c.closeScope()
+	}
+	if used != nil && !*used {
+		c.errorf(guard, "declared and not used: %s", symbol)
+	}
+}
+
+func (c *TypeChecker) selectStmt(ctx parser.ISelectStmtContext) {
+	for _, clause := range ctx.AllCommClause() {
+		c.openScope()
+		comm := clause.CommCase()
+		if comm.SendStmt() != nil {
+			c.sendStmt(comm.SendStmt())
+		} else if recv := comm.RecvStmt(); recv != nil {
+			c.recvStmt(recv)
+		}
+		if clause.StatementList() != nil {
+			c.stmtList(clause.StatementList())
+		}
+		c.closeScope()
+	}
+}
+
+func (c *TypeChecker) recvStmt(ctx parser.IRecvStmtContext) {
+	expr := ctx.GetRecvExpr()
+	if !isRecvExpr(expr) {
+		c.errorf(ctx, "select case must be receive, send or assign recv")
+		return
+	}
+	count := 1
+	if ctx.IdentifierList() != nil {
+		count = len(ctx.IdentifierList().AllIDENTIFIER())
+	} else if ctx.ExpressionList() != nil {
+		count = len(ctx.ExpressionList().AllExpression())
+	}
+	if count > 2 {
+		c.errorf(ctx, "assignment mismatch: %d variables but %s returns 2 values", count, expr.GetText())
+		return
+	}
+	x := c.rawExpr(expr)
+	vals := []operand{x, {mode: modeValue, tp: typesystem.Bool, expr: expr}}
+	if ctx.IdentifierList() != nil {
+		c.shortVarDecl(ctx, ctx.IdentifierList().AllIDENTIFIER(), vals[:count])
+	} else if ctx.ExpressionList() != nil {
+		for i, lhs := range ctx.ExpressionList().AllExpression() {
+			if isBlank(lhs) {
+				continue
+			}
+			if y := c.lvalue(lhs); y.mode != modeInvalid {
+				c.assign(vals[i], y.tp, "assignment")
+			}
+		}
+	}
+}
+
+// isTerminatingList checks if statement list ends with terminating statement.
+func (c *TypeChecker) isTerminatingList(ctx parser.IStatementListContext, label string) bool {
+	if ctx == nil {
+		return false
+	}
+	stmts := ctx.AllStatement()
+	// trailing empty statements are ignored
+	for len(stmts) > 0 && stmts[len(stmts)-1].GetText() == "" {
+		stmts = stmts[:len(stmts)-1]
+	}
+	return len(stmts) > 0 && c.isTerminating(stmts[len(stmts)-1], label)
+}
+
+// isTerminating checks if statement prevents execution of statements after it.
+func (c *TypeChecker) isTerminating(ctx parser.IStatementContext, label string) bool {
+	switch {
+	case ctx.ReturnStmt() != nil, ctx.GotoStmt() != nil:
+		return true
+	case ctx.SimpleStmt() != nil:
+		// call of panic
+		stmt := ctx.SimpleStmt().ExpressionStmt()
+		if stmt == nil {
+			return false
+		}
+		call := callExpr(stmt.Expression())
+		if call == nil {
+			return false
+		}
+		callee := call.PrimaryExpr()
+		if callee.Operand() == nil || callee.Operand().OperandName() == nil {
+			return false
+		}
+		obj, found := c.lookup(callee.Operand().OperandName().GetText())
+		return found && obj.kind == objBuiltin && obj.name == "panic"
+	case ctx.Block() != nil:
+		return c.isTerminatingList(ctx.Block().StatementList(), "")
+	case ctx.IfStmt() != nil:
+		return c.isTerminatingIf(ctx.IfStmt())
+	case ctx.LabeledStmt() != nil:
+		stmt := ctx.LabeledStmt()
+		return stmt.Statement() != nil && c.isTerminating(stmt.Statement(), stmt.IDENTIFIER().GetText())
+	case ctx.ForStmt() != nil:
+		stmt := ctx.ForStmt()
+		if stmt.RangeClause() != nil || stmt.Expression() != nil {
+			return false
+		} else if stmt.ForClause() != nil && stmt.ForClause().Expression() != nil {
+			return false
+		}
+		return !hasBreak(stmt.Block().StatementList(), label, true)
+	case ctx.SwitchStmt() != nil:
+		var bodies []parser.IStatementListContext
+		hasDefault := false
+		if sw := ctx.SwitchStmt().ExprSwitchStmt(); sw != nil {
+			for _, clause := range sw.AllExprCaseClause() {
+				hasDefault = hasDefault || clause.ExprSwitchCase().DEFAULT() != nil
+				bodies = append(bodies, clause.StatementList())
+			}
+		} else {
+			for _, clause := range ctx.SwitchStmt().TypeSwitchStmt().AllTypeCaseClause() {
+				hasDefault = hasDefault || clause.TypeSwitchCase().DEFAULT() != nil
+				bodies = append(bodies, clause.StatementList())
+			}
+		}
+		return hasDefault && c.terminatingClauses(bodies, label)
+	case ctx.SelectStmt() != nil:
+		var bodies []parser.IStatementListContext
+		for _, clause := range ctx.SelectStmt().AllCommClause() {
+			bodies = append(bodies, clause.StatementList())
+		}
+		return c.terminatingClauses(bodies, label)
+	}
+	return false
+}
+
+// isTerminatingIf checks if both branches of if statement are terminating.
+func (c *TypeChecker) isTerminatingIf(ctx parser.IIfStmtContext) bool {
+	if ctx.ELSE() == nil || !c.isTerminatingList(ctx.Block(0).StatementList(), "") {
+		return false
+	} else if ctx.IfStmt() != nil {
+		return c.isTerminatingIf(ctx.IfStmt())
+	}
+	return c.isTerminatingList(ctx.Block(1).StatementList(), "")
+}
+
+// terminatingClauses checks that all clauses of switch or select end in
+// terminating statement or fallthrough, and there are no breaks.
+func (c *TypeChecker) terminatingClauses(bodies []parser.IStatementListContext, label string) bool {
+	for _, body := range bodies {
+		if body == nil || hasBreak(body, label, true) {
+			return false
+		}
+		stmts := body.AllStatement()
+		if stmts[len(stmts)-1].FallthroughStmt() == nil && !c.isTerminatingList(body, "") {
+			return false
+		}
+	}
+	return true
+}
+
+// hasBreak checks if statements contain break referring to enclosing statement
+// with label. Unlabeled breaks count only outside of nested breakable statements.
+func hasBreak(ctx parser.IStatementListContext, label string, implicit bool) bool {
+	if ctx == nil {
+		return false
+	}
+	for _, stmt := range ctx.AllStatement() {
+		if stmtHasBreak(stmt, label, implicit) {
+			return true
+		}
+	}
+	return false
+}
+
+func stmtHasBreak(ctx parser.IStatementContext, label string, implicit bool) bool {
+	switch {
+	case ctx.BreakStmt() != nil:
+		if ctx.BreakStmt().IDENTIFIER() == nil {
+			return implicit
+		}
+		return ctx.BreakStmt().IDENTIFIER().GetText() == label
+	case ctx.Block() != nil:
+		return hasBreak(ctx.Block().StatementList(), label, implicit)
+	case ctx.LabeledStmt() != nil:
+		return ctx.LabeledStmt().Statement() != nil && stmtHasBreak(ctx.LabeledStmt().Statement(), label, implicit)
+	case ctx.IfStmt() != nil:
+		for stmt := ctx.IfStmt(); stmt != nil; stmt = stmt.IfStmt() {
+			for _, block := range stmt.AllBlock() {
+				if hasBreak(block.StatementList(), label, implicit) {
+					return true
+				}
+			}
+		}
+	case ctx.ForStmt() != nil:
+		return label != "" && hasBreak(ctx.ForStmt().Block().StatementList(), label, false)
+	case ctx.SwitchStmt() != nil:
+		if label == "" {
+			return false
+		}
+		if sw := ctx.SwitchStmt().ExprSwitchStmt(); sw != nil {
+			for _, clause := range sw.AllExprCaseClause() {
+				if hasBreak(clause.StatementList(), label, false) {
+					return true
+				}
+			}
+		} else {
+			for _, clause := range ctx.SwitchStmt().TypeSwitchStmt().AllTypeCaseClause() {
+				if hasBreak(clause.StatementList(), label, false) {
+					return true
+				}
+			}
+		}
+	case ctx.SelectStmt() != nil:
+		if label == "" {
+			return false
+		}
+		for _, clause := range ctx.SelectStmt().AllCommClause() {
+			if hasBreak(clause.StatementList(), label, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
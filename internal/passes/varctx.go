@@ -34,3 +34,21 @@ func (ctx *VariableContext) Add(name string, val value.Value) error {
 	ctx.vars[name] = val
 	return nil
 }
+
+// LookupLocal finds variable declared inside function, skipping global scope.
+func (ctx *VariableContext) LookupLocal(name string) (value.Value, bool) {
+	if ctx.Parent == nil {
+		return nil, false
+	} else if v, ok := ctx.vars[name]; ok {
+		return v, true
+	}
+	return ctx.Parent.LookupLocal(name)
+}
+
+// Root returns global scope.
+func (ctx *VariableContext) Root() *VariableContext {
+	for ctx.Parent != nil {
+		ctx = ctx.Parent
+	}
+	return ctx
+}
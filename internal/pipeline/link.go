@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"gocomp/internal/utils"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+)
+
+// linkModules merges modules of packages into module of program. Declarations
+// are replaced by definitions. Definitions shared by packages (type
+// descriptors, wrappers, zerobase) have linkonce_odr linkage and are kept
+// once, any other symbol defined twice is an error.
+func linkModules(modules []*ir.Module) (*ir.Module, error) {
+	prog := ir.NewModule()
+	typeDefs := make(map[string]bool)
+	globals := make(map[string]int)
+	funcs := make(map[string]int)
+	for _, m := range modules {
+		for _, tp := range m.TypeDefs {
+			if !typeDefs[tp.Name()] {
+				typeDefs[tp.Name()] = true
+				prog.TypeDefs = append(prog.TypeDefs, tp)
+			}
+		}
+		for _, glob := range m.Globals {
+			i, ok := globals[glob.Name()]
+			if !ok {
+				globals[glob.Name()] = len(prog.Globals)
+				prog.Globals = append(prog.Globals, glob)
+				continue
+			}
+			prev := prog.Globals[i]
+			if !prev.ContentType.Equal(glob.ContentType) {
+				return nil, utils.MakeError("conflicting types of global %s: %s and %s", glob.Ident(), prev.ContentType, glob.ContentType)
+			} else if prev.Init == nil {
+				prog.Globals[i] = glob
+			} else if glob.Init != nil && !linkOnce(prev.Linkage, glob.Linkage) {
+				return nil, utils.MakeError("duplicate definition of global %s", glob.Ident())
+			}
+		}
+		for _, fun := range m.Funcs {
+			i, ok := funcs[fun.Name()]
+			if !ok {
+				funcs[fun.Name()] = len(prog.Funcs)
+				prog.Funcs = append(prog.Funcs, fun)
+				continue
+			}
+			prev := prog.Funcs[i]
+			if !prev.Sig.Equal(fun.Sig) {
+				return nil, utils.MakeError("conflicting signatures of function %s: %s and %s", fun.Ident(), prev.Sig, fun.Sig)
+			} else if len(prev.Blocks) == 0 {
+				prog.Funcs[i] = fun
+			} else if len(fun.Blocks) > 0 && !linkOnce(prev.Linkage, fun.Linkage) {
+				return nil, utils.MakeError("duplicate definition of function %s", fun.Ident())
+			}
+		}
+	}
+	for _, fun := range prog.Funcs {
+		fun.Parent = prog
+	}
+	return prog, nil
+}
+
+// linkOnce checks if both definitions of symbol may be merged.
+func linkOnce(a, b enum.Linkage) bool {
+	return a == enum.LinkageLinkOnceODR && b == enum.LinkageLinkOnceODR
+}
@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"bufio"
+	"gocomp/internal/parser"
+	"gocomp/internal/passes"
+	"gocomp/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+)
+
+// loader compiles packages of module imported by program, each of them once.
+type loader struct {
+	root       string // directory of go.mod
+	modulePath string // empty without go.mod, only std packages are imported
+
+	// compiled packages in dependency order, which is order of initialization
+	packages []*passes.PackageData
+	modules  []*ir.Module
+	loaded   map[string]*passes.PackageData
+
+	// import paths of packages being loaded, to detect import cycles
+	loading []string
+}
+
+// ProcessDir compiles program, which main package is in directory dir.
+// Import paths of packages are mapped to directories by go.mod of module,
+// directory outside of module makes program of standard packages only.
+func ProcessDir(dir string) (*ir.Module, error) {
+	root, modulePath, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &loader{
+		root:       root,
+		modulePath: modulePath,
+		loaded:     make(map[string]*passes.PackageData),
+	}
+	files, err := parsePackageDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if name := files[0].PackageClause().GetPackageName().GetText(); name != "main" {
+		return nil, utils.MakeError("package %s is not a main package", name)
+	}
+	if err := l.importAll(files); err != nil {
+		return nil, err
+	}
+	module, _, err := compilePackage(files, "main", l.packages)
+	if err != nil {
+		return nil, err
+	}
+	return linkModules(append(l.modules, module))
+}
+
+// findModule finds go.mod in dir or its parents and returns its directory
+// and module path. Without go.mod, dir is root of module with empty path.
+func findModule(dir string) (string, string, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	dir = start
+	for {
+		f, err := os.Open(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) == 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], "\""), nil
+				}
+			}
+			return "", "", utils.MakeError("%s: no module declaration", filepath.Join(dir, "go.mod"))
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return start, "", nil
+		}
+		dir = parent
+	}
+}
+
+// parsePackageDir parses source files of package in dir, sorted by name.
+// Test files are skipped.
+func parsePackageDir(dir string) ([]parser.ISourceFileContext, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []parser.ISourceFileContext
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := ParseFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, utils.MakeError("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+// importAll compiles packages imported by source files, which are not compiled yet.
+func (l *loader) importAll(files []parser.ISourceFileContext) error {
+	for _, path := range importPaths(files) {
+		if err := l.importPackage(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importPackage compiles package with import path after packages it imports.
+func (l *loader) importPackage(path string) error {
+	if _, ok := l.loaded[path]; ok {
+		return nil
+	}
+	for i, loading := range l.loading {
+		if loading == path {
+			cycle := append(append([]string{}, l.loading[i:]...), path)
+			return utils.MakeError("import cycle not allowed: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if passes.IsStdlibPackage(path) {
+		l.add(passes.NewStdlibPackage(path), nil)
+		return nil
+	}
+	rel, ok := strings.CutPrefix(path, l.modulePath)
+	if !ok || l.modulePath == "" || rel != "" && !strings.HasPrefix(rel, "/") {
+		return utils.MakeError("package %s is not in std", path)
+	}
+	files, err := parsePackageDir(filepath.Join(l.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return utils.MakeError("cannot find package %s: %w", path, err)
+	}
+	if files[0].PackageClause().GetPackageName().GetText() == "main" {
+		return utils.MakeError("import %q is a program, not an importable package", path)
+	}
+
+	l.loading = append(l.loading, path)
+	if err := l.importAll(files); err != nil {
+		return err
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+
+	module, pdata, err := compilePackage(files, path, l.packages)
+	if err != nil {
+		return utils.MakeError("package %s:\n%w", path, err)
+	}
+	l.add(pdata, module)
+	return nil
+}
+
+// add records compiled package and its module.
+func (l *loader) add(pdata *passes.PackageData, module *ir.Module) {
+	l.loaded[pdata.Path] = pdata
+	// packages compiled later must not change list seen by earlier ones
+	l.packages = append(l.packages[:len(l.packages):len(l.packages)], pdata)
+	if module != nil {
+		l.modules = append(l.modules, module)
+	}
+}
@@ -1,27 +1,128 @@
 package pipeline
 
 import (
+	"errors"
+	"fmt"
 	"gocomp/internal/parser"
 	"gocomp/internal/passes"
+	"gocomp/internal/utils"
+	"strings"
 
 	"github.com/antlr4-go/antlr/v4"
 	"github.com/llir/llvm/ir"
 )
 
+// ProcessTree compiles program of single source file.
 func ProcessTree(ctx parser.ISourceFileContext) (*ir.Module, error) {
-	pass1 := passes.NewPackageListener()
-	antlr.ParseTreeWalkerDefault.Walk(pass1, ctx)
-	result, err := pass1.PackageData()
+	return ProcessFiles([]parser.ISourceFileContext{ctx})
+}
+
+// ProcessFiles compiles program of main package made of source files, which
+// may import packages of standard library only.
+func ProcessFiles(files []parser.ISourceFileContext) (*ir.Module, error) {
+	var packages []*passes.PackageData
+	seen := make(map[string]bool)
+	for _, path := range importPaths(files) {
+		if passes.IsStdlibPackage(path) && !seen[path] {
+			seen[path] = true
+			packages = append(packages, passes.NewStdlibPackage(path))
+		}
+	}
+	module, _, err := compilePackage(files, "main", packages)
+	return module, err
+}
+
+// ParseFile parses Go source file, positions in diagnostics refer to its name.
+func ParseFile(name string) (parser.ISourceFileContext, error) {
+	input, err := antlr.NewFileStream(name)
 	if err != nil {
 		return nil, err
 	}
+	return ParseStream(input, name)
+}
+
+// ParseStream parses Go source file read from input, syntax errors are
+// reported at positions in file with given name.
+func ParseStream(input antlr.CharStream, name string) (parser.ISourceFileContext, error) {
+	listener := &syntaxErrorListener{name: name}
+	lexer := parser.NewGoLexer(input)
+	lexer.RemoveErrorListeners()
+	lexer.AddErrorListener(listener)
+	tokenStream := antlr.NewCommonTokenStream(lexer, antlr.LexerDefaultTokenChannel)
+	p := parser.NewGoParser(tokenStream)
+	p.RemoveErrorListeners()
+	p.AddErrorListener(listener)
+	tree := p.SourceFile()
+	if len(listener.errs) > 0 {
+		return nil, errors.Join(listener.errs...)
+	}
+	return tree, nil
+}
+
+// maxSyntaxErrors limits number of syntax errors reported for a file.
+const maxSyntaxErrors = 10
+
+// syntaxErrorListener collects syntax errors reported by lexer and parser.
+type syntaxErrorListener struct {
+	*antlr.DefaultErrorListener
+	name string
+	errs []error
+}
+
+func (l *syntaxErrorListener) SyntaxError(_ antlr.Recognizer, offendingSymbol any, line, column int, msg string, _ antlr.RecognitionException) {
+	if len(l.errs) > maxSyntaxErrors {
+		return
+	} else if len(l.errs) == maxSyntaxErrors {
+		l.errs = append(l.errs, utils.MakeError("too many errors"))
+		return
+	}
+	pos := fmt.Sprintf("%s:%d:%d", l.name, line, column)
+	if tok, ok := offendingSymbol.(antlr.Token); ok {
+		pos = utils.Position(tok)
+	}
+	l.errs = append(l.errs, utils.MakeError("%s: syntax error: %s", pos, msg))
+}
+
+// compilePackage compiles source files of package with import path. Packages
+// it imports must be among packages compiled before.
+func compilePackage(files []parser.ISourceFileContext, path string, packages []*passes.PackageData) (*ir.Module, *passes.PackageData, error) {
+	pass1 := passes.NewPackageListener(path, packages)
+	for _, ctx := range files {
+		antlr.ParseTreeWalkerDefault.Walk(pass1, ctx)
+	}
+	result, err := pass1.PackageData()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// ast1, _ := json.MarshalIndent(result, "    ", "  ")
 	// fmt.Printf("package data:\n%s\n", ast1)
 
+	checker := passes.NewTypeChecker(result)
+	if err := checker.Check(files); err != nil {
+		return nil, nil, err
+	}
+
 	pass2, err := passes.NewCodeGenVisitor(result)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	module, err := pass2.VisitSourceFiles(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	return module, result, nil
+}
+
+// importPaths returns import paths of source files in order of declaration.
+func importPaths(files []parser.ISourceFileContext) []string {
+	var paths []string
+	for _, ctx := range files {
+		for _, imp := range ctx.AllImportDecl() {
+			for _, spec := range imp.AllImportSpec() {
+				paths = append(paths, strings.Trim(spec.ImportPath().GetText(), "\""))
+			}
+		}
 	}
-	return pass2.VisitSourceFile(ctx)
+	return paths
 }
@@ -0,0 +1,44 @@
+package typesystem
+
+import (
+	"github.com/llir/llvm/ir/types"
+)
+
+// ChanDir is direction of channel type.
+type ChanDir int
+
+const (
+	ChanBoth ChanDir = iota
+	ChanSend
+	ChanRecv
+)
+
+// ChanType describes channel of ElemType values.
+// Channel value is pointer to runtime channel, nil for zero value.
+type ChanType struct {
+	types.PointerType
+
+	ElemType types.Type
+	Dir      ChanDir
+}
+
+func NewChanType(elemType types.Type, dir ChanDir) *ChanType {
+	return &ChanType{
+		PointerType: *types.NewPointer(types.I8),
+		ElemType:    elemType,
+		Dir:         dir,
+	}
+}
+
+// Equal reports whether t and u are channels of equal element types and directions.
+func (ct *ChanType) Equal(u types.Type) bool {
+	if uct, ok := u.(*ChanType); ok {
+		return ct.Dir == uct.Dir && ct.ElemType.Equal(uct.ElemType)
+	}
+	return false
+}
+
+func IsChanType(t types.Type) bool {
+	_, ok := Underlying(t).(*ChanType)
+	return ok
+}
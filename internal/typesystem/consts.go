@@ -0,0 +1,122 @@
+package typesystem
+
+import (
+	"fmt"
+	goconstant "go/constant"
+	"go/token"
+	"math"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+// Const is value of constant expression, kept with arbitrary precision.
+// Untyped constants have nil Typ and get type of context they are used in,
+// or their default type.
+type Const struct {
+	Val goconstant.Value
+	Typ types.Type
+	// untyped constant is rune literal or result of operation on one
+	Rune bool
+}
+
+func NewConst(val goconstant.Value, tp types.Type) *Const {
+	return &Const{
+		Val: val,
+		Typ: tp,
+	}
+}
+
+func (c *Const) IsUntyped() bool {
+	return c.Typ == nil
+}
+
+// DefaultConstType returns type of untyped constant used without context type.
+func DefaultConstType(c *Const) types.Type {
+	switch c.Val.Kind() {
+	case goconstant.Bool:
+		return Bool
+	case goconstant.String:
+		return String
+	case goconstant.Float:
+		return Float64
+	}
+	if c.Rune {
+		return Rune
+	}
+	return Int
+}
+
+func (c *Const) Type() types.Type {
+	if c.Typ != nil {
+		return c.Typ
+	}
+	return DefaultConstType(c)
+}
+
+// IR returns LLVM constant of constant's type. Strings have no LLVM constant
+// form, they must be materialized by code generator.
+func (c *Const) IR() constant.Constant {
+	tp := c.Type()
+	if c.Val.Kind() == goconstant.Bool {
+		return constant.NewBool(goconstant.BoolVal(c.Val))
+	} else if itp, ok := UnderlyingIntType(tp); ok {
+		val := goconstant.ToInt(c.Val)
+		i, exact := goconstant.Int64Val(val)
+		if !exact {
+			// large unsigned values are stored in two's complement
+			u, _ := goconstant.Uint64Val(val)
+			i = int64(u)
+		}
+		return constant.NewInt(itp, i)
+	} else if ftp, ok := Underlying(tp).(*types.FloatType); ok {
+		f, _ := goconstant.Float64Val(goconstant.ToFloat(c.Val))
+		return constant.NewFloat(ftp, f)
+	}
+	panic(fmt.Sprintf("constant %s of type %s has no LLVM representation", c.Val, tp))
+}
+
+func (c *Const) Ident() string {
+	return c.IR().Ident()
+}
+
+func (c *Const) String() string {
+	return fmt.Sprintf("%s %s", c.Type(), c.Ident())
+}
+
+func (c *Const) IsConstant() {}
+
+// ConvertConst converts constant value to basic type tp. Reports false if
+// value can't be represented by tp. Floats are rounded to precision of tp.
+func ConvertConst(val goconstant.Value, tp types.Type) (goconstant.Value, bool) {
+	if IsBoolType(tp) {
+		return val, val.Kind() == goconstant.Bool
+	} else if IsStringType(tp) {
+		return val, val.Kind() == goconstant.String
+	} else if val.Kind() != goconstant.Int && val.Kind() != goconstant.Float {
+		return val, false
+	}
+	if itp, ok := UnderlyingIntType(tp); ok {
+		ival := goconstant.ToInt(val)
+		if ival.Kind() != goconstant.Int {
+			return val, false
+		}
+		one := goconstant.MakeInt64(1)
+		lo := goconstant.MakeInt64(0)
+		hi := goconstant.Shift(one, token.SHL, uint(itp.BitSize))
+		if !IsUintType(tp) {
+			hi = goconstant.Shift(one, token.SHL, uint(itp.BitSize-1))
+			lo = goconstant.UnaryOp(token.SUB, hi, 0)
+		}
+		return ival, goconstant.Compare(ival, token.GEQ, lo) && goconstant.Compare(ival, token.LSS, hi)
+	} else if ftp, ok := Underlying(tp).(*types.FloatType); ok {
+		fval := goconstant.ToFloat(val)
+		if ftp.Kind == types.FloatKindFloat {
+			f, _ := goconstant.Float32Val(fval)
+			return goconstant.MakeFloat64(float64(f)), !math.IsInf(float64(f), 0)
+		}
+		f, _ := goconstant.Float64Val(fval)
+		return goconstant.MakeFloat64(f), !math.IsInf(f, 0)
+	}
+	return val, false
+}
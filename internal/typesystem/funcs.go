@@ -0,0 +1,71 @@
+package typesystem
+
+import "github.com/llir/llvm/ir/types"
+
+// OutParamResults selects calling convention of functions with multiple
+// results. By default results are returned in struct {T1, T2, ...}, with the
+// flag set they are stored through out parameters preceding regular ones.
+var OutParamResults bool
+
+// ResultType returns IR return type of function with results retTypes.
+func ResultType(retTypes []types.Type) types.Type {
+	if len(retTypes) == 1 {
+		return retTypes[0]
+	} else if len(retTypes) == 0 || OutParamResults {
+		return types.Void
+	}
+	return types.NewStruct(retTypes...)
+}
+
+// OutParams returns types of out parameters of function with results retTypes.
+func OutParams(retTypes []types.Type) []types.Type {
+	var params []types.Type
+	if OutParamResults && len(retTypes) > 1 {
+		for _, tp := range retTypes {
+			params = append(params, types.NewPointer(tp))
+		}
+	}
+	return params
+}
+
+// FuncType describes func value {code, env}. Code points to function, which
+// takes pointer to environment of captured variables after out parameters of
+// multiple results, if any, and before regular arguments. Env is nil for functions
+// without captured variables.
+type FuncType struct {
+	types.StructType
+
+	ArgTypes    []types.Type
+	ReturnTypes []types.Type
+	// last parameter is ...T, extra arguments are passed in slice []T
+	Variadic bool
+}
+
+func NewFuncType(argTypes, returnTypes []types.Type) *FuncType {
+	return &FuncType{
+		StructType:  *types.NewStruct(types.I8Ptr, types.I8Ptr),
+		ArgTypes:    argTypes,
+		ReturnTypes: returnTypes,
+	}
+}
+
+// Equal reports whether t and u are func types with identical signatures.
+func (ft *FuncType) Equal(u types.Type) bool {
+	if uft, ok := u.(*FuncType); ok {
+		return ft.Variadic == uft.Variadic && typesEqual(ft.ArgTypes, uft.ArgTypes) && typesEqual(ft.ReturnTypes, uft.ReturnTypes)
+	}
+	return false
+}
+
+// CodeType returns type of pointer to code of func values.
+func (ft *FuncType) CodeType() *types.PointerType {
+	params := OutParams(ft.ReturnTypes)
+	params = append(params, types.I8Ptr)
+	params = append(params, ft.ArgTypes...)
+	return types.NewPointer(types.NewFunc(ResultType(ft.ReturnTypes), params...))
+}
+
+func IsFuncType(t types.Type) bool {
+	_, ok := Underlying(t).(*FuncType)
+	return ok
+}
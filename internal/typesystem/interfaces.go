@@ -0,0 +1,97 @@
+package typesystem
+
+import (
+	"sort"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// InterfaceType describes interface with method set Methods, sorted by name.
+// Interface value is pair {itab, data}, where itab points to dynamic type and
+// its methods (nil for nil interface) and data points to copy of dynamic value.
+type InterfaceType struct {
+	types.StructType
+
+	// name of declared interface type, empty for interface literals
+	TypeName string
+	Pkg      string // import path of package declaring named interface
+	Methods  []InterfaceMethod
+}
+
+// InterfaceMethod describes signature of interface method, without receiver.
+type InterfaceMethod struct {
+	Name        string
+	ArgTypes    []types.Type
+	ReturnTypes []types.Type
+}
+
+var (
+	// Any is predeclared empty interface 'any'.
+	Any = NewInterfaceType(nil)
+	// Error is predeclared 'error' interface.
+	Error = &InterfaceType{
+		StructType: *types.NewStruct(types.I8Ptr, types.I8Ptr),
+		TypeName:   "error",
+		Methods: []InterfaceMethod{
+			{Name: "Error", ReturnTypes: []types.Type{String}},
+		},
+	}
+)
+
+func NewInterfaceType(methods []InterfaceMethod) *InterfaceType {
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+	return &InterfaceType{
+		StructType: *types.NewStruct(types.I8Ptr, types.I8Ptr),
+		Methods:    methods,
+	}
+}
+
+// Equal reports whether t and u are interfaces with identical method sets.
+func (it *InterfaceType) Equal(u types.Type) bool {
+	uit, ok := u.(*InterfaceType)
+	if ok && it == uit {
+		return true
+	} else if !ok || len(it.Methods) != len(uit.Methods) {
+		return false
+	}
+	for i, m := range it.Methods {
+		if !m.Equal(uit.Methods[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MethodIndex returns position of method in method table of interface.
+func (it *InterfaceType) MethodIndex(name string) (int, bool) {
+	for i, m := range it.Methods {
+		if m.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Equal reports whether methods have the same names and signatures.
+func (m InterfaceMethod) Equal(u InterfaceMethod) bool {
+	return m.Name == u.Name && typesEqual(m.ArgTypes, u.ArgTypes) && typesEqual(m.ReturnTypes, u.ReturnTypes)
+}
+
+func typesEqual(ts, us []types.Type) bool {
+	if len(ts) != len(us) {
+		return false
+	}
+	for i := range ts {
+		if !ts[i].Equal(us[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func IsInterfaceType(t types.Type) bool {
+	_, ok := Underlying(t).(*InterfaceType)
+	return ok
+}
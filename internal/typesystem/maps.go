@@ -0,0 +1,55 @@
+package typesystem
+
+import (
+	"github.com/llir/llvm/ir/types"
+)
+
+// MapType describes map from KeyType to ElemType.
+// Map value is pointer to runtime hash table, nil for zero value.
+type MapType struct {
+	types.PointerType
+
+	KeyType  types.Type
+	ElemType types.Type
+}
+
+func NewMapType(keyType, elemType types.Type) *MapType {
+	return &MapType{
+		PointerType: *types.NewPointer(types.I8),
+		KeyType:     keyType,
+		ElemType:    elemType,
+	}
+}
+
+// Equal reports whether t and u are maps of equal key and element types.
+func (mt *MapType) Equal(u types.Type) bool {
+	if umt, ok := u.(*MapType); ok {
+		return mt.KeyType.Equal(umt.KeyType) && mt.ElemType.Equal(umt.ElemType)
+	}
+	return false
+}
+
+func IsMapType(t types.Type) bool {
+	_, ok := Underlying(t).(*MapType)
+	return ok
+}
+
+// IsComparable reports whether values of type t can be compared with == operator,
+// as required for map keys.
+func IsComparable(t types.Type) bool {
+	switch tp := Underlying(t).(type) {
+	case *SliceType, *MapType, *FuncType:
+		return false
+	case *types.ArrayType:
+		return IsComparable(tp.ElemType)
+	case *StructInfo:
+		for _, field := range tp.Fields {
+			if field.IsStruct && !IsComparable(field.Struct) {
+				return false
+			} else if !field.IsStruct && !IsComparable(field.Primitive) {
+				return false
+			}
+		}
+	}
+	return true
+}
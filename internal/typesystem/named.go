@@ -0,0 +1,46 @@
+package typesystem
+
+import (
+	"github.com/llir/llvm/ir/types"
+)
+
+// NamedType describes non-struct, non-interface type declared with 'type'
+// keyword. Named types are distinct from each other and from their underlying
+// types, but are represented in LLVM by their underlying types.
+type NamedType struct {
+	types.Type // underlying type
+
+	TypeName string
+	Pkg      string // import path of declaring package
+}
+
+func NewNamedType(pkg, name string, underlying types.Type) *NamedType {
+	return &NamedType{
+		Type:     Underlying(underlying),
+		TypeName: name,
+		Pkg:      pkg,
+	}
+}
+
+// Equal reports whether t and u are the same named type.
+func (nt *NamedType) Equal(u types.Type) bool {
+	return nt == u
+}
+
+// Name returns empty name, named types have no named LLVM type.
+func (nt *NamedType) Name() string {
+	return ""
+}
+
+// SetName ignores name, underlying type may be shared by other types.
+func (nt *NamedType) SetName(name string) {
+}
+
+// Underlying returns type, which defines representation and operations of
+// values of type t.
+func Underlying(t types.Type) types.Type {
+	if nt, ok := t.(*NamedType); ok {
+		return nt.Type
+	}
+	return t
+}
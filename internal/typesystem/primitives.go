@@ -3,20 +3,44 @@ package typesystem
 import (
 	"gocomp/internal/utils"
 
-	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
 
 type UintType struct {
 	types.IntType
+	// uint has the same size as uint32, but is distinct Go type
+	Word bool
+}
+
+// Equal distinguishes unsigned types from signed ones of the same size,
+// and uint from uint32.
+func (t *UintType) Equal(u types.Type) bool {
+	if u, ok := u.(*UintType); ok {
+		return t.BitSize == u.BitSize && t.Word == u.Word
+	}
+	return false
+}
+
+// IntType describes int32, which has the same size as int, but is distinct
+// Go type. Other signed integer types are plain LLVM integer types.
+type IntType struct {
+	types.IntType
+}
+
+// Equal distinguishes int32 from int.
+func (t *IntType) Equal(u types.Type) bool {
+	if u, ok := u.(*IntType); ok {
+		return t.BitSize == u.BitSize
+	}
+	return false
 }
 
 var (
 	Bool    = types.I1
 	Int8    = types.I8
 	Int16   = types.I16
-	Int32   = types.I32
+	Int32   = &IntType{IntType: *types.I32}
 	Int64   = types.I64
 	Uint8   = &UintType{IntType: *types.I8}
 	Uint16  = &UintType{IntType: *types.I16}
@@ -29,8 +53,8 @@ var (
 	Uintptr = types.I8Ptr
 	Byte    = Uint8
 	Rune    = Int32
-	Int     = Int32
-	Uint    = Uint32
+	Int     = types.I32
+	Uint    = &UintType{IntType: *types.I32, Word: true}
 )
 
 var typeMap = map[string]types.Type{
@@ -38,17 +62,19 @@ var typeMap = map[string]types.Type{
 	"bool":    types.I1,
 	"int8":    types.I8,
 	"int16":   types.I16,
-	"int32":   types.I32,
+	"int32":   Int32,
 	"int64":   types.I64,
-	"uint8":   types.I8,
-	"uint16":  types.I16,
-	"uint32":  types.I32,
-	"uint64":  types.I64,
+	"uint8":   Uint8,
+	"uint16":  Uint16,
+	"uint32":  Uint32,
+	"uint64":  Uint64,
 	"int":     types.I32,
-	"uint":    types.I32,
+	"uint":    Uint,
 	"float32": types.Float,
 	"float64": types.Double,
-	"string":  types.I8Ptr,
+	"byte":    Byte,
+	"rune":    Rune,
+	"string":  String,
 }
 
 type TypedValue struct {
@@ -68,40 +94,64 @@ func (nv TypedValue) Type() types.Type {
 }
 
 func IsBoolType(t types.Type) bool {
-	return t == Bool
+	return Underlying(t) == Bool
 }
 
 func IsIntType(t types.Type) bool {
-	_, okUint := t.(*UintType)
-	_, okInt := t.(*types.IntType)
-	return !okUint && okInt
+	switch Underlying(t).(type) {
+	case *types.IntType, *IntType:
+		return true
+	}
+	return false
 }
 
 func IsUintType(t types.Type) bool {
-	_, ok := t.(*UintType)
+	_, ok := Underlying(t).(*UintType)
 	return ok
 }
 
-func IsFloatType(t types.Type) bool {
-	_, ok := t.(*types.FloatType)
-	return ok
+// UnderlyingIntType returns LLVM integer type of signed or unsigned integer type.
+func UnderlyingIntType(t types.Type) (*types.IntType, bool) {
+	switch tp := Underlying(t).(type) {
+	case *UintType:
+		return &tp.IntType, true
+	case *IntType:
+		return &tp.IntType, true
+	case *types.IntType:
+		return tp, true
+	}
+	return nil, false
 }
 
-func CommonSupertype(v1, v2 value.Value) (types.Type, bool) {
-	t1 := v1.Type()
-	t2 := v2.Type()
-	_, null1 := v1.(*constant.Null)
-	_, null2 := v2.(*constant.Null)
-	if null1 && null2 {
-		panic("both must not be null")
-	} else if null1 {
-		return t2, true
-	} else if null2 {
-		return t1, true
-	} else if t1 == t2 {
-		return t1, true
+// Raw gives value of named type its underlying type, and value of integer
+// type plain LLVM integer type, which is expected by llir for comparisons
+// and conversions. Values of slice, string, interface and func types get
+// plain LLVM struct type, required for extractvalue and insertvalue.
+func Raw(val value.Value) value.Value {
+	tp := Underlying(val.Type())
+	switch itp := tp.(type) {
+	case *UintType:
+		tp = &itp.IntType
+	case *IntType:
+		tp = &itp.IntType
+	case *SliceType:
+		tp = &itp.StructType
+	case *StringType:
+		tp = &itp.StructType
+	case *InterfaceType:
+		tp = &itp.StructType
+	case *FuncType:
+		tp = &itp.StructType
 	}
-	return nil, false
+	if tp != val.Type() {
+		return NewTypedValue(val, tp)
+	}
+	return val
+}
+
+func IsFloatType(t types.Type) bool {
+	_, ok := Underlying(t).(*types.FloatType)
+	return ok
 }
 
 func GoTypeToIR(goType string) (types.Type, error) {
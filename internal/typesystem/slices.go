@@ -0,0 +1,30 @@
+package typesystem
+
+import "github.com/llir/llvm/ir/types"
+
+// SliceType describes slice header {ptr, len, cap} over elements of ElemType.
+type SliceType struct {
+	types.StructType
+
+	ElemType types.Type
+}
+
+func NewSliceType(elemType types.Type) *SliceType {
+	return &SliceType{
+		StructType: *types.NewStruct(types.NewPointer(elemType), Int, Int),
+		ElemType:   elemType,
+	}
+}
+
+// Equal reports whether t and u are slices of equal element types.
+func (st *SliceType) Equal(u types.Type) bool {
+	if ust, ok := u.(*SliceType); ok {
+		return st.ElemType.Equal(ust.ElemType)
+	}
+	return false
+}
+
+func IsSliceType(t types.Type) bool {
+	_, ok := Underlying(t).(*SliceType)
+	return ok
+}
@@ -0,0 +1,23 @@
+package typesystem
+
+import "github.com/llir/llvm/ir/types"
+
+// StringType describes string header {ptr, len} over immutable bytes.
+// Byte after the end of non-empty string is readable, strings created
+// by runtime are terminated by NUL.
+type StringType struct {
+	types.StructType
+}
+
+var String = &StringType{StructType: *types.NewStruct(types.I8Ptr, Int)}
+
+// Equal reports whether u is string type.
+func (st *StringType) Equal(u types.Type) bool {
+	_, ok := u.(*StringType)
+	return ok
+}
+
+func IsStringType(t types.Type) bool {
+	_, ok := Underlying(t).(*StringType)
+	return ok
+}
@@ -11,6 +11,8 @@ type StructInfo struct {
 	types.StructType
 
 	TypeName string
+	// import path of package declaring named type
+	Pkg string
 
 	Fields []StructFieldInfo
 }
@@ -18,10 +20,12 @@ type StructInfo struct {
 type StructFieldInfo struct {
 	Name   string
 	Offset int
-	/* IsEmbedded bool */
-	IsStruct  bool
-	Struct    *StructInfo
-	Primitive types.Type
+	// embedded field is named by its type, fields and methods of which
+	// are promoted to embedding struct
+	IsEmbedded bool
+	IsStruct   bool
+	Struct     *StructInfo
+	Primitive  types.Type
 }
 
 func NewStructInfo(name string, fields []StructFieldInfo) *StructInfo {
@@ -44,6 +48,13 @@ func NewStructInfo(name string, fields []StructFieldInfo) *StructInfo {
 }
 
 func (si *StructInfo) String() string {
+	if si.StructType.Name() != "" {
+		// LLVM name, qualified by package
+		return si.StructType.String()
+	} else if si.TypeName == "" {
+		// struct literal type
+		return si.StructType.LLString()
+	}
 	return fmt.Sprintf("%%%s", si.TypeName)
 }
 
@@ -52,9 +63,32 @@ func (si *StructInfo) SetName(name string) {
 	si.TypeName = name
 }
 
-// Equal reports whether t and u are of equal type.
+// Equal reports whether t and u are of equal type. Named struct types are
+// equal only to themselves, struct literal types are equal if their fields are.
 func (si *StructInfo) Equal(u types.Type) bool {
-	return si == u
+	usi, ok := u.(*StructInfo)
+	if !ok {
+		return false
+	} else if si == usi {
+		return true
+	} else if si.TypeName != "" || usi.TypeName != "" {
+		return false
+	}
+	return si.Identical(usi)
+}
+
+// Identical reports whether structs have fields of the same names and types,
+// which makes them convertible to each other.
+func (si *StructInfo) Identical(u *StructInfo) bool {
+	if len(si.Fields) != len(u.Fields) {
+		return false
+	}
+	for i := range si.Fields {
+		if si.Fields[i].Name != u.Fields[i].Name || si.Fields[i].IsEmbedded != u.Fields[i].IsEmbedded || !si.Fields[i].Type().Equal(u.Fields[i].Type()) {
+			return false
+		}
+	}
+	return true
 }
 
 func (si *StructInfo) UpdateRecursiveRef(ref *StructInfo) {
@@ -65,6 +99,12 @@ func (si *StructInfo) UpdateRecursiveRef(ref *StructInfo) {
 			if ptp.ElemType == ref {
 				si.Fields[i].Primitive = types.NewPointer(si)
 			}
+		} else if stp, ok := field.Primitive.(*SliceType); ok {
+			if stp.ElemType == ref {
+				si.Fields[i].Primitive = NewSliceType(si)
+			} else if ptp, ok := stp.ElemType.(*types.PointerType); ok && ptp.ElemType == ref {
+				si.Fields[i].Primitive = NewSliceType(types.NewPointer(si))
+			}
 		}
 	}
 }
@@ -81,17 +121,104 @@ func (si *StructInfo) Size() (int64, error) {
 	return size, nil
 }
 
-func (si *StructInfo) ComputeOffset(fieldName string) (int, types.Type, error) {
-	for _, field := range si.Fields {
-		if field.Name == fieldName {
-			if field.IsStruct {
-				return field.Offset, field.Struct, nil
-			} else {
-				return field.Offset, field.Primitive, nil
+// ComputeOffset finds field, which may be promoted from embedded fields, and
+// returns indices of fields on path to it. Field of the shallowest depth is
+// selected, more fields of the same name at that depth are ambiguous.
+func (si *StructInfo) ComputeOffset(fieldName string) ([]int, types.Type, error) {
+	for _, level := range si.EmbeddingLevels() {
+		var path []int
+		var tp types.Type
+		count := 0
+		for _, emb := range level {
+			if emb.Struct == nil {
+				continue
+			}
+			for i, field := range emb.Struct.Fields {
+				if field.Name == fieldName {
+					path = append(emb.Path[:len(emb.Path):len(emb.Path)], i)
+					tp = field.Type()
+					count++
+				}
+			}
+		}
+		if count == 1 {
+			return path, tp, nil
+		} else if count > 1 {
+			return nil, nil, &AmbiguousSelectorError{fieldName}
+		}
+	}
+	return nil, nil, utils.MakeError("field %s not found in type %s", fieldName, si.TypeName)
+}
+
+// AmbiguousSelectorError reports field, which is found more times at the
+// shallowest depth of embedding.
+type AmbiguousSelectorError struct {
+	Name string
+}
+
+func (e *AmbiguousSelectorError) Error() string {
+	return fmt.Sprintf("ambiguous selector %s", e.Name)
+}
+
+// Embedding is type embedded in struct through path of embedded fields.
+type Embedding struct {
+	Type   types.Type  // embedded named type
+	Struct *StructInfo // Type, if it is struct, nil otherwise
+	Path   []int       // indices of embedded fields
+	// path goes through embedded pointer, so embedded value is addressable
+	Indirect bool
+}
+
+// EmbeddingLevels returns struct and types embedded in it grouped by depth
+// of embedding. Types embedded at shallower depth are not repeated, types
+// embedded more times at the same depth are.
+func (si *StructInfo) EmbeddingLevels() [][]Embedding {
+	var levels [][]Embedding
+	seen := make(map[types.Type]bool)
+	level := []Embedding{{Type: si, Struct: si}}
+	for len(level) > 0 {
+		var current, next []Embedding
+		for _, emb := range level {
+			if !seen[emb.Type] {
+				current = append(current, emb)
+			}
+		}
+		for _, emb := range current {
+			seen[emb.Type] = true
+			if emb.Struct == nil {
+				continue
 			}
+			for i, field := range emb.Struct.Fields {
+				if !field.IsEmbedded {
+					continue
+				}
+				tp, indirect := field.Type(), emb.Indirect
+				if ptp, ok := tp.(*types.PointerType); ok {
+					tp, indirect = ptp.ElemType, true
+				}
+				stp, _ := tp.(*StructInfo)
+				next = append(next, Embedding{
+					Type:     tp,
+					Struct:   stp,
+					Path:     append(emb.Path[:len(emb.Path):len(emb.Path)], i),
+					Indirect: indirect,
+				})
+			}
+		}
+		if len(current) > 0 {
+			levels = append(levels, current)
 		}
+		level = next
+	}
+	return levels
+}
+
+// Type returns type of field.
+func (sf *StructFieldInfo) Type() types.Type {
+	if sf.IsStruct {
+		return sf.Struct
 	}
-	return 0, nil, utils.MakeError(fmt.Sprintf("field %s not found in type %s", fieldName, si.TypeName))
+	return sf.Primitive
 }
 
 func (sf *StructFieldInfo) Size() (int64, error) {
@@ -103,7 +230,8 @@ func (sf *StructFieldInfo) Size() (int64, error) {
 }
 
 func primitiveSize(tp types.Type) (int64, error) {
-	if intg, ok := tp.(*types.IntType); ok {
+	tp = Underlying(tp)
+	if intg, ok := UnderlyingIntType(tp); ok {
 		return int64(intg.BitSize) / 8, nil
 	} else if flt, ok := tp.(*types.FloatType); ok {
 		switch flt.Kind {
@@ -122,6 +250,20 @@ func primitiveSize(tp types.Type) (int64, error) {
 		return int64(arr.Len) * elSize, nil
 	} else if _, ok := tp.(*types.PointerType); ok {
 		return 8, nil
+	} else if _, ok := tp.(*SliceType); ok {
+		intSize, _ := primitiveSize(Int)
+		return 8 + 2*intSize, nil
+	} else if _, ok := tp.(*StringType); ok {
+		// header is padded to pointer alignment
+		return 16, nil
+	} else if _, ok := tp.(*MapType); ok {
+		return 8, nil
+	} else if _, ok := tp.(*ChanType); ok {
+		return 8, nil
+	} else if _, ok := tp.(*InterfaceType); ok {
+		return 16, nil
+	} else if _, ok := tp.(*FuncType); ok {
+		return 16, nil
 	}
 	return 0, utils.MakeError("cannot compute size of type %v", tp)
 }
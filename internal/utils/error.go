@@ -13,10 +13,23 @@ func MakeError(format string, args ...any) error {
 }
 
 func MakeErrorTrace(ctx antlr.ParserRuleContext, prevErr error, format string, args ...any) error {
-	tok := ctx.GetStart()
-	errMsg := fmt.Sprintf("<input>:%d:%d: %s", tok.GetLine(), tok.GetColumn(), fmt.Sprintf(format, args...))
+	errMsg := fmt.Sprintf("%s: %s", Position(ctx.GetStart()), fmt.Sprintf(format, args...))
 	if prevErr == nil {
 		return fmt.Errorf("%s", errMsg)
 	}
 	return fmt.Errorf("%s\n%w", errMsg, prevErr)
 }
+
+// Position formats position of token as file:line:column.
+func Position(tok antlr.Token) string {
+	return fmt.Sprintf("%s:%d:%d", SourceName(tok), tok.GetLine(), tok.GetColumn())
+}
+
+// SourceName returns name of file token is read from, source read
+// from standard input is named <input>.
+func SourceName(tok antlr.Token) string {
+	if fs, ok := tok.GetInputStream().(*antlr.FileStream); ok {
+		return fs.GetSourceName()
+	}
+	return "<input>"
+}
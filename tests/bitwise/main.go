@@ -0,0 +1,68 @@
+package main
+
+import "fmt"
+
+func popcount(x int) int {
+	n := 0
+	for x != 0 {
+		x = x & (x - 1)
+		n++
+	}
+	return n
+}
+
+func hash(s string) int {
+	h := 5381
+	for i := 0; i < len(s); i++ {
+		h = (h << 5) + h ^ int(s[i])
+	}
+	return h & 0x7fffffff
+}
+
+func shiftLeft(x int, n int) int {
+	return x << n
+}
+
+func tryShift(x int, n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("shift by %d: %s\n", n, r.(error).Error())
+		}
+	}()
+	fmt.Printf("%d << %d = %d\n", x, n, shiftLeft(x, n))
+}
+
+func main() {
+	a, b := 0x5c, 0x3a
+	fmt.Printf("%d %d %d %d\n", a&b, a|b, a^b, a&^b)
+	fmt.Printf("%d %d %d\n", ^a, ^0, ^-1)
+	fmt.Printf("%d %d %d\n", 1<<10, -8>>1, 1000>>3)
+	fmt.Printf("%d %d\n", popcount(255), popcount(0x12345678))
+	fmt.Printf("%d %d\n", hash("hello"), hash("world"))
+
+	// precedence: shifts and & bind like *, | and ^ like +
+	fmt.Printf("%d %d\n", 1+2<<3, 6&3|8^1)
+
+	// shift counts of different types and out of range
+	var n8 int8 = 3
+	var n64 int64 = 40
+	var x int32 = 5
+	fmt.Printf("%d %d\n", x<<n8, x>>n8)
+	fmt.Printf("%d %d %d\n", x<<n64, -x>>n64, x>>n64)
+	var big int64 = 1
+	fmt.Printf("%d %d\n", int(big<<40>>38), int(big<<63>>63))
+	var one, minus int32 = 1, -1
+	for _, n := range []int{0, 30, 31, 32, 100} {
+		fmt.Printf("[%d %d %d] ", one<<n, minus>>n, one<<n>>n)
+	}
+	fmt.Printf("\n")
+	var small int8 = -128
+	fmt.Printf("%d %d %d\n", int(small>>7), int(small>>n64), int(small<<1))
+
+	// untyped constant shifted by variable count takes type int
+	k := 4
+	fmt.Printf("%d\n", 1<<k|1)
+
+	tryShift(3, 2)
+	tryShift(3, -1)
+}
@@ -0,0 +1,136 @@
+package main
+
+import "fmt"
+
+type button struct {
+	label   string
+	onClick func(int) int
+}
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func apply(xs []int, f func(int) int) []int {
+	var res []int
+	for _, x := range xs {
+		res = append(res, f(x))
+	}
+	return res
+}
+
+func double(x int) int {
+	return x * 2
+}
+
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func compose(f func(int) int, g func(int) int) func(int) int {
+	return func(x int) int {
+		return g(f(x))
+	}
+}
+
+func deferred() {
+	msg := "first"
+	defer func() {
+		fmt.Printf("deferred sees %s\n", msg)
+	}()
+	show := func(s string) {
+		fmt.Printf("deferred arg %s\n", s)
+	}
+	defer show(msg)
+	msg = "second"
+}
+
+func main() {
+	next := counter()
+	other := counter()
+	fmt.Printf("%d %d %d\n", next(), next(), other())
+
+	total := 0
+	add := func(x int) {
+		total += x
+	}
+	for i := 1; i <= 4; i++ {
+		add(i)
+	}
+	fmt.Printf("total %d\n", total)
+
+	xs := apply([]int{1, 2, 3}, double)
+	fmt.Printf("%d %d %d\n", xs[0], xs[1], xs[2])
+	offset := 10
+	ys := apply(xs, func(x int) int {
+		return x + offset
+	})
+	fmt.Printf("%d %d %d\n", ys[0], ys[1], ys[2])
+
+	inc := func(x int) int {
+		return x + 1
+	}
+	f := compose(inc, double)
+	fmt.Printf("compose %d\n", f(5))
+
+	var fib func(int) int
+	if fib == nil {
+		fmt.Printf("fib is nil\n")
+	}
+	fib = func(n int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n-1) + fib(n-2)
+	}
+	if fib != nil {
+		fmt.Printf("fib(15) %d\n", fib(15))
+	}
+
+	clicks := 0
+	b := button{label: "ok", onClick: func(n int) int {
+		clicks += n
+		return clicks
+	}}
+	b.onClick(2)
+	fmt.Printf("%s clicked %d\n", b.label, b.onClick(3))
+	pb := &b
+	pb.onClick = double
+	fmt.Printf("%s now %d\n", pb.label, pb.onClick(21))
+
+	var funcs []func() int
+	for i := 0; i < 3; i++ {
+		funcs = append(funcs, func() int {
+			return i * i
+		})
+	}
+	for _, v := range []int{7, 8} {
+		funcs = append(funcs, func() int {
+			return v
+		})
+	}
+	for _, g := range funcs {
+		fmt.Printf("%d ", g())
+	}
+	fmt.Printf("\n")
+
+	ops := map[string]func(int, int) (int, int){"divmod": divmod}
+	q, r := ops["divmod"](17, 5)
+	fmt.Printf("divmod %d %d\n", q, r)
+	swap := func(a int, b int) (int, int) {
+		return b, a
+	}
+	q, r = swap(q, r)
+	fmt.Printf("swap %d %d\n", q, r)
+
+	result := func(a int) int {
+		return a * a
+	}(9)
+	fmt.Printf("immediate %d\n", result)
+
+	deferred()
+}
@@ -0,0 +1,94 @@
+package main
+
+import "fmt"
+
+type counter struct {
+	count int
+	total float64
+}
+
+var calls int
+
+func next() int {
+	calls++
+	return calls - 1
+}
+
+func main() {
+	a := []int{1, 2, 3, 4}
+	i := 2
+	a[i]++
+	a[0]--
+	fmt.Printf("%d %d %d %d\n", a[0], a[1], a[2], a[3])
+
+	p := &counter{count: 10, total: 1.5}
+	p.count--
+	p.count--
+	p.total++
+	fmt.Printf("%d %.1f\n", p.count, p.total)
+
+	v := 0
+	n := &v
+	*n++
+	*n++
+	*n++
+	fmt.Printf("%d\n", *n)
+
+	m := map[string]int{}
+	m["x"]++
+	m["x"]++
+	m["y"] += 5
+	m["y"] -= 2
+	fmt.Printf("%d %d\n", m["x"], m["y"])
+
+	var arr [3]int32
+	arr[1] += 7
+	arr[next()] += 10
+	arr[next()] *= 3
+	fmt.Printf("%d %d %d calls=%d\n", arr[0], arr[1], arr[2], calls)
+
+	var x int32 = 100
+	x %= 7
+	fmt.Printf("%d\n", x)
+	x = 0x5c
+	x &= 0x3f
+	fmt.Printf("%d\n", x)
+	x |= 0x100
+	fmt.Printf("%d\n", x)
+	x ^= 0xff
+	fmt.Printf("%d\n", x)
+	x <<= 3
+	fmt.Printf("%d\n", x)
+	x >>= 2
+	fmt.Printf("%d\n", x)
+	x &^= 0x0f
+	fmt.Printf("%d\n", x)
+	x /= 3
+	fmt.Printf("%d\n", x)
+
+	var big int64 = 1
+	big <<= 40
+	big += 1
+	fmt.Printf("%d\n", int(big>>38))
+
+	f := 2.5
+	f *= 4
+	f -= 1
+	f /= 2
+	f++
+	fmt.Printf("%.2f\n", f)
+
+	s := "go"
+	s += "pher"
+	fmt.Printf("%s\n", s)
+
+	var shift uint = 2
+	y := 3
+	y <<= shift
+	fmt.Printf("%d\n", y)
+
+	ptrs := []*counter{p, &counter{count: 1}}
+	ptrs[1].count += ptrs[0].count
+	ptrs[next()-2].count++
+	fmt.Printf("%d %d calls=%d\n", ptrs[0].count, ptrs[1].count, calls)
+}
@@ -0,0 +1,90 @@
+package main
+
+import "fmt"
+
+type Weekday int32
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+	Wednesday
+)
+
+const (
+	_  = iota
+	KB = 1 << (10 * iota)
+	MB
+	GB
+)
+
+const (
+	a, b = iota, iota * 10
+	c, d
+	e, f
+)
+
+const Big = 1 << 100
+const Small = Big >> 98
+const Pi = 3.14159265358979
+const Greeting = "hello, " + "world"
+const Size = len(Greeting)
+const Mask uint8 = 0xF0
+
+var table [Size / 2]int32
+var scaled = Pi * 2
+
+func classify(n int32) string {
+	const limit = 10
+	switch n {
+	case 0:
+		return "zero"
+	case 1, 2, 3:
+		return "small"
+	case limit:
+		return "limit"
+	}
+	if n > limit {
+		return "big"
+	}
+	return "other"
+}
+
+func main() {
+	fmt.Printf("%d %d %d %d\n", Sunday, Monday, Tuesday, Wednesday)
+	fmt.Printf("%d %d %d\n", KB, MB, GB)
+	fmt.Printf("%d %d %d %d %d %d\n", a, b, c, d, e, f)
+	fmt.Printf("%d\n", Small)
+	fmt.Printf("%.5f %.5f\n", Pi, scaled)
+	fmt.Printf("%s %d\n", Greeting, Size)
+	fmt.Printf("%c %c\n", Greeting[0], Greeting[Size-1])
+	fmt.Printf("%d\n", len(table))
+	fmt.Printf("%d %d\n", Mask, ^Mask)
+
+	var x int32 = 7
+	fmt.Printf("%d %d\n", x<<2, x>>1)
+	var h float64 = 1 / 2.0
+	fmt.Printf("%.2f %d\n", h, 7/2)
+	fmt.Printf("%.2f\n", float64(x)*Pi)
+
+	var u uint32 = 1<<32 - 1
+	fmt.Printf("%d\n", u/(1<<16))
+	fmt.Printf("%s\n", string(rune(65+1)))
+
+	for _, n := range []int32{0, 2, 10, 12, 5} {
+		fmt.Printf("%s ", classify(n))
+	}
+	fmt.Printf("\n")
+
+	const step = 3
+	add := func(v int32) int32 {
+		return v + step
+	}
+	fmt.Printf("%d\n", add(4))
+
+	var i int32
+	for i = 0; i < step; i++ {
+		table[i] = i * step
+	}
+	fmt.Printf("%d %d %d\n", table[0], table[1], table[2])
+}
@@ -0,0 +1,13 @@
+package main
+
+import "fmt"
+
+func main() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+	}()
+	fmt.Printf("got %d\n", <-ch)
+	<-ch
+	fmt.Printf("unreachable\n")
+}
@@ -0,0 +1,140 @@
+package main
+
+import "fmt"
+
+type Header struct {
+	ID      int
+	Version int
+}
+
+func (h Header) Describe() string {
+	return fmt.Sprintf("#%d v%d", h.ID, h.Version)
+}
+
+func (h *Header) Bump() {
+	h.Version++
+}
+
+type Audit struct {
+	CreatedBy string
+	Version   string
+}
+
+func (a *Audit) Touch(who string) {
+	a.CreatedBy = who
+}
+
+type Document struct {
+	Header
+	Title string
+}
+
+type Invoice struct {
+	*Header
+	Amount float64
+}
+
+type Record struct {
+	Document
+	Audit
+	Note string
+}
+
+// String of Header is not promoted, Document declares its own
+type Note struct {
+	Header
+	Text string
+}
+
+func (n Note) Describe() string {
+	return "note " + n.Text + " " + n.Header.Describe()
+}
+
+type Describer interface {
+	Describe() string
+}
+
+type Bumper interface {
+	Bump()
+}
+
+type Counter struct {
+	n int
+}
+
+func (c *Counter) Inc() int {
+	c.n++
+	return c.n
+}
+
+func (c Counter) Pair() (int, int) {
+	return c.n, c.n * 2
+}
+
+type Service struct {
+	name string
+	*Counter
+}
+
+func main() {
+	doc := Document{Header: Header{ID: 1, Version: 2}, Title: "spec"}
+	fmt.Println(doc.ID, doc.Version, doc.Title, doc.Header.ID)
+	doc.Version = 5
+	doc.Header.ID = 7
+	fmt.Println(doc.Describe())
+	doc.Bump()
+	fmt.Println(doc.Version, doc.Describe())
+	fmt.Printf("%v %+v\n", doc, doc)
+
+	inv := Invoice{&Header{ID: 3}, 9.5}
+	inv.Bump()
+	inv.Version += 10
+	fmt.Println(inv.ID, inv.Version, inv.Describe(), inv.Amount)
+
+	// fields shadow promoted fields of the same name
+	rec := Record{Document: doc, Audit: Audit{CreatedBy: "ann", Version: "draft"}}
+	rec.ID = 42
+	rec.Touch("bob")
+	rec.Note = "n"
+	fmt.Println(rec.ID, rec.Document.Version, rec.Audit.Version, rec.CreatedBy, rec.Title, rec.Describe())
+
+	// interfaces through promoted methods
+	var d Describer = doc
+	fmt.Println(d.Describe())
+	d = Note{Header{ID: 8}, "hi"}
+	fmt.Println(d.Describe())
+	var b Bumper = &doc
+	b.Bump()
+	b = inv
+	b.Bump()
+	fmt.Println(doc.Version, inv.Version)
+
+	// pointers to embedding structs
+	func() {
+		defer doc.Bump()
+		fmt.Println(doc.Describe())
+	}()
+	fmt.Println(doc.Describe())
+	p := &rec
+	p.Bump()
+	p.Title = "via pointer"
+	fmt.Println(p.Version, p.Title, rec.Document.Header.Version)
+
+	svc := Service{name: "svc", Counter: &Counter{}}
+	svc.Inc()
+	svc.Inc()
+	a, c := svc.Pair()
+	fmt.Println(svc.name, svc.n, a, c)
+
+	docs := []Document{{Title: "a"}, {Header{ID: 2}, "b"}}
+	for i := range docs {
+		docs[i].Bump()
+	}
+	for _, x := range docs {
+		fmt.Println(x.ID, x.Version, x.Title)
+	}
+	fmt.Printf("%v\n", struct {
+		Header
+		Extra bool
+	}{Header{1, 1}, true})
+}
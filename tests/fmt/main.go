@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type point struct {
+	X, Y int
+	name string
+}
+
+type celsius float64
+
+func (c celsius) String() string {
+	return fmt.Sprintf("%.1f°C", float64(c))
+}
+
+type notFound struct {
+	key string
+}
+
+func (e *notFound) Error() string {
+	return "not found: " + e.key
+}
+
+type weekday int
+
+func lookup(m map[string]int, key string) (int, error) {
+	v, ok := m[key]
+	if !ok {
+		return 0, &notFound{key: key}
+	}
+	return v, nil
+}
+
+func main() {
+	// integers
+	fmt.Printf("%d|%5d|%-5d|%05d|%+d|% d\n", 42, 42, 42, 42, 42, 42)
+	fmt.Printf("%x|%X|%#x|%o|%#o|%O|%b|%#b\n", 255, 255, 255, 8, 8, 8, 5, 5)
+	fmt.Printf("%c|%q|%U|%#U\n", 'G', 'o', 0x1F600, 'ä')
+	fmt.Printf("%.3d|%8.3d|%-8.3d|\n", 7, -7, 7)
+	var u8 uint8 = 200
+	var i64 int64 = -1 << 40
+	fmt.Printf("%d %d %v %x\n", u8, i64, uint32(4000000000), int8(-1))
+
+	// floats
+	fmt.Printf("%f|%.2f|%8.3f|%-8.2f|%08.3f\n", 3.14159, 3.14159, 3.14159, 2.5, -3.5)
+	fmt.Printf("%e|%E|%g|%G|%.3g\n", 123456.789, 0.000123, 1e21, 1e-7, 1234.5678)
+	fmt.Printf("%v %v %v %v %v\n", 1.0, 0.1, 100000.0, 1234567.0, float32(0.1))
+	fmt.Printf("%+.1f|% .1f|%#g|%#.3x\n", 2.0, 2.0, 1.0, 1.0)
+
+	// strings and booleans
+	fmt.Printf("%s|%10s|%-10s|%.2s|%q\n", "go", "go", "go", "golang", "tab\there")
+	fmt.Printf("%x|%X|% x|%#q|%+q\n", "hi", "hi", "abc", "raw", "héllo")
+	fmt.Printf("%t|%v|%6t|\n", true, false, true)
+
+	// composite values
+	p := point{1, 2, "origin"}
+	fmt.Printf("%v|%+v|%#v\n", p, p, p)
+	fmt.Printf("%v|%+v\n", &p, &p)
+	s := []int{3, 1, 2}
+	fmt.Printf("%v|%d|%#v|%x\n", s, s, s, s)
+	var nilSlice []string
+	fmt.Printf("%v|%#v|%d\n", nilSlice, nilSlice, len(nilSlice))
+	m := map[string]int{"b": 2, "a": 1, "c": 3}
+	fmt.Printf("%v|%#v\n", m, m)
+	km := map[int]bool{3: true, -1: false, 2: true}
+	fmt.Println(km)
+	arr := [3]string{"x", "y", "z"}
+	fmt.Printf("%v|%q|%#v\n", arr, arr, arr)
+	bs := []byte("bytes")
+	fmt.Printf("%s|%x|%q|%v\n", bs, bs, bs, bs)
+
+	// types
+	var e error
+	var a any = 3
+	fmt.Printf("%T|%T|%T|%T|%T|%T|%T\n", 1, "s", 2.5, p, &p, s, m)
+	fmt.Printf("%T|%T|%T|%v\n", e, a, weekday(1), weekday(3))
+
+	// methods
+	t := celsius(21.5)
+	fmt.Println(t, []celsius{1, 2})
+	fmt.Printf("%v|%s|%d\n", t, t, weekday(5))
+	_, err := lookup(m, "zz")
+	fmt.Println("error:", err)
+	fmt.Printf("%v|%q\n", err, err)
+	if v, err := lookup(m, "c"); err == nil {
+		fmt.Println("found", v, err)
+	}
+
+	// Print, Sprint and friends
+	fmt.Print("a", "b", 1, 2, "c", 3.5, true, "\n")
+	fmt.Print(1, 2, "\n")
+	str := fmt.Sprint("x=", 10, " y=", 20)
+	fmt.Println(str, len(str))
+	line := fmt.Sprintln("one", 2, 3.0)
+	fmt.Print(line)
+	n, perr := fmt.Println("counted")
+	fmt.Println(n, perr)
+	fmt.Fprintln(os.Stdout, "to stdout", 1)
+	fmt.Fprintf(os.Stdout, "%s=%d\n", "k", 9)
+	fmt.Fprintln(os.Stderr, "to stderr")
+
+	// errors
+	err1 := fmt.Errorf("open %s: code %d", "file.txt", 2)
+	fmt.Println(err1)
+	fmt.Printf("%T\n", err1)
+	err2 := fmt.Errorf("wrapped: %w", err1)
+	fmt.Println(err2.Error())
+
+	// argument indexes, star width and precision
+	fmt.Printf("%[2]d %[1]d %d\n", 10, 20)
+	fmt.Printf("%[3]*.[2]*[1]f|\n", 12.0, 2, 6)
+	fmt.Printf("%*d|%-*d|%.*f\n", 5, 1, 5, 2, 1, 3.14159)
+	fmt.Printf("%6.2f%%\n", 99.5)
+
+	// bad verbs and operands, formats are not constant, so that vet
+	// accepts them
+	bad := []string{"%d\n", "%s\n", "%z\n", "%d %d\n", "%[5]d\n", "%!\n", "%*d\n", "%v %d\n", "%", "%t\n", "%w"}
+	fmt.Printf(bad[0], "str")
+	fmt.Printf(bad[1], 12)
+	fmt.Printf(bad[2], 1.5)
+	fmt.Printf(bad[3], 1)
+	fmt.Printf(bad[0], 1, 2, "x")
+	fmt.Printf(bad[4], 1)
+	fmt.Printf(bad[5], 1)
+	fmt.Printf(bad[6], "w", 1)
+	fmt.Printf(bad[7], nil, nil)
+	fmt.Printf(bad[8], 1)
+	fmt.Println()
+	fmt.Printf(bad[9], 1)
+	fmt.Println(fmt.Sprintf(bad[10], err1))
+}
@@ -19,7 +19,7 @@ var (
 func main() {
 	fmt.Printf("%d\n", c1)
 	fmt.Printf("%f\n", c2)
-	fmt.Printf("%d\n", c3)
+	fmt.Printf("%t\n", c3)
 	fmt.Printf("%d\n", v1)
 	fmt.Printf("%d\n", v2)
 	fmt.Printf("%d\n", v3)
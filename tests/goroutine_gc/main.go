@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+type node struct {
+	val  int
+	next *node
+}
+
+func build(n int) *node {
+	var head *node
+	for i := 0; i < n; i++ {
+		head = &node{val: i, next: head}
+	}
+	return head
+}
+
+func sum(l *node) int {
+	s := 0
+	for l != nil {
+		s += l.val
+		l = l.next
+	}
+	return s
+}
+
+// each goroutine keeps its list only on its own stack while others allocate
+func keeper(id int, tick chan int, out chan int) {
+	list := build(1000 + id)
+	for range tick {
+		garbage := build(2000)
+		_ = garbage
+	}
+	out <- sum(list)
+}
+
+func main() {
+	const n = 8
+	ticks := make([]chan int, n)
+	out := make(chan int)
+	for i := 0; i < n; i++ {
+		ticks[i] = make(chan int)
+		go keeper(i, ticks[i], out)
+	}
+	for round := 0; round < 50; round++ {
+		for i := 0; i < n; i++ {
+			ticks[i] <- round
+		}
+	}
+	for i := 0; i < n; i++ {
+		close(ticks[i])
+	}
+	total := 0
+	for i := 0; i < n; i++ {
+		total += <-out
+	}
+	fmt.Printf("total %d\n", total)
+}
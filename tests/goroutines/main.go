@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *counter) inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n = c.n + 1
+}
+
+type shape interface {
+	area() int
+}
+
+type rect struct {
+	w int
+	h int
+}
+
+func (r rect) area() int {
+	return r.w * r.h
+}
+
+func producer(ch chan<- int, n int) {
+	for i := 1; i <= n; i++ {
+		ch <- i * i
+	}
+	close(ch)
+}
+
+func worker(id int, jobs <-chan int, results chan<- int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for j := range jobs {
+		results <- j * 10
+	}
+	_ = id
+}
+
+func report(s shape, done chan bool) {
+	fmt.Printf("area %d\n", s.area())
+	done <- true
+}
+
+func fib(n int, out chan int) {
+	if n < 2 {
+		out <- n
+		return
+	}
+	left := make(chan int)
+	right := make(chan int)
+	go fib(n-1, left)
+	go fib(n-2, right)
+	out <- <-left + <-right
+}
+
+var signal = make(chan string)
+
+func ping() {
+	signal <- "ping"
+}
+
+func main() {
+	// goroutine without arguments
+	go ping()
+	fmt.Printf("%s\n", <-signal)
+
+	// unbuffered channel with range
+	squares := make(chan int)
+	go producer(squares, 5)
+	sum := 0
+	for v := range squares {
+		sum += v
+	}
+	fmt.Printf("sum of squares %d\n", sum)
+
+	// buffered channel, len and cap
+	buf := make(chan string, 3)
+	buf <- "a"
+	buf <- "b"
+	fmt.Printf("len %d cap %d\n", len(buf), cap(buf))
+	fmt.Printf("%s%s\n", <-buf, <-buf)
+	close(buf)
+	_, ok := <-buf
+	if !ok {
+		fmt.Printf("closed\n")
+	}
+	nums := make(chan int, 1)
+	nums <- 5
+	close(nums)
+	n1, ok1 := <-nums
+	n2, ok2 := <-nums
+	if ok1 && !ok2 {
+		fmt.Printf("drained %d then %d\n", n1, n2)
+	}
+
+	// worker pool with WaitGroup
+	jobs := make(chan int, 10)
+	results := make(chan int, 10)
+	var wg sync.WaitGroup
+	for w := 1; w <= 3; w++ {
+		wg.Add(1)
+		go worker(w, jobs, results, &wg)
+	}
+	for j := 1; j <= 9; j++ {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	total := 0
+	for r := range results {
+		total += r
+	}
+	fmt.Printf("results total %d\n", total)
+
+	// mutex protected counter and closures
+	c := &counter{}
+	var wg2 sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg2.Add(1)
+		go func() {
+			defer wg2.Done()
+			c.inc()
+		}()
+	}
+	wg2.Wait()
+	fmt.Printf("counter %d\n", c.n)
+
+	// goroutine calling method through interface
+	done := make(chan bool)
+	var sh shape = rect{w: 3, h: 4}
+	go report(sh, done)
+	<-done
+	go sh.area()
+	r := rect{w: 2, h: 5}
+	go func(x int) {
+		fmt.Printf("arg %d area %d\n", x, r.area())
+		done <- true
+	}(7)
+	<-done
+
+	// select with default
+	ch := make(chan int, 1)
+	select {
+	case v := <-ch:
+		fmt.Printf("unexpected %d\n", v)
+	default:
+		fmt.Printf("nothing ready\n")
+	}
+	ch <- 42
+	select {
+	case v, ok := <-ch:
+		if ok {
+			fmt.Printf("received %d\n", v)
+		}
+	default:
+		fmt.Printf("unexpected default\n")
+	}
+
+	// select on several channels
+	a := make(chan int)
+	b := make(chan string)
+	quit := make(chan bool)
+	go func() {
+		a <- 1
+		b <- "two"
+		a <- 3
+		quit <- true
+	}()
+	count := 0
+	for {
+		var text string
+		select {
+		case n := <-a:
+			fmt.Printf("a %d\n", n)
+		case text = <-b:
+			fmt.Printf("b %s\n", text)
+		case <-quit:
+			fmt.Printf("quit\n")
+			count = -1
+		}
+		if count < 0 {
+			break
+		}
+		count++
+	}
+
+	// select with send case
+	out := make(chan int, 2)
+	for i := 0; i < 3; i++ {
+		select {
+		case out <- i:
+			fmt.Printf("sent %d\n", i)
+		default:
+			fmt.Printf("full at %d\n", i)
+		}
+	}
+
+	// nil channel compare and recursive goroutines
+	var nilch chan int
+	if nilch == nil {
+		fmt.Printf("nil channel\n")
+	}
+	res := make(chan int)
+	go fib(10, res)
+	fmt.Printf("fib %d\n", <-res)
+}
@@ -0,0 +1,235 @@
+package main
+
+import "fmt"
+
+type Shape interface {
+	Area() int
+	Perimeter() int
+}
+
+type Named interface {
+	Name() string
+}
+
+type NamedShape interface {
+	Shape
+	Named
+}
+
+type rect struct {
+	w int
+	h int
+}
+
+func (r rect) Area() int {
+	return r.w * r.h
+}
+
+func (r rect) Perimeter() int {
+	return 2 * (r.w + r.h)
+}
+
+func (r rect) Name() string {
+	return "rect"
+}
+
+type square struct {
+	side int
+}
+
+func (s *square) Area() int {
+	return s.side * s.side
+}
+
+func (s *square) Perimeter() int {
+	return 4 * s.side
+}
+
+func (s *square) grow(d int) {
+	s.side += d
+}
+
+type label struct {
+	text string
+}
+
+func (l label) Name() string {
+	return l.text
+}
+
+// stateless implementation of interface
+type anon struct{}
+
+func (anon) Name() string {
+	return "anon"
+}
+
+func greet(n Named) string {
+	return "hello " + n.Name()
+}
+
+type parseError struct {
+	line int
+	msg  string
+}
+
+func (e *parseError) Error() string {
+	return e.msg
+}
+
+func parse(n int) (int, error) {
+	if n < 0 {
+		return 0, &parseError{n, "negative value"}
+	}
+	return n * 10, nil
+}
+
+func totalArea(shapes []Shape) int {
+	total := 0
+	for _, s := range shapes {
+		total += s.Area()
+	}
+	return total
+}
+
+func describe(v any) int {
+	switch x := v.(type) {
+	case nil:
+		fmt.Printf("nil value\n")
+	case int:
+		fmt.Printf("int %d\n", x+1)
+	case string:
+		fmt.Printf("string %s\n", x)
+	case rect, *square:
+		fmt.Printf("shape with area %d\n", x.(Shape).Area())
+	case Named:
+		fmt.Printf("named %s\n", x.Name())
+	default:
+		return 0
+	}
+	return 1
+}
+
+func main() {
+	// dynamic dispatch on value and pointer receivers
+	sq := &square{3}
+	shapes := []Shape{rect{2, 3}, sq}
+	fmt.Printf("total=%d\n", totalArea(shapes))
+	sq.grow(1)
+	fmt.Printf("total after grow=%d\n", totalArea(shapes))
+	var s Shape = rect{4, 5}
+	fmt.Printf("area=%d perimeter=%d\n", s.Area(), s.Perimeter())
+	s = sq
+	fmt.Printf("area=%d perimeter=%d\n", s.Area(), s.Perimeter())
+
+	// type assertions
+	if r, ok := s.(rect); ok {
+		fmt.Printf("unexpected rect %d\n", r.w)
+	} else {
+		fmt.Printf("not a rect\n")
+	}
+	p := s.(*square)
+	fmt.Printf("square side=%d\n", p.side)
+	s = rect{1, 7}
+	r := s.(rect)
+	fmt.Printf("rect w=%d h=%d\n", r.w, r.h)
+
+	// interface to interface conversions
+	if n, ok := s.(Named); ok {
+		fmt.Printf("name=%s\n", n.Name())
+	}
+	if _, ok := Shape(sq).(Named); !ok {
+		fmt.Printf("square has no name\n")
+	}
+	var ns NamedShape = rect{2, 2}
+	s = ns
+	var named Named = ns
+	fmt.Printf("ns area=%d name=%s\n", s.Area(), named.Name())
+
+	// errors
+	for _, n := range []int{5, -1} {
+		v, err := parse(n)
+		if err != nil {
+			fmt.Printf("error: %s\n", err.Error())
+			if pe, ok := err.(*parseError); ok {
+				fmt.Printf("at line %d\n", pe.line)
+			}
+		} else {
+			fmt.Printf("parsed %d\n", v)
+		}
+	}
+
+	// empty interface and type switches
+	var nothing any
+	vals := []any{42, "hello", rect{3, 3}, &square{2}, label{"label"}, nothing, 2.5}
+	handled := 0
+	for _, v := range vals {
+		handled += describe(v)
+	}
+	fmt.Printf("handled=%d of %d\n", handled, len(vals))
+
+	// comparisons
+	var a, b any
+	fmt.Printf("nil==nil %d\n", btoi(a == b))
+	a = 7
+	b = 7
+	fmt.Printf("7==7 %d\n", btoi(a == b))
+	b = "7"
+	fmt.Printf("7==\"7\" %d\n", btoi(a == b))
+	fmt.Printf("a==7 %d a!=nil %d\n", btoi(a == 7), btoi(a != nil))
+	var s1, s2 Shape = rect{1, 2}, rect{1, 2}
+	fmt.Printf("rect==rect %d\n", btoi(s1 == s2))
+	s2 = sq
+	fmt.Printf("rect==square %d square==square %d\n", btoi(s1 == s2), btoi(s2 == Shape(sq)))
+
+	// maps with interface keys and values
+	m := map[any]int{}
+	m[1] = 10
+	m["one"] = 20
+	m[rect{1, 1}] = 30
+	m[1] += 5
+	fmt.Printf("len=%d m[1]=%d m[one]=%d m[rect]=%d\n", len(m), m[1], m["one"], m[rect{1, 1}])
+	byName := map[string]Shape{"a": rect{1, 2}, "b": &square{5}}
+	fmt.Printf("b area=%d\n", byName["b"].Area())
+	if _, ok := byName["c"]; !ok {
+		fmt.Printf("no c\n")
+	}
+
+	// struct with interface field
+	type holder struct {
+		label string
+		val   any
+	}
+	h := holder{"h", 3}
+	if n, ok := h.val.(int); ok {
+		fmt.Printf("holder %s %d\n", h.label, n)
+	}
+	h.val = nil
+	fmt.Printf("holder nil %d\n", btoi(h.val == nil))
+
+	// values of zero size
+	var nm Named = anon{}
+	fmt.Printf("%s %s\n", nm.Name(), greet(anon{}))
+	var e any = struct{}{}
+	var z any = [0]int{}
+	_, isAnon := e.(anon)
+	fmt.Printf("empty %d %d %d\n", btoi(e == struct{}{}), btoi(isAnon), btoi(z == [0]int{}))
+
+	// conversions to interface type literals
+	q := 42
+	boxed := interface{}(q)
+	nm2 := interface {
+		Name() string
+	}(label{"lit"})
+	shape := interface {
+		Area() int
+	}(&square{3})
+	fmt.Printf("literal %d %s %d %d\n", describe(boxed), nm2.Name(), shape.Area(), btoi(interface{}(nil) == nil))
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package main
+
+import "fmt"
+
+type point struct {
+	x int
+	y int
+}
+
+type segment struct {
+	from point
+	to   point
+}
+
+type account struct {
+	owner   string
+	balance int
+}
+
+func countWords(words []string) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < len(words); i++ {
+		counts[words[i]] += 1
+	}
+	return counts
+}
+
+func lookup(m map[int]int, key int) {
+	v, ok := m[key]
+	if ok {
+		fmt.Printf("m[%d] = %d\n", key, v)
+	} else {
+		fmt.Printf("m[%d] missing\n", key)
+	}
+}
+
+func main() {
+	// make, assignment and len
+	squares := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		squares[i] = i * i
+	}
+	fmt.Printf("len=%d sq[31]=%d sq[999]=%d\n", len(squares), squares[31], squares[999])
+
+	// comma-ok and delete
+	lookup(squares, 10)
+	delete(squares, 10)
+	lookup(squares, 10)
+	delete(squares, 12345)
+	fmt.Printf("len=%d\n", len(squares))
+	var v int
+	var ok bool
+	v, ok = squares[11]
+	if ok {
+		fmt.Printf("found %d\n", v)
+	}
+	_, ok = squares[10]
+	if !ok {
+		fmt.Printf("not found\n")
+	}
+
+	// string keys
+	words := []string{"go", "map", "go", "llvm", "go", "map"}
+	counts := countWords(words)
+	fmt.Printf("go=%d map=%d llvm=%d c=%d len=%d\n", counts["go"], counts["map"], counts["llvm"], counts["c"], len(counts))
+
+	// map literals with struct keys and values
+	dist := map[point]int{
+		{1, 2}:       3,
+		point{4, 5}:  9,
+		{x: 0, y: 0}: 0,
+	}
+	fmt.Printf("%d %d %d %d\n", dist[point{1, 2}], dist[point{4, 5}], dist[point{}], len(dist))
+	dist[point{1, 2}] = 42
+	fmt.Printf("%d %d\n", dist[point{1, 2}], len(dist))
+
+	segs := map[segment]string{}
+	segs[segment{point{0, 0}, point{1, 1}}] = "diagonal"
+	seg := segment{from: point{0, 0}, to: point{1, 1}}
+	fmt.Printf("%s\n", segs[seg])
+
+	accounts := map[string]account{
+		"alice": {"Alice", 100},
+		"bob":   {owner: "Bob", balance: 50},
+	}
+	fmt.Printf("%s %d\n", accounts["alice"].owner, accounts["bob"].balance)
+	fmt.Printf("%d %d\n", accounts["carol"].balance, len(accounts))
+
+	// float, bool and array keys
+	floats := map[float64]int{}
+	floats[0.5] = 1
+	floats[-0.0] = 2
+	floats[0.0] += 3
+	fmt.Printf("%d %d %d\n", floats[0.5], floats[0.0], len(floats))
+
+	flags := map[bool]string{true: "yes", false: "no"}
+	fmt.Printf("%s %s\n", flags[1 < 2], flags[2 < 1])
+
+	grid := make(map[[2]int]int, 16)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			grid[[2]int{i, j}] = i*10 + j
+		}
+	}
+	fmt.Printf("%d %d %d\n", grid[[2]int{3, 2}], grid[[2]int{0, 1}], len(grid))
+
+	// nil maps
+	var empty map[string]int
+	if empty == nil {
+		fmt.Printf("nil map: %d %d\n", len(empty), empty["x"])
+	}
+	delete(empty, "x")
+	empty = make(map[string]int)
+	empty["x"] = 7
+	if empty != nil {
+		fmt.Printf("non-nil map: %d\n", empty["x"])
+	}
+
+	// maps of slices and slices of maps
+	groups := map[int][]int{}
+	for i := 0; i < 10; i++ {
+		groups[i%3] = append(groups[i%3], i)
+	}
+	fmt.Printf("%d %d %d %d\n", len(groups[0]), len(groups[1]), groups[2][2], groups[0][3])
+
+	levels := []map[string]int{{"a": 1}, {"b": 2}}
+	fmt.Printf("%d %d\n", levels[0]["a"], levels[1]["b"])
+
+	// range over map visits every entry once
+	powers := map[int]int{1: 1, 2: 4, 3: 9, 4: 16}
+	keySum, valSum := 0, 0
+	for k, v := range powers {
+		keySum += k
+		valSum += v
+	}
+	fmt.Printf("range %d %d\n", keySum, valSum)
+	var nilMap map[string]int
+	for k := range nilMap {
+		fmt.Printf("unexpected %s\n", k)
+	}
+	// entries deleted before they are reached are not produced
+	visited := 0
+	for k := range powers {
+		visited++
+		for other := range powers {
+			if other != k {
+				delete(powers, other)
+			}
+		}
+	}
+	fmt.Printf("visited %d left %d\n", visited, len(powers))
+}
@@ -0,0 +1,169 @@
+package main
+
+import "fmt"
+
+type point struct {
+	x int
+	y int
+}
+
+func (p point) sum() int {
+	return p.x + p.y
+}
+
+func (p *point) move(dx, dy int) {
+	p.x += dx
+	p.y += dy
+}
+
+func (p point) scaled(k int) point {
+	return point{p.x * k, p.y * k}
+}
+
+// value receiver works on copy
+func (p point) reset() {
+	p.x = 0
+	p.y = 0
+}
+
+type counter int
+
+func (c *counter) inc() {
+	*c = *c + 1
+}
+
+func (c counter) double() counter {
+	return c * 2
+}
+
+type node struct {
+	val  int
+	next *node
+}
+
+func (n *node) push(val int) *node {
+	return &node{val, n}
+}
+
+func (n *node) total() int {
+	s := 0
+	for ; n != nil; n = n.next {
+		s += n.val
+	}
+	return s
+}
+
+type stack struct {
+	items []int
+}
+
+func (s *stack) push(v int) {
+	s.items = append(s.items, v)
+}
+
+func (s *stack) pop() (int, bool) {
+	if len(s.items) == 0 {
+		return 0, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+type segment struct {
+	from point
+	to   point
+}
+
+func (s segment) length2() int {
+	dx := s.to.x - s.from.x
+	dy := s.to.y - s.from.y
+	return dx*dx + dy*dy
+}
+
+func makePoint(x, y int) point {
+	return point{x, y}
+}
+
+func main() {
+	// value and pointer receivers on addressable variable
+	p := point{1, 2}
+	fmt.Printf("sum=%d\n", p.sum())
+	p.move(10, 20)
+	fmt.Printf("moved=%d %d\n", p.x, p.y)
+	p.reset()
+	fmt.Printf("after reset=%d %d\n", p.x, p.y)
+
+	// automatic dereference of pointer
+	pp := &p
+	pp.move(1, 1)
+	fmt.Printf("via ptr sum=%d\n", pp.sum())
+
+	// chained calls and results of calls
+	fmt.Printf("scaled=%d\n", p.scaled(2).scaled(3).sum())
+	fmt.Printf("made=%d\n", makePoint(4, 5).sum())
+
+	// methods on non-struct named type
+	var c counter
+	c.inc()
+	c.inc()
+	c.inc()
+	fmt.Printf("counter=%d double=%d\n", c, c.double())
+
+	// methods reached through fields, elements and pointers
+	var list *node
+	for i := 1; i <= 5; i++ {
+		list = list.push(i)
+	}
+	fmt.Printf("total=%d next total=%d\n", list.total(), list.next.total())
+	pts := []point{{1, 1}, {2, 3}}
+	pts[1].move(1, 1)
+	fmt.Printf("pts[1].sum=%d\n", pts[1].sum())
+	seg := segment{point{0, 0}, point{3, 4}}
+	seg.to.move(3, 4)
+	fmt.Printf("len2=%d from.sum=%d\n", seg.length2(), seg.from.sum())
+
+	// multiple results
+	var s stack
+	for i := 0; i < 4; i++ {
+		s.push(i * i)
+	}
+	for {
+		v, ok := s.pop()
+		if !ok {
+			break
+		}
+		fmt.Printf("pop %d\n", v)
+	}
+
+	// method expressions
+	fmt.Printf("expr sum=%d\n", point.sum(p))
+	(*point).move(&p, 100, 100)
+	fmt.Printf("expr via ptr=%d\n", (*point).sum(&p))
+
+	// method values bind receiver when evaluated
+	q := point{1, 2}
+	sum := q.sum
+	move := q.move
+	move(10, 10)
+	fmt.Printf("value sum=%d now=%d\n", sum(), q.sum())
+	var ctr counter = 4
+	inc := ctr.inc
+	inc()
+	inc()
+	fmt.Printf("value counter=%d\n", ctr)
+
+	// method expressions as func values
+	moveBy := (*point).move
+	moveBy(&q, 1, 2)
+	sumOf := point.sum
+	sumPtr := (*point).sum
+	fmt.Printf("expr value sum=%d ptr=%d\n", sumOf(q), sumPtr(&q))
+	scale := point.scaled
+	fmt.Printf("expr value scaled=%d\n", scale(q, 2).sum())
+	incs := []func(*counter){(*counter).inc, (*counter).inc}
+	for _, f := range incs {
+		f(&ctr)
+	}
+	fmt.Printf("expr value counter=%d\n", ctr)
+}
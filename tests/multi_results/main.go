@@ -0,0 +1,115 @@
+package main
+
+import "fmt"
+
+// multiple results passed through func values, interfaces, methods and
+// deferred calls
+
+type Pair struct {
+	a, b string
+}
+
+func (p Pair) Swap() (string, string) {
+	return p.b, p.a
+}
+
+func (p *Pair) Set(a, b string) (old string, ok bool) {
+	old, p.a, p.b = p.a+p.b, a, b
+	return old, a != b
+}
+
+type Named struct {
+	Pair
+	id int
+}
+
+type Swapper interface {
+	Swap() (string, string)
+}
+
+func minmax(xs []int) (lo, hi int) {
+	lo, hi = xs[0], xs[0]
+	for _, x := range xs[1:] {
+		if x < lo {
+			lo = x
+		}
+		if x > hi {
+			hi = x
+		}
+	}
+	return
+}
+
+func split(s string) (string, int, bool) {
+	if len(s) == 0 {
+		return "", 0, false
+	}
+	return s[1:], int(s[0]), true
+}
+
+func report(lo, hi int) {
+	fmt.Println("report", lo, hi)
+}
+
+func sum3(a, b, c int) int {
+	return a + b + c
+}
+
+func three() (int, int, int) {
+	return 1, 2, 3
+}
+
+func recovered() (s string, n int) {
+	defer func() {
+		recover()
+		n++
+	}()
+	s, n = "partial", 41
+	panic("stop")
+}
+
+func main() {
+	lo, hi := minmax([]int{4, -2, 9, 7})
+	fmt.Println(lo, hi)
+
+	rest, c, ok := split("go")
+	fmt.Println(rest, c, ok)
+	_, _, ok = split("")
+	fmt.Println(ok)
+
+	fmt.Println(sum3(three()))
+
+	f := minmax
+	fmt.Println(f([]int{3, 1, 2}))
+	g := func(x int) (int, int) {
+		return x / 2, x % 2
+	}
+	q, r := g(7)
+	fmt.Println(q, r)
+
+	p := Pair{"x", "y"}
+	var s Swapper = p
+	fmt.Println(s.Swap())
+	old, changed := p.Set("u", "u")
+	fmt.Println(old, changed, p.a, p.b)
+
+	n := Named{Pair{"left", "right"}, 1}
+	fmt.Println(n.Swap())
+	s = n
+	fmt.Println(s.Swap())
+	s = &n
+	fmt.Println(s.Swap())
+
+	fmt.Println(recovered())
+
+	defer s.Swap()
+	defer n.Set("a", "b")
+	defer report(minmax([]int{5, 8, 6}))
+	defer fmt.Println(split("ab"))
+	done := make(chan bool)
+	go func() {
+		fmt.Println(p.Swap())
+		done <- true
+	}()
+	<-done
+}
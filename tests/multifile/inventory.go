@@ -0,0 +1,27 @@
+package main
+
+// initialized after variables of later files it depends on
+var reserve = limit/4 + len(labels)
+
+type inventory struct {
+	items []item
+	cap   int
+}
+
+func newInventory(n int) *inventory {
+	return &inventory{cap: n}
+}
+
+func (inv *inventory) add(it item) {
+	if len(inv.items) < inv.cap {
+		inv.items = append(inv.items, it)
+	}
+}
+
+func (inv *inventory) total() int {
+	sum := 0
+	for _, it := range inv.items {
+		sum += it.qty
+	}
+	return sum
+}
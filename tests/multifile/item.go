@@ -0,0 +1,29 @@
+package main
+
+type kind int
+
+const (
+	hardware kind = iota
+	supplies
+)
+
+const capacity = limit / 50
+
+type item struct {
+	name string
+	kind kind
+	qty  int
+}
+
+func (k kind) String() string {
+	if k == hardware {
+		return "hardware"
+	}
+	return "supplies"
+}
+
+var labels = makeLabels()
+
+func makeLabels() []string {
+	return []string{"hw" + suffix, "sp" + suffix}
+}
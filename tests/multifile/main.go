@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+// declared in other files
+var stock = newInventory(capacity)
+
+func main() {
+	stock.add(item{name: "bolt", kind: hardware, qty: 40})
+	stock.add(item{name: "nut", kind: hardware, qty: 25})
+	stock.add(item{name: "glue", kind: supplies, qty: 3})
+	report(stock)
+	fmt.Printf("total=%d limit=%d\n", stock.total(), limit)
+	var s summary = stock
+	fmt.Printf("summary: %s\n", s.describe())
+	fmt.Printf("reserve=%d labels=%v\n", reserve, labels)
+}
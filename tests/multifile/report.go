@@ -0,0 +1,24 @@
+package main
+
+import out "fmt"
+
+const limit = 100
+
+var suffix = "-" + out.Sprint(limit)
+
+type summary interface {
+	describe() string
+}
+
+func (inv *inventory) describe() string {
+	if inv.total() > limit/2 {
+		return "large"
+	}
+	return "small"
+}
+
+func report(inv *inventory) {
+	for i, it := range inv.items {
+		out.Printf("%d: %s (%s) x%d\n", i, it.name, it.kind.String(), it.qty)
+	}
+}
@@ -36,6 +36,6 @@ func main() {
 
 	a, b, c := sort3(40.9, 21.0, 167.0)
 
-	fmt.Printf("res = %d, ok = %d\n", res, ok)
+	fmt.Printf("res = %d, ok = %t\n", res, ok)
 	fmt.Printf("a = %f, b = %f, c = %f\n", a, b, c)
 }
@@ -0,0 +1,107 @@
+package main
+
+import "fmt"
+
+// named results, bare returns and deferred calls modifying results
+
+func count(s string) (n int) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' {
+			n++
+		}
+	}
+	return
+}
+
+func zero() (x int, s string, ok bool) {
+	return
+}
+
+func divmod(a, b int) (q, r int) {
+	q = a / b
+	r = a % b
+	return
+}
+
+func swapped(a, b int) (x, y int) {
+	x, y = a, b
+	return y, x
+}
+
+func double(a int) (n int) {
+	defer func() {
+		n *= 2
+	}()
+	return a + 1
+}
+
+func trace(a int) (n int) {
+	defer func() {
+		fmt.Println("deferred sees", n)
+		n += 100
+	}()
+	n = a
+	return n * 3
+}
+
+func safeDiv(a, b int) (q int, err string) {
+	defer func() {
+		if r := recover(); r != nil {
+			q, err = -1, "division by zero"
+		}
+	}()
+	if b == 0 {
+		panic("zero")
+	}
+	q = a / b
+	return
+}
+
+func shadow(a int) (n int) {
+	n = a
+	if a > 0 {
+		n := a * 10
+		fmt.Println("inner", n)
+	}
+	return
+}
+
+func counter() (next func() int, reset func()) {
+	c := 0
+	next = func() int {
+		c++
+		return c
+	}
+	reset = func() {
+		c = 0
+	}
+	return
+}
+
+func unnamed(a int) (_ int, _ bool) {
+	if a > 0 {
+		return a, true
+	}
+	return
+}
+
+func main() {
+	fmt.Println(count("a b c d"))
+	x, s, ok := zero()
+	fmt.Println(x, s == "", ok)
+	fmt.Println(divmod(17, 5))
+	fmt.Println(swapped(1, 2))
+	fmt.Println(double(4))
+	fmt.Println(trace(5))
+	fmt.Println(safeDiv(7, 2))
+	fmt.Println(safeDiv(7, 0))
+	fmt.Println(shadow(3))
+	next, reset := counter()
+	next()
+	next()
+	fmt.Println(next())
+	reset()
+	fmt.Println(next())
+	fmt.Println(unnamed(5))
+	fmt.Println(unnamed(-5))
+}
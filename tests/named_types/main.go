@@ -0,0 +1,187 @@
+package main
+
+import "fmt"
+
+type Celsius float64
+
+type Fahrenheit float64
+
+const Boiling Celsius = 100
+
+func (c Celsius) ToF() Fahrenheit {
+	return Fahrenheit(c*9/5 + 32)
+}
+
+func (c Celsius) Describe() string {
+	if c >= Boiling {
+		return "boiling"
+	} else if c <= 0 {
+		return "freezing"
+	}
+	return "mild"
+}
+
+type Counter int
+
+func (c *Counter) Inc() {
+	*c++
+}
+
+func (c Counter) Double() Counter {
+	return c * 2
+}
+
+type IntList []int
+
+func (l IntList) Sum() int {
+	s := 0
+	for _, v := range l {
+		s += v
+	}
+	return s
+}
+
+func (l *IntList) Push(v int) {
+	*l = append(*l, v)
+}
+
+type Set map[string]bool
+
+func (s Set) Add(k string) {
+	s[k] = true
+}
+
+func (s Set) Has(k string) bool {
+	return s[k]
+}
+
+type BinOp func(int, int) int
+
+func (op BinOp) Apply(a, b int) int {
+	return op(a, b)
+}
+
+type Point struct {
+	x int
+	y int
+}
+
+func (p Point) Sum() int {
+	return p.x + p.y
+}
+
+type Vec Point
+
+func (v Vec) Len2() int {
+	return v.x*v.x + v.y*v.y
+}
+
+type Describer interface {
+	Describe() string
+}
+
+type Name string
+
+func (n Name) Describe() string {
+	return "name " + string(n)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case int:
+		return "int"
+	case int32:
+		return "int32"
+	case Counter:
+		return "Counter"
+	case float64:
+		return "float64"
+	case Celsius:
+		return "Celsius"
+	case Fahrenheit:
+		return "Fahrenheit"
+	case string:
+		return "string"
+	case Name:
+		return "Name"
+	case IntList:
+		return "IntList"
+	case []int:
+		return "[]int"
+	}
+	return "other"
+}
+
+func main() {
+	c := Celsius(25)
+	f := c.ToF()
+	fmt.Printf("%.1f %.1f %s\n", float64(c), float64(f), c.Describe())
+	fmt.Printf("%s %s\n", Boiling.Describe(), Celsius(-5).Describe())
+	var raw float64 = float64(c) + 0.5
+	c = Celsius(raw)
+	fmt.Printf("%.2f %s\n", float64(c), yesNo(c > 25))
+
+	var n Counter
+	n.Inc()
+	n.Inc()
+	p := &n
+	p.Inc()
+	fmt.Printf("%d %d\n", int(n), int(n.Double()))
+	for i := range Counter(3) {
+		fmt.Printf("%d ", int(i))
+	}
+	fmt.Printf("\n")
+
+	l := IntList{1, 2, 3}
+	l.Push(4)
+	l = append(l, 5)
+	fmt.Printf("%d %d %d\n", len(l), l[4], l.Sum())
+	plain := []int(l)
+	fmt.Printf("%d\n", IntList(plain[1:3]).Sum())
+
+	s := make(Set)
+	s.Add("a")
+	fmt.Printf("%s %s %d\n", yesNo(s.Has("a")), yesNo(s.Has("b")), len(s))
+
+	var add BinOp = func(a, b int) int {
+		return a + b
+	}
+	fmt.Printf("%d %d\n", add(2, 3), add.Apply(4, 5))
+
+	v := Vec{3, 4}
+	pt := Point(v)
+	fmt.Printf("%d %d\n", v.Len2(), pt.Sum())
+
+	var ds []Describer
+	ds = append(ds, Celsius(0), Name("bob"), Boiling)
+	for _, d := range ds {
+		fmt.Printf("%s\n", d.Describe())
+	}
+
+	var r rune = 'x'
+	var i32 int32 = 7
+	values := []any{1, r, i32, n, 1.5, c, f, "s", Name("t"), l, plain}
+	for _, val := range values {
+		fmt.Printf("%s ", kind(val))
+	}
+	fmt.Printf("\n")
+
+	var x any = Celsius(10)
+	if t, ok := x.(Celsius); ok {
+		fmt.Printf("celsius %.1f\n", float64(t))
+	}
+	if _, ok := x.(float64); !ok {
+		fmt.Printf("not float64\n")
+	}
+
+	m := map[Name]Celsius{"a": 1, "b": 2}
+	m["a"] += 10
+	fmt.Printf("%.1f %.1f\n", float64(m["a"]), float64(m[Name("b")]))
+}
@@ -0,0 +1,108 @@
+package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Segment struct {
+	From, To Point
+	Tags     [2]string
+}
+
+type Node struct {
+	Name     string
+	Children []*Node
+}
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+var colorNames = [...]string{
+	Red:   "red",
+	Green: "green",
+	Blue:  "blue",
+}
+
+type op struct {
+	name string
+	args [2]int
+	want int
+}
+
+var table = []op{
+	{"add", [2]int{1, 2}, 3},
+	{name: "sub", args: [2]int{5, 3}, want: 2},
+	{"mul", [...]int{4, 5}, 20},
+}
+
+func count(n *Node) int {
+	total := 1
+	for _, c := range n.Children {
+		total += count(c)
+	}
+	return total
+}
+
+func main() {
+	// arrays and slices of structs
+	pts := [...]Point{{1, 2}, {X: 3}, {Y: 4}}
+	fmt.Println(len(pts), pts)
+	grid := [][]Point{{{1, 1}, {2, 2}}, {}, {{Y: 9}}}
+	fmt.Println(grid, len(grid[1]))
+	matrix := [2][3]int{{1, 2, 3}, {4, 5, 6}}
+	fmt.Println(matrix, matrix[1][2])
+
+	// structs of arrays and structs
+	segs := []Segment{
+		{From: Point{0, 0}, To: Point{3, 4}, Tags: [2]string{"a", "b"}},
+		{Point{1, 1}, Point{2, 2}, [2]string{1: "only"}},
+	}
+	fmt.Printf("%+v\n", segs)
+
+	// elided &T
+	ptrs := []*Point{{1, 2}, {}, {Y: 7}}
+	for _, p := range ptrs {
+		fmt.Print(*p, " ")
+	}
+	fmt.Println()
+	tree := &Node{"root", []*Node{
+		{"a", []*Node{{"a1", nil}, {Name: "a2"}}},
+		{Name: "b"},
+	}}
+	fmt.Println(count(tree), tree.Children[0].Children[1].Name)
+
+	// maps with elided key and element types
+	byPoint := map[Point]string{{1, 2}: "p12", {0, 0}: "origin"}
+	fmt.Println(byPoint[Point{1, 2}], byPoint[Point{}], len(byPoint))
+	lines := map[string][]Point{"diag": {{0, 0}, {1, 1}}, "empty": {}}
+	fmt.Println(lines["diag"], len(lines["empty"]))
+	refs := map[string]*Point{"p": {5, 6}}
+	fmt.Println(refs["p"].X, refs["p"].Y)
+
+	// [...] arrays sized by the highest index
+	sparse := [...]int{5: 1, 2, 1: 7}
+	fmt.Println(len(sparse), sparse)
+	fmt.Println(len(colorNames), colorNames[Green], colorNames)
+	empty := [...]string{}
+	fmt.Println(len(empty))
+
+	for _, t := range table {
+		var got int
+		switch t.name {
+		case "add":
+			got = t.args[0] + t.args[1]
+		case "sub":
+			got = t.args[0] - t.args[1]
+		case "mul":
+			got = t.args[0] * t.args[1]
+		}
+		fmt.Println(t.name, got == t.want)
+	}
+}
@@ -0,0 +1,50 @@
+package geom
+
+import (
+	"example.com/shapes/internal/counter"
+	"fmt"
+)
+
+const Scale = 10
+
+type Unit int
+
+const (
+	Meter Unit = iota + 1
+	Kilometer
+)
+
+// Point is point on plane.
+type Point struct {
+	X, Y int
+	id   int
+}
+
+var Origin = NewPoint(0, 0)
+
+func NewPoint(x, y int) Point {
+	return Point{X: x, Y: y, id: counter.Next()}
+}
+
+func (p Point) ID() int {
+	return p.id
+}
+
+func (p Point) Dist2(q Point) int {
+	dx := p.X - q.X
+	dy := p.Y - q.Y
+	return dx*dx + dy*dy
+}
+
+func (p *Point) Move(dx, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+
+func (p Point) Print(label string) {
+	fmt.Printf("%s=(%d,%d)\n", label, p.X, p.Y)
+}
+
+func Add(a, b int) int {
+	return a + b
+}
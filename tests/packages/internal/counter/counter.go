@@ -0,0 +1,19 @@
+package counter
+
+import "fmt"
+
+// Calls counts calls of Next.
+var Calls int
+
+var start = initStart()
+
+func initStart() int {
+	fmt.Printf("counter initialized\n")
+	return 100
+}
+
+// Next returns next unique identifier.
+func Next() int {
+	Calls++
+	return start + Calls
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"example.com/shapes/geom"
+	"example.com/shapes/internal/counter"
+	sh "example.com/shapes/shape"
+	"fmt"
+)
+
+// grid is sized by constant of imported package
+var grid [geom.Scale]int
+
+type labeled struct {
+	label string
+}
+
+func (l labeled) Area() int {
+	return len(l.label)
+}
+
+func (l labeled) Name() string {
+	return l.label
+}
+
+func apply(f func(int, int) int, a, b int) int {
+	return f(a, b)
+}
+
+func describe(s sh.Shape) {
+	fmt.Printf("%s: %d\n", s.Name(), s.Area())
+}
+
+func main() {
+	p := geom.NewPoint(3, 4)
+	p.Print("p")
+	geom.Origin.Print("origin")
+	fmt.Printf("ids=%d,%d\n", p.ID(), geom.Origin.ID())
+	fmt.Printf("dist2=%d\n", p.Dist2(geom.Origin))
+	p.Move(1, 1)
+	p.Print("moved")
+	pp := &p
+	pp.Move(-2, 0)
+	pp.Print("moved again")
+
+	// package variables are shared
+	geom.Origin.X = 7
+	geom.Origin.Move(0, 2)
+	geom.Origin.Print("origin")
+	counter.Calls += 10
+	fmt.Printf("next=%d calls=%d\n", counter.Next(), counter.Calls)
+
+	// constants and named types
+	var u geom.Unit = geom.Kilometer
+	fmt.Printf("scale=%d unit=%d meter=%d\n", geom.Scale*2, int(u), int(geom.Meter))
+	var cells [geom.Scale / 2]int
+	for i := range cells {
+		cells[i] = i * i
+		grid[2*i] = cells[i]
+	}
+	fmt.Printf("grid=%v len=%d cells=%d\n", grid, len(grid), len(cells))
+
+	// functions as values
+	add := geom.Add
+	fmt.Printf("add=%d apply=%d\n", add(2, 3), apply(geom.Add, 4, 5))
+
+	// interfaces across packages
+	r := sh.NewRect(0, 0, 3, 2)
+	sq := &sh.Square{Corner: geom.NewPoint(1, 1), Side: 4}
+	shapes := []sh.Shape{r, sq, labeled{"hello"}}
+	for _, s := range shapes {
+		describe(s)
+	}
+	fmt.Printf("total=%d count=%d\n", sh.Total(shapes), sh.Count)
+	var s sh.Shape = sh.NewRect(1, 1, 2, 2)
+	if rect, ok := s.(sh.Rect); ok {
+		rect.Min.Print("min")
+		rect.Max.Print("max")
+	}
+	fmt.Printf("count=%d calls=%d\n", sh.Count, counter.Calls)
+}
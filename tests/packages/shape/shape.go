@@ -0,0 +1,51 @@
+package shape
+
+import (
+	g "example.com/shapes/geom"
+)
+
+// Shape has area.
+type Shape interface {
+	Area() int
+	Name() string
+}
+
+type Rect struct {
+	Min, Max g.Point
+}
+
+func (r Rect) Area() int {
+	return (r.Max.X - r.Min.X) * (r.Max.Y - r.Min.Y)
+}
+
+func (r Rect) Name() string {
+	return "rect"
+}
+
+type Square struct {
+	Corner g.Point
+	Side   int
+}
+
+func (s *Square) Area() int {
+	return s.Side * s.Side
+}
+
+func (s *Square) Name() string {
+	return "square"
+}
+
+var Count int
+
+func NewRect(x0, y0, x1, y1 int) Rect {
+	Count++
+	return Rect{Min: g.NewPoint(x0, y0), Max: g.NewPoint(x1, y1)}
+}
+
+func Total(shapes []Shape) int {
+	sum := 0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
@@ -0,0 +1,274 @@
+package main
+
+import "fmt"
+
+type MyErr struct {
+	code int
+}
+
+func (e MyErr) Error() string {
+	if e.code == 1 {
+		return "code one"
+	}
+	return "other code"
+}
+
+type Counter struct {
+	n int
+}
+
+func (c *Counter) SafeInc(fail bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("method recovered, n=%d\n", c.n)
+		}
+	}()
+	if fail {
+		panic("inc failed")
+	}
+	c.n = c.n + 1
+}
+
+func depth(n int) int {
+	defer fmt.Printf("unwind depth %d\n", n)
+	if n == 0 {
+		panic("bottom reached")
+	}
+	return depth(n-1) + 1
+}
+
+func describe(r any) {
+	switch v := r.(type) {
+	case nil:
+		fmt.Printf("nothing\n")
+	case string:
+		fmt.Printf("string: %s\n", v)
+	case int:
+		fmt.Printf("int: %d\n", v)
+	case error:
+		fmt.Printf("error: %s\n", v.Error())
+	default:
+		fmt.Printf("other\n")
+	}
+}
+
+func try(name string, f func()) {
+	defer func() {
+		r := recover()
+		fmt.Printf("%s -> ", name)
+		describe(r)
+	}()
+	f()
+}
+
+func divide(a, b int) (int, int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("divide recovered\n")
+		}
+	}()
+	if b == 0 {
+		panic(MyErr{1})
+	}
+	return a / b, a % b
+}
+
+func deferOrder() {
+	for i := 0; i < 3; i++ {
+		defer fmt.Printf("loop defer %d\n", i)
+	}
+	defer func() {
+		fmt.Printf("recover in order: ")
+		describe(recover())
+	}()
+	panic(7)
+}
+
+func repanic() {
+	defer func() {
+		r := recover()
+		fmt.Printf("repanic saw: ")
+		describe(r)
+		panic("second")
+	}()
+	panic("first")
+}
+
+func replaced() {
+	defer fmt.Printf("replaced: last defer still runs\n")
+	defer func() {
+		panic("replacement")
+	}()
+	panic("original")
+}
+
+func panicAfterReturn() int {
+	defer fmt.Printf("after return: outer defer runs\n")
+	defer func() {
+		panic("in deferred call")
+	}()
+	return 5
+}
+
+func earlyReturn(stop bool) {
+	for i := 0; i < 2; i++ {
+		if stop && i == 1 {
+			return
+		}
+		defer fmt.Printf("early defer %d\n", i)
+	}
+}
+
+func worker(id int, done chan string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s, _ := r.(string)
+			done <- s
+			return
+		}
+		done <- "ok"
+	}()
+	if id%2 == 1 {
+		panic(":failed")
+	}
+}
+
+func main() {
+	try("depth", func() {
+		fmt.Printf("depth=%d\n", depth(3))
+	})
+	try("none", func() {})
+	try("nil recover", func() {
+		describe(recover())
+	})
+	try("custom error", func() {
+		panic(MyErr{2})
+	})
+	try("nil map", func() {
+		var m map[string]int
+		m["a"] = 1
+	})
+	try("assertion", func() {
+		var x any = "str"
+		n := x.(int)
+		fmt.Printf("unreachable %d\n", n)
+	})
+	try("closed channel", func() {
+		ch := make(chan int)
+		close(ch)
+		close(ch)
+	})
+	try("order", deferOrder)
+	try("repanic", repanic)
+	try("replaced", replaced)
+	try("after return", func() {
+		fmt.Printf("value %d\n", panicAfterReturn())
+	})
+
+	// bounds of index and slice expressions are checked
+	nums := []int{1, 2, 3}
+	arr := [4]int{}
+	word := "go"
+	i, j, k := 5, 1, -1
+	try("slice index", func() {
+		nums[i] = 0
+	})
+	try("negative index", func() {
+		fmt.Printf("unreachable %d\n", nums[k])
+	})
+	try("array index", func() {
+		fmt.Printf("unreachable %d\n", arr[i])
+	})
+	try("string index", func() {
+		fmt.Printf("unreachable %d\n", word[i-3])
+	})
+	try("slice high", func() {
+		fmt.Printf("unreachable %d\n", len(nums[:i]))
+	})
+	try("slice low", func() {
+		fmt.Printf("unreachable %d\n", len(nums[i-2:j]))
+	})
+	try("slice from", func() {
+		fmt.Printf("unreachable %d\n", len(nums[i:]))
+	})
+	try("array high", func() {
+		fmt.Printf("unreachable %d\n", len(arr[j:i]))
+	})
+	try("string high", func() {
+		fmt.Printf("unreachable %s\n", word[j:i])
+	})
+	try("slice max", func() {
+		fmt.Printf("unreachable %d\n", len(nums[0:j:i]))
+	})
+	try("slice max low", func() {
+		fmt.Printf("unreachable %d\n", len(nums[0:i-3:j]))
+	})
+	try("slice negative", func() {
+		fmt.Printf("unreachable %d\n", len(nums[k:]))
+	})
+	fmt.Printf("in bounds %d %d %s %d\n", len(nums[j:3]), cap(nums[:j:2]), word[j:], arr[len(arr)-1])
+
+	// integer division by zero and faults through nil pointers panic
+	zero := j - 1
+	var uzero uint8
+	try("divide", func() {
+		fmt.Printf("unreachable %d\n", i/zero)
+	})
+	try("remainder", func() {
+		fmt.Printf("unreachable %d\n", i%zero)
+	})
+	try("unsigned divide", func() {
+		fmt.Printf("unreachable %d\n", uint8(i)/uzero)
+	})
+	var minInt int32 = -2147483648
+	minusOne := int32(k)
+	fmt.Printf("overflow %d %d\n", minInt/minusOne, minInt%minusOne)
+	var pc *Counter
+	var fn func() int
+	try("nil pointer", func() {
+		fmt.Printf("unreachable %d\n", pc.n)
+	})
+	try("nil store", func() {
+		pc.n = 1
+	})
+	try("nil method", func() {
+		pc.SafeInc(false)
+	})
+	try("nil func", func() {
+		fmt.Printf("unreachable %d\n", fn())
+	})
+	try("after fault", func() {
+		var p *int
+		*p = 1
+	})
+
+	q, r := divide(7, 2)
+	fmt.Printf("divide: %d %d\n", q, r)
+	q, r = divide(7, 0)
+	fmt.Printf("divide: %d %d\n", q, r)
+
+	c := &Counter{}
+	for i := 0; i < 5; i++ {
+		c.SafeInc(i%2 == 0)
+	}
+	fmt.Printf("counter: %d\n", c.n)
+
+	earlyReturn(true)
+	earlyReturn(false)
+
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go worker(i, done)
+	}
+	results := 0
+	for i := 0; i < 4; i++ {
+		if s := <-done; s != "ok" {
+			results++
+		}
+	}
+	fmt.Printf("failed workers: %d\n", results)
+
+	defer fmt.Printf("main defer runs before crash\n")
+	var e error = MyErr{1}
+	panic(e)
+}
@@ -0,0 +1,118 @@
+package main
+
+import "fmt"
+
+type item struct {
+	name  string
+	price int
+}
+
+func sumArray(arr *[5]int) int {
+	total := 0
+	for _, v := range arr {
+		total += v
+	}
+	return total
+}
+
+func main() {
+	// arrays with index and value
+	primes := [5]int{2, 3, 5, 7, 11}
+	for i, p := range primes {
+		fmt.Printf("%d:%d ", i, p)
+	}
+	fmt.Printf("\n")
+
+	// range over copy of array
+	for i, p := range primes {
+		if i+1 < len(primes) {
+			primes[i+1] = 0
+		}
+		fmt.Printf("%d ", p)
+	}
+	fmt.Printf("\n")
+
+	// pointer to array is not copied
+	squares := [5]int{1, 2, 3, 4, 5}
+	ptr := &squares
+	for i := range ptr {
+		ptr[i] = ptr[i] * ptr[i]
+	}
+	fmt.Printf("%d %d\n", squares[4], sumArray(ptr))
+
+	// index only and blank identifiers
+	count := 0
+	for range squares {
+		count++
+	}
+	for _, _ = range squares {
+		count++
+	}
+	for i, _ := range squares {
+		count += i
+	}
+	fmt.Printf("count=%d\n", count)
+
+	// assignment form
+	var idx, val int
+	for idx, val = range [3]int{7, 8, 9} {
+	}
+	fmt.Printf("idx=%d val=%d\n", idx, val)
+
+	// break and continue
+	for i, p := range [8]int{1, 2, 3, 4, 5, 6, 7, 8} {
+		if p%2 == 0 {
+			continue
+		}
+		if i > 5 {
+			break
+		}
+		fmt.Printf("%d ", p)
+	}
+	fmt.Printf("\n")
+
+	// slices of structs
+	items := []item{{"apple", 3}, {"pear", 5}, {"plum", 2}}
+	total := 0
+	for _, it := range items {
+		total += it.price
+	}
+	fmt.Printf("total=%d\n", total)
+
+	// strings are decoded into runes
+	for i, r := range "héllo, 世界" {
+		fmt.Printf("%d:%d ", i, r)
+	}
+	fmt.Printf("\n")
+	runes := 0
+	for range "日本語" {
+		runes++
+	}
+	fmt.Printf("runes=%d\n", runes)
+	for i, r := range "a\xffb" {
+		fmt.Printf("%d:%d ", i, r)
+	}
+	fmt.Printf("\n")
+
+	// integers
+	sum := 0
+	for i := range 10 {
+		sum += i
+	}
+	var n int64 = 4
+	for i := range n {
+		fmt.Printf("%d ", int(i*i))
+	}
+	fmt.Printf("sum=%d\n", sum)
+
+	// nested loops
+	for i := range 3 {
+		for j := range 3 {
+			if j > i {
+				break
+			}
+			fmt.Printf("(%d,%d)", i, j)
+		}
+	}
+	fmt.Printf("\n")
+}
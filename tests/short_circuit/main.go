@@ -0,0 +1,94 @@
+package main
+
+import "fmt"
+
+type node struct {
+	value int
+	next  *node
+}
+
+var calls int
+
+func check(name string, res bool) bool {
+	calls++
+	fmt.Printf("%s ", name)
+	return res
+}
+
+func positive(p *node) bool {
+	return p != nil && p.value > 0
+}
+
+func find(head *node, value int) *node {
+	p := head
+	for p != nil && p.value != value {
+		p = p.next
+	}
+	return p
+}
+
+func classify(n int) string {
+	switch {
+	case n < 0 || n > 100:
+		return "out of range"
+	case n%2 == 0 && n%3 == 0:
+		return "multiple of 6"
+	case n%2 == 0 || n%3 == 0:
+		return "multiple of 2 or 3"
+	}
+	return "other"
+}
+
+func main() {
+	// nil guards
+	var empty *node
+	list := &node{3, &node{-1, &node{7, nil}}}
+	fmt.Printf("%d %d\n", boolInt(positive(empty)), boolInt(positive(list)))
+	if p := find(list, 7); p != nil && p.next == nil {
+		fmt.Printf("found last %d\n", p.value)
+	}
+	if find(list, 5) == nil || find(list, 5).value == 5 {
+		fmt.Printf("5 not found\n")
+	}
+
+	// evaluation order and skipped operands
+	calls = 0
+	if check("a", false) && check("b", true) {
+		fmt.Printf("unreachable")
+	}
+	if check("c", true) || check("d", true) {
+		fmt.Printf("taken ")
+	}
+	if (check("e", true) && check("f", false)) || (check("g", false) || check("h", true)) {
+		fmt.Printf("nested ")
+	}
+	r := !(check("i", false) || check("j", false)) && check("k", true)
+	fmt.Printf("\nresult %d, %d calls\n", boolInt(r), calls)
+
+	// operands creating new blocks
+	m := map[string]int{"a": 1, "b": 2}
+	sum := m["a"] + m["b"]*10 + m["c"]
+	fmt.Printf("sum %d\n", sum)
+	if _, ok := m["a"]; ok && m["b"] == 2 && (m["c"] == 0 || m["c"] == 1) {
+		fmt.Printf("map guards ok\n")
+	}
+	ok := len(m) > 1 && m["a"] < m["b"]
+	fmt.Printf("%d\n", boolInt(ok))
+
+	// conditions of loops and switches
+	for _, n := range []int{-5, 12, 9, 7, 101} {
+		fmt.Printf("%d: %s\n", n, classify(n))
+	}
+	i := 0
+	for i < 10 && (i*i < 20 || i == 0) {
+		i++
+	}
+	fmt.Printf("i = %d\n", i)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
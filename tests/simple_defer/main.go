@@ -15,7 +15,17 @@ func bar(val int) {
 	}
 }
 
+func bye() {
+	fmt.Printf("bye\n")
+}
+
+func hi() {
+	defer bye()
+	fmt.Printf("hi ")
+}
+
 func main() {
+	hi()
 	defer fmt.Printf("defer no.1\n")
 	defer fmt.Printf("defer no.2\n")
 	foo(123)
@@ -0,0 +1,105 @@
+package main
+
+import "fmt"
+
+type point struct {
+	x int
+	y int
+}
+
+type grid struct {
+	name  int
+	cells []int
+}
+
+func sum(nums []int) int {
+	total := 0
+	for i := 0; i < len(nums); i++ {
+		total += nums[i]
+	}
+	return total
+}
+
+func squares(n int) []int {
+	var res []int
+	for i := 0; i < n; i++ {
+		res = append(res, i*i)
+	}
+	return res
+}
+
+func printSlice(nums []int) {
+	fmt.Printf("len=%d cap=%d [", len(nums), cap(nums))
+	for i := 0; i < len(nums); i++ {
+		if i > 0 {
+			fmt.Printf(" ")
+		}
+		fmt.Printf("%d", nums[i])
+	}
+	fmt.Printf("]\n")
+}
+
+func main() {
+	// make with length and capacity
+	s := make([]int, 3, 10)
+	s[0] = 4
+	s[2] = 7
+	printSlice(s)
+
+	// append within and beyond capacity
+	s = append(s, 1, 2)
+	printSlice(s)
+	var empty []int
+	if empty == nil {
+		fmt.Printf("nil slice\n")
+	}
+	printSlice(squares(10))
+	fmt.Printf("sum = %d\n", sum(squares(5)))
+
+	// slice expressions on arrays and slices
+	arr := [6]int{10, 20, 30, 40, 50, 60}
+	mid := arr[1:4]
+	printSlice(mid)
+	mid[0] = 21
+	fmt.Printf("arr[1] = %d\n", arr[1])
+	printSlice(arr[:2])
+	printSlice(arr[4:])
+	printSlice(mid[1:2:3])
+	pa := &arr
+	printSlice(pa[2:5])
+
+	// appending a slice to a slice
+	tail := []int{7, 8, 9}
+	s = append(s, tail...)
+	printSlice(s)
+	n := copy(s, tail[1:])
+	fmt.Printf("copied %d\n", n)
+	printSlice(s)
+
+	// composite literals
+	pts := []point{{1, 2}, {x: 3}, 4: {y: 5}}
+	for i := 0; i < len(pts); i++ {
+		fmt.Printf("{%d %d} ", pts[i].x, pts[i].y)
+	}
+	fmt.Printf("\n")
+	matrix := [][]int{{1}, {2, 3}, {}}
+	matrix[2] = append(matrix[2], 4, 5, 6)
+	for i := 0; i < len(matrix); i++ {
+		printSlice(matrix[i])
+	}
+
+	// slices as struct fields
+	g := &grid{name: 1}
+	g.cells = make([]int, 2)
+	g.cells = append(g.cells, 3)
+	g.cells[0] = 1
+	printSlice(g.cells)
+
+	// bytes from strings
+	word := "lo!"
+	b := append([]byte("hel"), word...)
+	b = append(b, "?"...)
+	fmt.Printf("%s %d\n", string(b), len(b))
+	n = copy(b[1:], "EL")
+	fmt.Printf("%s %d\n", string(b), n)
+}
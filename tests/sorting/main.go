@@ -18,7 +18,7 @@ func main() {
 	}
 
 	for index = 0; index < size; index++ {
-		fmt.Printf("Введите %u-е число: ", index+1)
+		fmt.Printf("Введите %d-е число: ", index+1)
 		fmt.Scanf("%d", &arr[index])
 	}
 	fmt.Printf("\n")
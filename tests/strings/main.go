@@ -0,0 +1,144 @@
+package main
+
+import "fmt"
+
+type word struct {
+	text  string
+	count int
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func join(parts []string, sep string) string {
+	res := ""
+	for i, p := range parts {
+		if i > 0 {
+			res += sep
+		}
+		res += p
+	}
+	return res
+}
+
+func split(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == sep {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func sortStrings(a []string) {
+	for i := 1; i < len(a); i++ {
+		for j := i; j > 0 && a[j] < a[j-1]; j-- {
+			a[j], a[j-1] = a[j-1], a[j]
+		}
+	}
+}
+
+func upper(s string) string {
+	b := []byte(s)
+	for i := 0; i < len(b); i++ {
+		if b[i] >= 'a' && b[i] <= 'z' {
+			b[i] = b[i] - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+func kind(s string) string {
+	switch s {
+	case "":
+		return "empty"
+	case "go", "c":
+		return "language"
+	}
+	return "other"
+}
+
+func str(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func main() {
+	// length, indexing and slicing
+	s := "hello, world"
+	fmt.Printf("%s has %d bytes\n", s, len(s))
+	fmt.Printf("s[0] = %d, s[7] = %c\n", s[0], s[7])
+	fmt.Printf("[%s] [%s] [%s]\n", s[:5], s[7:], s[3:9])
+	fmt.Printf("%d %c\n", len("abc"[1:]), "xyz"[2])
+
+	// concatenation and comparison
+	t := s[:5] + "!" + " " + s[7:]
+	fmt.Printf("%s (%d)\n", t, len(t))
+	fmt.Printf("%s %s %s\n", str(t == "hello! world"), str(s != t), str(s[:5] == "hello"))
+	fmt.Printf("%s %s %s %s\n", str("abc" < "abd"), str("ab" < "abc"), str("b" > "abc"), str("" <= ""))
+
+	// strings may contain NUL bytes
+	z := "a\x00b"
+	fmt.Printf("%d %d %s\n", len(z), z[2], str(z == "a"))
+
+	// conversions
+	fmt.Printf("%s\n", upper("Hello, Gophers"))
+	fmt.Printf("%s\n", reverse("stressed"))
+	u := "héllo, 世界"
+	fmt.Printf("%d bytes, %d runes\n", len(u), len([]rune(u)))
+	fmt.Printf("%s\n", reverse(u))
+	fmt.Printf("%s%s%s\n", string(rune(72)), string('i'), string([]byte{33, 10}))
+	r := 19990
+	fmt.Printf("%s %d\n", string(rune(r)), len(string(rune(r))))
+
+	// range yields runes
+	for i, c := range "aé世" {
+		fmt.Printf("%d:%d ", i, c)
+	}
+	fmt.Printf("\n")
+
+	// untyped rune constants default to rune
+	const last = 'z'
+	ch := '世'
+	next := ch + 1
+	var code int32 = ch
+	var found []rune
+	for _, c := range "a世b" {
+		if c == ch || c == 'b' {
+			found = append(found, c, ch)
+		}
+	}
+	fmt.Printf("%T %T %T %d %d %v\n", ch, next, last-1, code, len(found), found[1] == '世')
+
+	// split, sort and join
+	words := split("pear,apple,fig,banana,apple", ',')
+	sortStrings(words)
+	fmt.Printf("%s\n", join(words, " "))
+
+	// string keys of maps and struct fields
+	counts := make(map[string]int)
+	for _, w := range words {
+		counts[w] += 1
+	}
+	key := "app"
+	key += "le"
+	fmt.Printf("%d %d %d\n", len(counts), counts[key], counts["kiwi"])
+	list := []word{{"go", 2}, {"c", 1}, {"", 0}}
+	for _, w := range list {
+		fmt.Printf("[%s] %d %s\n", w.text, w.count, kind(w.text))
+	}
+
+	// zero value
+	var empty string
+	fmt.Printf("[%s] %d %s\n", empty, len(empty), str(empty == ""))
+}
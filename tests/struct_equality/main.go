@@ -0,0 +1,107 @@
+package main
+
+import "fmt"
+
+type point struct {
+	x, y int
+}
+
+type label struct {
+	name string
+	pos  point
+}
+
+type sample struct {
+	v    float64
+	tags [2]string
+}
+
+type key struct {
+	id  int
+	val any
+}
+
+type grid [2][2]int
+
+type celsius float64
+
+type reading struct {
+	temp celsius
+	ok   bool
+	ch   chan int
+	p    *point
+}
+
+func main() {
+	p1 := point{1, 2}
+	p2 := point{1, 2}
+	p3 := point{2, 1}
+	fmt.Println(p1 == p2, p1 != p2, p1 == p3, p1 != p3, p1 == point{1, 2})
+
+	// nested structs with strings built at run time
+	s := "na"
+	l1 := label{s + "me", p1}
+	l2 := label{"name", p2}
+	fmt.Println(l1 == l2, l1 == label{"name", p3}, l1.pos == l2.pos)
+
+	// arrays
+	a1 := [3]int{1, 2, 3}
+	a2 := [3]int{1, 2, 3}
+	a3 := [3]int{1, 2, 4}
+	fmt.Println(a1 == a2, a1 != a3, [2]string{"a", s} == [2]string{"a", "na"})
+	g1 := grid{{1, 2}, {3, 4}}
+	g2 := grid{{1, 2}, {3, 4}}
+	g2[1][1]++
+	fmt.Println(g1 == g2, g1 != g2)
+	pts := [2]point{{1, 2}, {3, 4}}
+	fmt.Println(pts == [2]point{{1, 2}, {3, 4}}, pts == [2]point{})
+
+	// floats compare by value: NaN is not equal to itself, zeros are equal
+	zero := 0.0
+	nan := zero / zero
+	fmt.Println(sample{nan, [2]string{}} == sample{nan, [2]string{}})
+	fmt.Println(sample{0, [2]string{"x"}} == sample{-zero, [2]string{"x"}})
+	fs := [2]float64{nan, 1}
+	fmt.Println(fs == fs, fs != fs)
+
+	// interface fields compare dynamic values
+	k1 := key{1, "a"}
+	k2 := key{1, "a"}
+	k3 := key{1, 1}
+	fmt.Println(k1 == k2, k1 == k3, key{} == key{})
+
+	// named types, pointers and channels
+	c := make(chan int)
+	r1 := reading{21.5, true, c, &p1}
+	r2 := reading{21.5, true, c, &p1}
+	r3 := reading{21.5, true, c, &p2}
+	pa, pb := r1.p, r3.p
+	same := *pa == *pb
+	fmt.Println(r1 == r2, r1 == r3, same)
+
+	// comparison in switch and as map key
+	switch p3 {
+	case p1:
+		fmt.Println("p1")
+	case point{2, 1}:
+		fmt.Println("swapped")
+	}
+	seen := map[point]bool{p1: true}
+	fmt.Println(seen[p2], seen[p3])
+
+	// operand of unnamed type is compared with value of named type
+	var anon struct {
+		x, y int
+	}
+	anon.x, anon.y = 2, 1
+	cells := [2][2]int{{1, 2}, {3, 4}}
+	fmt.Println(anon == point{2, 1}, p1 != anon, grid{{1, 2}, {3, 4}} == cells)
+
+	var anyPoint any = p1
+	fmt.Println(anyPoint == p2, anyPoint == any(p3))
+	defer func() {
+		fmt.Println("recovered:", recover())
+	}()
+	var f1, f2 any = []int{1}, []int{1}
+	fmt.Println(f1 == f2)
+}
@@ -0,0 +1,140 @@
+package main
+
+import "fmt"
+
+const (
+	stateIdle = 0
+	stateRun  = 1
+	stateStop = 2
+)
+
+func classify(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n == 0:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func dayKind(day int) int {
+	switch day {
+	case 0, 6:
+		return 1
+	case 1, 2, 3, 4, 5:
+		return 2
+	}
+	return 0
+}
+
+// state machine with constant cases
+func step(state int, input int) int {
+	switch state {
+	case stateIdle:
+		if input > 0 {
+			return stateRun
+		}
+	case stateRun:
+		if input == 0 {
+			return stateStop
+		}
+	case stateStop:
+		return stateIdle
+	}
+	return state
+}
+
+func threshold() int {
+	fmt.Printf("threshold ")
+	return 10
+}
+
+func main() {
+	fmt.Printf("%d %d %d\n", classify(-5), classify(0), classify(7))
+	fmt.Printf("%d %d %d\n", dayKind(0), dayKind(3), dayKind(9))
+
+	state := stateIdle
+	inputs := [6]int{0, 1, 1, 0, 5, 3}
+	for i := 0; i < 6; i++ {
+		state = step(state, inputs[i])
+		fmt.Printf("%d", state)
+	}
+	fmt.Printf("\n")
+
+	// default in the middle and fallthrough
+	for i := 0; i < 5; i++ {
+		switch i {
+		case 0:
+			fmt.Printf("zero ")
+			fallthrough
+		default:
+			fmt.Printf("default ")
+		case 3:
+			fmt.Printf("three ")
+			fallthrough
+		case 4:
+			fmt.Printf("four ")
+		}
+		fmt.Printf("| ")
+	}
+	fmt.Printf("\n")
+
+	// init statement and non-constant cases evaluated lazily
+	switch x := 5 * 2; x {
+	case 1 + 1:
+		fmt.Printf("two\n")
+	case threshold():
+		fmt.Printf("ten\n")
+	case threshold() + 1:
+		fmt.Printf("eleven\n")
+	}
+
+	// tagless switch with init statement
+	switch y := 42; {
+	case y > 100:
+		fmt.Printf("big\n")
+	case y > 10:
+		fmt.Printf("medium\n")
+	}
+
+	// break inside switch and continue of enclosing loop
+	for i := 0; i < 6; i++ {
+		switch i % 3 {
+		case 0:
+			if i > 2 {
+				break
+			}
+			fmt.Printf("a%d ", i)
+		case 1:
+			continue
+		default:
+			fmt.Printf("b%d ", i)
+		}
+		fmt.Printf("end%d ", i)
+	}
+	fmt.Printf("\n")
+
+	// switch on other types
+	var f float64 = 2.5
+	switch f {
+	case 1.5:
+		fmt.Printf("1.5\n")
+	case 2.5:
+		fmt.Printf("2.5\n")
+	}
+	var big int64 = 3000000000
+	switch big {
+	case 1:
+		fmt.Printf("one\n")
+	case 3000000000:
+		fmt.Printf("3e9\n")
+	}
+	switch flag := f > 2.0; flag {
+	case true:
+		fmt.Printf("yes\n")
+	case false:
+		fmt.Printf("no\n")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build ignore
+
+package main
+
+func twice(n int) int {
+	return n * 2
+}
+
+func broken(n int) int {
+	if n > 0 {
+		return n +
+	}
+	return -n
+}
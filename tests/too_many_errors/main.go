@@ -0,0 +1,24 @@
+//go:build ignore
+
+package main
+
+import "fmt"
+
+func main() {
+	var s string
+	var n int
+	var b bool
+	s = n
+	n = s
+	b = n
+	n = b
+	s = b
+	b = s
+	a1 := n + s
+	a2 := b + s
+	a3 := n + b
+	a4 := s - s
+	a5 := b * b
+	a6 := -s
+	fmt.Printf("%d %d %d %d %d %d\n", a1, a2, a3, a4, a5, a6)
+}
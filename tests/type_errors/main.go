@@ -0,0 +1,43 @@
+//go:build ignore
+
+package main
+
+import "fmt"
+
+type Celsius int
+
+func mismatched(c Celsius, n int) bool {
+	s := "deg"
+	fmt.Printf("%s\n", s+n)
+	return c < n
+}
+
+func undefined() {
+	total = 1
+	fmt.Printf("%d\n", count(total))
+}
+
+func cases(n int) {
+	switch n {
+	case 1, 2:
+		fmt.Printf("small\n")
+	case 3, 1:
+		fmt.Printf("dup\n")
+	}
+}
+
+func shadowed() (err error) {
+	if true {
+		err := fmt.Errorf("inner")
+		fmt.Printf("%v\n", err)
+		return
+	}
+	return nil
+}
+
+func main() {
+	fmt.Printf("%t\n", mismatched(1, 2))
+	undefined()
+	cases(1)
+	fmt.Printf("%v\n", shadowed())
+}
@@ -7,7 +7,7 @@ func main() {
 	b := 3.1415926535
 	bfp := float32(b) + float32(0.1)
 
-	fmt.Printf("%ld\n%d\n", a, int8(a))
+	fmt.Printf("%d\n%d\n", a, int8(a))
 	fmt.Printf("%d\n", int(bfp))
-	fmt.Printf("%.9lf\n", b)
+	fmt.Printf("%.9f\n", b)
 }
@@ -0,0 +1,90 @@
+package main
+
+import "fmt"
+
+func str(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func digits(n uint32) int32 {
+	count := int32(0)
+	for n > 0 {
+		n /= 10
+		count++
+	}
+	return count
+}
+
+func main() {
+	var big uint32 = 4000000000
+	var small uint32 = 7
+	fmt.Printf("%.0f\n", float64(big))
+	fmt.Printf("%.0f %d\n", float64(big/small), int32(big%small))
+	fmt.Printf("%s %s\n", str(big > small), str(big >= 2147483648))
+	fmt.Printf("%d\n", digits(big))
+
+	var b uint8 = 200
+	b += 100
+	fmt.Printf("%d\n", b)
+	b = 255
+	b++
+	fmt.Printf("%d\n", b)
+	var x uint8 = 250
+	fmt.Printf("%d %d\n", x/3, x%7)
+	fmt.Printf("%s\n", str(x > 127))
+
+	var s8 int8 = -6
+	fmt.Printf("%d %d\n", uint8(s8), int32(uint8(s8)))
+	fmt.Printf("%d %d\n", uint16(s8), int32(s8))
+
+	var u16 uint16 = 65535
+	fmt.Printf("%d %d\n", int32(u16), int16(u16))
+	var w uint64 = uint64(u16) << 20
+	fmt.Printf("%.0f\n", float64(w))
+
+	var sh uint32 = 0x80000000
+	fmt.Printf("%d %.0f\n", int32(sh>>31), float64(sh>>1))
+	var neg int32 = -8
+	fmt.Printf("%d %.0f\n", neg>>1, float64(uint32(neg)>>1))
+
+	f := 3000000000.75
+	u := uint32(f)
+	fmt.Printf("%.0f\n", float64(u))
+	u64 := uint64(1) << 63
+	fmt.Printf("%.0f\n", float64(u64))
+
+	var m uint32 = 0
+	m--
+	fmt.Printf("%.0f %s\n", float64(m), str(m > 0))
+
+	bs := []byte("hi!")
+	sum := uint32(0)
+	for _, c := range bs {
+		sum += uint32(c)
+	}
+	fmt.Printf("%d %d\n", sum, int32(bs[2]))
+	str := "\xff"
+	fmt.Printf("%d %s\n", str[0], boolStr(str[0] > 127))
+
+	var total uint32
+	for i := uint32(0); i < 5; i++ {
+		total += i * i
+	}
+	fmt.Printf("%d\n", total)
+	fmt.Printf("%d\n", -small+10)
+
+	// uint and uint32 are distinct types of the same size
+	var word uint = 1 << 31
+	word32 := uint32(word) + 1
+	fmt.Printf("%T %T %d %d\n", word, word32, word, uint(word32)-word)
+}
+
+func boolStr(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}